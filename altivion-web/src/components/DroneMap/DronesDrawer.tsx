@@ -123,7 +123,7 @@ export default function DronesDrawer(props: Props) {
                   <div>Speed</div>
                   <div className="text-right">{(d.speed_h_mps ?? 0).toFixed(2)} m/s</div>
                   <div>Direction</div>
-                  <div className="text-right">{d.direction_deg ?? 0}°</div>
+                  <div className="text-right">{typeof d.direction_deg === "number" ? `${d.direction_deg}°` : "—"}</div>
                   <div>Track pts</div>
                   <div className="text-right">{pts}</div>
                 </div>
@@ -4,7 +4,13 @@ import useSWR from "swr";
 
 const API_BASE = process.env.NEXT_PUBLIC_API_BASE || "http://127.0.0.1:8000";
 const WS_URL   = "ws://127.0.0.1:8000/ws";
-const fetcher  = (url: string) => fetch(url).then(r => r.json());
+// Go query API serves the same card values from pre-aggregated stats
+const GO_API_BASE = process.env.NEXT_PUBLIC_GO_API_BASE;
+const STATS_URL = GO_API_BASE ? `${GO_API_BASE}/api/v1/stats/summary` : `${API_BASE}/data`;
+// The Go API needs a client API key (or AUTH_MODE=off)
+const GO_API_KEY = process.env.NEXT_PUBLIC_GO_API_KEY;
+const fetcher  = (url: string) =>
+  fetch(url, GO_API_BASE && GO_API_KEY ? { headers: { "X-API-Key": GO_API_KEY } } : undefined).then(r => r.json());
 
 export default function StatsPanel() {
   const [uptime, setUptime] = useState(0);
@@ -18,7 +24,7 @@ export default function StatsPanel() {
   const [wsOk, setWsOk] = useState(false);
 
   // One baseline load (no fast polling)
-  const { data: base } = useSWR(`${API_BASE}/data`, fetcher, {
+  const { data: base } = useSWR(STATS_URL, fetcher, {
     refreshInterval: 30000, // occasional correction
     revalidateOnFocus: false,
   });
@@ -1,4 +1,4 @@
-from pydantic import BaseModel, Field
+from pydantic import BaseModel, Field, field_validator
 from typing import Optional
 from datetime import datetime, timezone
 
@@ -16,5 +16,11 @@ class Signal(BaseModel):
     operator_lat: Optional[float] = Field(default=None, alias="OperatorLatitude")
     operator_lon: Optional[float] = Field(default=None, alias="OperatorLongitude")
 
+    @field_validator("direction_deg")
+    @classmethod
+    def unknown_direction(cls, v):
+        # F3411 sends 361 when the direction is unknown
+        return None if v == 361 else v
+
     class Config:
         populate_by_name = True  # allow snake_case or aliased names
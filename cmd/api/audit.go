@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"silentraven/internal/audit"
+	"silentraven/internal/database"
+	"silentraven/internal/logging"
+	"silentraven/internal/models"
+)
+
+// auditPage is one page of audit events
+type auditPage struct {
+	Events []models.AuditEvent `json:"events"`
+	// NextAfter continues with ?after= when the page is full
+	NextAfter int64 `json:"next_after,omitempty"`
+}
+
+// handleAudit returns audit events in sequence order
+//
+//	GET /api/v1/audit?actor=&action=&from=RFC3339&to=RFC3339&after=seq&limit=
+func (a *APIServer) handleAudit(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	f := database.AuditFilter{Actor: query.Get("actor"), Action: query.Get("action")}
+
+	var err error
+	if f.From, err = parseTime(query, "from"); err != nil {
+		sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if f.To, err = parseTime(query, "to"); err != nil {
+		sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if v := query.Get("after"); v != "" {
+		if f.AfterSeq, err = strconv.ParseInt(v, 10, 64); err != nil {
+			sendError(w, http.StatusBadRequest, fmt.Sprintf("invalid 'after': %q", v))
+			return
+		}
+	}
+	if v := query.Get("limit"); v != "" {
+		if f.Limit, err = strconv.Atoi(v); err != nil {
+			sendError(w, http.StatusBadRequest, fmt.Sprintf("invalid 'limit': %q", v))
+			return
+		}
+	}
+
+	events, err := a.db.QueryAudit(f)
+	if err != nil {
+		logger.ErrorContext(r.Context(), "Audit query failed", logging.Err(err))
+		sendError(w, http.StatusInternalServerError, "Failed to query audit log")
+		return
+	}
+
+	page := auditPage{Events: events}
+	if n := len(events); n > 0 && n == pageLimit(f.Limit) {
+		page.NextAfter = events[n-1].Seq
+	}
+	sendJSON(w, http.StatusOK, models.APIResponse{Success: true, Data: page})
+}
+
+// pageLimit is the page size the store applies for limit
+func pageLimit(limit int) int {
+	if limit <= 0 {
+		return database.DefaultPageSize
+	}
+	return min(limit, database.MaxPageSize)
+}
+
+// commitAudit records the request's audit event before a response that
+// must not go out unaudited, answering 500 if it cannot be recorded
+func commitAudit(w http.ResponseWriter, r *http.Request, status int) bool {
+	if err := audit.Commit(r, status); err != nil {
+		sendError(w, http.StatusInternalServerError, "Failed to record audit event")
+		return false
+	}
+	return true
+}
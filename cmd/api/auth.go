@@ -0,0 +1,137 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"silentraven/internal/audit"
+	"silentraven/internal/auth"
+	"silentraven/internal/database"
+	"silentraven/internal/logging"
+	"silentraven/internal/models"
+)
+
+// tokenResponse is a bearer token issued for an API key
+type tokenResponse struct {
+	Token     string    `json:"token"`
+	TokenType string    `json:"token_type"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// handleToken exchanges a client API key for a short-lived bearer token,
+// so browsers and scripts need not hold the key itself
+func (a *APIServer) handleToken(w http.ResponseWriter, r *http.Request) {
+	audit.SetAction(r.Context(), audit.ActionTokenIssue)
+	p := auth.FromContext(r.Context())
+	if p == nil {
+		sendError(w, http.StatusNotFound, "Authentication is off")
+		return
+	}
+	if p.Method != auth.MethodAPIKey {
+		sendError(w, http.StatusForbidden, "Tokens are only issued for API keys")
+		return
+	}
+
+	token, expires, err := auth.IssueToken([]byte(a.config.APISecret), *p, a.config.AuthTokenTTL)
+	if err != nil {
+		logger.ErrorContext(r.Context(), "Token issue failed", logging.Err(err))
+		sendError(w, http.StatusInternalServerError, "Failed to issue token")
+		return
+	}
+
+	audit.Note(r.Context(), "expires_at", expires)
+	logger.InfoContext(r.Context(), "Issued token", "subject", p.Subject, "key_id", p.KeyID)
+	sendJSON(w, http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    tokenResponse{Token: token, TokenType: "Bearer", ExpiresAt: expires},
+	})
+}
+
+// actor returns who is making a request, for case records and evidence
+// custody: the caller, or an anonymous principal when authentication is
+// off
+func actor(r *http.Request) auth.Principal {
+	if p := auth.FromContext(r.Context()); p != nil {
+		return *p
+	}
+	return auth.Principal{Kind: audit.KindAnonymous, Subject: audit.KindAnonymous}
+}
+
+// errOperatorSearch rejects operator position searches from roles that
+// may not see operator positions
+var errOperatorSearch = errors.New("role may not search by operator position")
+
+// errUnknownJurisdiction rejects callers scoped to an area the API has
+// no definition for, rather than showing them everything
+var errUnknownJurisdiction = errors.New("jurisdiction is not configured")
+
+// scope returns the area the caller is limited to, or nil
+func (a *APIServer) scope(r *http.Request) (*database.Area, error) {
+	p := auth.FromContext(r.Context())
+	if p == nil {
+		return nil, nil
+	}
+	if p.Jurisdiction == "" {
+		if role, _ := auth.LookupRole(p.Role); role.Scoped {
+			return nil, auth.ErrNoJurisdiction
+		}
+		return nil, nil
+	}
+
+	j, ok := a.config.Jurisdiction(p.Jurisdiction)
+	if !ok {
+		logger.ErrorContext(r.Context(), "Caller has an unknown jurisdiction", "jurisdiction", p.Jurisdiction, "subject", p.Subject)
+		return nil, errUnknownJurisdiction
+	}
+	if len(j.Polygon) == 0 {
+		return &database.Area{Radius: &database.Radius{Lat: j.Latitude, Lon: j.Longitude, Meters: j.RadiusM}}, nil
+	}
+	area := &database.Area{Polygon: make([]database.Point, len(j.Polygon))}
+	for i, v := range j.Polygon {
+		area.Polygon[i] = database.Point{Lat: v[0], Lon: v[1]}
+	}
+	return area, nil
+}
+
+// scopedFilter parses the query filters and confines them to the
+// caller's jurisdiction, writing an error response on failure
+func (a *APIServer) scopedFilter(w http.ResponseWriter, r *http.Request) (database.DetectionFilter, bool) {
+	filter, err := parseDetectionFilter(r.URL.Query())
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err.Error())
+		return filter, false
+	}
+	if filter.Scope, err = a.scope(r); err != nil {
+		auth.Forbid(w, r, err)
+		return filter, false
+	}
+	return filter, true
+}
+
+// release prepares detections for the caller: it clears the fields they
+// may not see and notes which detections they received in the audit log
+func release(r *http.Request, detections []models.DroneDetection) {
+	p := auth.FromContext(r.Context())
+	for i := range detections {
+		p.Redact(&detections[i])
+	}
+	noteDetections(r, len(detections), func(i int) string { return detections[i].UASID })
+}
+
+// maxAuditUAS caps the UAS IDs noted per request
+const maxAuditUAS = 100
+
+// noteDetections records the row count and distinct UAS IDs of n results
+func noteDetections(r *http.Request, n int, uasID func(int) string) {
+	seen := make(map[string]bool)
+	ids := []string{}
+	for i := 0; i < n && len(ids) < maxAuditUAS; i++ {
+		if id := uasID(i); !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	audit.Note(r.Context(), "rows", n)
+	audit.Note(r.Context(), "uas_ids", ids)
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"silentraven/internal/auth"
+	"silentraven/internal/models"
+	"silentraven/pkg/config"
+)
+
+func TestScope(t *testing.T) {
+	a := &APIServer{config: &config.Config{Jurisdictions: []config.Geofence{
+		{Name: "north", Latitude: 51.5, Longitude: -0.1, RadiusM: 5000},
+		{Name: "harbour", Polygon: [][2]float64{{51.4, -0.5}, {51.4, -0.4}, {51.5, -0.4}}},
+	}}}
+	client := func(role, jurisdiction string) *auth.Principal {
+		return &auth.Principal{Kind: models.KeyKindClient, Subject: "x", Role: role, Jurisdiction: jurisdiction}
+	}
+
+	tests := []struct {
+		name      string
+		p         *auth.Principal
+		wantErr   error
+		radius    bool
+		polygon   int
+		unlimited bool
+	}{
+		{name: "auth off", unlimited: true},
+		{name: "analyst without jurisdiction", p: client(auth.RoleAnalyst, ""), unlimited: true},
+		{name: "partner without jurisdiction", p: client(auth.RolePartner, ""), wantErr: auth.ErrNoJurisdiction},
+		{name: "user token without jurisdiction", p: &auth.Principal{Kind: auth.KindUser, Subject: "alice", Role: auth.RolePartner}, wantErr: auth.ErrNoJurisdiction},
+		{name: "unknown jurisdiction", p: client(auth.RolePartner, "south"), wantErr: errUnknownJurisdiction},
+		{name: "unscoped role with unknown jurisdiction", p: client(auth.RoleAnalyst, "south"), wantErr: errUnknownJurisdiction},
+		{name: "radius", p: client(auth.RolePartner, "north"), radius: true},
+		{name: "polygon", p: client(auth.RolePartner, "harbour"), polygon: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/api/v1/detections", nil)
+			if tt.p != nil {
+				r = r.WithContext(auth.WithPrincipal(r.Context(), tt.p))
+			}
+			area, err := a.scope(r)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) || area != nil {
+					t.Errorf("scope = %+v, %v; want %v", area, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("scope: %v", err)
+			}
+			switch {
+			case tt.unlimited:
+				if area != nil {
+					t.Errorf("scope = %+v, want none", area)
+				}
+			case tt.radius:
+				if area == nil || area.Radius == nil || area.Radius.Meters != 5000 || area.Radius.Lat != 51.5 {
+					t.Errorf("scope = %+v, want the north radius", area)
+				}
+			default:
+				if area == nil || len(area.Polygon) != tt.polygon || area.Polygon[0].Lat != 51.4 || area.Polygon[0].Lon != -0.5 {
+					t.Errorf("scope = %+v, want the harbour polygon", area)
+				}
+			}
+		})
+	}
+}
+
+// A scoped caller without a jurisdiction gets 403 from the query API,
+// never unscoped results
+func TestScopedFilterFailsClosed(t *testing.T) {
+	a := &APIServer{config: &config.Config{}}
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/detections", nil)
+	r = r.WithContext(auth.WithPrincipal(r.Context(), &auth.Principal{Kind: models.KeyKindClient, Subject: "x", Role: auth.RolePartner}))
+	w := httptest.NewRecorder()
+	if _, ok := a.scopedFilter(w, r); ok || w.Code != http.StatusForbidden {
+		t.Errorf("scopedFilter = %v, status %d; want 403", ok, w.Code)
+	}
+}
@@ -0,0 +1,435 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"silentraven/internal/audit"
+	"silentraven/internal/database"
+	"silentraven/internal/logging"
+	"silentraven/internal/models"
+)
+
+// createCaseRequest opens a case from a track, an alert or by hand
+type createCaseRequest struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Assignee    string `json:"assignee"`
+	// Source is "track", "alert" or "manual" (default); alerts need a
+	// source_ref identifying the alert, tracks a uas_id, from and to
+	Source    string `json:"source"`
+	SourceRef string `json:"source_ref"`
+	UASID     string `json:"uas_id"`
+	// From and To bound the track attached to the new case
+	From         time.Time `json:"from"`
+	To           time.Time `json:"to"`
+	DetectionIDs []int64   `json:"detection_ids"`
+}
+
+// caseChangeRequest moves a case along the workflow or reassigns it
+type caseChangeRequest struct {
+	Status   string `json:"status"`
+	Assignee string `json:"assignee"`
+	Message  string `json:"message"`
+}
+
+// attachmentRequest attaches a detection, track or evidence bundle
+type attachmentRequest struct {
+	Kind   string    `json:"kind"`
+	Ref    string    `json:"ref"`
+	From   time.Time `json:"from"`
+	To     time.Time `json:"to"`
+	SHA256 string    `json:"sha256"`
+	Note   string    `json:"note"`
+}
+
+// casePage is one page of cases
+type casePage struct {
+	Cases []models.Case `json:"cases"`
+	// NextBefore continues with ?before= when the page is full
+	NextBefore int64 `json:"next_before,omitempty"`
+}
+
+// handleListCases returns cases, newest first
+//
+//	GET /api/v1/cases?status=&assignee=&uas_id=&before=id&limit=
+func (a *APIServer) handleListCases(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	f := database.CaseFilter{
+		Status:   query.Get("status"),
+		Assignee: query.Get("assignee"),
+		UASID:    query.Get("uas_id"),
+	}
+	if f.Status != "" && !models.ValidCaseStatus(f.Status) {
+		sendError(w, http.StatusBadRequest, fmt.Sprintf("invalid 'status': %q", f.Status))
+		return
+	}
+	var err error
+	if v := query.Get("before"); v != "" {
+		if f.BeforeID, err = strconv.ParseInt(v, 10, 64); err != nil {
+			sendError(w, http.StatusBadRequest, fmt.Sprintf("invalid 'before': %q", v))
+			return
+		}
+	}
+	if v := query.Get("limit"); v != "" {
+		if f.Limit, err = strconv.Atoi(v); err != nil {
+			sendError(w, http.StatusBadRequest, fmt.Sprintf("invalid 'limit': %q", v))
+			return
+		}
+	}
+
+	cases, err := a.db.ListCases(f)
+	if err != nil {
+		logger.ErrorContext(r.Context(), "Case list failed", logging.Err(err))
+		sendError(w, http.StatusInternalServerError, "Failed to list cases")
+		return
+	}
+
+	page := casePage{Cases: cases}
+	if n := len(cases); n > 0 && n == pageLimit(f.Limit) {
+		page.NextBefore = cases[n-1].ID
+	}
+	sendJSON(w, http.StatusOK, models.APIResponse{Success: true, Data: page})
+}
+
+// handleCreateCase opens a case; a case from a track gets the track
+// attached
+//
+//	POST /api/v1/cases
+func (a *APIServer) handleCreateCase(w http.ResponseWriter, r *http.Request) {
+	audit.SetAction(r.Context(), audit.ActionCaseCreate)
+	var req createCaseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(w, http.StatusBadRequest, "Invalid JSON format")
+		return
+	}
+
+	who := actor(r).Subject
+	c := &models.Case{
+		Title:       req.Title,
+		Description: req.Description,
+		Assignee:    req.Assignee,
+		Source:      req.Source,
+		SourceRef:   req.SourceRef,
+		UASID:       req.UASID,
+		CreatedBy:   who,
+	}
+	if c.Source == "" {
+		c.Source = models.CaseFromManual
+	}
+	switch c.Source {
+	case models.CaseFromTrack:
+		if req.UASID == "" || req.From.IsZero() || !req.To.After(req.From) {
+			sendError(w, http.StatusBadRequest, "a case from a track needs uas_id, and from before to")
+			return
+		}
+		if c.Title == "" {
+			c.Title = "UAS " + req.UASID
+		}
+		c.Attachments = append(c.Attachments, models.CaseAttachment{
+			Kind: models.AttachTrack, Ref: req.UASID, From: req.From, To: req.To, AddedBy: who,
+		})
+	case models.CaseFromAlert:
+		if req.SourceRef == "" {
+			sendError(w, http.StatusBadRequest, "a case from an alert needs source_ref")
+			return
+		}
+	case models.CaseFromManual:
+	default:
+		sendError(w, http.StatusBadRequest, fmt.Sprintf("invalid source %q: want track, alert or manual", c.Source))
+		return
+	}
+	if c.Title == "" {
+		sendError(w, http.StatusBadRequest, "title is required")
+		return
+	}
+	for _, id := range req.DetectionIDs {
+		c.Attachments = append(c.Attachments, models.CaseAttachment{
+			Kind: models.AttachDetection, Ref: strconv.FormatInt(id, 10), AddedBy: who,
+		})
+	}
+
+	if err := a.db.CreateCase(c); err != nil {
+		logger.ErrorContext(r.Context(), "Case create failed", logging.Err(err))
+		sendError(w, http.StatusInternalServerError, "Failed to create case")
+		return
+	}
+	audit.Note(r.Context(), "case_id", c.ID)
+	audit.Note(r.Context(), "source", c.Source)
+	if c.UASID != "" {
+		audit.Note(r.Context(), "uas_ids", []string{c.UASID})
+	}
+
+	if !commitAudit(w, r, http.StatusCreated) {
+		return
+	}
+
+	logger.InfoContext(r.Context(), "Opened case", "case_id", c.ID, "source", c.Source, logging.UAS(c.UASID))
+	sendJSON(w, http.StatusCreated, models.APIResponse{Success: true, Data: c})
+}
+
+// handleGetCase returns a case with its attachments, notes and history
+func (a *APIServer) handleGetCase(w http.ResponseWriter, r *http.Request) {
+	id, ok := caseID(w, r)
+	if !ok {
+		return
+	}
+	c, err := a.db.GetCase(id)
+	if err != nil {
+		sendCaseError(w, r, err)
+		return
+	}
+
+	sendJSON(w, http.StatusOK, models.APIResponse{Success: true, Data: c})
+}
+
+// handleCaseStatus moves a case along the workflow
+//
+//	POST /api/v1/cases/{id}/status {"status": "referred", "message": "..."}
+func (a *APIServer) handleCaseStatus(w http.ResponseWriter, r *http.Request) {
+	a.changeCase(w, r, models.CaseFieldStatus, func(req caseChangeRequest) (string, error) {
+		if !models.ValidCaseStatus(req.Status) {
+			return "", fmt.Errorf("invalid status %q: want open, investigating, referred or closed", req.Status)
+		}
+		return req.Status, nil
+	})
+}
+
+// handleCaseAssign assigns a case to a user, or unassigns it
+//
+//	POST /api/v1/cases/{id}/assign {"assignee": "subject", "message": "..."}
+func (a *APIServer) handleCaseAssign(w http.ResponseWriter, r *http.Request) {
+	a.changeCase(w, r, models.CaseFieldAssignee, func(req caseChangeRequest) (string, error) {
+		return req.Assignee, nil
+	})
+}
+
+// changeCase applies a status or assignee change read from the body
+func (a *APIServer) changeCase(w http.ResponseWriter, r *http.Request, field string, value func(caseChangeRequest) (string, error)) {
+	audit.SetAction(r.Context(), audit.ActionCaseChange)
+	id, ok := caseID(w, r)
+	if !ok {
+		return
+	}
+	var req caseChangeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(w, http.StatusBadRequest, "Invalid JSON format")
+		return
+	}
+	to, err := value(req)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	e := &models.CaseEvent{CaseID: id, Field: field, To: to, Actor: actor(r).Subject, Message: req.Message}
+	if err := a.db.ChangeCase(e); err != nil {
+		sendCaseError(w, r, err)
+		return
+	}
+	audit.Note(r.Context(), "case_id", id)
+	audit.Note(r.Context(), field, map[string]string{"from": e.From, "to": e.To})
+	if !commitAudit(w, r, http.StatusOK) {
+		return
+	}
+
+	logger.InfoContext(r.Context(), "Changed case", "case_id", id, "field", field, "from", e.From, "to", e.To)
+	sendJSON(w, http.StatusOK, models.APIResponse{Success: true, Data: e})
+}
+
+// handleCaseNote adds a note to a case
+//
+//	POST /api/v1/cases/{id}/notes {"body": "..."}
+func (a *APIServer) handleCaseNote(w http.ResponseWriter, r *http.Request) {
+	audit.SetAction(r.Context(), audit.ActionCaseNote)
+	id, ok := caseID(w, r)
+	if !ok {
+		return
+	}
+	var n models.CaseNote
+	if err := json.NewDecoder(r.Body).Decode(&n); err != nil {
+		sendError(w, http.StatusBadRequest, "Invalid JSON format")
+		return
+	}
+	if n.Body == "" {
+		sendError(w, http.StatusBadRequest, "body is required")
+		return
+	}
+
+	note := &models.CaseNote{CaseID: id, Author: actor(r).Subject, Body: n.Body}
+	if err := a.db.AddCaseNote(note); err != nil {
+		sendCaseError(w, r, err)
+		return
+	}
+	audit.Note(r.Context(), "case_id", id)
+	if !commitAudit(w, r, http.StatusCreated) {
+		return
+	}
+
+	sendJSON(w, http.StatusCreated, models.APIResponse{Success: true, Data: note})
+}
+
+// handleCaseAttach attaches a detection, track or evidence bundle to a
+// case
+//
+//	POST /api/v1/cases/{id}/attachments {"kind": "track", "ref": "uas", "from": ..., "to": ...}
+func (a *APIServer) handleCaseAttach(w http.ResponseWriter, r *http.Request) {
+	audit.SetAction(r.Context(), audit.ActionCaseAttach)
+	id, ok := caseID(w, r)
+	if !ok {
+		return
+	}
+	var req attachmentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(w, http.StatusBadRequest, "Invalid JSON format")
+		return
+	}
+	if err := checkAttachment(req); err != nil {
+		sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	att := &models.CaseAttachment{
+		CaseID:  id,
+		Kind:    req.Kind,
+		Ref:     req.Ref,
+		From:    req.From,
+		To:      req.To,
+		SHA256:  req.SHA256,
+		Note:    req.Note,
+		AddedBy: actor(r).Subject,
+	}
+	if err := a.db.AddCaseAttachment(att); err != nil {
+		sendCaseError(w, r, err)
+		return
+	}
+	audit.Note(r.Context(), "case_id", id)
+	audit.Note(r.Context(), "attachment", map[string]string{"kind": att.Kind, "ref": att.Ref})
+	if !commitAudit(w, r, http.StatusCreated) {
+		return
+	}
+
+	sendJSON(w, http.StatusCreated, models.APIResponse{Success: true, Data: att})
+}
+
+// checkAttachment validates an attachment for its kind
+func checkAttachment(req attachmentRequest) error {
+	switch req.Kind {
+	case models.AttachDetection:
+		if _, err := strconv.ParseInt(req.Ref, 10, 64); err != nil {
+			return fmt.Errorf("a detection attachment needs the detection ID as ref")
+		}
+	case models.AttachTrack:
+		if req.Ref == "" || req.From.IsZero() || !req.To.After(req.From) {
+			return fmt.Errorf("a track attachment needs the UAS ID as ref, and from before to")
+		}
+	case models.AttachEvidence:
+		if sum, err := hex.DecodeString(req.SHA256); req.Ref == "" || err != nil || len(sum) != 32 {
+			return fmt.Errorf("an evidence attachment needs the bundle ID as ref and its sha256")
+		}
+	default:
+		return fmt.Errorf("invalid kind %q: want detection, track or evidence", req.Kind)
+	}
+	return nil
+}
+
+// handleCaseEvidence builds an evidence bundle of the case's UAS,
+// attaches it to the case and returns it
+//
+//	POST /api/v1/cases/{id}/evidence?from=RFC3339&to=RFC3339
+func (a *APIServer) handleCaseEvidence(w http.ResponseWriter, r *http.Request) {
+	audit.SetAction(r.Context(), audit.ActionEvidence)
+	id, ok := caseID(w, r)
+	if !ok {
+		return
+	}
+	c, err := a.db.GetCase(id)
+	if err != nil {
+		sendCaseError(w, r, err)
+		return
+	}
+	if c.UASID == "" {
+		sendError(w, http.StatusBadRequest, "Case has no UAS")
+		return
+	}
+	query := r.URL.Query()
+	from, to, err := parseWindow(query.Get("from"), query.Get("to"), 24*time.Hour)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if query.Get("from") == "" && query.Get("to") == "" {
+		from, to = trackWindow(c, from, to)
+	}
+
+	data, bundle, ok := a.buildEvidence(w, r, c.UASID, from, to, strconv.FormatInt(id, 10))
+	if !ok {
+		return
+	}
+	att := &models.CaseAttachment{
+		CaseID:  id,
+		Kind:    models.AttachEvidence,
+		Ref:     bundle.Summary.BundleID,
+		From:    from,
+		To:      to,
+		SHA256:  bundle.SHA256,
+		AddedBy: actor(r).Subject,
+	}
+	if err := a.db.AddCaseAttachment(att); err != nil {
+		sendCaseError(w, r, err)
+		return
+	}
+	sendEvidence(w, r, data, bundle)
+}
+
+// trackWindow spans the case's track attachments, falling back to from
+// and to when it has none
+func trackWindow(c *models.Case, from, to time.Time) (time.Time, time.Time) {
+	var start, end time.Time
+	for _, a := range c.Attachments {
+		if a.Kind != models.AttachTrack || a.From.IsZero() {
+			continue
+		}
+		if start.IsZero() || a.From.Before(start) {
+			start = a.From
+		}
+		if a.To.After(end) {
+			end = a.To
+		}
+	}
+	if start.IsZero() || !end.After(start) {
+		return from, to
+	}
+	return start, end
+}
+
+// caseID parses the {id} route variable, responding 404 if it is not a
+// case ID
+func caseID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil || id < 1 {
+		sendError(w, http.StatusNotFound, "Case not found")
+		return 0, false
+	}
+	return id, true
+}
+
+// sendCaseError maps case store errors to HTTP responses
+func sendCaseError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, database.ErrNotFound):
+		sendError(w, http.StatusNotFound, "Case not found")
+	case errors.Is(err, database.ErrInvalidTransition):
+		sendError(w, http.StatusConflict, err.Error())
+	default:
+		logger.ErrorContext(r.Context(), "Case query failed", logging.Err(err))
+		sendError(w, http.StatusInternalServerError, "Failed to access case")
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"silentraven/internal/auth"
+	"silentraven/internal/compliance"
+	"silentraven/internal/database"
+	"silentraven/internal/logging"
+	"silentraven/internal/models"
+)
+
+// handleCompliance scores the track of every UAS seen in the window,
+// worst first
+//
+//	GET /api/v1/compliance?from=RFC3339&to=RFC3339&status=non_compliant&limit=
+//
+// The detection filters narrow which UAS are scored; each is scored on
+// its whole track in the window. The window defaults to the last hour.
+// At most limit UAS are scored, the most recently seen first.
+func (a *APIServer) handleCompliance(w http.ResponseWriter, r *http.Request) {
+	filter, ok := a.scopedFilter(w, r)
+	if !ok {
+		return
+	}
+	status := r.URL.Query().Get("status")
+	if status != "" && !compliance.ValidStatus(status) {
+		sendError(w, http.StatusBadRequest, fmt.Sprintf("invalid status %q: want non_compliant, warning, insufficient_data or compliant", status))
+		return
+	}
+	if filter.To.IsZero() {
+		filter.To = time.Now()
+	}
+	if filter.From.IsZero() {
+		filter.From = filter.To.Add(-time.Hour)
+	}
+
+	filter.Limit = pageLimit(filter.Limit)
+
+	latest, err := a.db.LatestPerUAS(filter)
+	if err != nil {
+		logger.ErrorContext(r.Context(), "Compliance query failed", logging.Err(err))
+		sendError(w, http.StatusInternalServerError, "Failed to query detections")
+		return
+	}
+	tracks, err := a.windowTracks(latest, filter)
+	if err != nil {
+		logger.ErrorContext(r.Context(), "Compliance query failed", logging.Err(err))
+		sendError(w, http.StatusInternalServerError, "Failed to query detections")
+		return
+	}
+
+	reports := []*compliance.Report{}
+	for _, d := range latest {
+		if report := compliance.Evaluate(tracks[d.UASID], a.complianceRules()); status == "" || report.Status == status {
+			reports = append(reports, report)
+		}
+	}
+	sort.SliceStable(reports, func(i, j int) bool {
+		if ri, rj := compliance.Rank(reports[i].Status), compliance.Rank(reports[j].Status); ri != rj {
+			return ri < rj
+		}
+		return reports[i].Score < reports[j].Score
+	})
+	noteDetections(r, len(reports), func(i int) string { return reports[i].UASID })
+
+	sendJSON(w, http.StatusOK, models.APIResponse{Success: true, Data: map[string]interface{}{
+		"from":    filter.From,
+		"to":      filter.To,
+		"reports": reports,
+		// More UAS were seen than were scored
+		"truncated": len(latest) == filter.Limit,
+	}})
+}
+
+// windowTracks returns the tracks of the UAS in latest over the filter's
+// window and scope, oldest first, reading the window once rather than
+// once per UAS
+func (a *APIServer) windowTracks(latest []models.DroneDetection, f database.DetectionFilter) (map[string][]models.DroneDetection, error) {
+	tracks := make(map[string][]models.DroneDetection, len(latest))
+	for _, d := range latest {
+		tracks[d.UASID] = nil
+	}
+	if len(latest) == 0 {
+		return tracks, nil
+	}
+
+	filter := database.DetectionFilter{
+		From:      f.From,
+		To:        f.To,
+		Scope:     f.Scope,
+		Limit:     database.MaxPageSize,
+		Ascending: true,
+	}
+	if len(latest) == 1 {
+		filter.UASID = latest[0].UASID
+	}
+	for {
+		page, err := a.db.QueryDetections(filter)
+		if err != nil {
+			return nil, err
+		}
+		for _, d := range page.Detections {
+			if track, ok := tracks[d.UASID]; ok {
+				tracks[d.UASID] = append(track, d)
+			}
+		}
+		if page.NextCursor == "" {
+			return tracks, nil
+		}
+		filter.Cursor = page.NextCursor
+	}
+}
+
+// handleUASCompliance scores one UAS's track
+//
+//	GET /api/v1/compliance/{uas_id}?from=RFC3339&to=RFC3339
+func (a *APIServer) handleUASCompliance(w http.ResponseWriter, r *http.Request) {
+	uasID := mux.Vars(r)["uas_id"]
+	query := r.URL.Query()
+
+	from, to, err := parseWindow(query.Get("from"), query.Get("to"), 24*time.Hour)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	scope, err := a.scope(r)
+	if err != nil {
+		auth.Forbid(w, r, err)
+		return
+	}
+
+	detections, err := a.trackDetections(uasID, from, to, scope)
+	if errors.Is(err, errTrackTooLong) {
+		sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		logger.ErrorContext(r.Context(), "Compliance query failed", logging.UAS(uasID), logging.Err(err))
+		sendError(w, http.StatusInternalServerError, "Failed to query detections")
+		return
+	}
+	noteDetections(r, len(detections), func(i int) string { return detections[i].UASID })
+	if len(detections) == 0 {
+		sendError(w, http.StatusNotFound, "No detections for UAS in time window")
+		return
+	}
+
+	sendJSON(w, http.StatusOK, models.APIResponse{Success: true, Data: compliance.Evaluate(detections, a.complianceRules())})
+}
+
+// complianceRules are the configured plausibility limits
+func (a *APIServer) complianceRules() compliance.Rules {
+	return compliance.Rules{MaxSpeed: a.config.ComplianceMaxSpeed, MinRate: a.config.ComplianceMinRate}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"silentraven/internal/compliance"
+	"silentraven/internal/database"
+	"silentraven/internal/models"
+	"silentraven/internal/storage"
+	"silentraven/pkg/config"
+)
+
+// countingStore counts the track queries made through it
+type countingStore struct {
+	storage.Store
+	queries int
+}
+
+func (s *countingStore) QueryDetections(f database.DetectionFilter) (*database.DetectionPage, error) {
+	s.queries++
+	return s.Store.QueryDetections(f)
+}
+
+func (s *countingStore) GetDetectionsForUAS(uasID string, from, to time.Time) ([]models.DroneDetection, error) {
+	s.queries++
+	return s.Store.GetDetectionsForUAS(uasID, from, to)
+}
+
+func TestComplianceReadsWindowOnce(t *testing.T) {
+	mem := storage.NewMemoryStore()
+	end := time.Now().UTC().Truncate(time.Second)
+	for u := 0; u < 5; u++ {
+		for i := 0; i < 6; i++ {
+			err := mem.InsertDroneDetection(&models.DroneDetection{
+				DetectionTime: end.Add(time.Duration(i-10*u-10) * time.Second),
+				SN:            fmt.Sprintf("sn-%d", u),
+				UASID:         fmt.Sprintf("uas-%d", u),
+				Latitude:      51.47 + float64(i)*1e-5,
+				Longitude:     -0.45,
+				Direction:     90,
+				NodeID:        "node-1",
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	store := &countingStore{Store: mem}
+	a := &APIServer{config: &config.Config{ComplianceMaxSpeed: 50}, db: store}
+
+	query := func(params string) (reports []compliance.Report, truncated bool) {
+		t.Helper()
+		store.queries = 0
+		r := httptest.NewRequest(http.MethodGet, "/api/v1/compliance?from="+end.Add(-time.Hour).Format(time.RFC3339)+params, nil)
+		w := httptest.NewRecorder()
+		a.handleCompliance(w, r)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d: %s", w.Code, w.Body)
+		}
+		var resp struct {
+			Data struct {
+				Reports   []compliance.Report `json:"reports"`
+				Truncated bool                `json:"truncated"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		return resp.Data.Reports, resp.Data.Truncated
+	}
+
+	reports, truncated := query("")
+	if len(reports) != 5 || truncated {
+		t.Fatalf("%d reports (truncated %v), want 5", len(reports), truncated)
+	}
+	for _, rep := range reports {
+		if rep.Received != 6 {
+			t.Errorf("%s scored on %d detections, want 6", rep.UASID, rep.Received)
+		}
+	}
+	if store.queries != 1 {
+		t.Errorf("%d track queries for 5 UAS, want 1", store.queries)
+	}
+
+	// The limit caps the UAS scored, most recently seen first
+	reports, truncated = query("&limit=2")
+	if len(reports) != 2 || !truncated {
+		t.Fatalf("%d reports (truncated %v), want 2 truncated", len(reports), truncated)
+	}
+	for _, rep := range reports {
+		if rep.UASID != "uas-0" && rep.UASID != "uas-1" {
+			t.Errorf("scored %s, want uas-0 and uas-1", rep.UASID)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"silentraven/internal/coverage"
+	"silentraven/internal/logging"
+)
+
+// handleCoverage returns estimated node coverage as GeoJSON
+//
+//	GET /api/v1/coverage?from=RFC3339&to=RFC3339&node_id=&layers=nodes,ranges,combined,grid
+//	    &sectors=36&percentile=0.95&min_detections=3&max_range_m=50000&cell_m=500
+func (a *APIServer) handleCoverage(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	from, to, err := parseWindow(query.Get("from"), query.Get("to"), 30*24*time.Hour)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	layers, err := coverage.ParseLayers(query.Get("layers"))
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	opts, err := parseCoverageOptions(query)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	samples, err := a.db.CoverageSamples(from, to, query.Get("node_id"))
+	if err != nil {
+		logger.ErrorContext(r.Context(), "Coverage query failed", logging.Err(err))
+		sendError(w, http.StatusInternalServerError, "Failed to query coverage")
+		return
+	}
+
+	nodes, err := a.db.ListNodes()
+	if err != nil {
+		logger.ErrorContext(r.Context(), "Node list failed", logging.Err(err))
+		sendError(w, http.StatusInternalServerError, "Failed to query coverage")
+		return
+	}
+
+	m, err := coverage.Compute(nodes, samples, opts)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/geo+json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(m.GeoJSON(layers))
+}
+
+// parseCoverageOptions overrides the default options from query parameters
+func parseCoverageOptions(query url.Values) (coverage.Options, error) {
+	opts := coverage.DefaultOptions()
+
+	if v := query.Get("sectors"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return opts, fmt.Errorf("invalid 'sectors': %q", v)
+		}
+		opts.Sectors = n
+	}
+	if v := query.Get("min_detections"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 0 {
+			return opts, fmt.Errorf("invalid 'min_detections': %q", v)
+		}
+		opts.MinDetections = n
+	}
+
+	floats := []struct {
+		key    string
+		target *float64
+	}{
+		{"percentile", &opts.Percentile},
+		{"max_range_m", &opts.MaxRangeMeters},
+		{"cell_m", &opts.CellMeters},
+	}
+	for _, f := range floats {
+		v, err := parseOptionalFloat(query, f.key)
+		if err != nil {
+			return opts, err
+		}
+		if v != nil {
+			*f.target = *v
+		}
+	}
+
+	return opts, opts.Validate()
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"silentraven/internal/audit"
+	"silentraven/internal/evidence"
+	"silentraven/internal/logging"
+)
+
+// handleEvidence builds a signed evidence bundle for a UAS and time
+// window
+//
+//	GET /api/v1/evidence/{uas_id}?from=RFC3339&to=RFC3339&case=ref
+func (a *APIServer) handleEvidence(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	audit.SetAction(r.Context(), audit.ActionEvidence)
+
+	from, to, err := parseWindow(query.Get("from"), query.Get("to"), 24*time.Hour)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	data, bundle, ok := a.buildEvidence(w, r, mux.Vars(r)["uas_id"], from, to, query.Get("case"))
+	if !ok {
+		return
+	}
+	sendEvidence(w, r, data, bundle)
+}
+
+// buildEvidence builds a bundle in memory, so failures can still be
+// reported as JSON, and notes it in the request's audit event. It writes
+// the error response itself when it fails.
+func (a *APIServer) buildEvidence(w http.ResponseWriter, r *http.Request, uasID string, from, to time.Time, caseRef string) (*bytes.Buffer, *evidence.Bundle, bool) {
+	if a.evidenceKey == nil {
+		sendError(w, http.StatusServiceUnavailable, "Evidence signing key unavailable")
+		return nil, nil, false
+	}
+
+	collector := actor(r)
+	req := evidence.Request{
+		UASID:         uasID,
+		From:          from,
+		To:            to,
+		CaseRef:       caseRef,
+		Collector:     collector.Subject,
+		CollectorKind: collector.Kind,
+		Tool:          "silentraven-api",
+	}
+
+	var buf bytes.Buffer
+	bundle, err := evidence.Build(&buf, a.db, req, a.evidenceKey)
+	if errors.Is(err, evidence.ErrNoDetections) {
+		sendError(w, http.StatusNotFound, "No detections for UAS in time window")
+		return nil, nil, false
+	}
+	if err != nil {
+		logger.ErrorContext(r.Context(), "Evidence bundle failed", logging.UAS(uasID), logging.Err(err))
+		sendError(w, http.StatusInternalServerError, "Failed to build evidence bundle")
+		return nil, nil, false
+	}
+
+	s := bundle.Summary
+	audit.Note(r.Context(), "bundle_id", s.BundleID)
+	audit.Note(r.Context(), "sha256", bundle.SHA256)
+	audit.Note(r.Context(), "case", caseRef)
+	audit.Note(r.Context(), "from", from)
+	audit.Note(r.Context(), "to", to)
+	audit.Note(r.Context(), "rows", s.Detections)
+	audit.Note(r.Context(), "uas_ids", []string{uasID})
+	return &buf, bundle, true
+}
+
+// sendEvidence writes a built bundle as a zip download once its audit
+// event is recorded
+func sendEvidence(w http.ResponseWriter, r *http.Request, data *bytes.Buffer, bundle *evidence.Bundle) {
+	if !commitAudit(w, r, http.StatusOK) {
+		return
+	}
+
+	s := bundle.Summary
+	filename := fmt.Sprintf("evidence_%s_%s.zip", s.UASID, s.BundleID)
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.Header().Set("X-Evidence-Bundle", s.BundleID)
+	w.Header().Set("X-Evidence-SHA256", bundle.SHA256)
+	if _, err := data.WriteTo(w); err != nil {
+		logger.ErrorContext(r.Context(), "Evidence write failed", logging.UAS(s.UASID), logging.Err(err))
+		return
+	}
+
+	logger.InfoContext(r.Context(), "Built evidence bundle", logging.UAS(s.UASID),
+		"bundle_id", s.BundleID, "rows", s.Detections, "sha256", bundle.SHA256)
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"silentraven/internal/database"
+)
+
+// parseDetectionFilter builds a database filter from query parameters:
+//
+//	uas_id, sn, node_id, from, to (RFC3339), minutes,
+//	bbox=minLat,minLon,maxLat,maxLon, lat, lon, radius_m,
+//	min_height, max_height, cursor, limit, order=asc|desc
+func parseDetectionFilter(q url.Values) (database.DetectionFilter, error) {
+	f := database.DetectionFilter{
+		UASID:     q.Get("uas_id"),
+		SN:        q.Get("sn"),
+		NodeID:    q.Get("node_id"),
+		Cursor:    q.Get("cursor"),
+		Ascending: q.Get("order") == "asc",
+	}
+
+	var err error
+	if f.From, err = parseTime(q, "from"); err != nil {
+		return f, err
+	}
+	if f.To, err = parseTime(q, "to"); err != nil {
+		return f, err
+	}
+	if v := q.Get("minutes"); v != "" && f.From.IsZero() {
+		minutes, err := strconv.Atoi(v)
+		if err != nil || minutes <= 0 {
+			return f, fmt.Errorf("invalid 'minutes': %q", v)
+		}
+		f.From = time.Now().Add(-time.Duration(minutes) * time.Minute)
+	}
+
+	if v := q.Get("bbox"); v != "" {
+		var b database.BoundingBox
+		if _, err := fmt.Sscanf(v, "%f,%f,%f,%f", &b.MinLat, &b.MinLon, &b.MaxLat, &b.MaxLon); err != nil {
+			return f, fmt.Errorf("invalid 'bbox' (want minLat,minLon,maxLat,maxLon): %q", v)
+		}
+		f.BBox = &b
+	}
+
+	if v := q.Get("radius_m"); v != "" {
+		r := &database.Radius{}
+		if r.Meters, err = strconv.ParseFloat(v, 64); err != nil {
+			return f, fmt.Errorf("invalid 'radius_m': %q", v)
+		}
+		if r.Lat, err = strconv.ParseFloat(q.Get("lat"), 64); err != nil {
+			return f, fmt.Errorf("'radius_m' requires numeric 'lat'")
+		}
+		if r.Lon, err = strconv.ParseFloat(q.Get("lon"), 64); err != nil {
+			return f, fmt.Errorf("'radius_m' requires numeric 'lon'")
+		}
+		f.Radius = r
+	}
+
+	if f.MinHeight, err = parseOptionalFloat(q, "min_height"); err != nil {
+		return f, err
+	}
+	if f.MaxHeight, err = parseOptionalFloat(q, "max_height"); err != nil {
+		return f, err
+	}
+
+	if v := q.Get("limit"); v != "" {
+		if f.Limit, err = strconv.Atoi(v); err != nil {
+			return f, fmt.Errorf("invalid 'limit': %q", v)
+		}
+	}
+
+	return f, nil
+}
+
+func parseTime(q url.Values, key string) (time.Time, error) {
+	v := q.Get(key)
+	if v == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid '%s' timestamp: %w", key, err)
+	}
+	return t, nil
+}
+
+func parseOptionalFloat(q url.Values, key string) (*float64, error) {
+	v := q.Get(key)
+	if v == "" {
+		return nil, nil
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid '%s': %q", key, v)
+	}
+	return &f, nil
+}
@@ -0,0 +1,497 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/cors"
+
+	"silentraven/internal/audit"
+	"silentraven/internal/auth"
+	"silentraven/internal/crypto"
+	"silentraven/internal/database"
+	"silentraven/internal/export"
+	"silentraven/internal/logging"
+	"silentraven/internal/metrics"
+	"silentraven/internal/models"
+	"silentraven/internal/reload"
+	"silentraven/internal/storage"
+	"silentraven/pkg/config"
+)
+
+var logger = logging.For("api")
+
+type APIServer struct {
+	config    *config.Config
+	db        storage.Store
+	auth      *auth.Authenticator
+	audit     *audit.Recorder
+	router    *mux.Router
+	startedAt time.Time
+
+	// evidenceKey signs evidence bundles; nil disables them
+	evidenceKey *ecdsa.PrivateKey
+}
+
+func main() {
+	// Load configuration
+	cfg, err := config.Load(config.API)
+	if err != nil {
+		logging.Fatal(logger, "Failed to load configuration", logging.Err(err))
+	}
+	if err := logging.Configure(cfg, "api"); err != nil {
+		logging.Fatal(logger, "Invalid logging configuration", logging.Err(err))
+	}
+	logger.Info("Starting SilentRaven API Service")
+
+	// Connect to storage (Postgres unless STORAGE_BACKEND=memory)
+	db, err := storage.Open(cfg)
+	if err != nil {
+		logging.Fatal(logger, "Failed to connect to database", logging.Err(err))
+	}
+	defer db.Close()
+
+	// Create API server
+	api := NewAPIServer(cfg, db)
+	api.setupRoutes()
+
+	// Load the evidence signing key, creating it on first start
+	key, created, err := crypto.LoadOrCreateKey(cfg.EvidenceKeyFile)
+	if err != nil {
+		logger.Error("Evidence bundles disabled", "key_file", cfg.EvidenceKeyFile, logging.Err(err))
+	} else {
+		api.evidenceKey = key
+		if created {
+			pub, _ := crypto.PublicKeyPEM(key)
+			logger.Info("Generated evidence signing key; publish its public key", "key_file", cfg.EvidenceKeyFile, "public_key", pub)
+		}
+	}
+
+	// Keep statistics aggregates fresh
+	refreshCtx, stopRefresh := context.WithCancel(context.Background())
+	defer stopRefresh()
+	go api.refreshStats(refreshCtx, cfg.StatsRefreshInterval)
+
+	// Apply logging changes on SIGHUP, auditing each configuration
+	// including those rejected for needing a restart
+	api.audit.ConfigLoaded(config.API.Name, cfg)
+	go reload.Watch(refreshCtx, config.API, cfg, reload.Hooks{
+		Audit: func(prev, next *config.Config, err error) {
+			api.audit.ConfigReloaded(config.API.Name, prev, next, err)
+		},
+	})
+
+	// Setup CORS
+	corsHandler := cors.New(cors.Options{
+		AllowedOrigins: cfg.CORSOrigins,
+		AllowedMethods: []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowedHeaders: []string{"Authorization", "Content-Type", auth.APIKeyHeader},
+	})
+
+	server := &http.Server{
+		Addr:         cfg.GetQueryAPIAddress(),
+		Handler:      corsHandler.Handler(api.router),
+		ReadTimeout:  15 * time.Second,
+		WriteTimeout: 60 * time.Second,
+		IdleTimeout:  60 * time.Second,
+	}
+
+	go func() {
+		logger.Info("API listening", "addr", cfg.GetQueryAPIAddress())
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logging.Fatal(logger, "Failed to start server", logging.Err(err))
+		}
+	}()
+
+	// Wait for interrupt signal
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+
+	logger.Info("Shutting down API")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := server.Shutdown(ctx); err != nil {
+		logging.Fatal(logger, "Server forced to shutdown", logging.Err(err))
+	}
+
+	logger.Info("API stopped")
+}
+
+// NewAPIServer creates a new API server instance. Unless AUTH_MODE=off,
+// requests must carry a client API key or a token signed with API_SECRET.
+func NewAPIServer(cfg *config.Config, db storage.Store) *APIServer {
+	a := &APIServer{
+		config:    cfg,
+		db:        db,
+		audit:     audit.New(db),
+		router:    mux.NewRouter(),
+		startedAt: time.Now(),
+	}
+	if cfg.AuthMode != "off" {
+		a.auth = auth.New(db, []byte(cfg.APISecret))
+	}
+	return a
+}
+
+// setupRoutes configures HTTP routes
+func (a *APIServer) setupRoutes() {
+	a.router.Use(logging.Middleware, metrics.Middleware)
+	a.router.Handle("/metrics", metrics.Handler()).Methods("GET")
+
+	a.router.HandleFunc("/health", a.handleHealth).Methods("GET")
+
+	// Everything else needs a client API key or token; node keys may
+	// only submit to the gateway. Every request is audited, including
+	// rejected ones.
+	api := a.router.PathPrefix("/api/v1").Subrouter()
+	api.Use(a.audit.Middleware, a.auth.Middleware(models.KeyKindClient, auth.KindUser), audit.Identify)
+
+	// Exchange an API key for a bearer token
+	api.HandleFunc("/auth/token", a.handleToken).Methods("POST")
+
+	// Detection queries, limited to the caller's jurisdiction
+	api.HandleFunc("/detections", auth.Require(auth.ReadDetections, a.handleDetections)).Methods("GET")
+	api.HandleFunc("/latest", auth.Require(auth.ReadDetections, a.handleLatest)).Methods("GET")
+	api.HandleFunc("/detections/within", auth.Require(auth.ReadDetections, a.handleWithin)).Methods("POST")
+	api.HandleFunc("/nearest", auth.Require(auth.ReadDetections, a.handleNearest)).Methods("GET")
+
+	// Statistics
+	api.HandleFunc("/stats", auth.Require(auth.ReadStats, a.handleStats)).Methods("GET")
+	api.HandleFunc("/stats/summary", auth.Require(auth.ReadStats, a.handleStatsSummary)).Methods("GET")
+
+	// Sensor node coverage (GeoJSON)
+	api.HandleFunc("/coverage", auth.Require(auth.ReadNodes, a.handleCoverage)).Methods("GET")
+
+	// Sensor node registry
+	api.HandleFunc("/nodes", auth.Require(auth.ReadNodes, a.handleListNodes)).Methods("GET")
+	api.HandleFunc("/nodes/{node_id}", auth.Require(auth.ReadNodes, a.handleGetNode)).Methods("GET")
+	api.HandleFunc("/nodes/{node_id}", auth.Require(auth.ManageNodes, a.handlePutNode)).Methods("PUT")
+	api.HandleFunc("/nodes/{node_id}", auth.Require(auth.ManageNodes, a.handleDeleteNode)).Methods("DELETE")
+	api.HandleFunc("/nodes/{node_id}/events", auth.Require(auth.ReadNodes, a.handleNodeEvents)).Methods("GET")
+
+	// Track export (KML/KMZ/GeoJSON)
+	api.HandleFunc("/export/{uas_id}", auth.Require(auth.ExportTracks, a.handleExport)).Methods("GET")
+
+	// Signed evidence bundles
+	api.HandleFunc("/evidence/{uas_id}", auth.Require(auth.ExportEvidence, a.handleEvidence)).Methods("GET")
+
+	// Remote ID compliance
+	api.HandleFunc("/compliance", auth.Require(auth.ReadCompliance, a.handleCompliance)).Methods("GET")
+	api.HandleFunc("/compliance/{uas_id}", auth.Require(auth.ReadCompliance, a.handleUASCompliance)).Methods("GET")
+
+	// Enforcement cases
+	api.HandleFunc("/cases", auth.Require(auth.ReadCases, a.handleListCases)).Methods("GET")
+	api.HandleFunc("/cases", auth.Require(auth.ManageCases, a.handleCreateCase)).Methods("POST")
+	api.HandleFunc("/cases/{id}", auth.Require(auth.ReadCases, a.handleGetCase)).Methods("GET")
+	api.HandleFunc("/cases/{id}/status", auth.Require(auth.ManageCases, a.handleCaseStatus)).Methods("POST")
+	api.HandleFunc("/cases/{id}/assign", auth.Require(auth.ManageCases, a.handleCaseAssign)).Methods("POST")
+	api.HandleFunc("/cases/{id}/notes", auth.Require(auth.ManageCases, a.handleCaseNote)).Methods("POST")
+	api.HandleFunc("/cases/{id}/attachments", auth.Require(auth.ManageCases, a.handleCaseAttach)).Methods("POST")
+	api.HandleFunc("/cases/{id}/evidence",
+		auth.Require(auth.ManageCases, auth.Require(auth.ExportEvidence, a.handleCaseEvidence))).Methods("POST")
+
+	// Audit log
+	api.HandleFunc("/audit", auth.Require(auth.ReadAudit, a.handleAudit)).Methods("GET")
+}
+
+// handleHealth returns service health status
+func (a *APIServer) handleHealth(w http.ResponseWriter, r *http.Request) {
+	if err := a.db.Health(); err != nil {
+		sendJSON(w, http.StatusServiceUnavailable, models.APIResponse{
+			Success: false,
+			Error:   "Database unavailable",
+		})
+		return
+	}
+
+	sendJSON(w, http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "API service is healthy",
+		Data: map[string]string{
+			"service": "api",
+			"status":  "running",
+			"version": "1.0.0",
+		},
+	})
+}
+
+// handleDetections returns a page of detections matching the query filters
+func (a *APIServer) handleDetections(w http.ResponseWriter, r *http.Request) {
+	filter, ok := a.scopedFilter(w, r)
+	if !ok {
+		return
+	}
+
+	page, err := a.db.QueryDetections(filter)
+	if err != nil {
+		logger.ErrorContext(r.Context(), "Detection query failed", logging.Err(err))
+		sendError(w, http.StatusInternalServerError, "Failed to query detections")
+		return
+	}
+	release(r, page.Detections)
+
+	sendJSON(w, http.StatusOK, models.APIResponse{Success: true, Data: page})
+}
+
+// handleLatest returns the latest detection per UAS (default: last 10 minutes)
+func (a *APIServer) handleLatest(w http.ResponseWriter, r *http.Request) {
+	filter, ok := a.scopedFilter(w, r)
+	if !ok {
+		return
+	}
+	if filter.From.IsZero() {
+		filter.From = time.Now().Add(-10 * time.Minute)
+	}
+
+	detections, err := a.db.LatestPerUAS(filter)
+	if err != nil {
+		logger.ErrorContext(r.Context(), "Latest query failed", logging.Err(err))
+		sendError(w, http.StatusInternalServerError, "Failed to query latest detections")
+		return
+	}
+	release(r, detections)
+
+	sendJSON(w, http.StatusOK, models.APIResponse{Success: true, Data: detections})
+}
+
+// withinRequest is the body for polygon queries
+type withinRequest struct {
+	Polygon []database.Point `json:"polygon"`
+	// Target is "drone" (default) or "operator"
+	Target string `json:"target"`
+}
+
+// handleWithin returns detections inside a polygon; other filters come
+// from the query string.
+func (a *APIServer) handleWithin(w http.ResponseWriter, r *http.Request) {
+	filter, ok := a.scopedFilter(w, r)
+	if !ok {
+		return
+	}
+
+	var req withinRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(w, http.StatusBadRequest, "Invalid JSON format")
+		return
+	}
+
+	audit.Note(r.Context(), "polygon", req.Polygon)
+	audit.Note(r.Context(), "target", req.Target)
+	switch req.Target {
+	case "", "drone":
+		filter.Polygon = req.Polygon
+	case "operator":
+		// Searching by operator position would reveal it
+		if !auth.FromContext(r.Context()).Can(auth.ReadOperator) {
+			auth.Forbid(w, r, errOperatorSearch)
+			return
+		}
+		filter.OperatorPolygon = req.Polygon
+	default:
+		sendError(w, http.StatusBadRequest, "target must be 'drone' or 'operator'")
+		return
+	}
+
+	page, err := a.db.QueryDetections(filter)
+	if err != nil {
+		logger.ErrorContext(r.Context(), "Polygon query failed", logging.Err(err))
+		sendError(w, http.StatusInternalServerError, "Failed to query detections")
+		return
+	}
+	release(r, page.Detections)
+
+	sendJSON(w, http.StatusOK, models.APIResponse{Success: true, Data: page})
+}
+
+// handleNearest returns the UAS closest to ?lat=&lon= (latest positions,
+// default last 10 minutes, ?k= results)
+func (a *APIServer) handleNearest(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	filter, ok := a.scopedFilter(w, r)
+	if !ok {
+		return
+	}
+	if filter.From.IsZero() {
+		filter.From = time.Now().Add(-10 * time.Minute)
+	}
+
+	var center database.Point
+	if _, err := fmt.Sscanf(query.Get("lat")+" "+query.Get("lon"), "%f %f", &center.Lat, &center.Lon); err != nil {
+		sendError(w, http.StatusBadRequest, "lat and lon are required")
+		return
+	}
+
+	k := 10
+	if v := query.Get("k"); v != "" {
+		if _, err := fmt.Sscanf(v, "%d", &k); err != nil {
+			sendError(w, http.StatusBadRequest, "invalid 'k'")
+			return
+		}
+	}
+
+	results, err := a.db.NearestUAS(center, k, filter)
+	if err != nil {
+		logger.ErrorContext(r.Context(), "Nearest query failed", logging.Err(err))
+		sendError(w, http.StatusInternalServerError, "Failed to query nearest UAS")
+		return
+	}
+	p := auth.FromContext(r.Context())
+	for i := range results {
+		p.Redact(&results[i].DroneDetection)
+	}
+	noteDetections(r, len(results), func(i int) string { return results[i].UASID })
+
+	sendJSON(w, http.StatusOK, models.APIResponse{Success: true, Data: results})
+}
+
+// handleExport returns a UAS flight path for a time window
+//
+//	GET /api/v1/export/{uas_id}?format=kml|kmz|geojson&from=RFC3339&to=RFC3339
+func (a *APIServer) handleExport(w http.ResponseWriter, r *http.Request) {
+	uasID := mux.Vars(r)["uas_id"]
+	query := r.URL.Query()
+	audit.SetAction(r.Context(), audit.ActionExport)
+
+	format, err := export.ParseFormat(query.Get("format"))
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	from, to, err := parseWindow(query.Get("from"), query.Get("to"), 24*time.Hour)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	scope, err := a.scope(r)
+	if err != nil {
+		auth.Forbid(w, r, err)
+		return
+	}
+
+	detections, err := a.trackDetections(uasID, from, to, scope)
+	if errors.Is(err, errTrackTooLong) {
+		sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		logger.ErrorContext(r.Context(), "Export query failed", logging.Err(err))
+		sendError(w, http.StatusInternalServerError, "Failed to query detections")
+		return
+	}
+	release(r, detections)
+	audit.Note(r.Context(), "from", from)
+	audit.Note(r.Context(), "to", to)
+	audit.Note(r.Context(), "format", format.Extension())
+	if len(detections) == 0 {
+		sendError(w, http.StatusNotFound, "No detections for UAS in time window")
+		return
+	}
+
+	if !commitAudit(w, r, http.StatusOK) {
+		return
+	}
+
+	filename := fmt.Sprintf("%s_%s.%s", uasID, from.UTC().Format("20060102T150405Z"), format.Extension())
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+	if err := export.Write(w, format, uasID, detections); err != nil {
+		logger.ErrorContext(r.Context(), "Export write failed", logging.UAS(uasID), logging.Err(err))
+		return
+	}
+
+	logger.InfoContext(r.Context(), "Exported detections", logging.UAS(uasID), "rows", len(detections), "format", format)
+}
+
+// maxTrackDetections caps the detections read for one track
+const maxTrackDetections = 10 * database.MaxPageSize
+
+// errTrackTooLong rejects track windows holding more than
+// maxTrackDetections detections
+var errTrackTooLong = fmt.Errorf("more than %d detections in the time window; narrow from and to", maxTrackDetections)
+
+// trackDetections returns a UAS's detections in [from, to], oldest first,
+// keeping only those within scope if it is set. Windows holding more than
+// maxTrackDetections return errTrackTooLong.
+func (a *APIServer) trackDetections(uasID string, from, to time.Time, scope *database.Area) ([]models.DroneDetection, error) {
+	filter := database.DetectionFilter{
+		UASID:     uasID,
+		From:      from,
+		To:        to,
+		Scope:     scope,
+		Limit:     database.MaxPageSize,
+		Ascending: true,
+	}
+	var detections []models.DroneDetection
+	for {
+		page, err := a.db.QueryDetections(filter)
+		if err != nil {
+			return nil, err
+		}
+		detections = append(detections, page.Detections...)
+		if page.NextCursor == "" {
+			return detections, nil
+		}
+		if len(detections) >= maxTrackDetections {
+			return nil, errTrackTooLong
+		}
+		filter.Cursor = page.NextCursor
+	}
+}
+
+// parseWindow parses optional RFC3339 bounds; missing bounds default to
+// the trailing window ending now.
+func parseWindow(fromStr, toStr string, defaultWindow time.Duration) (time.Time, time.Time, error) {
+	to := time.Now()
+	if toStr != "" {
+		t, err := time.Parse(time.RFC3339, toStr)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid 'to' timestamp: %w", err)
+		}
+		to = t
+	}
+
+	from := to.Add(-defaultWindow)
+	if fromStr != "" {
+		t, err := time.Parse(time.RFC3339, fromStr)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid 'from' timestamp: %w", err)
+		}
+		from = t
+	}
+
+	if !from.Before(to) {
+		return time.Time{}, time.Time{}, fmt.Errorf("'from' must be before 'to'")
+	}
+	return from, to, nil
+}
+
+// sendError sends a JSON error response
+func sendError(w http.ResponseWriter, statusCode int, message string) {
+	sendJSON(w, statusCode, models.APIResponse{
+		Success: false,
+		Error:   message,
+	})
+}
+
+// sendJSON sends JSON response
+func sendJSON(w http.ResponseWriter, statusCode int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(data)
+}
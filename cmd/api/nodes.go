@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"silentraven/internal/audit"
+	"silentraven/internal/crypto"
+	"silentraven/internal/database"
+	"silentraven/internal/logging"
+	"silentraven/internal/models"
+)
+
+// nodeRequest is the writable part of a sensor node registration
+type nodeRequest struct {
+	Name        string  `json:"name"`
+	Latitude    float64 `json:"latitude"`
+	Longitude   float64 `json:"longitude"`
+	Altitude    float64 `json:"altitude"`
+	AntennaType string  `json:"antenna_type"`
+	Firmware    string  `json:"firmware"`
+	Owner       string  `json:"owner"`
+	PublicKey   string  `json:"public_key"`
+}
+
+// handleListNodes returns every registered sensor node
+func (a *APIServer) handleListNodes(w http.ResponseWriter, r *http.Request) {
+	nodes, err := a.db.ListNodes()
+	if err != nil {
+		logger.ErrorContext(r.Context(), "Node list failed", logging.Err(err))
+		sendError(w, http.StatusInternalServerError, "Failed to list nodes")
+		return
+	}
+
+	sendJSON(w, http.StatusOK, models.APIResponse{Success: true, Data: nodes})
+}
+
+// handleGetNode returns one sensor node
+func (a *APIServer) handleGetNode(w http.ResponseWriter, r *http.Request) {
+	node, err := a.db.GetNode(mux.Vars(r)["node_id"])
+	if err != nil {
+		sendNodeError(w, r, err)
+		return
+	}
+
+	sendJSON(w, http.StatusOK, models.APIResponse{Success: true, Data: node})
+}
+
+// handlePutNode registers a node or updates its metadata
+func (a *APIServer) handlePutNode(w http.ResponseWriter, r *http.Request) {
+	audit.SetAction(r.Context(), audit.ActionNodeUpdate)
+	var req nodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendError(w, http.StatusBadRequest, "Invalid JSON format")
+		return
+	}
+	audit.Note(r.Context(), "node", req)
+	if req.Latitude < -90 || req.Latitude > 90 || req.Longitude < -180 || req.Longitude > 180 {
+		sendError(w, http.StatusBadRequest, "latitude/longitude out of range")
+		return
+	}
+	if req.PublicKey != "" {
+		if _, err := crypto.ParsePublicKey(req.PublicKey); err != nil {
+			sendError(w, http.StatusBadRequest, "public_key must be a PEM ECDSA public key")
+			return
+		}
+	}
+
+	node := &models.SensorNode{
+		NodeID:      mux.Vars(r)["node_id"],
+		Name:        req.Name,
+		Latitude:    req.Latitude,
+		Longitude:   req.Longitude,
+		Altitude:    req.Altitude,
+		AntennaType: req.AntennaType,
+		Firmware:    req.Firmware,
+		Owner:       req.Owner,
+		PublicKey:   req.PublicKey,
+	}
+	if err := a.db.UpsertNode(node); err != nil {
+		logger.ErrorContext(r.Context(), "Node upsert failed", logging.Err(err))
+		sendError(w, http.StatusInternalServerError, "Failed to save node")
+		return
+	}
+
+	logger.InfoContext(r.Context(), "Registered node", logging.Node(node.NodeID))
+	sendJSON(w, http.StatusOK, models.APIResponse{Success: true, Data: node})
+}
+
+// handleDeleteNode removes a node from the registry
+func (a *APIServer) handleDeleteNode(w http.ResponseWriter, r *http.Request) {
+	audit.SetAction(r.Context(), audit.ActionNodeDelete)
+	nodeID := mux.Vars(r)["node_id"]
+	if err := a.db.DeleteNode(nodeID); err != nil {
+		sendNodeError(w, r, err)
+		return
+	}
+
+	logger.InfoContext(r.Context(), "Deleted node", logging.Node(nodeID))
+	sendJSON(w, http.StatusOK, models.APIResponse{Success: true, Message: "Node deleted"})
+}
+
+// handleNodeEvents returns a node's recent status transitions
+//
+//	GET /api/v1/nodes/{node_id}/events?limit=100
+func (a *APIServer) handleNodeEvents(w http.ResponseWriter, r *http.Request) {
+	nodeID := mux.Vars(r)["node_id"]
+
+	limit := 100
+	if v := r.URL.Query().Get("limit"); v != "" {
+		var err error
+		if limit, err = strconv.Atoi(v); err != nil || limit <= 0 {
+			sendError(w, http.StatusBadRequest, "invalid 'limit'")
+			return
+		}
+	}
+
+	if _, err := a.db.GetNode(nodeID); err != nil {
+		sendNodeError(w, r, err)
+		return
+	}
+
+	events, err := a.db.NodeEvents(nodeID, limit)
+	if err != nil {
+		logger.ErrorContext(r.Context(), "Node events query failed", logging.Err(err))
+		sendError(w, http.StatusInternalServerError, "Failed to query node events")
+		return
+	}
+
+	sendJSON(w, http.StatusOK, models.APIResponse{Success: true, Data: events})
+}
+
+// sendNodeError maps registry errors to HTTP responses
+func sendNodeError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, database.ErrNotFound) {
+		sendError(w, http.StatusNotFound, "Node not found")
+		return
+	}
+	logger.ErrorContext(r.Context(), "Node query failed", logging.Err(err))
+	sendError(w, http.StatusInternalServerError, "Failed to query node")
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"silentraven/internal/database"
+	"silentraven/internal/logging"
+	"silentraven/internal/models"
+)
+
+// statsResponse bundles every statistics view for one window
+type statsResponse struct {
+	Bucket        string                   `json:"bucket"`
+	From          time.Time                `json:"from"`
+	To            time.Time                `json:"to"`
+	Series        []database.StatsBucket   `json:"series"`
+	Nodes         []database.NodeStat      `json:"nodes"`
+	BusiestHours  []database.HourStat      `json:"busiest_hours"`
+	TopDroneTypes []database.DroneTypeStat `json:"top_drone_types"`
+}
+
+// handleStats returns aggregated statistics
+//
+//	GET /api/v1/stats?bucket=hour|day|week&from=RFC3339&to=RFC3339&top=10
+func (a *APIServer) handleStats(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	bucket := query.Get("bucket")
+	if bucket == "" {
+		bucket = "hour"
+	}
+	size, ok := database.StatsBuckets[bucket]
+	if !ok {
+		sendError(w, http.StatusBadRequest, "bucket must be hour, day or week")
+		return
+	}
+
+	// Default to 24 buckets of the requested size
+	from, to, err := parseWindow(query.Get("from"), query.Get("to"), 24*size)
+	if err != nil {
+		sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	top := 10
+	if v := query.Get("top"); v != "" {
+		if top, err = strconv.Atoi(v); err != nil || top <= 0 {
+			sendError(w, http.StatusBadRequest, "invalid 'top'")
+			return
+		}
+	}
+
+	resp := statsResponse{Bucket: bucket, From: from, To: to}
+	if resp.Series, err = a.db.StatsSeries(bucket, from, to); err == nil {
+		if resp.Nodes, err = a.db.NodeStats(from, to); err == nil {
+			if resp.BusiestHours, err = a.db.BusiestHours(from, to); err == nil {
+				resp.TopDroneTypes, err = a.db.TopDroneTypes(from, to, top)
+			}
+		}
+	}
+	if err != nil {
+		logger.ErrorContext(r.Context(), "Stats query failed", logging.Err(err))
+		sendError(w, http.StatusInternalServerError, "Failed to query statistics")
+		return
+	}
+
+	sendJSON(w, http.StatusOK, models.APIResponse{Success: true, Data: resp})
+}
+
+// handleStatsSummary returns the dashboard card values (the shape the
+// StatsPanel reads from the legacy /data endpoint)
+func (a *APIServer) handleStatsSummary(w http.ResponseWriter, r *http.Request) {
+	window := 2 * time.Minute
+	if v := r.URL.Query().Get("minutes_online_window"); v != "" {
+		minutes, err := strconv.Atoi(v)
+		if err != nil || minutes <= 0 {
+			sendError(w, http.StatusBadRequest, "invalid 'minutes_online_window'")
+			return
+		}
+		window = time.Duration(minutes) * time.Minute
+	}
+
+	summary, err := a.db.LiveSummary(window)
+	if err != nil {
+		logger.ErrorContext(r.Context(), "Summary query failed", logging.Err(err))
+		sendError(w, http.StatusInternalServerError, "Failed to query summary")
+		return
+	}
+
+	// Node counts come from the registry; fall back to nodes seen in
+	// recent detections when no node has been registered yet
+	nodes, err := a.db.ListNodes()
+	if err != nil {
+		logger.ErrorContext(r.Context(), "Node list failed", logging.Err(err))
+		sendError(w, http.StatusInternalServerError, "Failed to query summary")
+		return
+	}
+	nodesActive, nodesTotal := summary.NodesActive, int64(len(nodes))
+	if nodesTotal > 0 {
+		nodesActive = 0
+		for _, n := range nodes {
+			if n.Status == models.NodeOnline || n.Status == models.NodeDegraded {
+				nodesActive++
+			}
+		}
+	}
+
+	sendJSON(w, http.StatusOK, map[string]interface{}{
+		"uptime_seconds":      int64(time.Since(a.startedAt).Seconds()),
+		"today_unique_uasids": summary.TodayUniqueUAS,
+		"online_drones":       summary.OnlineDrones,
+		"nodes_active":        nodesActive,
+		"nodes_total":         nodesTotal,
+		"as_of":               summary.AsOf,
+	})
+}
+
+// refreshStats periodically refreshes the aggregates for the recent past
+func (a *APIServer) refreshStats(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		now := time.Now()
+		if err := a.db.RefreshStats(now.Add(-3*time.Hour), now.Add(time.Hour)); err != nil {
+			logger.Warn("Stats refresh failed", logging.Err(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
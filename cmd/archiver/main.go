@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"silentraven/internal/archive"
+	"silentraven/internal/database"
+	"silentraven/internal/logging"
+	"silentraven/internal/reload"
+	"silentraven/internal/retention"
+	"silentraven/pkg/config"
+)
+
+const usage = `Usage:
+  archiver [run] [-once] [-interval 1h]   enforce retention and archive expiring data
+  archiver list                           list archive files from the manifest
+  archiver restore [-table t] <file>      load an archive file back into Postgres
+`
+
+var logger = logging.For("archiver")
+
+func main() {
+	flag.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	flag.Parse()
+
+	cmd := "run"
+	args := flag.Args()
+	if len(args) > 0 {
+		cmd, args = args[0], args[1:]
+	}
+
+	cfg, err := config.Load(config.Archiver)
+	if err != nil {
+		logging.Fatal(logger, "Failed to load configuration", logging.Err(err))
+	}
+	if err := logging.Configure(cfg, "archiver"); err != nil {
+		logging.Fatal(logger, "Invalid logging configuration", logging.Err(err))
+	}
+
+	switch cmd {
+	case "run":
+		runRetention(cfg, args)
+	case "list":
+		listArchives(cfg)
+	case "restore":
+		restoreArchive(cfg, args)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
+
+func policyFromConfig(cfg *config.Config) retention.Policy {
+	return retention.Policy{
+		RawRetention:   cfg.RetentionRaw,
+		TrackRetention: cfg.RetentionTracks,
+		TrackBucket:    cfg.TrackBucket,
+		CompressAfter:  cfg.CompressAfter,
+		ArchiveDir:     cfg.ArchiveDir,
+		ArchivePeriod:  cfg.ArchivePeriod,
+	}
+}
+
+func runRetention(cfg *config.Config, args []string) {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	once := fs.Bool("once", false, "run a single retention pass and exit")
+	interval := fs.Duration("interval", time.Hour, "time between retention passes")
+	fs.Parse(args)
+
+	logger.Info("Starting SilentRaven Archiver")
+
+	db, err := database.New(cfg)
+	if err != nil {
+		logging.Fatal(logger, "Failed to connect to database", logging.Err(err))
+	}
+	defer db.Close()
+
+	manager, err := retention.NewManager(db, policyFromConfig(cfg))
+	if err != nil {
+		logging.Fatal(logger, "Invalid retention policy", logging.Err(err))
+	}
+
+	logger.Info("Retention policy", "raw", cfg.RetentionRaw, "tracks", cfg.RetentionTracks,
+		"compress_after", cfg.CompressAfter, "archive_dir", cfg.ArchiveDir)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if *once {
+		if err := db.SetCompressionPolicy(cfg.CompressAfter); err != nil {
+			logging.Fatal(logger, "Failed to set compression policy", logging.Err(err))
+		}
+		res, err := manager.RunOnce(ctx)
+		if err != nil {
+			logging.Fatal(logger, "Retention pass failed", logging.Err(err))
+		}
+		logger.Info("Retention pass complete", "archived_files", len(res.Archived), "track_points", res.TrackPoints,
+			"dropped_raw", res.DroppedRaw, "dropped_track_points", res.DroppedTracks)
+		return
+	}
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sigChan
+		logger.Info("Shutting down")
+		cancel()
+	}()
+	go reload.Watch(ctx, config.Archiver, cfg, reload.Hooks{})
+
+	if err := manager.Run(ctx, *interval); err != nil {
+		logging.Fatal(logger, "Retention failed", logging.Err(err))
+	}
+
+	logger.Info("Archiver stopped")
+}
+
+func listArchives(cfg *config.Config) {
+	manifest, err := archive.LoadManifest(cfg.ArchiveDir)
+	if err != nil {
+		logging.Fatal(logger, "Failed to read manifest", logging.Err(err))
+	}
+
+	for _, e := range manifest.Entries {
+		fmt.Printf("%s  %s → %s  %8d rows  %10d bytes  sha256:%s\n",
+			e.File, e.From.Format(time.RFC3339), e.To.Format(time.RFC3339), e.Rows, e.Bytes, e.SHA256)
+	}
+}
+
+func restoreArchive(cfg *config.Config, args []string) {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	table := fs.String("table", "restored_detections", "target table (restored_detections or drone_detections)")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	db, err := database.New(cfg)
+	if err != nil {
+		logging.Fatal(logger, "Failed to connect to database", logging.Err(err))
+	}
+	defer db.Close()
+
+	n, err := retention.Restore(db, cfg.ArchiveDir, fs.Arg(0), *table)
+	if err != nil {
+		logging.Fatal(logger, "Restore failed", logging.Err(err))
+	}
+
+	logger.Info("Restored detections", "rows", n, "file", fs.Arg(0), "table", *table)
+}
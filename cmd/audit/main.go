@@ -0,0 +1,94 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"silentraven/internal/audit"
+	"silentraven/internal/database"
+	"silentraven/pkg/config"
+)
+
+const usage = `Usage:
+  audit verify [-anchor seq:hash]
+      check the audit log's hash chain from the first entry to the last
+      and print the head to record elsewhere; with -anchor, also check
+      that a head recorded earlier is still part of the chain
+
+Exit status is 1 if the chain is broken or the anchor does not match.
+`
+
+// service needs the audit log
+var service = config.Service{Name: "audit", Needs: []config.Requirement{config.Database}}
+
+func main() {
+	flag.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	flag.Parse()
+
+	args := flag.Args()
+	if len(args) == 0 || args[0] != "verify" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := verify(args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+// verify checks the chain and, if given, an anchor
+func verify(args []string) error {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	fs.Usage = flag.Usage
+	anchor := fs.String("anchor", "", "head recorded by an earlier verify, as seq:hash")
+	fs.Parse(args)
+
+	var anchorSeq int64
+	var anchorHash string
+	if *anchor != "" {
+		seq, hash, ok := strings.Cut(*anchor, ":")
+		n, err := strconv.ParseInt(seq, 10, 64)
+		if !ok || err != nil || n < 1 || hash == "" {
+			return fmt.Errorf("invalid -anchor %q: want seq:hash", *anchor)
+		}
+		anchorSeq, anchorHash = n, hash
+	}
+
+	cfg, err := config.Load(service)
+	if err != nil {
+		return err
+	}
+	db, err := database.New(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	head, count, err := audit.Verify(db)
+	var chainErr *audit.ChainError
+	if errors.As(err, &chainErr) {
+		fmt.Printf("BROKEN after %d good entries\n", count)
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		fmt.Println("Audit log is empty")
+	} else {
+		fmt.Printf("OK: %d entries\n", count)
+		fmt.Printf("Head: %d:%s\n", head.Seq, head.Hash)
+	}
+
+	if anchorSeq > 0 {
+		if err := audit.CheckAnchor(db, head, anchorSeq, anchorHash); err != nil {
+			return err
+		}
+		fmt.Printf("Anchor %d matches\n", anchorSeq)
+	}
+	return nil
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"silentraven/internal/audit"
+	"silentraven/internal/auth"
+	"silentraven/internal/database"
+	"silentraven/internal/models"
+	"silentraven/pkg/config"
+)
+
+const usage = `Usage:
+  auth key create -kind node|client -subject id [-name text] [-ttl 8760h]
+                  [-role name] [-jurisdiction name]
+      issue an API key; node keys carry the node ID as subject and may
+      only submit for that node, client keys need a role for the query API
+  auth key list
+      list keys with their state and last use
+  auth key revoke id
+      revoke a key at once
+  auth key rotate [-grace 24h] id
+      issue a replacement key; the old one keeps working for the grace
+      period
+  auth token -subject name -role name [-jurisdiction name] [-kind user] [-ttl 1h]
+      mint a bearer token for the query API, signed with API_SECRET
+
+Roles: admin and analyst see everything (admin may also edit nodes),
+partner sees detections and compliance reports within its jurisdiction,
+and public sees drones and statistics without operator positions or raw
+payloads. Jurisdictions are the named areas under jurisdictions in the
+config file.
+
+Plaintext keys and tokens are printed once and never stored.
+`
+
+// keyService needs the key store
+var keyService = config.Service{Name: "auth", Needs: []config.Requirement{config.Database}}
+
+// tokenService needs the signing secret
+var tokenService = config.Service{Name: "auth", Needs: []config.Requirement{config.APISecret}}
+
+func main() {
+	flag.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	flag.Parse()
+
+	args := flag.Args()
+	if len(args) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var err error
+	switch {
+	case args[0] == "token":
+		err = token(args[1:])
+	case args[0] == "key" && len(args) > 1:
+		err = key(args[1], args[2:])
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+// key runs a key subcommand against the database
+func key(cmd string, args []string) error {
+	fs := flag.NewFlagSet("key "+cmd, flag.ExitOnError)
+	fs.Usage = flag.Usage
+	var kind, subject, name, role, jurisdiction *string
+	var ttl, grace *time.Duration
+	switch cmd {
+	case "create":
+		kind = fs.String("kind", models.KeyKindNode, "key kind: node or client")
+		subject = fs.String("subject", "", "node ID or client name")
+		name = fs.String("name", "", "description")
+		role = fs.String("role", "", "client key role: "+strings.Join(auth.RoleNames(), ", "))
+		jurisdiction = fs.String("jurisdiction", "", "area the key is limited to")
+		ttl = fs.Duration("ttl", 0, "lifetime (0 never expires)")
+	case "list":
+	case "revoke":
+	case "rotate":
+		grace = fs.Duration("grace", 24*time.Hour, "how long the old key keeps working")
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	fs.Parse(args)
+
+	cfg, err := config.Load(keyService)
+	if err != nil {
+		return err
+	}
+	db, err := database.New(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	switch cmd {
+	case "create":
+		if err := checkJurisdiction(cfg, *jurisdiction); err != nil {
+			return err
+		}
+		spec := models.APIKey{Kind: *kind, Subject: *subject, Name: *name, Role: *role, Jurisdiction: *jurisdiction}
+		plaintext, k, err := auth.Issue(db, spec, *ttl)
+		if err != nil {
+			return err
+		}
+		printKey(plaintext, k)
+		record(db, audit.ActionKeyCreate, k.ID, map[string]any{
+			"kind": k.Kind, "subject": k.Subject, "role": k.Role, "jurisdiction": k.Jurisdiction,
+		})
+	case "list":
+		keys, err := db.ListAPIKeys()
+		if err != nil {
+			return err
+		}
+		printKeys(keys)
+	case "revoke":
+		if fs.NArg() != 1 {
+			return fmt.Errorf("revoke needs a key ID")
+		}
+		if err := db.RevokeAPIKey(fs.Arg(0), time.Now()); err != nil {
+			return err
+		}
+		fmt.Printf("Revoked %s\n", fs.Arg(0))
+		record(db, audit.ActionKeyRevoke, fs.Arg(0), nil)
+	case "rotate":
+		if fs.NArg() != 1 {
+			return fmt.Errorf("rotate needs a key ID")
+		}
+		plaintext, k, err := auth.Rotate(db, fs.Arg(0), *grace)
+		if err != nil {
+			return err
+		}
+		printKey(plaintext, k)
+		fmt.Printf("Old key %s expires at %s\n", fs.Arg(0), time.Now().Add(*grace).Format(time.RFC3339))
+		record(db, audit.ActionKeyRotate, fs.Arg(0), map[string]any{"replaced_by": k.ID, "grace": grace.String()})
+	}
+	return nil
+}
+
+// token mints a bearer token
+func token(args []string) error {
+	fs := flag.NewFlagSet("token", flag.ExitOnError)
+	fs.Usage = flag.Usage
+	kind := fs.String("kind", auth.KindUser, "principal kind: user or client")
+	subject := fs.String("subject", "", "who the token is for")
+	role := fs.String("role", "", "role: "+strings.Join(auth.RoleNames(), ", "))
+	jurisdiction := fs.String("jurisdiction", "", "area the token is limited to")
+	ttl := fs.Duration("ttl", 0, "lifetime (default auth.token_ttl)")
+	fs.Parse(args)
+
+	switch *kind {
+	case auth.KindUser, models.KeyKindClient:
+	default:
+		return fmt.Errorf("invalid token kind %q: want user or client", *kind)
+	}
+	if *subject == "" {
+		return fmt.Errorf("a token needs a -subject")
+	}
+
+	cfg, err := config.Load(tokenService)
+	if err != nil {
+		return err
+	}
+	if *ttl == 0 {
+		*ttl = cfg.AuthTokenTTL
+	}
+	if err := checkJurisdiction(cfg, *jurisdiction); err != nil {
+		return err
+	}
+
+	p := auth.Principal{Kind: *kind, Subject: *subject, Role: *role, Jurisdiction: *jurisdiction}
+	t, expires, err := auth.IssueToken([]byte(cfg.APISecret), p, *ttl)
+	if err != nil {
+		return err
+	}
+	fmt.Println(t)
+	fmt.Fprintf(os.Stderr, "Expires at %s\n", expires.Format(time.RFC3339))
+	return nil
+}
+
+// record audits a key change as made by the operator running the tool.
+// The change has been made by then, so a failure is only reported.
+func record(db *database.DB, action, keyID string, detail map[string]any) {
+	e := &models.AuditEvent{
+		Actor:     audit.Operator(),
+		ActorKind: audit.KindOperator,
+		Action:    action,
+		Resource:  "api_key " + keyID,
+		KeyID:     keyID,
+	}
+	if err := audit.New(db).Record(e, detail); err != nil {
+		fmt.Fprintln(os.Stderr, "warning: change not audited:", err)
+	}
+}
+
+// checkJurisdiction catches typos: a key or token for an unknown
+// jurisdiction is refused by the API
+func checkJurisdiction(cfg *config.Config, name string) error {
+	if name == "" {
+		return nil
+	}
+	if _, ok := cfg.Jurisdiction(name); !ok {
+		return fmt.Errorf("unknown jurisdiction %q: add it under jurisdictions in the config file", name)
+	}
+	return nil
+}
+
+func printKey(plaintext string, k *models.APIKey) {
+	fmt.Printf("ID:      %s\n", k.ID)
+	fmt.Printf("Kind:    %s\n", k.Kind)
+	fmt.Printf("Subject: %s\n", k.Subject)
+	if k.Role != "" {
+		fmt.Printf("Role:    %s\n", k.Role)
+	}
+	if k.Jurisdiction != "" {
+		fmt.Printf("Scope:   %s\n", k.Jurisdiction)
+	}
+	if !k.ExpiresAt.IsZero() {
+		fmt.Printf("Expires: %s\n", k.ExpiresAt.Format(time.RFC3339))
+	}
+	fmt.Printf("Key:     %s\n", plaintext)
+	fmt.Println("Store the key now; it cannot be shown again.")
+}
+
+func printKeys(keys []models.APIKey) {
+	now := time.Now()
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tKIND\tSUBJECT\tROLE\tJURISDICTION\tNAME\tSTATE\tEXPIRES\tLAST USED")
+	for _, k := range keys {
+		state := "active"
+		switch {
+		case !k.RevokedAt.IsZero() && !k.RevokedAt.After(now):
+			state = "revoked"
+		case !k.Active(now):
+			state = "expired"
+		case k.ReplacedBy != "":
+			state = "rotating to " + k.ReplacedBy
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			k.ID, k.Kind, k.Subject, orDash(k.Role), orDash(k.Jurisdiction), k.Name, state,
+			formatTime(k.ExpiresAt), formatTime(k.LastUsedAt))
+	}
+	w.Flush()
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Format(time.RFC3339)
+}
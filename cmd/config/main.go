@@ -0,0 +1,92 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/joho/godotenv"
+
+	"silentraven/internal/logging"
+	"silentraven/pkg/config"
+)
+
+const usage = `Usage:
+  config check [-file path] [-service name]
+      validate the configuration and print the effective settings, with
+      secrets redacted
+
+The file defaults to CONFIG_FILE; environment variables override it.
+Without -service the settings every service needs are required.
+`
+
+func main() {
+	flag.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	flag.Parse()
+
+	args := flag.Args()
+	if len(args) == 0 || args[0] != "check" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	os.Exit(check(args[1:]))
+}
+
+// check loads and validates the configuration and prints it
+func check(args []string) int {
+	fs := flag.NewFlagSet("check", flag.ExitOnError)
+	fs.Usage = flag.Usage
+	file := fs.String("file", "", "YAML or TOML config file (default $CONFIG_FILE)")
+	name := fs.String("service", "", "check only what this service needs")
+	fs.Parse(args)
+
+	svc := allServices()
+	if *name != "" {
+		var ok bool
+		if svc, ok = config.LookupService(*name); !ok {
+			fmt.Fprintf(os.Stderr, "unknown service %q\n", *name)
+			return 2
+		}
+	}
+
+	_ = godotenv.Load()
+	path := *file
+	if path == "" {
+		path = os.Getenv("CONFIG_FILE")
+	}
+
+	cfg, err := config.LoadFile(path, svc)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	// Levels are parsed by the logging package, which config cannot import
+	if _, err := logging.ParseLevels(cfg.LogLevel); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration:\n  logging.level (LOG_LEVEL): %v\n", err)
+		return 1
+	}
+
+	if path != "" {
+		fmt.Printf("# %s\n", path)
+	}
+	if err := cfg.WriteYAML(os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// allServices needs what every service needs
+func allServices() config.Service {
+	all := config.Service{Name: "all"}
+	seen := make(map[config.Requirement]bool)
+	for _, svc := range config.Services {
+		for _, need := range svc.Needs {
+			if !seen[need] {
+				seen[need] = true
+				all.Needs = append(all.Needs, need)
+			}
+		}
+	}
+	return all
+}
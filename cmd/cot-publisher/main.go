@@ -3,27 +3,59 @@ package main
 import (
 	"context"
 	"encoding/json"
-	"log"
 	"os"
 	"os/signal"
-	"strconv"
 	"syscall"
+	"time"
 
 	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 
 	"silentraven/internal/cot"
+	"silentraven/internal/geoid"
+	"silentraven/internal/logging"
+	"silentraven/internal/metrics"
 	"silentraven/internal/models"
+	"silentraven/internal/reload"
+	"silentraven/internal/tracing"
 	"silentraven/pkg/config"
 )
 
-func main() {
-	log.Println("🚀 Starting CoT Publisher Service...")
+var (
+	logger = logging.For("cot")
+	// detectionLog samples the per-detection lines
+	detectionLog = logging.Sampled(logger)
+)
 
+func main() {
 	// Load config
-	cfg, err := config.Load()
+	cfg, err := config.Load(config.CoTPublisher)
+	if err != nil {
+		logging.Fatal(logger, "Config load failed", logging.Err(err))
+	}
+	if err := logging.Configure(cfg, "cot-publisher"); err != nil {
+		logging.Fatal(logger, "Invalid logging configuration", logging.Err(err))
+	}
+	logger.Info("Starting CoT Publisher Service")
+	switch {
+	case cfg.GeoidGrid != "":
+		model, err := geoid.Load(cfg.GeoidGrid)
+		if err != nil {
+			logging.Fatal(logger, "Failed to load geoid grid", "path", cfg.GeoidGrid, logging.Err(err))
+		}
+		cot.UseGeoid(model)
+		logger.Info("Converting pressure altitudes with geoid grid", "path", cfg.GeoidGrid)
+	case !geoid.Default().Available():
+		logger.Warn("No EGM96 grid embedded or set with GEOID_GRID; pressure altitudes are sent without MSL to HAE conversion")
+	}
+
+	// Export traces to the configured collector
+	shutdownTracing, err := tracing.Init(context.Background(), "cot-publisher", cfg)
 	if err != nil {
-		log.Fatal("Config load failed:", err)
+		logging.Fatal(logger, "Failed to set up tracing", logging.Err(err))
 	}
+	defer shutdownTracing()
 
 	// Create Redpanda consumer
 	reader := kafka.NewReader(kafka.ReaderConfig{
@@ -35,66 +67,18 @@ func main() {
 	})
 	defer reader.Close()
 
-	// Create appropriate sender based on TAK_MODE
-	var sender cot.Sender
-	takMode := os.Getenv("TAK_MODE")
-
-	switch takMode {
-	case "tcp":
-		serverIP := os.Getenv("TAK_SERVER_IP")
-		if serverIP == "" {
-			log.Fatal("TAK_SERVER_IP not set in .env")
-		}
-
-		portStr := os.Getenv("TAK_SERVER_PORT")
-		if portStr == "" {
-			portStr = "8088"
-		}
-		port, err := strconv.Atoi(portStr)
-		if err != nil {
-			log.Fatal("Invalid TAK_SERVER_PORT:", err)
-		}
-
-		sender, err = cot.NewTCPSender(serverIP, port)
-		if err != nil {
-			log.Fatal("TCP sender failed:", err)
-		}
-		log.Printf("✅ Connected to TAK Server: %s:%d (TCP)", serverIP, port)
-
-	case "direct":
-		targetIP := os.Getenv("TAK_TARGET_IP")
-		if targetIP == "" {
-			log.Fatal("TAK_TARGET_IP not set in .env")
-		}
-
-		portStr := os.Getenv("TAK_TARGET_PORT")
-		if portStr == "" {
-			portStr = "6969"
-		}
-		port, err := strconv.Atoi(portStr)
-		if err != nil {
-			log.Fatal("Invalid TAK_TARGET_PORT:", err)
-		}
-
-		sender, err = cot.NewDirectSender(targetIP, port)
-		if err != nil {
-			log.Fatal("Direct sender failed:", err)
-		}
-		log.Printf("✅ Sending to direct IP: %s:%d (UDP)", targetIP, port)
-
-	case "multicast":
-		sender, err = cot.NewMulticastSender()
-		if err != nil {
-			log.Fatal("Multicast sender failed:", err)
-		}
-		log.Println("✅ Sending to multicast: 239.2.3.1:6969 (UDP)")
-
-	default:
-		log.Fatal("TAK_MODE must be 'tcp', 'direct', or 'multicast' (check .env file)")
+	// Open the configured TAK sinks
+	sinks, err := openSinks(cfg.CoTSinks)
+	if err != nil {
+		logging.Fatal(logger, "Failed to open CoT sinks", logging.Err(err))
 	}
-	defer sender.Close()
+	defer sinks.Close()
+
+	// UAS sent to TAK in the last five minutes
+	tracks := metrics.NewActiveTracks(5 * time.Minute)
+	metrics.Serve(cfg.CoTMetricsAddr)
 
-	log.Println("📡 Listening for detections on Redpanda...")
+	logger.Info("Listening for detections on Redpanda", "topic", cfg.KafkaTopic)
 
 	// Process messages
 	ctx, cancel := context.WithCancel(context.Background())
@@ -104,42 +88,88 @@ func main() {
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 	go func() {
 		<-sigChan
-		log.Println("🛑 Shutting down...")
+		logger.Info("Shutting down")
 		cancel()
 	}()
 
+	// Apply logging and sink changes on SIGHUP
+	go reload.Watch(ctx, config.CoTPublisher, cfg, reload.Hooks{Apply: func(next *config.Config) {
+		if err := sinks.Replace(next.CoTSinks); err != nil {
+			logger.Error("Failed to reopen CoT sinks; keeping the running sinks", logging.Err(err))
+		}
+	}})
+
 	for {
 		msg, err := reader.FetchMessage(ctx)
 		if err != nil {
 			if ctx.Err() != nil {
 				break
 			}
-			log.Printf("Fetch error: %v", err)
+			logger.Error("Failed to fetch message", logging.Err(err))
 			continue
 		}
+		forward(ctx, msg, sinks, tracks)
+		reader.CommitMessages(ctx, msg)
+	}
 
-		var detection models.IncomingPacket
-		if err := json.Unmarshal(msg.Value, &detection); err != nil {
-			log.Printf("Parse error: %v", err)
-			reader.CommitMessages(ctx, msg)
-			continue
-		}
+	logger.Info("CoT Publisher stopped")
+}
 
-		cotXML, err := cot.ConvertToCoT(detection)
-		if err != nil {
-			log.Printf("CoT conversion error: %v", err)
-			reader.CommitMessages(ctx, msg)
-			continue
-		}
+// forward converts one detection to CoT and sends it to every sink,
+// continuing the detection's trace
+func forward(ctx context.Context, msg kafka.Message, sinks *sinkSet, tracks *metrics.ActiveTracks) {
+	ctx, span := tracing.StartConsume(ctx, "cot.publish", msg)
+	defer span.End()
+	metrics.ObserveLag(msg.Topic, msg.Partition, msg.Offset, msg.HighWaterMark)
+
+	var detection models.IncomingPacket
+	if err := json.Unmarshal(msg.Value, &detection); err != nil {
+		logger.WarnContext(ctx, "Invalid detection", "offset", msg.Offset, logging.Err(err))
+		metrics.MessagesConsumed.WithLabelValues(msg.Topic, "error").Inc()
+		tracing.Fail(span, err)
+		return
+	}
+	span.SetAttributes(attribute.String("uas.id", detection.UASID), attribute.String("uas.sn", detection.SN))
 
-		if err := sender.Send(cotXML); err != nil {
-			log.Printf("❌ TAK send error: %v", err)
-		} else {
-			log.Printf("✅ Sent to TAK: UAS=%s (Mode: %s)", detection.UASID, takMode)
-		}
+	cotXML, err := cot.ConvertToCoT(detection)
+	if err != nil {
+		logger.WarnContext(ctx, "CoT conversion failed", logging.UAS(detection.UASID), logging.Err(err))
+		metrics.MessagesConsumed.WithLabelValues(msg.Topic, "error").Inc()
+		tracing.Fail(span, err)
+		return
+	}
+	metrics.MessagesConsumed.WithLabelValues(msg.Topic, "ok").Inc()
 
-		reader.CommitMessages(ctx, msg)
+	sinks.mu.RLock()
+	defer sinks.mu.RUnlock()
+
+	sent := false
+	for _, s := range sinks.sinks {
+		if err := send(ctx, s, cotXML, detection); err != nil {
+			tracing.Fail(span, err)
+			continue
+		}
+		sent = true
 	}
+	if sent {
+		tracks.Seen(detection.UASID)
+	}
+}
 
-	log.Println("✅ CoT Publisher stopped")
+// send delivers one CoT event to sink s in a child span
+func send(ctx context.Context, s sink, cotXML []byte, detection models.IncomingPacket) error {
+	ctx, span := tracing.Tracer().Start(ctx, "cot.send",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("cot.sink", s.name)))
+	defer span.End()
+
+	if err := s.sender.Send(cotXML); err != nil {
+		logger.ErrorContext(ctx, "TAK send failed", logging.UAS(detection.UASID), "sink", s.name, logging.Err(err))
+		metrics.CoTFailures.WithLabelValues(s.name).Inc()
+		tracing.Fail(span, err)
+		return err
+	}
+	detectionLog.InfoContext(ctx, "Sent to TAK", logging.UAS(detection.UASID), logging.Node(detection.NodeID), "sink", s.name)
+	metrics.CoTSends.WithLabelValues(s.name).Inc()
+	return nil
 }
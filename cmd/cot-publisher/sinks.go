@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+
+	"silentraven/internal/cot"
+	"silentraven/internal/logging"
+	"silentraven/pkg/config"
+)
+
+// sink is an open TAK destination
+type sink struct {
+	name   string
+	sender cot.Sender
+}
+
+// sinkSet is the open sinks, replaced as a whole when the configuration
+// is reloaded
+type sinkSet struct {
+	// mu is held for reading while a detection is sent
+	mu    sync.RWMutex
+	sinks []sink
+}
+
+// openSinks connects to every configured TAK destination
+func openSinks(configs []config.CoTSink) (*sinkSet, error) {
+	sinks, err := open(configs)
+	if err != nil {
+		return nil, err
+	}
+	return &sinkSet{sinks: sinks}, nil
+}
+
+// Replace opens the sinks in configs and, if all connect, closes the
+// current ones in their favour
+func (s *sinkSet) Replace(configs []config.CoTSink) error {
+	sinks, err := open(configs)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	old := s.sinks
+	s.sinks = sinks
+	s.mu.Unlock()
+
+	closeAll(old)
+	return nil
+}
+
+// Close closes every sink
+func (s *sinkSet) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	closeAll(s.sinks)
+	s.sinks = nil
+}
+
+func open(configs []config.CoTSink) ([]sink, error) {
+	sinks := make([]sink, 0, len(configs))
+	for _, sc := range configs {
+		sender, err := openSink(sc)
+		if err != nil {
+			closeAll(sinks)
+			return nil, fmt.Errorf("sink %s: %w", sc.Name, err)
+		}
+		sinks = append(sinks, sink{name: sc.Name, sender: sender})
+		logger.Info("Opened CoT sink", "sink", sc.Name, "mode", sc.Mode, "host", sc.Host, "port", sc.Port)
+	}
+	return sinks, nil
+}
+
+// openSink connects to a configured TAK destination
+func openSink(sc config.CoTSink) (cot.Sender, error) {
+	switch sc.Mode {
+	case "tcp":
+		return cot.NewTCPSender(sc.Host, sc.Port)
+	case "direct":
+		return cot.NewDirectSender(sc.Host, sc.Port)
+	case "multicast":
+		return cot.NewMulticastSender()
+	}
+	return nil, fmt.Errorf("unknown mode %q", sc.Mode)
+}
+
+func closeAll(sinks []sink) {
+	for _, s := range sinks {
+		if err := s.sender.Close(); err != nil {
+			logger.Warn("Failed to close CoT sink", "sink", s.name, logging.Err(err))
+		}
+	}
+}
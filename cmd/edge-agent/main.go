@@ -0,0 +1,272 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"silentraven/internal/crypto"
+	"silentraven/internal/logging"
+	"silentraven/internal/models"
+	"silentraven/internal/remoteid"
+	"silentraven/internal/spool"
+)
+
+// version is reported as the node firmware in heartbeats
+const version = "1.0.0"
+
+// maxBatch matches the gateway's limit on packets per batch upload
+const maxBatch = 1000
+
+var (
+	logger = logging.For("edge")
+	// detectionLog samples the per-frame lines
+	detectionLog = logging.Sampled(logger)
+)
+
+const usage = `Usage:
+  edge-agent -node-id ID -source SPEC [flags]
+
+Sources:
+  pcap:FILE       pcap/pcapng capture of Wi-Fi or BLE Remote ID (exits once uploaded)
+  serial:DEVICE   receiver emitting "[sender] [rssi] HEX" lines
+  udp:ADDR        one such line per datagram, e.g. udp::4000
+
+Flags:
+`
+
+// Agent turns received Remote ID frames into signed, spooled detections
+type Agent struct {
+	nodeID    string
+	key       *ecdsa.PrivateKey
+	assembler *remoteid.Assembler
+	spool     *spool.Spool
+	uploader  *Uploader
+	started   time.Time
+
+	detections atomic.Int64 // packets since the last heartbeat
+}
+
+func main() {
+	nodeID := flag.String("node-id", os.Getenv("NODE_ID"), "sensor node ID")
+	gateway := flag.String("gateway", envOr("GATEWAY_URL", "http://localhost:8080"), "gateway base URL")
+	apiKey := flag.String("api-key", os.Getenv("GATEWAY_API_KEY"), "node API key for the gateway")
+	keyPath := flag.String("key", envOr("NODE_KEY_FILE", "node.key"), "node private key (created if missing)")
+	spoolDir := flag.String("spool", envOr("EDGE_SPOOL_DIR", "edge-spool"), "offline buffer directory")
+	spoolMB := flag.Int("spool-max-mb", 256, "offline buffer size limit")
+	sourceSpec := flag.String("source", os.Getenv("EDGE_SOURCE"), "frame source (see above)")
+	batch := flag.Int("batch", 100, fmt.Sprintf("detections per upload (at most %d)", maxBatch))
+	heartbeat := flag.Duration("heartbeat", 30*time.Second, "heartbeat interval")
+	maxSkew := flag.Duration("max-skew", 2*time.Second, "warn when the node clock is further off the gateway")
+	printKey := flag.Bool("pubkey", false, "print the node public key for registration and exit")
+	logLevel := flag.String("log-level", envOr("LOG_LEVEL", "info"), "log level, with optional component overrides (edge=debug)")
+	logFormat := flag.String("log-format", envOr("LOG_FORMAT", "text"), "log format: text or json")
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	err := logging.Setup(logging.Options{
+		Service:          "edge-agent",
+		Level:            *logLevel,
+		Format:           *logFormat,
+		SampleInitial:    10,
+		SampleThereafter: 100,
+	})
+	if err != nil {
+		logging.Fatal(logger, "Invalid logging configuration", logging.Err(err))
+	}
+
+	key, created, err := crypto.LoadOrCreateKey(*keyPath)
+	if err != nil {
+		logging.Fatal(logger, "Failed to load node key", logging.Err(err))
+	}
+	if created || *printKey {
+		pub, err := crypto.PublicKeyPEM(key)
+		if err != nil {
+			logging.Fatal(logger, "Failed to encode public key", logging.Err(err))
+		}
+		if *printKey {
+			fmt.Print(pub)
+			return
+		}
+		logger.Info("Generated node key; register its public key", "key_file", *keyPath, "public_key", pub)
+	}
+
+	if *nodeID == "" || *sourceSpec == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *batch < 1 || *batch > maxBatch {
+		logging.Fatal(logger, "Invalid -batch", "batch", *batch, "max", maxBatch)
+	}
+
+	logger.Info("Starting SilentRaven Edge Agent", "version", version, logging.Node(*nodeID))
+
+	src, err := OpenSource(*sourceSpec)
+	if err != nil {
+		logging.Fatal(logger, "Failed to open source", logging.Err(err))
+	}
+	defer src.Close()
+
+	sp, err := spool.Open(*spoolDir, spool.Options{MaxBytes: int64(*spoolMB) << 20})
+	if err != nil {
+		logging.Fatal(logger, "Failed to open spool", logging.Err(err))
+	}
+	defer sp.Close()
+	if depth := sp.Depth(); depth > 0 {
+		logger.Info("Resuming with buffered detections", "buffered", depth)
+	}
+
+	agent := &Agent{
+		nodeID:    *nodeID,
+		key:       key,
+		assembler: remoteid.NewAssembler(*nodeID),
+		spool:     sp,
+		uploader:  NewUploader(*gateway, *nodeID, *apiKey, *maxSkew),
+		started:   time.Now(),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go sp.Drain(ctx, agent.uploader.Send, *batch, 30*time.Second)
+	go agent.runHeartbeats(ctx, *heartbeat)
+
+	sourceDone := make(chan error, 1)
+	go func() { sourceDone <- agent.readSource(src) }()
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+
+	select {
+	case <-quit:
+		logger.Info("Shutting down edge agent")
+	case err := <-sourceDone:
+		if err != nil {
+			logger.Error("Source failed", logging.Err(err))
+			break
+		}
+		// A finite source is done once everything read has been uploaded
+		logger.Info("Source exhausted, waiting for uploads to finish")
+		agent.waitDrained(quit)
+	}
+
+	if depth := sp.Depth(); depth > 0 {
+		logger.Info("Detections remain buffered for the next run", "buffered", depth)
+	}
+	logger.Info("Edge agent stopped")
+}
+
+// readSource decodes, signs and spools frames until the source ends
+func (a *Agent) readSource(src Source) error {
+	for {
+		r, err := src.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		var lineErr *lineError
+		if errors.As(err, &lineErr) {
+			logger.Warn("Skipping malformed input", logging.Err(err))
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		msgs, err := remoteid.Decode(r.Payload)
+		if err != nil {
+			detectionLog.Warn("Undecodable frame", "sender", r.Sender, logging.Err(err))
+			continue
+		}
+
+		for _, packet := range a.assembler.Add(r.Sender, r.Time, msgs) {
+			if err := a.enqueue(packet, r.Time); err != nil {
+				logger.Error("Failed to buffer detection", logging.UAS(packet.UASID), logging.Err(err))
+			}
+		}
+	}
+}
+
+// enqueue signs a packet and appends it to the offline buffer
+func (a *Agent) enqueue(packet models.IncomingPacket, at time.Time) error {
+	if err := crypto.SignPacket(a.key, &packet); err != nil {
+		return err
+	}
+	value, err := json.Marshal(packet)
+	if err != nil {
+		return fmt.Errorf("failed to encode detection: %w", err)
+	}
+	if err := a.spool.Append(spool.Record{Key: []byte(packet.UASID), Value: value, Time: at}); err != nil {
+		return err
+	}
+
+	a.detections.Add(1)
+	detectionLog.Info("Detected UAS", logging.UAS(packet.UASID), "lat", packet.Latitude, "lon", packet.Longitude)
+	return nil
+}
+
+// runHeartbeats reports liveness, throughput and clock skew
+func (a *Agent) runHeartbeats(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		count := a.detections.Swap(0)
+		status := "ok"
+		if a.spool.Stats().Stuck {
+			// Detections are piling up behind a batch the gateway will not take
+			status = "degraded"
+		}
+		hb := models.NodeHeartbeat{
+			NodeID:        a.nodeID,
+			Timestamp:     time.Now().UTC().Format(time.RFC3339),
+			Firmware:      version,
+			Status:        status,
+			UptimeSeconds: int64(time.Since(a.started).Seconds()),
+			Detections:    count,
+		}
+		if err := a.uploader.Heartbeat(ctx, hb); err != nil && ctx.Err() == nil {
+			// Count the frames again in the next heartbeat
+			a.detections.Add(count)
+			logger.Warn("Heartbeat failed", logging.Err(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// waitDrained blocks until the spool is empty or a signal arrives
+func (a *Agent) waitDrained(quit <-chan os.Signal) {
+	ticker := time.NewTicker(200 * time.Millisecond)
+	defer ticker.Stop()
+
+	for a.spool.Depth() > 0 {
+		select {
+		case <-quit:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
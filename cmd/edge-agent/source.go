@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"silentraven/internal/pcap"
+	"silentraven/internal/remoteid"
+)
+
+// Reading is one Remote ID payload received by the node
+type Reading struct {
+	Sender  string
+	RSSI    int
+	Time    time.Time
+	Payload []byte // one message or a message pack
+}
+
+// Source yields Remote ID payloads. Next returns io.EOF when a finite
+// source (a capture file) is exhausted.
+type Source interface {
+	Next() (Reading, error)
+	Close() error
+}
+
+// OpenSource opens a source from its spec:
+//
+//	pcap:capture.pcap     frames from a pcap/pcapng file, timed by capture time
+//	serial:/dev/ttyUSB0   text lines from a receiver (set the baud rate with stty)
+//	udp::4000             one text line per datagram
+//
+// Text lines are "[sender] [rssi] HEX", HEX being the raw message bytes.
+func OpenSource(spec string) (Source, error) {
+	kind, arg, ok := strings.Cut(spec, ":")
+	if !ok || arg == "" {
+		return nil, fmt.Errorf("invalid source %q (want pcap:FILE, serial:DEVICE or udp:ADDR)", spec)
+	}
+
+	switch kind {
+	case "pcap":
+		return openPcapSource(arg)
+	case "serial":
+		f, err := os.Open(arg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open serial device: %w", err)
+		}
+		return &lineSource{scanner: bufio.NewScanner(f), closer: f}, nil
+	case "udp":
+		conn, err := net.ListenPacket("udp", arg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to listen on %s: %w", arg, err)
+		}
+		return &udpSource{conn: conn, buf: make([]byte, 2048)}, nil
+	}
+	return nil, fmt.Errorf("unknown source type %q", kind)
+}
+
+// pcapSource reads Wi-Fi beacon/NAN and BLE advertising captures
+type pcapSource struct {
+	f *os.File
+	r pcap.PacketReader
+}
+
+func openPcapSource(path string) (*pcapSource, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open capture: %w", err)
+	}
+	r, err := pcap.Open(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &pcapSource{f: f, r: r}, nil
+}
+
+func (s *pcapSource) Next() (Reading, error) {
+	for {
+		pkt, err := s.r.Next()
+		if err != nil {
+			return Reading{}, err
+		}
+		if frame, ok := remoteid.Extract(pkt.LinkType, pkt.Data); ok {
+			return Reading{Sender: frame.Sender, RSSI: frame.RSSI, Time: pkt.Time, Payload: frame.Payload}, nil
+		}
+	}
+}
+
+func (s *pcapSource) Close() error {
+	return s.f.Close()
+}
+
+// lineSource reads text lines from a serial receiver
+type lineSource struct {
+	scanner *bufio.Scanner
+	closer  io.Closer
+}
+
+func (s *lineSource) Next() (Reading, error) {
+	for s.scanner.Scan() {
+		line := strings.TrimSpace(s.scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		r, err := parseLine(line)
+		if err != nil {
+			return Reading{}, err
+		}
+		r.Time = time.Now()
+		return r, nil
+	}
+	if err := s.scanner.Err(); err != nil {
+		return Reading{}, fmt.Errorf("failed to read source: %w", err)
+	}
+	return Reading{}, io.EOF
+}
+
+func (s *lineSource) Close() error {
+	return s.closer.Close()
+}
+
+// udpSource receives one text line per datagram. Without an explicit
+// sender the datagram's source address is used.
+type udpSource struct {
+	conn net.PacketConn
+	buf  []byte
+}
+
+func (s *udpSource) Next() (Reading, error) {
+	n, addr, err := s.conn.ReadFrom(s.buf)
+	if err != nil {
+		return Reading{}, fmt.Errorf("failed to read datagram: %w", err)
+	}
+	r, err := parseLine(strings.TrimSpace(string(s.buf[:n])))
+	if err != nil {
+		return Reading{}, err
+	}
+	if r.Sender == "" {
+		r.Sender = addr.String()
+	}
+	r.Time = time.Now()
+	return r, nil
+}
+
+func (s *udpSource) Close() error {
+	return s.conn.Close()
+}
+
+// parseLine parses "[sender] [rssi] HEX"
+func parseLine(line string) (Reading, error) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 || len(fields) > 3 {
+		return Reading{}, &lineError{line: line, reason: "expected [sender] [rssi] HEX"}
+	}
+
+	payload, err := hex.DecodeString(fields[len(fields)-1])
+	if err != nil {
+		return Reading{}, &lineError{line: line, reason: "payload is not hex"}
+	}
+
+	r := Reading{Payload: payload}
+	if len(fields) >= 2 {
+		r.Sender = fields[0]
+	}
+	if len(fields) == 3 {
+		if r.RSSI, err = strconv.Atoi(fields[1]); err != nil {
+			return Reading{}, &lineError{line: line, reason: "invalid rssi"}
+		}
+	}
+	return r, nil
+}
+
+// lineError is a malformed receiver line; the agent skips it
+type lineError struct {
+	line   string
+	reason string
+}
+
+func (e *lineError) Error() string {
+	return fmt.Sprintf("malformed line %q: %s", e.line, e.reason)
+}
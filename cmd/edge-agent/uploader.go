@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"silentraven/internal/models"
+	"silentraven/internal/spool"
+)
+
+// batchRequest mirrors the gateway's batch upload body
+type batchRequest struct {
+	NodeID  string            `json:"node_id"`
+	Packets []json.RawMessage `json:"packets"`
+}
+
+// batchResponse is the gateway's batch result envelope
+type batchResponse struct {
+	Success bool   `json:"success"`
+	Error   string `json:"error"`
+	Data    struct {
+		Accepted int `json:"accepted"`
+		Rejected int `json:"rejected"`
+		Errors   []struct {
+			Index int    `json:"index"`
+			Error string `json:"error"`
+		} `json:"errors"`
+		ServerTime string `json:"server_time"`
+	} `json:"data"`
+}
+
+// Uploader posts spooled detections to the gateway and tracks the node's
+// clock skew from the gateway's server_time
+type Uploader struct {
+	gateway string
+	nodeID  string
+	apiKey  string
+	client  *http.Client
+	maxSkew time.Duration
+
+	mu      sync.Mutex
+	skew    time.Duration
+	hasSkew bool
+}
+
+// NewUploader creates an uploader for a gateway base URL, authenticating
+// with the node's API key
+func NewUploader(gateway, nodeID, apiKey string, maxSkew time.Duration) *Uploader {
+	return &Uploader{
+		gateway: strings.TrimRight(gateway, "/"),
+		nodeID:  nodeID,
+		apiKey:  apiKey,
+		client:  &http.Client{Timeout: 30 * time.Second},
+		maxSkew: maxSkew,
+	}
+}
+
+// Send uploads one spooled batch; it is the spool drain function, so an
+// error leaves the batch spooled for retry. Packets the gateway rejects
+// as invalid are logged and dropped, as is a batch it refuses as
+// malformed (retrying would never succeed). A batch refused as too large
+// is split in half and resent; only a single record too large to upload
+// is dropped. A refused API key keeps the batch: the key may be
+// mid-rotation or not yet registered.
+func (u *Uploader) Send(ctx context.Context, records []spool.Record) error {
+	req := batchRequest{NodeID: u.nodeID, Packets: make([]json.RawMessage, len(records))}
+	for i, rec := range records {
+		req.Packets[i] = rec.Value
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("failed to encode batch: %w", err)
+	}
+
+	sent := time.Now()
+	resp, err := u.post(ctx, "/api/v1/detections/batch", body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	received := time.Now()
+
+	// Error responses may come from a proxy and not be JSON
+	var result batchResponse
+	decodeErr := json.NewDecoder(resp.Body).Decode(&result)
+
+	switch resp.StatusCode {
+	case http.StatusRequestEntityTooLarge:
+		if len(records) > 1 {
+			// An error on the second half keeps the whole batch spooled,
+			// so the first half is sent again on retry
+			half := len(records) / 2
+			logger.Warn("Gateway refused batch as too large; splitting", "records", len(records))
+			if err := u.Send(ctx, records[:half]); err != nil {
+				return err
+			}
+			return u.Send(ctx, records[half:])
+		}
+		logger.Error("Gateway refused record as too large; dropping", "status", resp.Status, "reason", result.Error)
+		return nil
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		logger.Error("Gateway refused batch; dropping", "records", len(records), "status", resp.Status, "reason", result.Error)
+		return nil
+	case http.StatusUnauthorized, http.StatusForbidden:
+		logger.Error("Gateway refused the node API key; keeping batch", "records", len(records), "status", resp.Status, "reason", result.Error)
+		return fmt.Errorf("gateway returned %s: %s", resp.Status, result.Error)
+	}
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("gateway returned %s: %s", resp.Status, result.Error)
+	}
+	if decodeErr != nil && decodeErr != io.EOF {
+		return fmt.Errorf("invalid gateway response (%s): %w", resp.Status, decodeErr)
+	}
+
+	for _, e := range result.Data.Errors {
+		logger.Warn("Gateway rejected packet", "index", e.Index, "reason", e.Error)
+	}
+	u.observeSkew(sent, received, result.Data.ServerTime)
+
+	logger.Info("Uploaded detections", "accepted", result.Data.Accepted, "rejected", result.Data.Rejected)
+	return nil
+}
+
+// Heartbeat reports node liveness, stamped with the current clock skew
+func (u *Uploader) Heartbeat(ctx context.Context, hb models.NodeHeartbeat) error {
+	if skew, ok := u.Skew(); ok {
+		ms := skew.Milliseconds()
+		hb.ClockSkewMs = &ms
+	}
+
+	body, err := json.Marshal(hb)
+	if err != nil {
+		return fmt.Errorf("failed to encode heartbeat: %w", err)
+	}
+
+	resp, err := u.post(ctx, "/api/v1/nodes/"+u.nodeID+"/heartbeat", body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("gateway returned %s", resp.Status)
+	}
+	return nil
+}
+
+// Skew returns the last measured node-minus-gateway clock offset
+func (u *Uploader) Skew() (time.Duration, bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.skew, u.hasSkew
+}
+
+// observeSkew estimates the clock offset assuming the gateway stamped
+// server_time halfway through the round trip
+func (u *Uploader) observeSkew(sent, received time.Time, serverTime string) {
+	server, err := time.Parse(time.RFC3339Nano, serverTime)
+	if err != nil {
+		return
+	}
+	midpoint := sent.Add(received.Sub(sent) / 2)
+	skew := midpoint.Sub(server).Round(time.Millisecond)
+
+	u.mu.Lock()
+	u.skew, u.hasSkew = skew, true
+	u.mu.Unlock()
+
+	if skew > u.maxSkew || skew < -u.maxSkew {
+		logger.Warn("Node clock is off the gateway clock", "skew", skew, "max_skew", u.maxSkew)
+	}
+}
+
+func (u *Uploader) post(ctx context.Context, path string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.gateway+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if u.apiKey != "" {
+		req.Header.Set("X-API-Key", u.apiKey)
+	}
+
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach gateway: %w", err)
+	}
+	return resp, nil
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"silentraven/internal/spool"
+)
+
+func TestSendStatus(t *testing.T) {
+	tests := []struct {
+		status int
+		body   string
+		keep   bool // an error keeps the batch spooled
+	}{
+		{http.StatusOK, `{"success":true,"data":{"accepted":1}}`, false},
+		{http.StatusBadRequest, `{"success":false,"error":"Invalid JSON format"}`, false},
+		{http.StatusRequestEntityTooLarge, `<html>too large</html>`, false},
+		{http.StatusUnprocessableEntity, `{"success":false,"error":"invalid"}`, false},
+		{http.StatusUnauthorized, `{"success":false,"error":"Invalid API key"}`, true},
+		{http.StatusForbidden, `{"success":false,"error":"node key for another node"}`, true},
+		{http.StatusRequestTimeout, ``, true},
+		{http.StatusTooManyRequests, `{"success":false,"error":"slow down"}`, true},
+		{http.StatusBadGateway, `<html>bad gateway</html>`, true},
+		{http.StatusServiceUnavailable, `{"success":false,"error":"Failed to queue message"}`, true},
+	}
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if got := r.Header.Get("X-API-Key"); got != "node-key" {
+					t.Errorf("X-API-Key = %q", got)
+				}
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			u := NewUploader(srv.URL, "node-1", "node-key", time.Second)
+			err := u.Send(context.Background(), []spool.Record{{Value: []byte(`{"SN":"sn-1","UASID":"uas-1"}`)}})
+			if keep := err != nil; keep != tt.keep {
+				t.Errorf("Send error = %v; want the batch kept: %v", err, tt.keep)
+			}
+		})
+	}
+}
+
+// TestRefusedKeyIsRetried drains a spool through a gateway that refuses
+// the key until it is registered, and checks nothing is lost
+func TestRefusedKeyIsRetried(t *testing.T) {
+	var calls, delivered atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"success":false,"error":"Invalid API key"}`))
+			return
+		}
+		var req batchRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+		}
+		delivered.Add(int32(len(req.Packets)))
+		w.Write([]byte(`{"success":true,"data":{"accepted":2}}`))
+	}))
+	defer srv.Close()
+
+	sp, err := spool.Open(t.TempDir(), spool.Options{MaxBytes: 1 << 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sp.Close()
+	err = sp.AppendBatch([]spool.Record{
+		{Value: []byte(`{"SN":"sn-1","UASID":"uas-1"}`)},
+		{Value: []byte(`{"SN":"sn-1","UASID":"uas-1"}`)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	u := NewUploader(srv.URL, "node-1", "node-key", time.Second)
+	go sp.Drain(ctx, u.Send, 100, time.Second)
+
+	for sp.Depth() > 0 && ctx.Err() == nil {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if d := sp.Depth(); d != 0 {
+		t.Fatalf("spool still holds %d records", d)
+	}
+	if calls.Load() != 2 || delivered.Load() != 2 {
+		t.Errorf("%d calls delivered %d packets, want 2 calls delivering 2", calls.Load(), delivered.Load())
+	}
+}
+
+// TestTooLargeBatchIsSplit uploads through a gateway that takes at most
+// two packets per batch, and checks every record is delivered once
+func TestTooLargeBatchIsSplit(t *testing.T) {
+	var calls, delivered atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		var req batchRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+		}
+		if len(req.Packets) > 2 {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			w.Write([]byte(`{"success":false,"error":"Batch exceeds 2 packets"}`))
+			return
+		}
+		delivered.Add(int32(len(req.Packets)))
+		w.Write([]byte(`{"success":true,"data":{"accepted":2}}`))
+	}))
+	defer srv.Close()
+
+	records := make([]spool.Record, 5)
+	for i := range records {
+		records[i] = spool.Record{Value: []byte(`{"SN":"sn-1","UASID":"uas-1"}`)}
+	}
+	u := NewUploader(srv.URL, "node-1", "node-key", time.Second)
+	if err := u.Send(context.Background(), records); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	// 5 -> 2 + 3, 3 -> 1 + 2
+	if calls.Load() != 5 || delivered.Load() != 5 {
+		t.Errorf("%d calls delivered %d packets, want 5 calls delivering 5", calls.Load(), delivered.Load())
+	}
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"silentraven/internal/audit"
+	"silentraven/internal/crypto"
+	"silentraven/internal/database"
+	"silentraven/internal/evidence"
+	"silentraven/internal/models"
+	"silentraven/pkg/config"
+)
+
+const usage = `Usage:
+  evidence create -uas id -from RFC3339 -to RFC3339 [-case ref] [-o file]
+      build a signed evidence bundle of the UAS's raw packets, signature
+      checks, receiving nodes, track KML, summary and custody record
+  evidence verify [-pubkey file] bundle.zip
+      check every file against the signed manifest and every verified
+      packet signature against the bundled node keys; with -pubkey, also
+      check the bundle was signed by that key
+  evidence pubkey
+      print the public half of the signing key (EVIDENCE_KEY_FILE) to
+      hand over with bundles
+
+verify exits with status 1 if the bundle does not verify.
+`
+
+// service needs the detections and the signing key
+var service = config.Service{Name: "evidence", Needs: []config.Requirement{config.Database}}
+
+func main() {
+	flag.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	flag.Parse()
+
+	args := flag.Args()
+	if len(args) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var err error
+	switch args[0] {
+	case "create":
+		err = create(args[1:])
+	case "verify":
+		err = verify(args[1:])
+	case "pubkey":
+		err = pubkey()
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+// create builds a bundle and records it in the audit log
+func create(args []string) error {
+	fs := flag.NewFlagSet("create", flag.ExitOnError)
+	fs.Usage = flag.Usage
+	uasID := fs.String("uas", "", "UAS ID")
+	fromStr := fs.String("from", "", "window start (RFC3339)")
+	toStr := fs.String("to", "", "window end (RFC3339, default now)")
+	caseRef := fs.String("case", "", "enforcement case reference")
+	out := fs.String("o", "", "output file (default evidence_<uas>_<bundle>.zip)")
+	fs.Parse(args)
+
+	if *uasID == "" || *fromStr == "" {
+		return fmt.Errorf("create needs -uas and -from")
+	}
+	from, err := time.Parse(time.RFC3339, *fromStr)
+	if err != nil {
+		return fmt.Errorf("invalid -from: %w", err)
+	}
+	to := time.Now()
+	if *toStr != "" {
+		if to, err = time.Parse(time.RFC3339, *toStr); err != nil {
+			return fmt.Errorf("invalid -to: %w", err)
+		}
+	}
+	if !to.After(from) {
+		return fmt.Errorf("-to must be after -from")
+	}
+
+	cfg, err := config.Load(service)
+	if err != nil {
+		return err
+	}
+	key, err := signingKey(cfg)
+	if err != nil {
+		return err
+	}
+	db, err := database.New(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	// Write to a temporary file first: the bundle ID is only known once
+	// it is built
+	dir := "."
+	if *out != "" {
+		dir = filepath.Dir(*out)
+	}
+	tmp, err := os.CreateTemp(dir, ".evidence-*.zip")
+	if err != nil {
+		return fmt.Errorf("failed to create bundle file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	req := evidence.Request{
+		UASID:         *uasID,
+		From:          from,
+		To:            to,
+		CaseRef:       *caseRef,
+		Collector:     audit.Operator(),
+		CollectorKind: audit.KindOperator,
+		Tool:          "silentraven-evidence",
+	}
+	bundle, err := evidence.Build(tmp, db, req, key)
+	if cerr := tmp.Close(); err == nil && cerr != nil {
+		err = fmt.Errorf("failed to write bundle: %w", cerr)
+	}
+	if err != nil {
+		return err
+	}
+	s := bundle.Summary
+	path := *out
+	if path == "" {
+		path = fmt.Sprintf("evidence_%s_%s.zip", *uasID, s.BundleID)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write bundle: %w", err)
+	}
+
+	e := &models.AuditEvent{
+		Actor:     req.Collector,
+		ActorKind: req.CollectorKind,
+		Action:    audit.ActionEvidence,
+		Resource:  "evidence " + s.BundleID,
+	}
+	detail := map[string]any{
+		"sha256": bundle.SHA256, "case": *caseRef, "from": from, "to": to,
+		"rows": s.Detections, "uas_ids": []string{*uasID},
+	}
+	if err := audit.New(db).Record(e, detail); err != nil {
+		fmt.Fprintln(os.Stderr, "warning: bundle not audited:", err)
+	}
+
+	fmt.Printf("Bundle:     %s\n", s.BundleID)
+	fmt.Printf("File:       %s (%d bytes)\n", path, bundle.Bytes)
+	fmt.Printf("SHA-256:    %s\n", bundle.SHA256)
+	fmt.Printf("Detections: %d from %s to %s\n", s.Detections,
+		s.FirstSeen.Format(time.RFC3339), s.LastSeen.Format(time.RFC3339))
+	fmt.Printf("Signatures: %s\n", counts(s.Signatures))
+	return nil
+}
+
+// verify checks a bundle
+func verify(args []string) error {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	fs.Usage = flag.Usage
+	pubFile := fs.String("pubkey", "", "PEM public key the bundle must be signed with")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return fmt.Errorf("verify needs a bundle file")
+	}
+
+	var trusted *ecdsa.PublicKey
+	if *pubFile != "" {
+		data, err := os.ReadFile(*pubFile)
+		if err != nil {
+			return fmt.Errorf("failed to read public key: %w", err)
+		}
+		if trusted, err = crypto.ParsePublicKey(string(data)); err != nil {
+			return err
+		}
+	}
+
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("failed to open bundle: %w", err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to open bundle: %w", err)
+	}
+
+	report, err := evidence.Verify(f, info.Size(), trusted)
+	if err != nil {
+		return err
+	}
+	c := report.Custody
+	fmt.Printf("Bundle:    %s\n", c.BundleID)
+	if c.CaseRef != "" {
+		fmt.Printf("Case:      %s\n", c.CaseRef)
+	}
+	fmt.Printf("Collected: %s by %s (%s) with %s on %s\n",
+		c.CollectedAt.Format(time.RFC3339), c.Collector, c.CollectorKind, c.Tool, c.Host)
+	fmt.Printf("Query:     %s from %s to %s\n", c.Query.UASID,
+		c.Query.From.Format(time.RFC3339), c.Query.To.Format(time.RFC3339))
+	fmt.Printf("Signer:    %s\n", report.SignerFingerprint)
+	fmt.Printf("Files:     %d\n", len(report.Files))
+	fmt.Printf("Packets:   %d signatures checked against the bundled node keys\n", report.PacketsVerified)
+	if !report.OK() {
+		fmt.Println("FAILED:")
+		for _, p := range report.Problems {
+			fmt.Println("  " + p)
+		}
+		return fmt.Errorf("bundle does not verify")
+	}
+	if trusted == nil {
+		fmt.Println("OK: intact and signed by the bundled key; pass -pubkey to check who signed it")
+	} else {
+		fmt.Println("OK: intact and signed by the trusted key")
+	}
+	return nil
+}
+
+// pubkey prints the signing key's public half
+func pubkey() error {
+	cfg, err := config.Load(config.Service{Name: "evidence"})
+	if err != nil {
+		return err
+	}
+	key, err := signingKey(cfg)
+	if err != nil {
+		return err
+	}
+	pub, err := crypto.PublicKeyPEM(key)
+	if err != nil {
+		return err
+	}
+	fingerprint, err := crypto.Fingerprint(&key.PublicKey)
+	if err != nil {
+		return err
+	}
+	fmt.Print(pub)
+	fmt.Fprintf(os.Stderr, "Fingerprint: %s\n", fingerprint)
+	return nil
+}
+
+// signingKey loads the evidence key, creating it on first use
+func signingKey(cfg *config.Config) (*ecdsa.PrivateKey, error) {
+	key, created, err := crypto.LoadOrCreateKey(cfg.EvidenceKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	if created {
+		fmt.Fprintf(os.Stderr, "Generated evidence signing key %s\n", cfg.EvidenceKeyFile)
+	}
+	return key, nil
+}
+
+// counts formats signature check counts, e.g. "verified 10, unsigned 2"
+func counts(byStatus map[string]int) string {
+	statuses := make([]string, 0, len(byStatus))
+	for s := range byStatus {
+		statuses = append(statuses, s)
+	}
+	sort.Strings(statuses)
+	out := ""
+	for i, s := range statuses {
+		if i > 0 {
+			out += ", "
+		}
+		out += fmt.Sprintf("%s %d", s, byStatus[s])
+	}
+	return out
+}
@@ -2,56 +2,92 @@ package main
 
 import (
 	"context"
-	"encoding/json"
-	"log"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
-	"github.com/gorilla/mux"
 	"github.com/rs/cors"
-	"github.com/segmentio/kafka-go"
 
-	"silentraven/internal/models"
+	"silentraven/internal/auth"
+	"silentraven/internal/gateway"
+	"silentraven/internal/logging"
+	"silentraven/internal/metrics"
+	"silentraven/internal/queue"
+	"silentraven/internal/reload"
+	"silentraven/internal/storage"
+	"silentraven/internal/tracing"
 	"silentraven/pkg/config"
 )
 
-type Gateway struct {
-	config *config.Config
-	writer *kafka.Writer
-	router *mux.Router
-}
+var logger = logging.For("gateway")
 
 func main() {
-	log.Println("🚀 Starting SilentRaven Gateway Service...")
-
 	// Load configuration
-	cfg, err := config.Load()
+	cfg, err := config.Load(config.Gateway)
 	if err != nil {
-		log.Fatal("Failed to load configuration:", err)
+		logging.Fatal(logger, "Failed to load configuration", logging.Err(err))
+	}
+	if err := logging.Configure(cfg, "gateway"); err != nil {
+		logging.Fatal(logger, "Invalid logging configuration", logging.Err(err))
+	}
+	logger.Info("Starting SilentRaven Gateway Service")
+
+	// Export traces to the configured collector
+	shutdownTracing, err := tracing.Init(context.Background(), "gateway", cfg)
+	if err != nil {
+		logging.Fatal(logger, "Failed to set up tracing", logging.Err(err))
+	}
+	defer shutdownTracing()
+
+	// Serve /metrics on its own port, away from the node-facing API
+	metrics.Serve(cfg.GatewayMetricsAddr)
+
+	// Apply logging changes on SIGHUP
+	go reload.Watch(context.Background(), config.Gateway, cfg, reload.Hooks{})
+
+	// Check node and client API keys against the key store
+	var authn *auth.Authenticator
+	if cfg.AuthMode == "off" {
+		logger.Warn("Authentication is off; the gateway accepts detections from anyone")
+	} else {
+		store, err := storage.Open(cfg)
+		if err != nil {
+			logging.Fatal(logger, "Failed to open key store", logging.Err(err))
+		}
+		defer store.Close()
+		var secret []byte
+		if cfg.APISecret != "" {
+			secret = []byte(cfg.APISecret)
+		}
+		authn = auth.New(store, secret)
 	}
 
 	// Create gateway instance
-	gateway := NewGateway(cfg)
-	defer gateway.Close()
+	gw, err := gateway.New(cfg, queue.NewDetectionWriter(cfg), queue.NewHeartbeatWriter(cfg), authn)
+	if err != nil {
+		logging.Fatal(logger, "Failed to create gateway", logging.Err(err))
+	}
+	defer gw.Close()
+	logger.Info("Publishing to Redpanda", "brokers", cfg.KafkaBrokers, "topic", cfg.KafkaTopic)
 
-	// Setup routes
-	gateway.setupRoutes()
+	// Forward spooled detections once Redpanda is reachable
+	drainCtx, stopDrain := context.WithCancel(context.Background())
+	defer stopDrain()
+	go gw.Drain(drainCtx)
 
 	// Setup CORS
 	corsHandler := cors.New(cors.Options{
-		AllowedOrigins:   []string{"*"},
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"*"},
-		AllowCredentials: true,
+		AllowedOrigins: cfg.CORSOrigins,
+		AllowedMethods: []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowedHeaders: []string{"Authorization", "Content-Type", auth.APIKeyHeader},
 	})
 
 	// Create HTTP server
 	server := &http.Server{
 		Addr:         cfg.GetAPIAddress(),
-		Handler:      corsHandler.Handler(gateway.router),
+		Handler:      corsHandler.Handler(gw.Handler()),
 		ReadTimeout:  15 * time.Second,
 		WriteTimeout: 15 * time.Second,
 		IdleTimeout:  60 * time.Second,
@@ -59,10 +95,9 @@ func main() {
 
 	// Start server in goroutine
 	go func() {
-		log.Printf("✅ Gateway listening on %s", cfg.GetAPIAddress())
-		log.Println("📡 Ready to receive drone detections")
+		logger.Info("Gateway listening", "addr", cfg.GetAPIAddress())
 		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatal("Failed to start server:", err)
+			logging.Fatal(logger, "Failed to start server", logging.Err(err))
 		}
 	}()
 
@@ -71,169 +106,15 @@ func main() {
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
-	log.Println("🛑 Shutting down gateway...")
+	logger.Info("Shutting down gateway")
 
 	// Graceful shutdown
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
 	if err := server.Shutdown(ctx); err != nil {
-		log.Fatal("Server forced to shutdown:", err)
+		logging.Fatal(logger, "Server forced to shutdown", logging.Err(err))
 	}
 
-	log.Println("✅ Gateway stopped gracefully")
-}
-
-// NewGateway creates a new gateway instance
-func NewGateway(cfg *config.Config) *Gateway {
-	// Create Kafka writer for Redpanda
-	writer := &kafka.Writer{
-		Addr:         kafka.TCP(cfg.KafkaBrokers),
-		Topic:        cfg.KafkaTopic,
-		Balancer:     &kafka.LeastBytes{},
-		BatchSize:    10,
-		BatchTimeout: 10 * time.Millisecond,
-		RequiredAcks: kafka.RequireOne,
-		Async:        false,
-	}
-
-	log.Printf("✅ Connected to Redpanda at %s", cfg.KafkaBrokers)
-
-	return &Gateway{
-		config: cfg,
-		writer: writer,
-		router: mux.NewRouter(),
-	}
-}
-
-// Close closes all connections
-func (g *Gateway) Close() {
-	if g.writer != nil {
-		g.writer.Close()
-	}
-}
-
-// setupRoutes configures HTTP routes
-func (g *Gateway) setupRoutes() {
-	// Health check
-	g.router.HandleFunc("/health", g.handleHealth).Methods("GET")
-
-	// Receive drone detection
-	g.router.HandleFunc("/api/v1/detection", g.handleDetection).Methods("POST")
-
-	// Test endpoint
-	g.router.HandleFunc("/api/v1/test", g.handleTest).Methods("GET")
-}
-
-// handleHealth returns service health status
-func (g *Gateway) handleHealth(w http.ResponseWriter, r *http.Request) {
-	response := models.APIResponse{
-		Success: true,
-		Message: "Gateway service is healthy",
-		Data: map[string]string{
-			"service": "gateway",
-			"status":  "running",
-			"version": "1.0.0",
-		},
-	}
-	sendJSON(w, http.StatusOK, response)
-}
-
-// handleTest returns test response
-func (g *Gateway) handleTest(w http.ResponseWriter, r *http.Request) {
-	response := models.APIResponse{
-		Success: true,
-		Message: "Test endpoint working",
-		Data: map[string]string{
-			"timestamp": time.Now().Format(time.RFC3339),
-		},
-	}
-	sendJSON(w, http.StatusOK, response)
-}
-
-// handleDetection processes incoming drone detection
-func (g *Gateway) handleDetection(w http.ResponseWriter, r *http.Request) {
-	// Parse incoming packet
-	var packet models.IncomingPacket
-	if err := json.NewDecoder(r.Body).Decode(&packet); err != nil {
-		log.Printf("❌ Invalid JSON: %v", err)
-		response := models.APIResponse{
-			Success: false,
-			Error:   "Invalid JSON format",
-		}
-		sendJSON(w, http.StatusBadRequest, response)
-		return
-	}
-
-	// Validate required fields
-	if packet.SN == "" || packet.UASID == "" {
-		log.Println("❌ Missing required fields")
-		response := models.APIResponse{
-			Success: false,
-			Error:   "Missing required fields: SN or UASID",
-		}
-		sendJSON(w, http.StatusBadRequest, response)
-		return
-	}
-
-	// Add timestamp if not present
-	if packet.Timestamp == "" {
-		packet.Timestamp = time.Now().Format(time.RFC3339)
-	}
-
-	// Log received detection
-	log.Printf("📡 Received detection: UASID=%s, SN=%s, Type=%s",
-		packet.UASID, packet.SN, packet.DroneType)
-
-	// Convert to JSON for Kafka
-	packetJSON, err := json.Marshal(packet)
-	if err != nil {
-		log.Printf("❌ Failed to marshal packet: %v", err)
-		response := models.APIResponse{
-			Success: false,
-			Error:   "Internal processing error",
-		}
-		sendJSON(w, http.StatusInternalServerError, response)
-		return
-	}
-
-	// Publish to Redpanda
-	err = g.writer.WriteMessages(context.Background(),
-		kafka.Message{
-			Key:   []byte(packet.UASID),
-			Value: packetJSON,
-			Time:  time.Now(),
-		},
-	)
-
-	if err != nil {
-		log.Printf("❌ Failed to publish to Redpanda: %v", err)
-		response := models.APIResponse{
-			Success: false,
-			Error:   "Failed to queue message",
-		}
-		sendJSON(w, http.StatusInternalServerError, response)
-		return
-	}
-
-	log.Printf("✅ Published to Redpanda: %s", packet.UASID)
-
-	// Send success response
-	response := models.APIResponse{
-		Success: true,
-		Message: "Detection received and queued",
-		Data: map[string]string{
-			"uas_id":    packet.UASID,
-			"sn":        packet.SN,
-			"timestamp": packet.Timestamp,
-		},
-	}
-	sendJSON(w, http.StatusOK, response)
-}
-
-// sendJSON sends JSON response
-func sendJSON(w http.ResponseWriter, statusCode int, data interface{}) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(statusCode)
-	json.NewEncoder(w).Encode(data)
+	logger.Info("Gateway stopped")
 }
@@ -2,47 +2,69 @@ package main
 
 import (
 	"context"
-	"encoding/json"
-	"log"
 	"os"
 	"os/signal"
-	"silentraven/internal/database"
-	"silentraven/internal/models"
+	"silentraven/internal/ingestion"
+	"silentraven/internal/logging"
+	"silentraven/internal/metrics"
+	"silentraven/internal/nodes"
+	"silentraven/internal/queue"
+	"silentraven/internal/reload"
+	"silentraven/internal/storage"
+	"silentraven/internal/tracing"
 	"silentraven/pkg/config"
 	"syscall"
-	"time"
-
-	"github.com/segmentio/kafka-go"
 )
 
-type IngestionService struct {
-	config *config.Config
-	db     *database.DB
-	reader *kafka.Reader
-}
+var logger = logging.For("ingestion")
 
 func main() {
-	log.Println("🚀 Starting SilentRaven Ingestion Service...")
-
 	// Load configuration
-	cfg, err := config.Load()
+	cfg, err := config.Load(config.Ingestion)
 	if err != nil {
-		log.Fatal("Failed to load configuration:", err)
+		logging.Fatal(logger, "Failed to load configuration", logging.Err(err))
 	}
+	if err := logging.Configure(cfg, "ingestion"); err != nil {
+		logging.Fatal(logger, "Invalid logging configuration", logging.Err(err))
+	}
+	logger.Info("Starting SilentRaven Ingestion Service")
 
-	// Connect to database
-	db, err := database.New(cfg)
+	// Export traces to the configured collector
+	shutdownTracing, err := tracing.Init(context.Background(), "ingestion", cfg)
 	if err != nil {
-		log.Fatal("Failed to connect to database:", err)
+		logging.Fatal(logger, "Failed to set up tracing", logging.Err(err))
+	}
+	defer shutdownTracing()
+
+	// Connect to storage (Postgres unless STORAGE_BACKEND=memory)
+	store, err := storage.Open(cfg)
+	if err != nil {
+		logging.Fatal(logger, "Failed to connect to database", logging.Err(err))
+	}
+	defer store.Close()
+
+	// Track sensor node liveness
+	var alerter nodes.Alerter
+	if cfg.NodeAlertWebhook != "" {
+		alerter = nodes.NewWebhookAlerter(cfg.NodeAlertWebhook)
+	}
+	monitor, err := nodes.NewMonitor(store, nodes.Thresholds{
+		DegradedAfter: cfg.NodeDegradedAfter,
+		OfflineAfter:  cfg.NodeOfflineAfter,
+	}, alerter)
+	if err != nil {
+		logging.Fatal(logger, "Invalid node monitoring configuration", logging.Err(err))
 	}
-	defer db.Close()
 
 	// Create ingestion service
-	service := NewIngestionService(cfg, db)
+	service := ingestion.NewService(store, queue.NewDetectionReader(cfg), queue.NewHeartbeatReader(cfg), monitor)
 	defer service.Close()
+	logger.Info("Consuming from Redpanda", "brokers", cfg.KafkaBrokers,
+		"topics", []string{cfg.KafkaTopic, cfg.KafkaHeartbeatTopic})
 
-	log.Println("✅ Ingestion service started successfully")
-	log.Println("📡 Listening for drone detections from Redpanda...")
+	metrics.Serve(cfg.IngestionMetricsAddr)
+
+	logger.Info("Ingestion service started")
 
 	// Start consuming messages
 	ctx, cancel := context.WithCancel(context.Background())
@@ -54,127 +76,21 @@ func main() {
 
 	go func() {
 		<-sigChan
-		log.Println("\n🛑 Shutdown signal received...")
+		logger.Info("Shutdown signal received")
 		cancel()
 	}()
 
-	// Start processing
-	if err := service.ProcessMessages(ctx); err != nil {
-		log.Fatal("Processing failed:", err)
-	}
-
-	log.Println("✅ Ingestion service stopped gracefully")
-}
-
-// NewIngestionService creates a new ingestion service
-func NewIngestionService(cfg *config.Config, db *database.DB) *IngestionService {
-	reader := kafka.NewReader(kafka.ReaderConfig{
-		Brokers:        []string{cfg.KafkaBrokers},
-		Topic:          cfg.KafkaTopic,
-		GroupID:        "silentraven-ingestion",
-		MinBytes:       10e3, // 10KB
-		MaxBytes:       10e6, // 10MB
-		CommitInterval: time.Second,
-		StartOffset:    kafka.LastOffset,
-	})
-
-	log.Printf("✅ Connected to Redpanda topic: %s", cfg.KafkaTopic)
-
-	return &IngestionService{
-		config: cfg,
-		db:     db,
-		reader: reader,
-	}
-}
+	// Apply logging changes on SIGHUP
+	go reload.Watch(ctx, config.Ingestion, cfg, reload.Hooks{})
 
-// Close closes all connections
-func (s *IngestionService) Close() {
-	if s.reader != nil {
-		s.reader.Close()
-	}
-}
+	// Node heartbeats and status checks run alongside detections
+	go service.ProcessHeartbeats(ctx)
+	go monitor.Run(ctx, cfg.NodeCheckInterval)
 
-// ProcessMessages reads and processes messages from Redpanda
-func (s *IngestionService) ProcessMessages(ctx context.Context) error {
-	messageCount := 0
-
-	for {
-		select {
-		case <-ctx.Done():
-			log.Printf("📊 Processed %d messages total", messageCount)
-			return nil
-		default:
-			// Read message with timeout
-			m, err := s.reader.FetchMessage(ctx)
-			if err != nil {
-				if err == context.Canceled {
-					return nil
-				}
-				log.Printf("❌ Error fetching message: %v", err)
-				time.Sleep(time.Second)
-				continue
-			}
-
-			// Process the message
-			if err := s.processMessage(m); err != nil {
-				log.Printf("❌ Error processing message: %v", err)
-			} else {
-				messageCount++
-			}
-
-			// Commit the message
-			if err := s.reader.CommitMessages(ctx, m); err != nil {
-				log.Printf("⚠️  Failed to commit message: %v", err)
-			}
-		}
-	}
-}
-
-// processMessage processes a single message
-func (s *IngestionService) processMessage(m kafka.Message) error {
-	// Parse incoming packet
-	var packet models.IncomingPacket
-	if err := json.Unmarshal(m.Value, &packet); err != nil {
-		return err
-	}
-
-	log.Printf("📥 Processing: UASID=%s, SN=%s, Type=%s",
-		packet.UASID, packet.SN, packet.DroneType)
-
-	// Convert to database model
-	detection := &models.DroneDetection{
-		DetectionTime:     time.Now(),
-		SN:                packet.SN,
-		UASID:             packet.UASID,
-		DroneType:         packet.DroneType,
-		Latitude:          packet.Latitude,
-		Longitude:         packet.Longitude,
-		Height:            packet.Height,
-		Direction:         packet.Direction,
-		SpeedHorizontal:   packet.SpeedHorizontal,
-		SpeedVertical:     packet.SpeedVertical,
-		OperatorLatitude:  packet.OperatorLatitude,
-		OperatorLongitude: packet.OperatorLongitude,
-		NodeID:            packet.NodeID,
-		Signature:         packet.Signature,
-	}
-
-	// Parse timestamp if provided
-	if packet.Timestamp != "" {
-		if ts, err := time.Parse(time.RFC3339, packet.Timestamp); err == nil {
-			detection.DetectionTime = ts
-		}
-	}
-
-	// Store raw JSON data
-	rawJSON, _ := json.Marshal(packet)
-	detection.RawData = string(rawJSON)
-
-	// Insert into database
-	if err := s.db.InsertDroneDetection(detection); err != nil {
-		return err
+	// Start processing
+	if err := service.ProcessMessages(ctx); err != nil {
+		logging.Fatal(logger, "Processing failed", logging.Err(err))
 	}
 
-	log.Printf("✅ Stored detection ID=%d, UASID=%s", detection.ID, detection.UASID)
-	return nil
+	logger.Info("Ingestion service stopped")
 }
@@ -0,0 +1,600 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"silentraven/internal/database"
+	"silentraven/internal/gateway"
+	"silentraven/internal/ingestion"
+	"silentraven/internal/logging"
+	"silentraven/internal/models"
+	"silentraven/internal/queue"
+	"silentraven/internal/storage"
+	"silentraven/internal/tracing"
+	"silentraven/pkg/config"
+)
+
+const usage = `Usage:
+  loadtest [flags]
+
+Drives the gateway at a fixed rate and measures end-to-end latency from
+POST to stored row. Every detection carries a tagged serial number
+(BENCH-<run>-<seq>) so its row can be matched to the request.
+
+By default the gateway and ingestion run in-process, with -queue and
+-store choosing real (kafka, postgres) or in-memory stand-ins. With
+-gateway the target is a running deployment and rows are observed by
+polling Postgres; latency then includes any clock offset between this
+host and the database.
+
+With -trace the in-process services record every span in memory, and the
+report counts spans per stage and traces that reached the database.
+
+Flags:
+`
+
+// Run is one load test
+type Run struct {
+	id      string
+	target  string
+	apiKey  string
+	batch   int
+	drones  int
+	client  *http.Client
+	started time.Time
+
+	mu     sync.Mutex
+	sentAt map[int64]time.Time // seq -> POST time, until stored
+
+	next     atomic.Int64
+	accepted atomic.Int64
+	failed   atomic.Int64
+	shed     atomic.Int64
+	stored   atomic.Int64
+
+	httpLatency Recorder
+	e2eLatency  Recorder
+}
+
+// Result is the final report, also printed as JSON with -json
+type Result struct {
+	RunID      string         `json:"run_id"`
+	Mode       string         `json:"mode"`
+	Rate       float64        `json:"offered_rate"`
+	Duration   time.Duration  `json:"duration_ns"`
+	Sent       int64          `json:"sent"`
+	Accepted   int64          `json:"accepted"`
+	Failed     int64          `json:"failed"`
+	Shed       int64          `json:"shed"`
+	Stored     int64          `json:"stored"`
+	Lost       int64          `json:"lost"`
+	Throughput float64        `json:"stored_per_second"`
+	HTTP       Summary        `json:"http_latency"`
+	EndToEnd   Summary        `json:"e2e_latency"`
+	Spans      map[string]int `json:"spans,omitempty"`
+}
+
+func main() {
+	target := flag.String("gateway", "", "gateway base URL to load (default: run the pipeline in-process)")
+	apiKey := flag.String("api-key", os.Getenv("GATEWAY_API_KEY"), "API key for -gateway")
+	queueKind := flag.String("queue", "memory", "in-process queue: memory or kafka")
+	storeKind := flag.String("store", "memory", "in-process store: memory or postgres")
+	rate := flag.Float64("rate", 200, "detections per second")
+	duration := flag.Duration("duration", 30*time.Second, "how long to send (use hours for a soak test)")
+	batch := flag.Int("batch", 1, "detections per request (>1 uses the batch endpoint)")
+	workers := flag.Int("workers", 32, "concurrent requests")
+	drones := flag.Int("drones", 50, "distinct UAS IDs")
+	reportEvery := flag.Duration("report", 5*time.Second, "progress report interval")
+	drain := flag.Duration("drain", 30*time.Second, "how long to wait for outstanding rows after sending")
+	jsonOut := flag.Bool("json", false, "print the final result as JSON on stdout")
+	verbose := flag.Bool("verbose", false, "keep the in-process services' logs")
+	traced := flag.Bool("trace", false, "record spans in memory and report them (in-process only)")
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *rate <= 0 || *duration <= 0 || *batch <= 0 || *batch > 1000 || *workers <= 0 || *drones <= 0 ||
+		(*traced && *target != "") {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	// Progress goes to stderr; the services' per-detection logs are muted
+	out := log.New(os.Stderr, "", log.LstdFlags)
+	if !*verbose {
+		logging.Setup(logging.Options{Service: "loadtest", Output: io.Discard})
+	}
+
+	run := &Run{
+		id:     strconv.FormatInt(time.Now().Unix()%1e6, 36),
+		apiKey: *apiKey,
+		batch:  *batch,
+		drones: *drones,
+		client: &http.Client{
+			Timeout:   30 * time.Second,
+			Transport: &http.Transport{MaxIdleConnsPerHost: *workers},
+		},
+		sentAt: make(map[int64]time.Time),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var spans *tracetest.InMemoryExporter
+	var tp *sdktrace.TracerProvider
+	if *traced {
+		spans = tracetest.NewInMemoryExporter()
+		tp = tracing.Setup("loadtest", spans, 1)
+	}
+
+	var mode string
+	if *target != "" {
+		mode = "external"
+		cfg, err := config.Load(config.Service{Name: "loadtest", Needs: []config.Requirement{config.Database}})
+		if err != nil {
+			out.Fatal("Failed to load configuration:", err)
+		}
+		store, err := database.New(cfg)
+		if err != nil {
+			out.Fatal("Failed to connect to database:", err)
+		}
+		defer store.Close()
+
+		run.target = strings.TrimRight(*target, "/")
+		go run.poll(ctx, store, time.Now().Add(-time.Second), 250*time.Millisecond)
+	} else {
+		mode = fmt.Sprintf("in-process (queue=%s, store=%s)", *queueKind, *storeKind)
+		url, stop, err := startPipeline(ctx, *queueKind, *storeKind, run)
+		if err != nil {
+			out.Fatal("Failed to start pipeline:", err)
+		}
+		defer stop()
+		run.target = url
+	}
+
+	out.Printf("🚀 Load test %s: %.0f detections/s for %s against %s", run.id, *rate, *duration, mode)
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	sendCtx, stopSending := context.WithTimeout(ctx, *duration)
+	go func() {
+		select {
+		case <-quit:
+			out.Println("🛑 Interrupted, stopping load...")
+			stopSending()
+		case <-sendCtx.Done():
+		}
+	}()
+
+	run.started = time.Now()
+	done := make(chan struct{})
+	go run.report(out, *reportEvery, done)
+	run.generate(sendCtx, *rate, *workers)
+	sendTime := time.Since(run.started)
+
+	// Wait for the pipeline to catch up
+	deadline := time.Now().Add(*drain)
+	for run.outstanding() > 0 && time.Now().Before(deadline) {
+		time.Sleep(100 * time.Millisecond)
+	}
+	close(done)
+
+	total := time.Since(run.started)
+	result := Result{
+		RunID:    run.id,
+		Mode:     mode,
+		Rate:     *rate,
+		Duration: sendTime,
+		Sent:     run.next.Load(),
+		Accepted: run.accepted.Load(),
+		Failed:   run.failed.Load(),
+		Shed:     run.shed.Load(),
+		Stored:   run.stored.Load(),
+		Lost:     int64(run.outstanding()),
+		HTTP:     run.httpLatency.Total(),
+		EndToEnd: run.e2eLatency.Total(),
+	}
+	result.Throughput = float64(result.Stored) / total.Seconds()
+	if tp != nil {
+		tp.ForceFlush(context.Background())
+		result.Spans = countSpans(spans.GetSpans())
+	}
+
+	out.Printf("📊 Sent %d in %s: %d accepted, %d failed, %d shed by the client",
+		result.Sent, sendTime.Round(time.Millisecond), result.Accepted, result.Failed, result.Shed)
+	out.Printf("📊 Stored %d (%.1f/s), %d lost", result.Stored, result.Throughput, result.Lost)
+	out.Printf("📊 HTTP        %s", result.HTTP)
+	out.Printf("📊 End-to-end  %s", result.EndToEnd)
+	if result.Spans != nil {
+		out.Printf("📊 Spans       %s", formatSpans(result.Spans))
+	}
+
+	if *jsonOut {
+		json.NewEncoder(os.Stdout).Encode(result)
+	}
+	if result.Lost > 0 || result.Failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// pipelineService declares what the in-process pipeline needs from the
+// configuration for the chosen queue and store
+func pipelineService(queueKind, storeKind string) config.Service {
+	svc := config.Service{Name: "loadtest"}
+	if queueKind == "kafka" {
+		svc.Needs = append(svc.Needs, config.Kafka)
+	}
+	if storeKind == "postgres" {
+		svc.Needs = append(svc.Needs, config.Database)
+	}
+	return svc
+}
+
+// startPipeline runs the gateway and ingestion in-process over the chosen
+// queue and store and returns the gateway's URL
+func startPipeline(ctx context.Context, queueKind, storeKind string, run *Run) (string, func(), error) {
+	cfg := &config.Config{KafkaTopic: "detections", KafkaHeartbeatTopic: "node-heartbeats"}
+	if queueKind == "kafka" || storeKind == "postgres" {
+		loaded, err := config.Load(pipelineService(queueKind, storeKind))
+		if err != nil {
+			return "", nil, err
+		}
+		cfg = loaded
+	}
+	// Measure the pipeline itself, not the disk spool
+	cfg.SpoolMaxMB = 0
+
+	var store storage.Store
+	switch storeKind {
+	case "memory":
+		store = storage.NewMemoryStore()
+	case "postgres":
+		db, err := database.New(cfg)
+		if err != nil {
+			return "", nil, err
+		}
+		store = db
+	default:
+		return "", nil, fmt.Errorf("unknown store %q", storeKind)
+	}
+
+	var writer, heartbeatWriter queue.Writer
+	var reader queue.Reader
+	switch queueKind {
+	case "memory":
+		topic := queue.NewMemoryTopic(cfg.KafkaTopic, 10000)
+		writer, reader = topic, topic
+		heartbeatWriter = queue.NewMemoryTopic(cfg.KafkaHeartbeatTopic, 100)
+	case "kafka":
+		writer, reader = queue.NewDetectionWriter(cfg), queue.NewDetectionReader(cfg)
+		heartbeatWriter = queue.NewHeartbeatWriter(cfg)
+	default:
+		store.Close()
+		return "", nil, fmt.Errorf("unknown queue %q", queueKind)
+	}
+
+	gw, err := gateway.New(cfg, writer, heartbeatWriter, nil)
+	if err != nil {
+		store.Close()
+		return "", nil, err
+	}
+
+	// Time each row as it is stored
+	tapped := &tapStore{Store: store, observe: run.observe}
+	service := ingestion.NewService(tapped, reader, nil, nil)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		store.Close()
+		return "", nil, err
+	}
+	server := &http.Server{Handler: gw.Handler()}
+	go server.Serve(listener)
+
+	pipeCtx, stopPipe := context.WithCancel(ctx)
+	go service.ProcessMessages(pipeCtx)
+
+	stop := func() {
+		server.Close()
+		stopPipe()
+		gw.Close()
+		reader.Close()
+		store.Close()
+	}
+	return "http://" + listener.Addr().String(), stop, nil
+}
+
+// countSpans counts spans by name, plus the traces started by the
+// gateway that reached a database insert
+func countSpans(spans tracetest.SpanStubs) map[string]int {
+	counts := make(map[string]int)
+	started := make(map[string]bool)
+	stored := make(map[string]bool)
+	for _, s := range spans {
+		name := s.Name
+		if strings.HasPrefix(name, "publish ") {
+			name = "publish" // the topic varies with -queue
+		}
+		counts[name]++
+		id := s.SpanContext.TraceID().String()
+		switch s.Name {
+		case "gateway.detection", "gateway.detection_batch":
+			started[id] = true
+		case "db.insert":
+			stored[id] = true
+		}
+	}
+
+	for id := range started {
+		if stored[id] {
+			counts["complete_traces"]++
+		}
+	}
+	counts["traces"] = len(started)
+	return counts
+}
+
+// formatSpans lists span counts in pipeline order
+func formatSpans(counts map[string]int) string {
+	var parts []string
+	for _, name := range []string{"gateway.detection", "gateway.detection_batch", "publish", "ingestion.process", "db.insert"} {
+		if n, ok := counts[name]; ok {
+			parts = append(parts, fmt.Sprintf("%s %d", name, n))
+		}
+	}
+	return fmt.Sprintf("%s | %d/%d traces stored", strings.Join(parts, ", "), counts["complete_traces"], counts["traces"])
+}
+
+// tapStore reports every stored detection to the run
+type tapStore struct {
+	storage.Store
+	observe func(sn string, at time.Time)
+}
+
+func (t *tapStore) InsertDroneDetection(d *models.DroneDetection) error {
+	if err := t.Store.InsertDroneDetection(d); err != nil {
+		return err
+	}
+	t.observe(d.SN, time.Now())
+	return nil
+}
+
+// poll observes tagged rows in an external database. Each pass re-reads
+// a short window behind the newest detection, since rows can commit out
+// of detection-time order.
+func (r *Run) poll(ctx context.Context, store storage.DetectionReader, since time.Time, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	watermark := since
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		from := watermark.Add(-10 * time.Second)
+		if from.Before(since) {
+			from = since
+		}
+		f := database.DetectionFilter{From: from, Ascending: true, Limit: 1000}
+		for {
+			page, err := store.QueryDetections(f)
+			if err != nil {
+				break
+			}
+			for _, d := range page.Detections {
+				r.observe(d.SN, d.CreatedAt)
+				if d.DetectionTime.After(watermark) {
+					watermark = d.DetectionTime
+				}
+			}
+			if page.NextCursor == "" {
+				break
+			}
+			f.Cursor = page.NextCursor
+		}
+	}
+}
+
+// generate issues requests at rate until ctx ends. It is open loop: when
+// every worker is busy the request is shed and counted rather than
+// delayed, so a slow gateway cannot lower the offered rate unnoticed.
+func (r *Run) generate(ctx context.Context, rate float64, workers int) {
+	jobs := make(chan int, workers)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := range jobs {
+				r.send(n)
+			}
+		}()
+	}
+
+	ticker := time.NewTicker(5 * time.Millisecond)
+	defer ticker.Stop()
+	start := time.Now()
+	issued := int64(0)
+
+loop:
+	for {
+		select {
+		case <-ctx.Done():
+			break loop
+		case <-ticker.C:
+		}
+
+		due := int64(rate * time.Since(start).Seconds())
+		for issued < due {
+			n := int(min(int64(r.batch), due-issued))
+			issued += int64(n)
+			select {
+			case jobs <- n:
+			default:
+				r.shed.Add(int64(n))
+			}
+		}
+	}
+
+	close(jobs)
+	wg.Wait()
+}
+
+// send posts n tagged detections
+func (r *Run) send(n int) {
+	now := time.Now()
+	packets := make([]models.IncomingPacket, n)
+	seqs := make([]int64, n)
+
+	r.mu.Lock()
+	for i := range packets {
+		seq := r.next.Add(1)
+		seqs[i] = seq
+		r.sentAt[seq] = now
+		packets[i] = r.packet(seq, now)
+	}
+	r.mu.Unlock()
+
+	var body []byte
+	path := "/api/v1/detection"
+	if r.batch > 1 {
+		path = "/api/v1/detections/batch"
+		body, _ = json.Marshal(map[string]interface{}{"node_id": "loadtest", "packets": packets})
+	} else {
+		body, _ = json.Marshal(packets[0])
+	}
+
+	err := r.post(path, body)
+	r.httpLatency.Add(time.Since(now))
+
+	if err != nil {
+		r.failed.Add(int64(n))
+		r.mu.Lock()
+		for _, seq := range seqs {
+			delete(r.sentAt, seq)
+		}
+		r.mu.Unlock()
+		return
+	}
+	r.accepted.Add(int64(n))
+}
+
+// post sends one request body to the gateway
+func (r *Run) post(path string, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, r.target+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if r.apiKey != "" {
+		req.Header.Set("X-API-Key", r.apiKey)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("gateway returned %s", resp.Status)
+	}
+	return nil
+}
+
+// packet builds the tagged detection seq
+func (r *Run) packet(seq int64, now time.Time) models.IncomingPacket {
+	rng := rand.New(rand.NewSource(seq))
+	uas := seq % int64(r.drones)
+	return models.IncomingPacket{
+		SN:              fmt.Sprintf("BENCH-%s-%d", r.id, seq),
+		UASID:           fmt.Sprintf("BENCH-%s-UAS%04d", r.id, uas),
+		DroneType:       "Multirotor",
+		Direction:       rng.Intn(360),
+		SpeedHorizontal: rng.Float64() * 20,
+		Latitude:        49.70 + rng.Float64()*0.05,
+		Longitude:       -112.82 + rng.Float64()*0.05,
+		Height:          rng.Float64() * 120,
+		NodeID:          "loadtest",
+		Timestamp:       now.UTC().Format(time.RFC3339Nano),
+	}
+}
+
+// observe matches a stored row to its request
+func (r *Run) observe(sn string, at time.Time) {
+	prefix := "BENCH-" + r.id + "-"
+	if !strings.HasPrefix(sn, prefix) {
+		return
+	}
+	seq, err := strconv.ParseInt(sn[len(prefix):], 10, 64)
+	if err != nil {
+		return
+	}
+
+	r.mu.Lock()
+	sent, ok := r.sentAt[seq]
+	delete(r.sentAt, seq)
+	r.mu.Unlock()
+	if !ok {
+		return // duplicate or already counted
+	}
+
+	r.stored.Add(1)
+	r.e2eLatency.Add(at.Sub(sent))
+}
+
+// outstanding counts detections sent but not yet stored
+func (r *Run) outstanding() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.sentAt)
+}
+
+// report logs progress every interval until done
+func (r *Run) report(out *log.Logger, every time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	var lastSent, lastStored int64
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+
+		sent, stored := r.next.Load(), r.stored.Load()
+		secs := every.Seconds()
+		out.Printf("⏱  %s: sent %.0f/s, stored %.0f/s, pending %d | e2e %s",
+			time.Since(r.started).Round(time.Second),
+			float64(sent-lastSent)/secs, float64(stored-lastStored)/secs,
+			r.outstanding(), r.e2eLatency.Window())
+		r.httpLatency.Window()
+		lastSent, lastStored = sent, stored
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Recorder collects latency samples for the whole run and for the
+// current reporting window
+type Recorder struct {
+	mu     sync.Mutex
+	all    []time.Duration
+	window []time.Duration
+}
+
+// Add records one sample
+func (r *Recorder) Add(d time.Duration) {
+	r.mu.Lock()
+	r.all = append(r.all, d)
+	r.window = append(r.window, d)
+	r.mu.Unlock()
+}
+
+// Window returns and resets the samples since the last call
+func (r *Recorder) Window() Summary {
+	r.mu.Lock()
+	w := r.window
+	r.window = nil
+	r.mu.Unlock()
+	return Summarize(w)
+}
+
+// Total summarises every sample
+func (r *Recorder) Total() Summary {
+	r.mu.Lock()
+	all := append([]time.Duration(nil), r.all...)
+	r.mu.Unlock()
+	return Summarize(all)
+}
+
+// Summary is a latency distribution
+type Summary struct {
+	Count int           `json:"count"`
+	P50   time.Duration `json:"p50_ns"`
+	P90   time.Duration `json:"p90_ns"`
+	P99   time.Duration `json:"p99_ns"`
+	Max   time.Duration `json:"max_ns"`
+}
+
+// Summarize sorts samples in place and computes percentiles
+func Summarize(samples []time.Duration) Summary {
+	if len(samples) == 0 {
+		return Summary{}
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+	at := func(q float64) time.Duration {
+		return samples[int(q*float64(len(samples)-1))]
+	}
+	return Summary{
+		Count: len(samples),
+		P50:   at(0.50),
+		P90:   at(0.90),
+		P99:   at(0.99),
+		Max:   samples[len(samples)-1],
+	}
+}
+
+func (s Summary) String() string {
+	if s.Count == 0 {
+		return "no samples"
+	}
+	return fmt.Sprintf("p50 %v  p90 %v  p99 %v  max %v",
+		round(s.P50), round(s.P90), round(s.P99), round(s.Max))
+}
+
+// round trims durations to a readable precision
+func round(d time.Duration) time.Duration {
+	switch {
+	case d >= time.Second:
+		return d.Round(time.Millisecond)
+	case d >= time.Millisecond:
+		return d.Round(10 * time.Microsecond)
+	default:
+		return d.Round(time.Microsecond)
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"silentraven/internal/database"
+	"silentraven/internal/logging"
+	"silentraven/pkg/config"
+)
+
+var logger = logging.For("migrate")
+
+func main() {
+	cfg, err := config.Load(config.Migrate)
+	if err != nil {
+		logging.Fatal(logger, "Failed to load configuration", logging.Err(err))
+	}
+	if err := logging.Configure(cfg, "migrate"); err != nil {
+		logging.Fatal(logger, "Invalid logging configuration", logging.Err(err))
+	}
+	logger.Info("Running SilentRaven database migrations")
+
+	db, err := database.New(cfg)
+	if err != nil {
+		logging.Fatal(logger, "Failed to connect to database", logging.Err(err))
+	}
+	defer db.Close()
+
+	if err := db.Migrate(); err != nil {
+		logging.Fatal(logger, "Migration failed", logging.Err(err))
+	}
+
+	logger.Info("Database schema is up to date")
+}
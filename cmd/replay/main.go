@@ -0,0 +1,253 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"silentraven/internal/logging"
+	"silentraven/internal/models"
+	"silentraven/internal/pcap"
+	"silentraven/internal/remoteid"
+)
+
+const usage = `Usage:
+  replay [flags] CAPTURE...
+
+Extracts OpenDroneID payloads from pcap/pcapng captures of Wi-Fi beacon,
+Wi-Fi NAN and BLE advertising frames and replays the resulting detections
+into the gateway at original or -speed scaled timing. Without -gateway
+it is a dry run and runs without delays.
+
+Each detection is written as one JSON line. Unless -retime is set the
+output depends only on the captures, so it can be diffed against golden
+files.
+
+Flags:
+`
+
+var logger = logging.For("replay")
+
+// Record is one replayed detection with the frame it came from
+type Record struct {
+	Capture   string                `json:"capture"`
+	Frame     int                   `json:"frame"` // 1-based packet number in the capture
+	Time      string                `json:"time"`
+	Sender    string                `json:"sender"`
+	Transport string                `json:"transport"`
+	RSSI      int                   `json:"rssi,omitempty"`
+	Packet    models.IncomingPacket `json:"packet"`
+}
+
+// Stats summarises a replay
+type Stats struct {
+	Frames     int // captured packets read
+	RemoteID   int // packets carrying a Remote ID payload
+	Undecoded  int // Remote ID payloads that failed to decode
+	Detections int
+	Failed     int // detections the gateway did not accept
+}
+
+// Replayer replays captures at scaled timing
+type Replayer struct {
+	gateway string
+	nodeID  string
+	apiKey  string
+	speed   float64
+	retime  bool
+	client  *http.Client
+	out     *json.Encoder
+	stats   Stats
+}
+
+func main() {
+	gateway := flag.String("gateway", "", "gateway base URL, e.g. http://localhost:8080 (omit for a dry run)")
+	nodeID := flag.String("node-id", "replay", "node ID stamped on detections")
+	apiKey := flag.String("api-key", os.Getenv("GATEWAY_API_KEY"), "API key for the gateway")
+	speed := flag.Float64("speed", 1, "timing scale: 1 = original timing, 10 = ten times faster, 0 = no delays")
+	retime := flag.Bool("retime", false, "timestamp detections with the replay time instead of the capture time")
+	output := flag.String("o", "-", "write detections as JSON lines to this file (- for stdout, empty to disable)")
+	logLevel := flag.String("log-level", envOr("LOG_LEVEL", "info"), "log level, with optional component overrides (replay=debug)")
+	logFormat := flag.String("log-format", envOr("LOG_FORMAT", "text"), "log format: text or json")
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 || *speed < 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := logging.Setup(logging.Options{Service: "replay", Level: *logLevel, Format: *logFormat}); err != nil {
+		logging.Fatal(logger, "Invalid logging configuration", logging.Err(err))
+	}
+
+	r := &Replayer{
+		gateway: strings.TrimRight(*gateway, "/"),
+		nodeID:  *nodeID,
+		apiKey:  *apiKey,
+		speed:   *speed,
+		retime:  *retime,
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+
+	if *output != "" {
+		w := os.Stdout
+		if *output != "-" {
+			f, err := os.Create(*output)
+			if err != nil {
+				logging.Fatal(logger, "Failed to create output", logging.Err(err))
+			}
+			defer f.Close()
+			w = f
+		}
+		bw := bufio.NewWriter(w)
+		defer bw.Flush()
+		r.out = json.NewEncoder(bw)
+	}
+
+	for _, path := range flag.Args() {
+		if err := r.ReplayFile(path); err != nil {
+			logging.Fatal(logger, "Failed to replay capture", "capture", path, logging.Err(err))
+		}
+	}
+
+	s := r.stats
+	logger.Info("Replay finished", "frames", s.Frames, "remote_id", s.RemoteID,
+		"undecodable", s.Undecoded, "detections", s.Detections, "failed", s.Failed)
+	if s.Failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// ReplayFile replays one capture. Transmitter state is kept per file.
+func (r *Replayer) ReplayFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open capture: %w", err)
+	}
+	defer f.Close()
+
+	reader, err := pcap.Open(f)
+	if err != nil {
+		return err
+	}
+
+	assembler := remoteid.NewAssembler(r.nodeID)
+	var first time.Time
+	started := time.Now()
+
+	for n := 1; ; n++ {
+		pkt, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		r.stats.Frames++
+
+		frame, ok := remoteid.Extract(pkt.LinkType, pkt.Data)
+		if !ok {
+			continue
+		}
+		r.stats.RemoteID++
+
+		msgs, err := remoteid.Decode(frame.Payload)
+		if err != nil {
+			r.stats.Undecoded++
+			logger.Warn("Undecodable Remote ID payload", "capture", path, "frame", n, "sender", frame.Sender, logging.Err(err))
+			continue
+		}
+
+		if first.IsZero() {
+			first = pkt.Time
+		}
+		r.wait(started, pkt.Time.Sub(first))
+
+		for _, packet := range assembler.Add(frame.Sender, pkt.Time, msgs) {
+			if r.retime {
+				packet.Timestamp = time.Now().UTC().Format(time.RFC3339Nano)
+			}
+			r.stats.Detections++
+
+			if r.out != nil {
+				err := r.out.Encode(Record{
+					Capture:   path,
+					Frame:     n,
+					Time:      pkt.Time.Format(time.RFC3339Nano),
+					Sender:    frame.Sender,
+					Transport: frame.Transport,
+					RSSI:      frame.RSSI,
+					Packet:    packet,
+				})
+				if err != nil {
+					return fmt.Errorf("failed to write output: %w", err)
+				}
+			}
+
+			if r.gateway != "" {
+				if err := r.send(packet); err != nil {
+					r.stats.Failed++
+					logger.Error("Failed to replay detection", logging.UAS(packet.UASID), "capture", path, "frame", n, logging.Err(err))
+				}
+			}
+		}
+	}
+}
+
+// wait sleeps until offset (in capture time, scaled by speed) after start
+func (r *Replayer) wait(start time.Time, offset time.Duration) {
+	if r.speed == 0 || r.gateway == "" {
+		return
+	}
+	due := start.Add(time.Duration(float64(offset) / r.speed))
+	if d := time.Until(due); d > 0 {
+		time.Sleep(d)
+	}
+}
+
+// send posts one detection to the gateway
+func (r *Replayer) send(packet models.IncomingPacket) error {
+	body, err := json.Marshal(packet)
+	if err != nil {
+		return fmt.Errorf("failed to encode detection: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, r.gateway+"/api/v1/detection", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if r.apiKey != "" {
+		req.Header.Set("X-API-Key", r.apiKey)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach gateway: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		var apiResp models.APIResponse
+		json.NewDecoder(resp.Body).Decode(&apiResp)
+		return fmt.Errorf("gateway returned %s: %s", resp.Status, apiResp.Error)
+	}
+	return nil
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"flag"
+	"hash/crc32"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"silentraven/internal/pcap"
+	"silentraven/internal/remoteid"
+)
+
+var update = flag.Bool("update", false, "rewrite the capture fixtures and golden output in testdata")
+
+var fixtures = []string{"testdata/beacon.pcap", "testdata/nan.pcapng", "testdata/ble.pcap"}
+
+const golden = "testdata/replay.jsonl"
+
+// TestReplayGolden replays the fixtures in dry-run mode and diffs the JSON
+// lines against the golden file
+func TestReplayGolden(t *testing.T) {
+	if *update {
+		writeFixtures(t)
+	}
+
+	var out bytes.Buffer
+	r := &Replayer{nodeID: "replay", out: json.NewEncoder(&out)}
+	for _, path := range fixtures {
+		if err := r.ReplayFile(path); err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+	}
+
+	want := Stats{Frames: 14, RemoteID: 13, Undecoded: 1, Detections: 8}
+	if r.stats != want {
+		t.Errorf("stats = %+v, want %+v", r.stats, want)
+	}
+
+	if *update {
+		if err := os.WriteFile(golden, out.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expected, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out.Bytes(), expected) {
+		t.Errorf("output differs from %s (rerun with -update if intended):\n%s", golden, out.String())
+	}
+}
+
+// writeFixtures synthesises one capture per transport
+func writeFixtures(t *testing.T) {
+	t.Helper()
+	if err := os.MkdirAll("testdata", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t0 := time.Date(2026, 5, 4, 10, 30, 0, 0, time.UTC)
+
+	// Wi-Fi beacon, radiotap with FCS: a serial-numbered multirotor
+	// sending full packs, a repeated Location, an unrelated access point
+	// and a pack with an unknown message type
+	beaconTx := net.HardwareAddr{0x60, 0x60, 0x1F, 0xA0, 0x00, 0x01}
+	var frames [][]byte
+	for i, loc := range []remoteid.Location{
+		location(52.5200, 13.4050, 0),
+		location(52.5201, 13.4052, 1),
+		location(52.5201, 13.4052, 1),
+		location(52.5203, 13.4055, 2),
+	} {
+		pack := remoteid.EncodePack(
+			remoteid.EncodeBasicID(remoteid.BasicID{IDType: remoteid.IDTypeSerialNumber, UAType: 2, ID: "1581F5FKD229400A"}),
+			remoteid.EncodeLocation(loc),
+			remoteid.EncodeSystem(remoteid.System{OperatorLatitude: 52.5190, OperatorLongitude: 13.4040, OperatorAltitude: 34}),
+			remoteid.EncodeOperatorID(remoteid.OperatorID{ID: "DEU87astrdge12k8"}),
+		)
+		frames = append(frames, radiotap(remoteid.EncodeBeacon(beaconTx, byte(i), pack), int8(-60-i)))
+	}
+	ap := remoteid.EncodeBeacon(net.HardwareAddr{0x02, 0, 0, 0, 0, 0x0A}, 0, nil)
+	frames = append(frames, radiotap(ap[:len(ap)-7], -40))
+	bad := remoteid.EncodePack(make([]byte, remoteid.MessageSize))
+	bad[3] = 0x60
+	frames = append(frames, radiotap(remoteid.EncodeBeacon(beaconTx, 9, bad), -65))
+
+	var buf bytes.Buffer
+	w, err := pcap.NewWriter(&buf, pcap.LinkTypeRadiotap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, f := range frames {
+		if err := w.Write(t0.Add(time.Duration(i)*time.Second), f); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile(t, fixtures[0], buf.Bytes())
+
+	// Wi-Fi NAN, pcapng with nanosecond timestamps: a registered UA whose
+	// serial number and registration ID arrive in one pack
+	nanTx := net.HardwareAddr{0x90, 0x3A, 0xE6, 0x5B, 0x00, 0x02}
+	ng := pcapngHeader(pcap.LinkTypeIEEE80211)
+	for i := 0; i < 3; i++ {
+		pack := remoteid.EncodePack(
+			remoteid.EncodeBasicID(remoteid.BasicID{IDType: remoteid.IDTypeSerialNumber, UAType: 1, ID: "SRTEST000NAN"}),
+			remoteid.EncodeBasicID(remoteid.BasicID{IDType: remoteid.IDTypeCAARegistry, UAType: 1, ID: "FA3XYZ1234"}),
+			remoteid.EncodeLocation(location(48.8566+float64(i)*0.0004, 2.3522, i)),
+		)
+		at := t0.Add(time.Duration(i)*time.Second + 123_456_789)
+		ng = pcapngPacket(ng, uint64(at.UnixNano()), remoteid.EncodeNANAction(nanTx, byte(i), pack))
+	}
+	writeFile(t, fixtures[1], ng)
+
+	// BLE legacy advertising with the pseudo-header: one message per
+	// advertisement, so the first Location arrives before the Basic ID
+	bleTx := net.HardwareAddr{0xC4, 0x11, 0x22, 0x33, 0x44, 0x03}
+	adverts := [][]byte{
+		remoteid.EncodeLocation(location(40.7128, -74.0060, 0)),
+		remoteid.EncodeBasicID(remoteid.BasicID{IDType: remoteid.IDTypeSerialNumber, UAType: 2, ID: "SRTEST000BLE"}),
+		remoteid.EncodeSystem(remoteid.System{OperatorLatitude: 40.7120, OperatorLongitude: -74.0050}),
+		remoteid.EncodeLocation(location(40.7129, -74.0061, 1)),
+		remoteid.EncodeLocation(location(40.7130, -74.0062, 2)),
+	}
+	buf.Reset()
+	if w, err = pcap.NewWriter(&buf, pcap.LinkTypeBluetoothPHDR); err != nil {
+		t.Fatal(err)
+	}
+	for i, msg := range adverts {
+		if err := w.Write(t0.Add(time.Duration(i)*300*time.Millisecond), phdr(remoteid.EncodeBLEAdvertising(bleTx, byte(i), msg), int8(-70-i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile(t, fixtures[2], buf.Bytes())
+}
+
+func location(lat, lon float64, i int) remoteid.Location {
+	return remoteid.Location{
+		Status:          2,
+		Direction:       45 + 90*i,
+		SpeedHorizontal: 4.5,
+		SpeedVertical:   0.5,
+		Latitude:        lat,
+		Longitude:       lon,
+		PressureAlt:     remoteid.UnknownAltitude,
+		GeodeticAlt:     120 + float64(i),
+		Height:          60 + float64(i),
+		HorizAccuracy:   10,
+		VertAccuracy:    4,
+		TenthsOfHour:    i * 10,
+	}
+}
+
+// radiotap prefixes an 802.11 frame with flags (FCS present) and antenna
+// signal, and appends the FCS
+func radiotap(frame []byte, rssi int8) []byte {
+	b := []byte{0, 0, 10, 0, 0x22, 0, 0, 0, 0x10, byte(rssi)}
+	b = append(b, frame...)
+	return binary.LittleEndian.AppendUint32(b, crc32.ChecksumIEEE(frame))
+}
+
+// phdr prefixes a BLE packet with a pseudo-header carrying the signal
+func phdr(ll []byte, rssi int8) []byte {
+	h := make([]byte, 10)
+	h[0], h[1] = 37, byte(rssi)
+	binary.LittleEndian.PutUint16(h[8:10], 0x0002)
+	return append(h, ll...)
+}
+
+// pcapngHeader is a section header and one interface with nanosecond
+// timestamps
+func pcapngHeader(linkType uint16) []byte {
+	shb := binary.LittleEndian.AppendUint32(nil, 0x1A2B3C4D)
+	shb = binary.LittleEndian.AppendUint32(shb, 1) // version 1.0
+	shb = binary.LittleEndian.AppendUint64(shb, ^uint64(0))
+	b := pcapngBlock(nil, 0x0A0D0D0A, shb)
+
+	idb := binary.LittleEndian.AppendUint16(nil, linkType)
+	idb = append(idb, 0, 0, 0, 0, 0, 0)
+	idb = append(idb, 9, 0, 1, 0, 9, 0, 0, 0) // if_tsresol = 9
+	idb = append(idb, 0, 0, 0, 0)
+	return pcapngBlock(b, 1, idb)
+}
+
+// pcapngPacket appends an Enhanced Packet Block on interface 0
+func pcapngPacket(b []byte, ts uint64, data []byte) []byte {
+	epb := binary.LittleEndian.AppendUint32(nil, 0)
+	epb = binary.LittleEndian.AppendUint32(epb, uint32(ts>>32))
+	epb = binary.LittleEndian.AppendUint32(epb, uint32(ts))
+	epb = binary.LittleEndian.AppendUint32(epb, uint32(len(data)))
+	epb = binary.LittleEndian.AppendUint32(epb, uint32(len(data)))
+	return pcapngBlock(b, 6, append(epb, data...))
+}
+
+func pcapngBlock(b []byte, blockType uint32, body []byte) []byte {
+	for len(body)%4 != 0 {
+		body = append(body, 0)
+	}
+	total := uint32(12 + len(body))
+	b = binary.LittleEndian.AppendUint32(b, blockType)
+	b = binary.LittleEndian.AppendUint32(b, total)
+	b = append(b, body...)
+	return binary.LittleEndian.AppendUint32(b, total)
+}
+
+func writeFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+	if err := os.WriteFile(filepath.FromSlash(path), data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"silentraven/internal/logging"
+	"silentraven/internal/models"
+	"silentraven/internal/sim"
+)
+
+var logger = logging.For("simulator")
+
+const usage = `Usage:
+  simulator [flags]
+
+Flies synthetic UAS (waypoint missions, loiter, takeoff/landing hops,
+geofence incursions, with ID spoofing and signal dropouts) over virtual
+sensor nodes and posts what the nodes hear to the gateway. With -dry-run
+the detections are written to stdout as JSON lines instead, as fast as
+possible, for -duration of simulated time.
+
+Flags:
+`
+
+// Poster delivers one tick's packets for a node to the gateway
+type Poster struct {
+	gateway string
+	apiKey  string
+	single  bool
+	client  *http.Client
+
+	sent   atomic.Int64
+	failed atomic.Int64
+}
+
+func main() {
+	def := sim.DefaultScenario(sim.Point{Lat: 49.701, Lon: -112.818})
+
+	gateway := flag.String("gateway", "http://localhost:8080", "gateway base URL")
+	apiKey := flag.String("api-key", os.Getenv("GATEWAY_API_KEY"), "ingest client API key for the gateway (node keys only cover one node)")
+	lat := flag.Float64("lat", def.Center.Lat, "area center latitude")
+	lon := flag.Float64("lon", def.Center.Lon, "area center longitude")
+	radius := flag.Float64("radius", def.Radius, "operating area radius in meters")
+	drones := flag.Int("drones", def.Drones, "number of simulated UAS")
+	nodes := flag.Int("nodes", def.Nodes, "number of virtual sensor nodes")
+	nodeRange := flag.Float64("node-range", def.NodeRange, "node reception range in meters")
+	mix := flag.String("mix", "waypoint=4,loiter=2,hop=2,incursion=1", "flight profile weights")
+	geofence := flag.Float64("geofence", def.Geofence.Radius, "radius in meters of the geofence around the center (0 disables incursions)")
+	spoofRate := flag.Float64("spoof-rate", def.SpoofRate, "fraction of UAS broadcasting a cloned ID")
+	dropoutRate := flag.Float64("dropout-rate", def.DropoutRate, "signal dropouts per UAS per minute")
+	seed := flag.Int64("seed", def.Seed, "random seed")
+	interval := flag.Duration("interval", time.Second, "broadcast interval (simulation step)")
+	speedup := flag.Float64("speedup", 1, "simulated seconds per real second")
+	duration := flag.Duration("duration", 0, "simulated time to run for (0 = until interrupted)")
+	workers := flag.Int("workers", 8, "concurrent gateway requests")
+	single := flag.Bool("single", false, "post detections one by one instead of per-node batches")
+	dryRun := flag.Bool("dry-run", false, "write detections to stdout instead of posting them")
+	logLevel := flag.String("log-level", envOr("LOG_LEVEL", "info"), "log level, with optional component overrides (simulator=debug)")
+	logFormat := flag.String("log-format", envOr("LOG_FORMAT", "text"), "log format: text or json")
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if err := logging.Setup(logging.Options{Service: "simulator", Level: *logLevel, Format: *logFormat}); err != nil {
+		logging.Fatal(logger, "Invalid logging configuration", logging.Err(err))
+	}
+
+	sc := def
+	sc.Center = sim.Point{Lat: *lat, Lon: *lon}
+	sc.Radius, sc.Drones, sc.Nodes, sc.NodeRange = *radius, *drones, *nodes, *nodeRange
+	sc.Geofence = sim.Circle{Center: sc.Center, Radius: *geofence}
+	sc.SpoofRate, sc.DropoutRate, sc.Seed = *spoofRate, *dropoutRate, *seed
+
+	var err error
+	if sc.Mix, err = sim.ParseMix(*mix); err != nil {
+		logging.Fatal(logger, "Invalid profile mix", logging.Err(err))
+	}
+	if *interval <= 0 || *speedup <= 0 || *workers <= 0 || (*dryRun && *duration <= 0) {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	start := time.Now().UTC()
+	if *dryRun {
+		// A fixed epoch keeps dry runs reproducible
+		start = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	s, err := sim.New(sc, start)
+	if err != nil {
+		logging.Fatal(logger, "Invalid scenario", logging.Err(err))
+	}
+
+	if *dryRun {
+		dryRunSim(s, *interval, *duration)
+		return
+	}
+
+	logger.Info("Simulating UAS", "drones", sc.Drones, "nodes", sc.Nodes, "gateway", *gateway)
+	for _, u := range s.Fleet() {
+		if u.Spoofer {
+			logger.Info("Spoofer in fleet", "drone_type", u.DroneType, "profile", u.Profile, logging.UAS(u.UASID))
+		}
+	}
+
+	poster := &Poster{
+		gateway: strings.TrimRight(*gateway, "/"),
+		apiKey:  *apiKey,
+		single:  *single,
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+	runSim(s, poster, *interval, *speedup, *duration, *workers)
+}
+
+// dryRunSim steps without delays and prints every detection
+func dryRunSim(s *sim.Simulator, interval, duration time.Duration) {
+	w := bufio.NewWriter(os.Stdout)
+	defer w.Flush()
+	enc := json.NewEncoder(w)
+
+	for elapsed := time.Duration(0); elapsed < duration; elapsed += interval {
+		for _, p := range s.Step(interval) {
+			if err := enc.Encode(p); err != nil {
+				logging.Fatal(logger, "Failed to write detection", logging.Err(err))
+			}
+		}
+	}
+}
+
+// runSim steps in (scaled) real time and posts each tick's packets
+// through a pool of workers
+func runSim(s *sim.Simulator, poster *Poster, interval time.Duration, speedup float64, duration time.Duration, workers int) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+
+	jobs := make(chan []models.IncomingPacket, workers*4)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for batch := range jobs {
+				poster.Post(ctx, batch)
+			}
+		}()
+	}
+
+	ticker := time.NewTicker(time.Duration(float64(interval) / speedup))
+	defer ticker.Stop()
+	report := time.NewTicker(10 * time.Second)
+	defer report.Stop()
+
+	elapsed := time.Duration(0)
+loop:
+	for duration == 0 || elapsed < duration {
+		select {
+		case <-quit:
+			logger.Info("Stopping simulator")
+			break loop
+		case <-report.C:
+			logger.Info("Progress", "simulated", elapsed, "airborne", airborne(s),
+				"sent", poster.sent.Load(), "failed", poster.failed.Load())
+			continue
+		case <-ticker.C:
+		}
+
+		elapsed += interval
+		for _, batch := range byNode(s.Step(interval)) {
+			select {
+			case jobs <- batch:
+			default:
+				// The gateway cannot keep up; shed load rather than drift
+				poster.failed.Add(int64(len(batch)))
+			}
+		}
+	}
+
+	close(jobs)
+	wg.Wait()
+	logger.Info("Simulation finished", "simulated", elapsed, "sent", poster.sent.Load(), "failed", poster.failed.Load())
+}
+
+// byNode groups packets by the node that heard them
+func byNode(packets []models.IncomingPacket) [][]models.IncomingPacket {
+	index := make(map[string]int)
+	var batches [][]models.IncomingPacket
+	for _, p := range packets {
+		i, ok := index[p.NodeID]
+		if !ok {
+			i = len(batches)
+			index[p.NodeID] = i
+			batches = append(batches, nil)
+		}
+		batches[i] = append(batches[i], p)
+	}
+	return batches
+}
+
+func airborne(s *sim.Simulator) int {
+	n := 0
+	for _, u := range s.Fleet() {
+		if u.Flying() {
+			n++
+		}
+	}
+	return n
+}
+
+// Post sends one node's packets, as a batch or one by one
+func (p *Poster) Post(ctx context.Context, packets []models.IncomingPacket) {
+	if !p.single {
+		body, _ := json.Marshal(map[string]interface{}{
+			"node_id": packets[0].NodeID,
+			"packets": packets,
+		})
+		p.count(p.post(ctx, "/api/v1/detections/batch", body), len(packets))
+		return
+	}
+
+	for _, packet := range packets {
+		body, _ := json.Marshal(packet)
+		p.count(p.post(ctx, "/api/v1/detection", body), 1)
+	}
+}
+
+func (p *Poster) count(err error, n int) {
+	if err != nil {
+		if p.failed.Add(int64(n)) == int64(n) {
+			// Log the first failure; later ones show in the periodic report
+			logger.Error("Failed to post detections", logging.Err(err))
+		}
+		return
+	}
+	p.sent.Add(int64(n))
+}
+
+func (p *Poster) post(ctx context.Context, path string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.gateway+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.apiKey != "" {
+		req.Header.Set("X-API-Key", p.apiKey)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("gateway returned %s", resp.Status)
+	}
+	return nil
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
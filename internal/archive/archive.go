@@ -0,0 +1,222 @@
+// Package archive writes and reads gzip-compressed NDJSON detection
+// archives with a manifest describing each file.
+package archive
+
+import (
+	"bufio"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"silentraven/internal/models"
+)
+
+// ManifestFile is the manifest filename inside an archive directory
+const ManifestFile = "manifest.json"
+
+// Entry describes one archive file
+type Entry struct {
+	File      string    `json:"file"`
+	From      time.Time `json:"from"`
+	To        time.Time `json:"to"`
+	Rows      int64     `json:"rows"`
+	Bytes     int64     `json:"bytes"`
+	SHA256    string    `json:"sha256"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Manifest lists every archive file in a directory
+type Manifest struct {
+	Table   string  `json:"table"`
+	Format  string  `json:"format"`
+	Entries []Entry `json:"entries"`
+}
+
+// Has reports whether a file covering exactly [from, to) is archived
+func (m *Manifest) Has(from, to time.Time) bool {
+	for _, e := range m.Entries {
+		if e.From.Equal(from) && e.To.Equal(to) {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadManifest reads dir/manifest.json, returning an empty manifest if absent
+func LoadManifest(dir string) (*Manifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, ManifestFile))
+	if os.IsNotExist(err) {
+		return &Manifest{Table: "drone_detections", Format: "ndjson.gz"}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read manifest: %w", err)
+	}
+
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("parse manifest: %w", err)
+	}
+	return &m, nil
+}
+
+// Save writes the manifest atomically (temp file + rename)
+func (m *Manifest) Save(dir string) error {
+	sort.Slice(m.Entries, func(i, j int) bool { return m.Entries[i].From.Before(m.Entries[j].From) })
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal manifest: %w", err)
+	}
+
+	tmp := filepath.Join(dir, ManifestFile+".tmp")
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("write manifest: %w", err)
+	}
+	return os.Rename(tmp, filepath.Join(dir, ManifestFile))
+}
+
+// FileName returns the archive filename for a time range
+func FileName(from, to time.Time) string {
+	return fmt.Sprintf("drone_detections_%s_%s.ndjson.gz",
+		from.UTC().Format("20060102T150405Z"), to.UTC().Format("20060102T150405Z"))
+}
+
+// Writer streams detections into a gzip NDJSON file
+type Writer struct {
+	entry Entry
+	path  string
+	file  *os.File
+	hash  hash.Hash
+	gz    *gzip.Writer
+	buf   *bufio.Writer
+	enc   *json.Encoder
+}
+
+// Create starts a new archive file for [from, to) in dir. The file is
+// written under a temporary name until Close succeeds.
+func Create(dir string, from, to time.Time) (*Writer, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create archive dir: %w", err)
+	}
+
+	name := FileName(from, to)
+	path := filepath.Join(dir, name)
+	f, err := os.Create(path + ".partial")
+	if err != nil {
+		return nil, fmt.Errorf("create archive file: %w", err)
+	}
+
+	h := sha256.New()
+	gz := gzip.NewWriter(io.MultiWriter(f, h))
+	buf := bufio.NewWriter(gz)
+
+	return &Writer{
+		entry: Entry{File: name, From: from.UTC(), To: to.UTC()},
+		path:  path,
+		file:  f,
+		hash:  h,
+		gz:    gz,
+		buf:   buf,
+		enc:   json.NewEncoder(buf),
+	}, nil
+}
+
+// Write appends one detection
+func (w *Writer) Write(d models.DroneDetection) error {
+	if err := w.enc.Encode(d); err != nil {
+		return fmt.Errorf("encode detection %d: %w", d.ID, err)
+	}
+	w.entry.Rows++
+	return nil
+}
+
+// Close finalizes the file and returns its manifest entry
+func (w *Writer) Close() (Entry, error) {
+	if err := w.buf.Flush(); err != nil {
+		w.Abort()
+		return Entry{}, fmt.Errorf("flush archive: %w", err)
+	}
+	if err := w.gz.Close(); err != nil {
+		w.Abort()
+		return Entry{}, fmt.Errorf("close gzip: %w", err)
+	}
+	if err := w.file.Sync(); err != nil {
+		w.Abort()
+		return Entry{}, fmt.Errorf("sync archive: %w", err)
+	}
+
+	info, err := w.file.Stat()
+	if err != nil {
+		w.Abort()
+		return Entry{}, fmt.Errorf("stat archive: %w", err)
+	}
+	w.file.Close()
+
+	if err := os.Rename(w.path+".partial", w.path); err != nil {
+		return Entry{}, fmt.Errorf("rename archive: %w", err)
+	}
+
+	w.entry.Bytes = info.Size()
+	w.entry.SHA256 = hex.EncodeToString(w.hash.Sum(nil))
+	w.entry.CreatedAt = time.Now().UTC()
+	return w.entry, nil
+}
+
+// Abort discards a partially written file
+func (w *Writer) Abort() {
+	w.file.Close()
+	os.Remove(w.path + ".partial")
+}
+
+// Read calls fn for every detection in an archive file, verifying the
+// checksum when the manifest entry is provided.
+func Read(path string, expected *Entry, fn func(models.DroneDetection) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open archive: %w", err)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	gz, err := gzip.NewReader(io.TeeReader(f, h))
+	if err != nil {
+		return fmt.Errorf("open gzip: %w", err)
+	}
+	defer gz.Close()
+
+	dec := json.NewDecoder(bufio.NewReader(gz))
+	var rows int64
+	for dec.More() {
+		var d models.DroneDetection
+		if err := dec.Decode(&d); err != nil {
+			return fmt.Errorf("decode row %d: %w", rows+1, err)
+		}
+		if err := fn(d); err != nil {
+			return err
+		}
+		rows++
+	}
+
+	if expected != nil {
+		// Drain trailing gzip bytes so the hash covers the whole file
+		if _, err := io.Copy(io.Discard, io.TeeReader(f, h)); err != nil {
+			return fmt.Errorf("read archive: %w", err)
+		}
+		if sum := hex.EncodeToString(h.Sum(nil)); sum != expected.SHA256 {
+			return fmt.Errorf("checksum mismatch for %s: got %s, manifest %s", expected.File, sum, expected.SHA256)
+		}
+		if rows != expected.Rows {
+			return fmt.Errorf("row count mismatch for %s: got %d, manifest %d", expected.File, rows, expected.Rows)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,135 @@
+package archive
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"silentraven/internal/models"
+)
+
+var (
+	from = time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	to   = from.Add(24 * time.Hour)
+)
+
+func detections(n int) []models.DroneDetection {
+	out := make([]models.DroneDetection, n)
+	for i := range out {
+		out[i] = models.DroneDetection{
+			ID: int64(i + 1), DetectionTime: from.Add(time.Duration(i) * time.Minute),
+			SN: "1581F5FJD228400M", UASID: "uas-1", Latitude: 51.47, Longitude: -0.45, NodeID: "node-1",
+		}
+	}
+	return out
+}
+
+// write archives detections for [from, to) in dir
+func write(t *testing.T, dir string, rows []models.DroneDetection) Entry {
+	t.Helper()
+	w, err := Create(dir, from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range rows {
+		if err := w.Write(d); err != nil {
+			t.Fatal(err)
+		}
+	}
+	entry, err := w.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return entry
+}
+
+func TestRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	want := detections(3)
+	entry := write(t, dir, want)
+	if entry.File != FileName(from, to) || entry.Rows != 3 || entry.Bytes == 0 || len(entry.SHA256) != 64 {
+		t.Errorf("entry = %+v", entry)
+	}
+	if _, err := os.Stat(filepath.Join(dir, entry.File+".partial")); !os.IsNotExist(err) {
+		t.Error("partial file left behind")
+	}
+
+	m, err := LoadManifest(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Entries) != 0 || m.Table != "drone_detections" {
+		t.Fatalf("new manifest = %+v", m)
+	}
+	m.Entries = append(m.Entries, entry)
+	if err := m.Save(dir); err != nil {
+		t.Fatal(err)
+	}
+	m, err = LoadManifest(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !m.Has(from, to) || m.Has(from, to.Add(time.Hour)) || len(m.Entries) != 1 || m.Entries[0].SHA256 != entry.SHA256 {
+		t.Errorf("saved manifest = %+v", m)
+	}
+
+	var got []models.DroneDetection
+	err = Read(filepath.Join(dir, entry.File), &m.Entries[0], func(d models.DroneDetection) error {
+		got = append(got, d)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("read %d rows, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i].ID != want[i].ID || !got[i].DetectionTime.Equal(want[i].DetectionTime) || got[i].UASID != want[i].UASID {
+			t.Errorf("row %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestReadRejectsMismatch(t *testing.T) {
+	dir := t.TempDir()
+	entry := write(t, dir, detections(3))
+	path := filepath.Join(dir, entry.File)
+	noop := func(models.DroneDetection) error { return nil }
+
+	rows := entry
+	rows.Rows = 4
+	if err := Read(path, &rows, noop); err == nil || !strings.Contains(err.Error(), "row count mismatch") {
+		t.Errorf("Read with the wrong row count = %v", err)
+	}
+
+	// Replace the file with a valid archive of different rows
+	write(t, dir, detections(2))
+	if err := Read(path, &entry, noop); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Errorf("Read of a replaced file = %v, want a checksum mismatch", err)
+	}
+	if err := Read(path, nil, noop); err != nil {
+		t.Errorf("Read without a manifest entry = %v", err)
+	}
+}
+
+func TestAbort(t *testing.T) {
+	dir := t.TempDir()
+	w, err := Create(dir, from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write(detections(1)[0]); err != nil {
+		t.Fatal(err)
+	}
+	w.Abort()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("aborted archive left %v", entries)
+	}
+}
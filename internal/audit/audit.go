@@ -0,0 +1,226 @@
+// Package audit records who accessed, exported or changed what in a
+// hash-chained, append-only log, and verifies the chain
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os/user"
+	"sync"
+
+	"silentraven/internal/auth"
+	"silentraven/internal/logging"
+	"silentraven/internal/metrics"
+	"silentraven/internal/models"
+	"silentraven/internal/storage"
+)
+
+var logger = logging.For("audit")
+
+// Actions
+const (
+	ActionAccess       = "access"
+	ActionExport       = "export"
+	ActionEvidence     = "evidence.export"
+	ActionCaseCreate   = "case.create"
+	ActionCaseChange   = "case.change"
+	ActionCaseNote     = "case.note"
+	ActionCaseAttach   = "case.attach"
+	ActionNodeUpdate   = "node.update"
+	ActionNodeDelete   = "node.delete"
+	ActionTokenIssue   = "token.issue"
+	ActionKeyCreate    = "key.create"
+	ActionKeyRevoke    = "key.revoke"
+	ActionKeyRotate    = "key.rotate"
+	ActionConfigLoad   = "config.load"
+	ActionConfigReload = "config.reload"
+)
+
+// Actor kinds besides the auth principal kinds
+const (
+	// KindAnonymous is a request that did not authenticate
+	KindAnonymous = "anonymous"
+	// KindService is a service acting on its own, e.g. loading config
+	KindService = "service"
+	// KindOperator is a person running a CLI tool
+	KindOperator = "operator"
+)
+
+// Recorder appends events to the audit log
+type Recorder struct {
+	store storage.AuditStore
+}
+
+// New creates a recorder writing to store
+func New(store storage.AuditStore) *Recorder {
+	return &Recorder{store: store}
+}
+
+// Record appends e, encoding detail (if not nil) as its Detail. Failures
+// are logged and counted before being returned. A nil Recorder records
+// nothing.
+func (rec *Recorder) Record(e *models.AuditEvent, detail map[string]any) error {
+	if rec == nil {
+		return nil
+	}
+	if err := rec.append(e, detail); err != nil {
+		metrics.AuditFailures.Inc()
+		logger.Error("Failed to record audit event", "action", e.Action, "actor", e.Actor,
+			"resource", e.Resource, logging.Err(err))
+		return err
+	}
+	metrics.AuditEvents.WithLabelValues(e.Action).Inc()
+	return nil
+}
+
+func (rec *Recorder) append(e *models.AuditEvent, detail map[string]any) error {
+	if detail != nil {
+		data, err := json.Marshal(detail)
+		if err != nil {
+			return fmt.Errorf("failed to encode audit detail: %w", err)
+		}
+		e.Detail = string(data)
+	}
+	return rec.store.AppendAudit(e)
+}
+
+// Operator returns the OS user running a CLI tool, as an audit actor
+func Operator() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return "unknown"
+}
+
+// entry is the audit event being built for a request
+type entry struct {
+	mu        sync.Mutex
+	rec       *Recorder
+	principal *auth.Principal
+	action    string
+	detail    map[string]any
+	// committed is set once Commit has recorded the event
+	committed bool
+}
+
+type entryKey struct{}
+
+// Middleware records every request as an access event once it has been
+// served, including those rejected by authentication. Handlers refine the
+// event with SetAction and Note, and Commit it before responding when the
+// response must not go out unaudited. Place it before the auth middleware
+// and Identify after it.
+func (rec *Recorder) Middleware(next http.Handler) http.Handler {
+	if rec == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		en := &entry{rec: rec, action: ActionAccess, detail: make(map[string]any)}
+		if r.URL.RawQuery != "" {
+			en.detail["query"] = r.URL.RawQuery
+		}
+		sr := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sr, r.WithContext(context.WithValue(r.Context(), entryKey{}, en)))
+
+		// The response is gone; a failure is logged and counted by Record
+		en.record(r, sr.status)
+	})
+}
+
+// Commit records the request's audit event now, with the status about to
+// be sent, rather than after the response. Handlers of exports, evidence
+// and case changes call it before responding and fail the request if it
+// returns an error, so the response never goes out unaudited.
+func Commit(r *http.Request, status int) error {
+	en, ok := r.Context().Value(entryKey{}).(*entry)
+	if !ok {
+		return nil
+	}
+	return en.record(r, status)
+}
+
+// record appends the event unless it has already been committed
+func (en *entry) record(r *http.Request, status int) error {
+	e := &models.AuditEvent{
+		Actor:      KindAnonymous,
+		ActorKind:  KindAnonymous,
+		Resource:   r.Method + " " + r.URL.Path,
+		Status:     status,
+		RemoteAddr: remoteHost(r),
+		RequestID:  logging.RequestID(r.Context()),
+	}
+	en.mu.Lock()
+	if en.committed {
+		en.mu.Unlock()
+		return nil
+	}
+	en.committed = true
+	if p := en.principal; p != nil {
+		e.Actor, e.ActorKind, e.Role, e.KeyID = p.Subject, p.Kind, p.Role, p.KeyID
+	}
+	e.Action = en.action
+	detail := en.detail
+	if len(detail) == 0 {
+		detail = nil
+	}
+	en.mu.Unlock()
+	return en.rec.Record(e, detail)
+}
+
+// Identify attaches the authenticated principal to the request's audit
+// event. It runs after the auth middleware.
+func Identify(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if en, ok := r.Context().Value(entryKey{}).(*entry); ok {
+			en.mu.Lock()
+			en.principal = auth.FromContext(r.Context())
+			en.mu.Unlock()
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// SetAction replaces the request's audit action, e.g. with ActionExport
+func SetAction(ctx context.Context, action string) {
+	if en, ok := ctx.Value(entryKey{}).(*entry); ok {
+		en.mu.Lock()
+		en.action = action
+		en.mu.Unlock()
+	}
+}
+
+// Note adds a detail field to the request's audit event
+func Note(ctx context.Context, key string, value any) {
+	if en, ok := ctx.Value(entryKey{}).(*entry); ok {
+		en.mu.Lock()
+		en.detail[key] = value
+		en.mu.Unlock()
+	}
+}
+
+func remoteHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// statusRecorder captures the status code written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
@@ -0,0 +1,115 @@
+package audit
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"silentraven/internal/metrics"
+	"silentraven/internal/models"
+	"silentraven/internal/storage"
+)
+
+// failingStore refuses every append
+type failingStore struct {
+	storage.AuditStore
+}
+
+func (failingStore) AppendAudit(*models.AuditEvent) error {
+	return errors.New("database unavailable")
+}
+
+func events(t *testing.T, s storage.AuditStore) []models.AuditEvent {
+	t.Helper()
+	var out []models.AuditEvent
+	if err := s.StreamAudit(0, func(e models.AuditEvent) error { out = append(out, e); return nil }); err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestMiddlewareRecordsAfterResponse(t *testing.T) {
+	store := storage.NewMemoryStore()
+	h := New(store).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Note(r.Context(), "rows", 3)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/v1/detections?uas_id=x", nil))
+
+	got := events(t, store)
+	if len(got) != 1 || got[0].Action != ActionAccess || got[0].Status != http.StatusNotFound ||
+		got[0].Detail != `{"query":"uas_id=x","rows":3}` {
+		t.Errorf("events = %+v", got)
+	}
+}
+
+func TestCommitRecordsOnce(t *testing.T) {
+	store := storage.NewMemoryStore()
+	var committed bool
+	h := New(store).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		SetAction(r.Context(), ActionExport)
+		Note(r.Context(), "format", "kml")
+		if err := Commit(r, http.StatusOK); err != nil {
+			t.Fatal(err)
+		}
+		// Recorded before anything is written
+		committed = len(events(t, store)) == 1
+		w.Write([]byte("<kml/>"))
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/v1/export/uas-1", nil))
+
+	if !committed {
+		t.Error("Commit did not record the event before the response")
+	}
+	got := events(t, store)
+	if len(got) != 1 || got[0].Action != ActionExport || got[0].Status != http.StatusOK || got[0].Detail != `{"format":"kml"}` {
+		t.Errorf("events = %+v, want one export event", got)
+	}
+}
+
+func TestCommitFailsClosed(t *testing.T) {
+	failures := testutil.ToFloat64(metrics.AuditFailures)
+	h := New(failingStore{}).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		SetAction(r.Context(), ActionEvidence)
+		if err := Commit(r, http.StatusOK); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte("bundle"))
+	}))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/evidence/uas-1", nil))
+
+	if w.Code != http.StatusInternalServerError || w.Body.Len() != 0 {
+		t.Errorf("response = %d %q, want an empty 500", w.Code, w.Body)
+	}
+	// Counted once: the middleware does not retry a failed commit
+	if n := testutil.ToFloat64(metrics.AuditFailures) - failures; n != 1 {
+		t.Errorf("audit failures counted %v times, want 1", n)
+	}
+}
+
+func TestRecordCountsFailures(t *testing.T) {
+	failures := testutil.ToFloat64(metrics.AuditFailures)
+	rec := New(storage.NewMemoryStore())
+
+	err := rec.Record(&models.AuditEvent{Action: ActionAccess}, map[string]any{"bad": func() {}})
+	if err == nil {
+		t.Fatal("unencodable detail recorded")
+	}
+	if err := New(failingStore{}).Record(&models.AuditEvent{Action: ActionAccess}, nil); err == nil {
+		t.Fatal("store failure not returned")
+	}
+	if n := testutil.ToFloat64(metrics.AuditFailures) - failures; n != 2 {
+		t.Errorf("audit failures counted %v times, want 2", n)
+	}
+}
+
+func TestCommitWithoutMiddleware(t *testing.T) {
+	if err := Commit(httptest.NewRequest(http.MethodGet, "/", nil), http.StatusOK); err != nil {
+		t.Errorf("Commit outside the middleware = %v, want nil", err)
+	}
+}
@@ -0,0 +1,89 @@
+package audit
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+
+	"silentraven/internal/logging"
+	"silentraven/internal/models"
+	"silentraven/pkg/config"
+)
+
+// ConfigLoaded records the configuration service started with: a digest
+// of the effective settings (secrets redacted) and of each geofence and
+// jurisdiction, so a changed area shows up between two loads
+func (rec *Recorder) ConfigLoaded(service string, cfg *config.Config) {
+	if rec == nil {
+		return
+	}
+	detail := map[string]any{
+		"file":          os.Getenv("CONFIG_FILE"),
+		"digest":        configDigest(cfg),
+		"geofences":     areaDigests(cfg.Geofences),
+		"jurisdictions": areaDigests(cfg.Jurisdictions),
+	}
+	rec.recordConfig(service, ActionConfigLoad, detail)
+}
+
+// ConfigReloaded records a configuration read on SIGHUP: the settings
+// applied and those that changed but need a restart, or, when err is
+// set, that the whole change was rejected. Area digests are included so
+// a changed geofence or jurisdiction is on record even when not applied.
+func (rec *Recorder) ConfigReloaded(service string, prev, next *config.Config, err error) {
+	if rec == nil {
+		return
+	}
+	applied, restart := prev.Changes(next)
+	detail := map[string]any{
+		"digest":        configDigest(next),
+		"geofences":     areaDigests(next.Geofences),
+		"jurisdictions": areaDigests(next.Jurisdictions),
+	}
+	if err != nil {
+		detail["rejected"] = err.Error()
+		detail["changed"] = append(applied, restart...)
+	} else {
+		detail["applied"] = applied
+	}
+	if len(restart) > 0 {
+		detail["needs_restart"] = restart
+	}
+	rec.recordConfig(service, ActionConfigReload, detail)
+}
+
+func (rec *Recorder) recordConfig(service, action string, detail map[string]any) {
+	e := &models.AuditEvent{
+		Actor:     service,
+		ActorKind: KindService,
+		Action:    action,
+		Resource:  "config",
+	}
+	if err := rec.Record(e, detail); err != nil {
+		logger.Warn("Configuration change not audited", "action", action, logging.Err(err))
+	}
+}
+
+// configDigest hashes the effective configuration as written by
+// WriteYAML, which leaves secrets out
+func configDigest(cfg *config.Config) string {
+	var buf bytes.Buffer
+	if err := cfg.WriteYAML(&buf); err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(buf.Bytes())
+	return hex.EncodeToString(sum[:])
+}
+
+// areaDigests maps each named area to a hash of its definition
+func areaDigests(areas []config.Geofence) map[string]string {
+	digests := make(map[string]string, len(areas))
+	for _, a := range areas {
+		data, _ := json.Marshal(a)
+		sum := sha256.Sum256(data)
+		digests[a.Name] = hex.EncodeToString(sum[:])
+	}
+	return digests
+}
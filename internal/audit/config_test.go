@@ -0,0 +1,52 @@
+package audit
+
+import (
+	"encoding/json"
+	"testing"
+
+	"silentraven/internal/storage"
+	"silentraven/pkg/config"
+)
+
+// A reload that changes restart-only settings is audited when it is
+// rejected, with the changed areas, not only at the next start
+func TestConfigReloaded(t *testing.T) {
+	store := storage.NewMemoryStore()
+	rec := New(store)
+
+	prev := &config.Config{LogLevel: "info"}
+	next := &config.Config{
+		LogLevel:      "debug",
+		Jurisdictions: []config.Geofence{{Name: "north", Latitude: 51.5, Longitude: -0.1, RadiusM: 5000}},
+	}
+	rec.ConfigReloaded("api", prev, next, prev.CheckReload(next))
+	applied := &config.Config{LogLevel: "debug"}
+	rec.ConfigReloaded("api", prev, applied, nil)
+
+	got := events(t, store)
+	if len(got) != 2 {
+		t.Fatalf("events = %+v, want 2", got)
+	}
+	var rejected, ok struct {
+		Rejected      string            `json:"rejected"`
+		Changed       []string          `json:"changed"`
+		Applied       []string          `json:"applied"`
+		NeedsRestart  []string          `json:"needs_restart"`
+		Jurisdictions map[string]string `json:"jurisdictions"`
+	}
+	if err := json.Unmarshal([]byte(got[0].Detail), &rejected); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(got[1].Detail), &ok); err != nil {
+		t.Fatal(err)
+	}
+
+	if got[0].Action != ActionConfigReload || got[0].Actor != "api" || rejected.Rejected == "" ||
+		len(rejected.Applied) != 0 || len(rejected.Changed) != 2 ||
+		len(rejected.NeedsRestart) != 1 || rejected.NeedsRestart[0] != "jurisdictions" || rejected.Jurisdictions["north"] == "" {
+		t.Errorf("rejected reload = %+v: %s", got[0], got[0].Detail)
+	}
+	if ok.Rejected != "" || len(ok.Applied) != 1 || ok.Applied[0] != "logging.level" || len(ok.NeedsRestart) != 0 {
+		t.Errorf("applied reload = %s", got[1].Detail)
+	}
+}
@@ -0,0 +1,57 @@
+package audit
+
+import (
+	"fmt"
+
+	"silentraven/internal/database"
+	"silentraven/internal/models"
+	"silentraven/internal/storage"
+)
+
+// ChainError reports where the audit chain stops verifying
+type ChainError struct {
+	Seq     int64
+	Problem string
+}
+
+func (e *ChainError) Error() string {
+	return fmt.Sprintf("audit log broken at seq %d: %s", e.Seq, e.Problem)
+}
+
+// Verify walks the whole audit log checking that sequence numbers have
+// no gaps, each entry's hash matches its contents and each entry links to
+// the one before. It returns the last entry, whose hash fixes every entry
+// up to it: recording it elsewhere lets a later Verify detect the log
+// being cut short.
+func Verify(store storage.AuditStore) (head models.AuditEvent, count int64, err error) {
+	err = store.StreamAudit(0, func(e models.AuditEvent) error {
+		switch {
+		case e.Seq != head.Seq+1:
+			return &ChainError{Seq: e.Seq, Problem: fmt.Sprintf("follows seq %d", head.Seq)}
+		case e.PrevHash != head.Hash:
+			return &ChainError{Seq: e.Seq, Problem: fmt.Sprintf("previous hash does not match seq %d", head.Seq)}
+		case e.ComputeHash() != e.Hash:
+			return &ChainError{Seq: e.Seq, Problem: "contents do not match hash"}
+		}
+		head = e
+		count++
+		return nil
+	})
+	return head, count, err
+}
+
+// CheckAnchor checks that a previously recorded head is still in the
+// verified chain ending at head
+func CheckAnchor(store storage.AuditStore, head models.AuditEvent, seq int64, hash string) error {
+	if seq > head.Seq {
+		return &ChainError{Seq: seq, Problem: fmt.Sprintf("log ends at seq %d; entries were removed", head.Seq)}
+	}
+	events, err := store.QueryAudit(database.AuditFilter{AfterSeq: seq - 1, Limit: 1})
+	if err != nil {
+		return err
+	}
+	if len(events) == 0 || events[0].Seq != seq || events[0].Hash != hash {
+		return &ChainError{Seq: seq, Problem: "hash differs from the recorded anchor"}
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"silentraven/internal/models"
+	"silentraven/internal/storage"
+)
+
+// Keys look like sr_<id>_<secret>: the 12 hex character ID locates the
+// stored hash, the secret is 32 random bytes in base64url
+const (
+	keyPrefix = "sr_"
+	idLength  = 12
+)
+
+// NewKey generates a key with the kind, subject, name, role,
+// jurisdiction and expiry of spec, returning the plaintext to hand out
+// once and the record to store. A zero expiry never expires.
+func NewKey(spec models.APIKey) (string, *models.APIKey, error) {
+	switch spec.Kind {
+	case models.KeyKindNode:
+		if spec.Role != "" || spec.Jurisdiction != "" {
+			return "", nil, fmt.Errorf("node keys only submit to the gateway and take no role or jurisdiction")
+		}
+	case models.KeyKindClient:
+		if err := checkRole(spec.Role, spec.Jurisdiction); err != nil {
+			return "", nil, err
+		}
+	default:
+		return "", nil, fmt.Errorf("invalid key kind %q: want node or client", spec.Kind)
+	}
+	if spec.Subject == "" {
+		return "", nil, fmt.Errorf("a %s key needs a subject", spec.Kind)
+	}
+
+	var raw [idLength/2 + 32]byte
+	if _, err := rand.Read(raw[:]); err != nil {
+		return "", nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+	id := hex.EncodeToString(raw[:idLength/2])
+	secret := base64.RawURLEncoding.EncodeToString(raw[idLength/2:])
+
+	key := &models.APIKey{
+		ID:           id,
+		Name:         spec.Name,
+		Kind:         spec.Kind,
+		Subject:      spec.Subject,
+		Role:         spec.Role,
+		Jurisdiction: spec.Jurisdiction,
+		Hash:         hashSecret(secret),
+		ExpiresAt:    spec.ExpiresAt,
+	}
+	return keyPrefix + id + "_" + secret, key, nil
+}
+
+// Issue generates and stores a key like spec valid for ttl (0 never
+// expires)
+func Issue(keys storage.APIKeyStore, spec models.APIKey, ttl time.Duration) (string, *models.APIKey, error) {
+	spec.ExpiresAt = time.Time{}
+	if ttl > 0 {
+		spec.ExpiresAt = time.Now().Add(ttl)
+	}
+	plaintext, key, err := NewKey(spec)
+	if err != nil {
+		return "", nil, err
+	}
+	if err := keys.CreateAPIKey(key); err != nil {
+		return "", nil, err
+	}
+	return plaintext, key, nil
+}
+
+// Rotate issues a replacement for key id with the same kind, subject,
+// name, role, jurisdiction and lifetime. The old key keeps working for grace, so holders can
+// switch over without downtime.
+func Rotate(keys storage.APIKeyStore, id string, grace time.Duration) (string, *models.APIKey, error) {
+	old, err := keys.GetAPIKey(id)
+	if err != nil {
+		return "", nil, err
+	}
+	now := time.Now()
+	if !old.Active(now) {
+		return "", nil, fmt.Errorf("key %s is revoked or expired", id)
+	}
+
+	var ttl time.Duration
+	if !old.ExpiresAt.IsZero() {
+		ttl = old.ExpiresAt.Sub(old.CreatedAt)
+	}
+	plaintext, key, err := Issue(keys, *old, ttl)
+	if err != nil {
+		return "", nil, err
+	}
+	if err := keys.ReplaceAPIKey(id, key.ID, now.Add(grace)); err != nil {
+		return "", nil, err
+	}
+	return plaintext, key, nil
+}
+
+// parseKey splits a plaintext key into its ID and secret
+func parseKey(s string) (id, secret string, ok bool) {
+	rest, ok := strings.CutPrefix(s, keyPrefix)
+	if !ok || len(rest) < idLength+2 || rest[idLength] != '_' {
+		return "", "", false
+	}
+	return rest[:idLength], rest[idLength+1:], true
+}
+
+// hashSecret hashes a key secret. Secrets are 256 random bits, so a
+// plain SHA-256 needs no salt or stretching.
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func checkSecret(key *models.APIKey, secret string) bool {
+	return subtle.ConstantTimeCompare([]byte(hashSecret(secret)), []byte(key.Hash)) == 1
+}
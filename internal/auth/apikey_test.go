@@ -0,0 +1,183 @@
+package auth
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"silentraven/internal/models"
+	"silentraven/internal/storage"
+)
+
+func TestNewKey(t *testing.T) {
+	plaintext, key, err := NewKey(models.APIKey{Kind: models.KeyKindClient, Subject: "dash", Role: RolePublic})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, secret, ok := parseKey(plaintext)
+	if !ok || id != key.ID || len(id) != idLength {
+		t.Fatalf("parseKey(%q) = %q, %q, %v", plaintext, id, secret, ok)
+	}
+	if strings.Contains(key.Hash, secret) || key.Hash != hashSecret(secret) || len(key.Hash) != 64 {
+		t.Errorf("hash = %q, want the hex SHA-256 of the secret", key.Hash)
+	}
+	if !checkSecret(key, secret) || checkSecret(key, secret+"x") {
+		t.Error("checkSecret does not match exactly the key's secret")
+	}
+
+	_, other, _ := NewKey(models.APIKey{Kind: models.KeyKindClient, Subject: "dash", Role: RolePublic})
+	if other.ID == key.ID || other.Hash == key.Hash {
+		t.Error("two keys share an ID or secret")
+	}
+
+	for name, spec := range map[string]models.APIKey{
+		"node with role":           {Kind: models.KeyKindNode, Subject: "node-1", Role: RoleAdmin},
+		"node with jurisdiction":   {Kind: models.KeyKindNode, Subject: "node-1", Jurisdiction: "north"},
+		"client with unknown role": {Kind: models.KeyKindClient, Subject: "dash", Role: "root"},
+		"client without role":      {Kind: models.KeyKindClient, Subject: "dash"},
+		"partner, no jurisdiction": {Kind: models.KeyKindClient, Subject: "dash", Role: RolePartner},
+		"no subject":               {Kind: models.KeyKindClient, Role: RolePublic},
+		"user kind":                {Kind: KindUser, Subject: "alice", Role: RoleAdmin},
+	} {
+		if _, _, err := NewKey(spec); err == nil {
+			t.Errorf("%s: key issued", name)
+		}
+	}
+}
+
+func TestParseKey(t *testing.T) {
+	tests := []struct {
+		in         string
+		id, secret string
+		ok         bool
+	}{
+		{"sr_0123456789ab_secret", "0123456789ab", "secret", true},
+		{"sr_0123456789ab_s", "0123456789ab", "s", true},
+		{"sr_0123456789ab_", "", "", false},
+		{"sr_0123456789ab", "", "", false},
+		{"sr_0123456789a_secret", "", "", false},
+		{"sr_0123456789abXsecret", "", "", false},
+		{"xx_0123456789ab_secret", "", "", false},
+		{"", "", "", false},
+	}
+	for _, tt := range tests {
+		id, secret, ok := parseKey(tt.in)
+		if id != tt.id || secret != tt.secret || ok != tt.ok {
+			t.Errorf("parseKey(%q) = %q, %q, %v; want %q, %q, %v", tt.in, id, secret, ok, tt.id, tt.secret, tt.ok)
+		}
+	}
+}
+
+// authenticate presents credential as an API key to a fresh
+// authenticator, so nothing is served from the cache
+func authenticate(keys storage.APIKeyStore, credential string) (*Principal, error) {
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set(APIKeyHeader, credential)
+	return New(keys, nil).Authenticate(r)
+}
+
+func TestAuthenticateKey(t *testing.T) {
+	store := storage.NewMemoryStore()
+	spec := models.APIKey{Kind: models.KeyKindClient, Subject: "agency", Role: RolePartner, Jurisdiction: "north"}
+	plaintext, key, err := Issue(store, spec, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := authenticate(store, plaintext)
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if p.Kind != spec.Kind || p.Subject != spec.Subject || p.Role != spec.Role ||
+		p.Jurisdiction != spec.Jurisdiction || p.KeyID != key.ID || p.Method != MethodAPIKey {
+		t.Errorf("principal = %+v", p)
+	}
+
+	id, secret, _ := parseKey(plaintext)
+	for name, credential := range map[string]string{
+		"wrong secret": keyPrefix + id + "_" + secret + "x",
+		"unknown id":   keyPrefix + "000000000000_" + secret,
+		"malformed":    keyPrefix + id,
+		"token":        "not.a.token",
+	} {
+		if _, err := authenticate(store, credential); !errors.Is(err, ErrInvalidCredentials) {
+			t.Errorf("%s: err = %v, want ErrInvalidCredentials", name, err)
+		}
+	}
+
+	if err := store.RevokeAPIKey(key.ID, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := authenticate(store, plaintext); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("revoked key: err = %v, want ErrInvalidCredentials", err)
+	}
+
+	expiredSpec := spec
+	expiredSpec.ExpiresAt = time.Now().Add(-time.Second)
+	expired, record, err := NewKey(expiredSpec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.CreateAPIKey(record); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := authenticate(store, expired); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("expired key: err = %v, want ErrInvalidCredentials", err)
+	}
+}
+
+func TestRotate(t *testing.T) {
+	store := storage.NewMemoryStore()
+	spec := models.APIKey{Kind: models.KeyKindClient, Name: "dashboard", Subject: "dash", Role: RoleAnalyst}
+	oldPlain, old, err := Issue(store, spec, 24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newPlain, replacement, err := Rotate(store, old.ID, time.Hour)
+	if err != nil {
+		t.Fatalf("Rotate: %v", err)
+	}
+	if replacement.ID == old.ID || replacement.Subject != spec.Subject || replacement.Role != spec.Role ||
+		replacement.Name != spec.Name || replacement.Kind != spec.Kind {
+		t.Errorf("replacement = %+v, want a new key like %+v", replacement, spec)
+	}
+	if lifetime := replacement.ExpiresAt.Sub(replacement.CreatedAt); lifetime < 23*time.Hour || lifetime > 25*time.Hour {
+		t.Errorf("replacement lifetime = %v, want the old key's 24h", lifetime)
+	}
+
+	stored, err := store.GetAPIKey(old.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.ReplacedBy != replacement.ID {
+		t.Errorf("old key replaced by %q, want %q", stored.ReplacedBy, replacement.ID)
+	}
+	if until := time.Until(stored.ExpiresAt); until <= 0 || until > time.Hour {
+		t.Errorf("old key expires in %v, want within the 1h grace", until)
+	}
+
+	// Both keys work during the grace period
+	for _, plaintext := range []string{oldPlain, newPlain} {
+		if _, err := authenticate(store, plaintext); err != nil {
+			t.Errorf("Authenticate during grace: %v", err)
+		}
+	}
+
+	// Without grace the old key stops at once and cannot be rotated again
+	_, again, err := Rotate(store, replacement.ID, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := authenticate(store, newPlain); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("key rotated without grace: err = %v, want ErrInvalidCredentials", err)
+	}
+	if _, _, err := Rotate(store, replacement.ID, time.Hour); err == nil {
+		t.Error("an expired key was rotated")
+	}
+	if again.ExpiresAt.IsZero() {
+		t.Error("rotation dropped the key's expiry")
+	}
+}
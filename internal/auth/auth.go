@@ -0,0 +1,240 @@
+// Package auth authenticates callers of the gateway and query API. Sensor
+// nodes and integrations present hashed API keys; clients can exchange a
+// key for a short-lived HS256 JWT bearer token.
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"silentraven/internal/database"
+	"silentraven/internal/logging"
+	"silentraven/internal/metrics"
+	"silentraven/internal/models"
+	"silentraven/internal/storage"
+)
+
+var logger = logging.For("auth")
+
+// KindUser is the principal kind of tokens issued to people rather than
+// to a key holder
+const KindUser = "user"
+
+// Authentication methods
+const (
+	MethodAPIKey = "api_key"
+	MethodToken  = "token"
+)
+
+// APIKeyHeader carries an API key; keys are also accepted as bearer tokens
+const APIKeyHeader = "X-API-Key"
+
+var (
+	// ErrNoCredentials is returned for requests without a key or token
+	ErrNoCredentials = errors.New("missing API key or bearer token")
+	// ErrInvalidCredentials covers unknown, revoked, expired and
+	// malformed keys and tokens alike
+	ErrInvalidCredentials = errors.New("invalid API key or bearer token")
+)
+
+// cacheTTL bounds how long a revoked key keeps working on a service that
+// has already seen it, and how often last-used times are recorded
+const cacheTTL = 30 * time.Second
+
+// Principal is an authenticated caller
+type Principal struct {
+	// Kind is models.KeyKindNode, models.KeyKindClient or KindUser
+	Kind    string `json:"kind"`
+	Subject string `json:"subject"`
+	// Role names the built-in role granting permissions on the query API
+	Role string `json:"role,omitempty"`
+	// Jurisdiction names the area a scoped caller is limited to
+	Jurisdiction string `json:"jurisdiction,omitempty"`
+	// KeyID is the API key used, if any
+	KeyID  string `json:"key_id,omitempty"`
+	Method string `json:"method"`
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns ctx carrying p
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// FromContext returns the caller authenticated for ctx, or nil when
+// authentication is off
+func FromContext(ctx context.Context) *Principal {
+	p, _ := ctx.Value(principalKey{}).(*Principal)
+	return p
+}
+
+// Authenticator checks API keys against a key store and tokens against
+// a signing secret
+type Authenticator struct {
+	keys   storage.APIKeyStore
+	secret []byte
+
+	mu    sync.Mutex
+	cache map[string]cachedKey
+}
+
+type cachedKey struct {
+	key     *models.APIKey
+	fetched time.Time
+}
+
+// New creates an authenticator. A nil secret disables bearer tokens.
+func New(keys storage.APIKeyStore, secret []byte) *Authenticator {
+	return &Authenticator{
+		keys:   keys,
+		secret: secret,
+		cache:  make(map[string]cachedKey),
+	}
+}
+
+// Authenticate checks the credentials on r: an API key in X-API-Key, or
+// an API key or token as an Authorization bearer token
+func (a *Authenticator) Authenticate(r *http.Request) (*Principal, error) {
+	credential := r.Header.Get(APIKeyHeader)
+	if credential == "" {
+		bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok {
+			return nil, ErrNoCredentials
+		}
+		credential = strings.TrimSpace(bearer)
+	}
+
+	if strings.HasPrefix(credential, keyPrefix) {
+		return a.authenticateKey(credential)
+	}
+	if a.secret == nil {
+		return nil, ErrInvalidCredentials
+	}
+	claims, err := ParseToken(a.secret, credential, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return &Principal{
+		Kind:         claims.Kind,
+		Subject:      claims.Subject,
+		Role:         claims.Role,
+		Jurisdiction: claims.Jurisdiction,
+		Method:       MethodToken,
+	}, nil
+}
+
+func (a *Authenticator) authenticateKey(credential string) (*Principal, error) {
+	id, secret, ok := parseKey(credential)
+	if !ok {
+		return nil, ErrInvalidCredentials
+	}
+	key, err := a.key(id)
+	if errors.Is(err, database.ErrNotFound) {
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !checkSecret(key, secret) || !key.Active(time.Now()) {
+		return nil, ErrInvalidCredentials
+	}
+	return &Principal{
+		Kind:         key.Kind,
+		Subject:      key.Subject,
+		Role:         key.Role,
+		Jurisdiction: key.Jurisdiction,
+		KeyID:        key.ID,
+		Method:       MethodAPIKey,
+	}, nil
+}
+
+// key returns the stored key id, from the cache when fresh. Each fetch
+// also records the key as used.
+func (a *Authenticator) key(id string) (*models.APIKey, error) {
+	now := time.Now()
+	a.mu.Lock()
+	cached, ok := a.cache[id]
+	a.mu.Unlock()
+	if ok && now.Sub(cached.fetched) < cacheTTL {
+		return cached.key, nil
+	}
+
+	key, err := a.keys.GetAPIKey(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.keys.TouchAPIKey(id, now); err != nil {
+		logger.Warn("Failed to record API key use", "key_id", id, logging.Err(err))
+	}
+
+	a.mu.Lock()
+	a.cache[id] = cachedKey{key: key, fetched: now}
+	a.mu.Unlock()
+	return key, nil
+}
+
+// Middleware rejects requests without valid credentials and, when kinds
+// are given, those from other kinds of principal. A nil Authenticator
+// lets every request through, for AUTH_MODE=off.
+func (a *Authenticator) Middleware(kinds ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if a == nil {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p, err := a.Authenticate(r)
+			switch {
+			case errors.Is(err, ErrNoCredentials), errors.Is(err, ErrInvalidCredentials):
+				reject(w, r, http.StatusUnauthorized, err)
+				return
+			case err != nil:
+				logger.ErrorContext(r.Context(), "Authentication failed", logging.Err(err))
+				reject(w, r, http.StatusServiceUnavailable, errors.New("authentication unavailable"))
+				return
+			case len(kinds) > 0 && !contains(kinds, p.Kind):
+				reject(w, r, http.StatusForbidden, errors.New(p.Kind+" credentials cannot be used here"))
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), p)))
+		})
+	}
+}
+
+// Forbid rejects a request that authenticated but may not do what it
+// asked, e.g. a node submitting as another node
+func Forbid(w http.ResponseWriter, r *http.Request, err error) {
+	reject(w, r, http.StatusForbidden, err)
+}
+
+// reject writes an error response and counts the failure
+func reject(w http.ResponseWriter, r *http.Request, status int, err error) {
+	reason := map[int]string{
+		http.StatusUnauthorized:       "unauthenticated",
+		http.StatusForbidden:          "forbidden",
+		http.StatusServiceUnavailable: "unavailable",
+	}[status]
+	metrics.AuthFailures.WithLabelValues(reason).Inc()
+	logger.WarnContext(r.Context(), "Rejected request", "reason", reason, "path", r.URL.Path, logging.Err(err))
+
+	if status == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="silentraven"`)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(models.APIResponse{Success: false, Error: err.Error()})
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,100 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// issuer is the iss claim of every token
+const issuer = "silentraven"
+
+// clockSkew is how far ahead of a service's clock a token's nbf may be,
+// for services on other hosts than the one that issued it
+const clockSkew = 30 * time.Second
+
+// Claims are the JWT claims the services issue and accept
+type Claims struct {
+	Issuer    string `json:"iss"`
+	Subject   string `json:"sub"`
+	Kind      string `json:"kind"`
+	IssuedAt  int64  `json:"iat"`
+	NotBefore int64  `json:"nbf,omitempty"`
+	ExpiresAt int64  `json:"exp"`
+
+	Role         string `json:"role,omitempty"`
+	Jurisdiction string `json:"jurisdiction,omitempty"`
+}
+
+// jwtHeader is the only header accepted: HS256, so a token cannot pick
+// its own verification algorithm
+var jwtHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+// IssueToken signs a token for p's kind, subject, role and jurisdiction
+// valid for ttl
+func IssueToken(secret []byte, p Principal, ttl time.Duration) (string, time.Time, error) {
+	if len(secret) == 0 {
+		return "", time.Time{}, errors.New("no token signing secret configured")
+	}
+	if err := checkRole(p.Role, p.Jurisdiction); err != nil {
+		return "", time.Time{}, err
+	}
+	now := time.Now()
+	expires := now.Add(ttl)
+	payload, err := json.Marshal(Claims{
+		Issuer:       issuer,
+		Subject:      p.Subject,
+		Kind:         p.Kind,
+		IssuedAt:     now.Unix(),
+		NotBefore:    now.Unix(),
+		ExpiresAt:    expires.Unix(),
+		Role:         p.Role,
+		Jurisdiction: p.Jurisdiction,
+	})
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to encode token: %w", err)
+	}
+
+	signed := jwtHeader + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return signed + "." + sign(secret, signed), expires, nil
+}
+
+// ParseToken verifies a token's signature and lifetime at now. Only the
+// HS256 header IssueToken writes is accepted.
+func ParseToken(secret []byte, token string, now time.Time) (*Claims, error) {
+	header, rest, ok := strings.Cut(token, ".")
+	if !ok || header != jwtHeader {
+		return nil, ErrInvalidCredentials
+	}
+	payload, signature, ok := strings.Cut(rest, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(sign(secret, header+"."+payload))) {
+		return nil, ErrInvalidCredentials
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, ErrInvalidCredentials
+	}
+	var claims Claims
+	if err := json.Unmarshal(data, &claims); err != nil {
+		return nil, ErrInvalidCredentials
+	}
+	if claims.Issuer != issuer || claims.Subject == "" || now.Unix() >= claims.ExpiresAt {
+		return nil, ErrInvalidCredentials
+	}
+	if claims.NotBefore != 0 && now.Add(clockSkew).Unix() < claims.NotBefore {
+		return nil, ErrInvalidCredentials
+	}
+	return &claims, nil
+}
+
+func sign(secret []byte, signed string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(signed))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
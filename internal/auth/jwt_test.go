@@ -0,0 +1,119 @@
+package auth
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"silentraven/internal/models"
+)
+
+var secret = []byte("test-secret")
+
+// craft builds a token with any header and claims, signed HS256 with key
+func craft(t *testing.T, key []byte, header string, c Claims) string {
+	t.Helper()
+	payload, err := json.Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed := base64.RawURLEncoding.EncodeToString([]byte(header)) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return signed + "." + sign(key, signed)
+}
+
+func TestParseToken(t *testing.T) {
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	valid := Claims{
+		Issuer: issuer, Subject: "dash", Kind: models.KeyKindClient, Role: RolePublic,
+		IssuedAt: now.Unix(), NotBefore: now.Unix(), ExpiresAt: now.Add(time.Hour).Unix(),
+	}
+	hs256 := `{"alg":"HS256","typ":"JWT"}`
+	with := func(modify func(c *Claims)) Claims {
+		c := valid
+		modify(&c)
+		return c
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		ok    bool
+	}{
+		{"valid", craft(t, secret, hs256, valid), true},
+		{"no nbf", craft(t, secret, hs256, with(func(c *Claims) { c.NotBefore = 0 })), true},
+		{"nbf within clock skew", craft(t, secret, hs256, with(func(c *Claims) { c.NotBefore = now.Add(clockSkew).Unix() })), true},
+		{"alg none", craft(t, secret, `{"alg":"none","typ":"JWT"}`, valid), false},
+		{"alg RS256", craft(t, secret, `{"alg":"RS256","typ":"JWT"}`, valid), false},
+		{"header reordered", craft(t, secret, `{"typ":"JWT","alg":"HS256"}`, valid), false},
+		{"wrong secret", craft(t, []byte("other"), hs256, valid), false},
+		{"expired", craft(t, secret, hs256, with(func(c *Claims) { c.ExpiresAt = now.Add(-time.Second).Unix() })), false},
+		{"expires now", craft(t, secret, hs256, with(func(c *Claims) { c.ExpiresAt = now.Unix() })), false},
+		{"no exp", craft(t, secret, hs256, with(func(c *Claims) { c.ExpiresAt = 0 })), false},
+		{"not yet valid", craft(t, secret, hs256, with(func(c *Claims) { c.NotBefore = now.Add(clockSkew + time.Minute).Unix() })), false},
+		{"wrong issuer", craft(t, secret, hs256, with(func(c *Claims) { c.Issuer = "someone-else" })), false},
+		{"no subject", craft(t, secret, hs256, with(func(c *Claims) { c.Subject = "" })), false},
+		{"two parts", strings.Join(strings.Split(craft(t, secret, hs256, valid), ".")[:2], "."), false},
+		{"empty", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := ParseToken(secret, tt.token, now)
+			if tt.ok {
+				if err != nil || claims.Subject != valid.Subject || claims.Role != valid.Role {
+					t.Errorf("ParseToken = %+v, %v; want the claims", claims, err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrInvalidCredentials) {
+				t.Errorf("ParseToken = %+v, %v; want ErrInvalidCredentials", claims, err)
+			}
+		})
+	}
+}
+
+// A token signed with alg none has an empty signature part
+func TestParseTokenUnsigned(t *testing.T) {
+	payload, _ := json.Marshal(Claims{Issuer: issuer, Subject: "x", ExpiresAt: time.Now().Add(time.Hour).Unix()})
+	for _, header := range []string{`{"alg":"none","typ":"JWT"}`, `{"alg":"HS256","typ":"JWT"}`} {
+		token := base64.RawURLEncoding.EncodeToString([]byte(header)) + "." + base64.RawURLEncoding.EncodeToString(payload) + "."
+		if _, err := ParseToken(secret, token, time.Now()); !errors.Is(err, ErrInvalidCredentials) {
+			t.Errorf("unsigned %s token: err = %v, want ErrInvalidCredentials", header, err)
+		}
+	}
+}
+
+func TestIssueToken(t *testing.T) {
+	p := Principal{Kind: models.KeyKindClient, Subject: "agency", Role: RolePartner, Jurisdiction: "north"}
+	token, expires, err := IssueToken(secret, p, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := ParseToken(secret, token, time.Now())
+	if err != nil {
+		t.Fatalf("ParseToken: %v", err)
+	}
+	if claims.Subject != p.Subject || claims.Kind != p.Kind || claims.Role != p.Role || claims.Jurisdiction != p.Jurisdiction {
+		t.Errorf("claims = %+v, want %+v", claims, p)
+	}
+	if claims.ExpiresAt != expires.Unix() || claims.NotBefore != claims.IssuedAt {
+		t.Errorf("claims lifetime = iat %d nbf %d exp %d", claims.IssuedAt, claims.NotBefore, claims.ExpiresAt)
+	}
+	if _, err := ParseToken(secret, token, expires); err == nil {
+		t.Error("token accepted at its expiry")
+	}
+
+	for name, bad := range map[string]Principal{
+		"unknown role":             {Subject: "x", Role: "root"},
+		"partner, no jurisdiction": {Subject: "x", Role: RolePartner},
+	} {
+		if _, _, err := IssueToken(secret, bad, time.Hour); err == nil {
+			t.Errorf("%s: token issued", name)
+		}
+	}
+	if _, _, err := IssueToken(nil, p, time.Hour); err == nil {
+		t.Error("token issued without a secret")
+	}
+}
@@ -0,0 +1,160 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"silentraven/internal/models"
+)
+
+// Permission is something a role may do on the query API
+type Permission string
+
+// Permissions
+const (
+	// ReadDetections covers detection, track and nearest queries
+	ReadDetections Permission = "detections:read"
+	// ReadOperator reveals operator positions; without it they are
+	// redacted and cannot be searched on
+	ReadOperator Permission = "detections:operator"
+	// ReadRaw reveals raw Remote ID payloads
+	ReadRaw Permission = "detections:raw"
+	// ExportTracks covers KML/KMZ/GeoJSON track export
+	ExportTracks Permission = "tracks:export"
+	// ExportEvidence covers signed evidence bundles, which hold raw
+	// packets and operator positions for any area
+	ExportEvidence Permission = "evidence:export"
+	// ReadStats covers the system-wide statistics
+	ReadStats Permission = "stats:read"
+	// ReadNodes covers the sensor node registry and coverage
+	ReadNodes Permission = "nodes:read"
+	// ManageNodes covers registering and deleting sensor nodes
+	ManageNodes Permission = "nodes:write"
+	// ReadCompliance covers Remote ID compliance reports
+	ReadCompliance Permission = "compliance:read"
+	// ReadCases covers enforcement cases
+	ReadCases Permission = "cases:read"
+	// ManageCases covers opening, assigning, updating and annotating cases
+	ManageCases Permission = "cases:write"
+	// ReadAudit covers the audit log
+	ReadAudit Permission = "audit:read"
+	// Ingest covers submitting detections and heartbeats to the gateway
+	// for any node; node keys submit for their own node without it
+	Ingest Permission = "detections:ingest"
+)
+
+// Role is a named set of permissions
+type Role struct {
+	Name        string
+	Permissions []Permission
+	// Scoped roles must be limited to a jurisdiction
+	Scoped bool
+}
+
+// Built-in roles
+const (
+	RoleAdmin   = "admin"
+	RoleAnalyst = "analyst"
+	RolePartner = "partner"
+	RolePublic  = "public"
+	RoleIngest  = "ingest"
+)
+
+var roles = map[string]Role{
+	RoleAdmin: {Name: RoleAdmin, Permissions: []Permission{
+		ReadDetections, ReadOperator, ReadRaw, ExportTracks, ExportEvidence, ReadStats, ReadNodes, ManageNodes,
+		ReadCompliance, ReadCases, ManageCases, ReadAudit,
+	}},
+	RoleAnalyst: {Name: RoleAnalyst, Permissions: []Permission{
+		ReadDetections, ReadOperator, ReadRaw, ExportTracks, ExportEvidence, ReadStats, ReadNodes,
+		ReadCompliance, ReadCases, ManageCases,
+	}},
+	// Partner agencies see full tracks within their jurisdiction only;
+	// statistics and the node registry span every area, so they get none
+	RolePartner: {Name: RolePartner, Scoped: true, Permissions: []Permission{
+		ReadDetections, ReadOperator, ExportTracks, ReadCompliance,
+	}},
+	// Public dashboards see drones but never where their operators are
+	RolePublic: {Name: RolePublic, Permissions: []Permission{
+		ReadDetections, ReadStats,
+	}},
+	// Simulators, replay and other feeds submitting for many nodes
+	RoleIngest: {Name: RoleIngest, Permissions: []Permission{
+		Ingest,
+	}},
+}
+
+// LookupRole returns the built-in role called name
+func LookupRole(name string) (Role, bool) {
+	r, ok := roles[name]
+	return r, ok
+}
+
+// RoleNames lists the built-in roles
+func RoleNames() []string {
+	names := make([]string, 0, len(roles))
+	for name := range roles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Has reports whether the role grants perm
+func (r Role) Has(perm Permission) bool {
+	for _, p := range r.Permissions {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrNoJurisdiction rejects a scoped role without a jurisdiction
+var ErrNoJurisdiction = errors.New("role is limited to a jurisdiction but none is set")
+
+// Can reports whether p may use perm. A nil principal, i.e. with
+// authentication off, may do anything; unknown roles may do nothing.
+func (p *Principal) Can(perm Permission) bool {
+	if p == nil {
+		return true
+	}
+	role, ok := LookupRole(p.Role)
+	return ok && role.Has(perm)
+}
+
+// Redact clears the fields of d that p may not see
+func (p *Principal) Redact(d *models.DroneDetection) {
+	if !p.Can(ReadOperator) {
+		d.OperatorLatitude, d.OperatorLongitude = 0, 0
+	}
+	if !p.Can(ReadRaw) {
+		d.RawData = ""
+	}
+}
+
+// Require wraps a handler so only callers with perm reach it
+func Require(perm Permission, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if p := FromContext(r.Context()); !p.Can(perm) {
+			reject(w, r, http.StatusForbidden, fmt.Errorf("role %q lacks %s", p.Role, perm))
+			return
+		}
+		next(w, r)
+	}
+}
+
+// checkRole validates the role and jurisdiction given to a key or token
+func checkRole(role, jurisdiction string) error {
+	r, ok := LookupRole(role)
+	if !ok {
+		return fmt.Errorf("unknown role %q: want one of %s", role, strings.Join(RoleNames(), ", "))
+	}
+	if r.Scoped && jurisdiction == "" {
+		return fmt.Errorf("the %s role needs a jurisdiction", role)
+	}
+	return nil
+}
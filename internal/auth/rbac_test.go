@@ -0,0 +1,110 @@
+package auth
+
+import (
+	"testing"
+
+	"silentraven/internal/models"
+)
+
+var allPermissions = []Permission{
+	ReadDetections, ReadOperator, ReadRaw, ExportTracks, ExportEvidence, ReadStats, ReadNodes,
+	ManageNodes, ReadCompliance, ReadCases, ManageCases, ReadAudit, Ingest,
+}
+
+func TestCan(t *testing.T) {
+	grants := map[string][]Permission{
+		RoleAdmin: {
+			ReadDetections, ReadOperator, ReadRaw, ExportTracks, ExportEvidence, ReadStats, ReadNodes,
+			ManageNodes, ReadCompliance, ReadCases, ManageCases, ReadAudit,
+		},
+		RoleAnalyst: {
+			ReadDetections, ReadOperator, ReadRaw, ExportTracks, ExportEvidence, ReadStats, ReadNodes,
+			ReadCompliance, ReadCases, ManageCases,
+		},
+		RolePartner: {ReadDetections, ReadOperator, ExportTracks, ReadCompliance},
+		RolePublic:  {ReadDetections, ReadStats},
+		RoleIngest:  {Ingest},
+		"":          nil,
+		"root":      nil,
+	}
+	if len(grants)-2 != len(RoleNames()) {
+		t.Fatalf("roles %v are not all covered", RoleNames())
+	}
+
+	for role, granted := range grants {
+		want := make(map[Permission]bool)
+		for _, perm := range granted {
+			want[perm] = true
+		}
+		p := &Principal{Kind: models.KeyKindClient, Subject: "x", Role: role}
+		for _, perm := range allPermissions {
+			if got := p.Can(perm); got != want[perm] {
+				t.Errorf("role %q Can(%s) = %v, want %v", role, perm, got, want[perm])
+			}
+		}
+	}
+
+	// With authentication off there is no principal and no limit
+	var off *Principal
+	for _, perm := range allPermissions {
+		if !off.Can(perm) {
+			t.Errorf("nil principal Can(%s) = false", perm)
+		}
+	}
+}
+
+func TestRedact(t *testing.T) {
+	full := models.DroneDetection{
+		UASID: "uas-1", Latitude: 51.47, Longitude: -0.45,
+		OperatorLatitude: 51.46, OperatorLongitude: -0.44, RawData: `{"UASID":"uas-1"}`,
+	}
+	tests := []struct {
+		role         string
+		operator     bool
+		raw          bool
+		jurisdiction string
+	}{
+		{role: RoleAdmin, operator: true, raw: true},
+		{role: RoleAnalyst, operator: true, raw: true},
+		{role: RolePartner, operator: true, jurisdiction: "north"},
+		{role: RolePublic},
+		{role: RoleIngest},
+		{role: "unknown"},
+	}
+	for _, tt := range tests {
+		d := full
+		p := &Principal{Kind: models.KeyKindClient, Subject: "x", Role: tt.role, Jurisdiction: tt.jurisdiction}
+		p.Redact(&d)
+		if d.UASID != full.UASID || d.Latitude != full.Latitude || d.Longitude != full.Longitude {
+			t.Errorf("%s: drone position redacted: %+v", tt.role, d)
+		}
+		if gotOperator := d.OperatorLatitude != 0 || d.OperatorLongitude != 0; gotOperator != tt.operator {
+			t.Errorf("%s: operator position shown = %v, want %v", tt.role, gotOperator, tt.operator)
+		}
+		if gotRaw := d.RawData != ""; gotRaw != tt.raw {
+			t.Errorf("%s: raw data shown = %v, want %v", tt.role, gotRaw, tt.raw)
+		}
+	}
+
+	d := full
+	var off *Principal
+	off.Redact(&d)
+	if d != full {
+		t.Errorf("nil principal redacted %+v", d)
+	}
+}
+
+func TestScopedRoles(t *testing.T) {
+	for _, name := range RoleNames() {
+		role, _ := LookupRole(name)
+		if err := checkRole(name, ""); (err != nil) != role.Scoped {
+			t.Errorf("checkRole(%q, no jurisdiction) = %v, scoped %v", name, err, role.Scoped)
+		}
+		if err := checkRole(name, "north"); err != nil {
+			t.Errorf("checkRole(%q, north) = %v", name, err)
+		}
+	}
+	if role, _ := LookupRole(RolePartner); !role.Scoped {
+		t.Error("partner role is not scoped")
+	}
+}
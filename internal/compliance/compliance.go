@@ -0,0 +1,80 @@
+// Package compliance checks Remote ID broadcasts against ASTM F3411 and
+// FAA Part 89: single packets for values the message format cannot carry,
+// and tracks for the message types, operator location, motion and
+// broadcast rate a compliant transmitter produces.
+package compliance
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"silentraven/internal/models"
+)
+
+// ErrInvalidField marks a value outside its F3411 range, or a position
+// that is not on the globe
+var ErrInvalidField = errors.New("invalid Remote ID field")
+
+// F3411 value ranges
+const (
+	maxDirection       = 359    // degrees; 361 means unknown
+	maxHorizontalSpeed = 254.25 // m/s; 255 means unknown
+	maxVerticalSpeed   = 62     // m/s
+	minAltitude        = -1000  // m
+	maxAltitude        = 31767.5
+)
+
+// bound is a field's value and the range F3411 allows for it
+type bound struct {
+	name     string
+	value    float64
+	min, max float64
+}
+
+// unknown holds the values outside a field's range that F3411 uses to
+// mean unknown
+var unknown = map[string]float64{
+	"Direction": models.DirectionUnknown,
+}
+
+// Validate rejects a packet that cannot be stored: a drone or operator
+// position off the globe, returned as an ErrInvalidField error. Other
+// values F3411 cannot encode are kept, for Evaluate to flag; a zero
+// position means no Location was heard, which Evaluate also reports.
+func Validate(p *models.IncomingPacket) error {
+	return check([]bound{
+		{"Latitude", p.Latitude, -90, 90},
+		{"Longitude", p.Longitude, -180, 180},
+		{"OperatorLatitude", p.OperatorLatitude, -90, 90},
+		{"OperatorLongitude", p.OperatorLongitude, -180, 180},
+	})
+}
+
+// validDetection checks a stored detection's values against their F3411
+// ranges
+func validDetection(d *models.DroneDetection) error {
+	return check([]bound{
+		{"Latitude", d.Latitude, -90, 90},
+		{"Longitude", d.Longitude, -180, 180},
+		{"Direction", float64(d.Direction), 0, maxDirection},
+		{"SpeedHorizontal", d.SpeedHorizontal, 0, maxHorizontalSpeed},
+		{"SpeedVertical", d.SpeedVertical, -maxVerticalSpeed, maxVerticalSpeed},
+		{"Height", d.Height, minAltitude, maxAltitude},
+		{"OperatorLatitude", d.OperatorLatitude, -90, 90},
+		{"OperatorLongitude", d.OperatorLongitude, -180, 180},
+	})
+}
+
+// check returns an ErrInvalidField error for the first value out of range
+func check(bounds []bound) error {
+	for _, b := range bounds {
+		if u, ok := unknown[b.name]; ok && b.value == u {
+			continue
+		}
+		if math.IsNaN(b.value) || b.value < b.min || b.value > b.max {
+			return fmt.Errorf("%w: %s %g is outside [%g, %g]", ErrInvalidField, b.name, b.value, b.min, b.max)
+		}
+	}
+	return nil
+}
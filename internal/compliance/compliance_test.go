@@ -0,0 +1,89 @@
+package compliance
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"silentraven/internal/models"
+)
+
+func TestDirectionRange(t *testing.T) {
+	tests := []struct {
+		direction int
+		valid     bool
+	}{
+		{0, true},
+		{359, true},
+		{models.DirectionUnknown, true},
+		{360, false},
+		{362, false},
+		{-1, false},
+	}
+	for _, tt := range tests {
+		err := validDetection(&models.DroneDetection{Direction: tt.direction})
+		if valid := err == nil; valid != tt.valid {
+			t.Errorf("validDetection direction %d = %v, want valid: %v", tt.direction, err, tt.valid)
+		}
+		if err != nil && !errors.Is(err, ErrInvalidField) {
+			t.Errorf("validDetection direction %d = %v, want ErrInvalidField", tt.direction, err)
+		}
+	}
+}
+
+func TestValidateKeepsOutOfRangeValues(t *testing.T) {
+	tests := []struct {
+		name   string
+		packet models.IncomingPacket
+		valid  bool
+	}{
+		{"no position", models.IncomingPacket{}, true},
+		{"direction", models.IncomingPacket{Direction: 400}, true},
+		{"speed", models.IncomingPacket{SpeedHorizontal: 300, SpeedVertical: -80}, true},
+		{"height", models.IncomingPacket{Height: 40000}, true},
+		{"timestamp", models.IncomingPacket{Timestamp: "yesterday"}, true},
+		{"latitude", models.IncomingPacket{Latitude: 91}, false},
+		{"longitude", models.IncomingPacket{Longitude: -181}, false},
+		{"operator latitude", models.IncomingPacket{OperatorLatitude: -90.5}, false},
+		{"operator longitude", models.IncomingPacket{OperatorLongitude: 200}, false},
+	}
+	for _, tt := range tests {
+		err := Validate(&tt.packet)
+		if valid := err == nil; valid != tt.valid {
+			t.Errorf("%s: Validate = %v, want valid: %v", tt.name, err, tt.valid)
+		}
+	}
+}
+
+func TestEvaluateFlagsFieldsOutOfRange(t *testing.T) {
+	start := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	var detections []models.DroneDetection
+	for i := 0; i < 6; i++ {
+		detections = append(detections, models.DroneDetection{
+			DetectionTime:     start.Add(time.Duration(i) * time.Second),
+			SN:                "sn-1",
+			UASID:             "uas-1",
+			Latitude:          51.47 + float64(i)*1e-5,
+			Longitude:         -0.45,
+			Direction:         90,
+			OperatorLatitude:  51.46,
+			OperatorLongitude: -0.45,
+		})
+	}
+	detections[2].SpeedVertical = 80
+	detections[4].Direction = 400
+
+	report := Evaluate(detections, Rules{MaxSpeed: 100})
+	var found bool
+	for _, f := range report.Findings {
+		if f.Check == CheckFields {
+			found = true
+			if f.Severity != SeverityError || f.Count != 2 {
+				t.Errorf("fields finding = %+v, want an error for 2 messages", f)
+			}
+		}
+	}
+	if !found || report.Status != StatusNonCompliant {
+		t.Errorf("report = %s with findings %+v, want non-compliant on fields", report.Status, report.Findings)
+	}
+}
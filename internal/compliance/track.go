@@ -0,0 +1,281 @@
+package compliance
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"silentraven/internal/geo"
+	"silentraven/internal/models"
+)
+
+// Track statuses, worst first
+const (
+	StatusNonCompliant = "non_compliant"
+	StatusWarning      = "warning"
+	// StatusInsufficient is for tracks too short to judge
+	StatusInsufficient = "insufficient_data"
+	StatusCompliant    = "compliant"
+)
+
+// ValidStatus reports whether s is a track status
+func ValidStatus(s string) bool {
+	switch s {
+	case StatusNonCompliant, StatusWarning, StatusInsufficient, StatusCompliant:
+		return true
+	}
+	return false
+}
+
+// Checks a track is scored on
+const (
+	// CheckFields covers values outside their F3411 range
+	CheckFields = "fields"
+	// CheckMessages covers the Basic ID and Location messages
+	CheckMessages = "message_types"
+	// CheckOperator covers the System message's operator location,
+	// required for standard Remote ID by Part 89
+	CheckOperator = "operator_location"
+	// CheckSpeed covers reported and implied speeds
+	CheckSpeed = "speed"
+	// CheckRate covers the one message a second Part 89 requires
+	CheckRate = "broadcast_rate"
+)
+
+// Finding severities
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// weights of each check in the score, out of 100
+var weights = map[string]float64{
+	CheckFields:   25,
+	CheckMessages: 20,
+	CheckOperator: 20,
+	CheckSpeed:    20,
+	CheckRate:     15,
+}
+
+const (
+	// minMessages is the fewest distinct broadcasts a track is judged on
+	minMessages = 5
+	// duplicateWindow merges copies of one broadcast heard by several nodes
+	duplicateWindow = 500 * time.Millisecond
+	// minInterval floors the time between positions when implying a
+	// speed, so receive-time jitter does not inflate it
+	minInterval = time.Second
+	// positionSlack allows for GPS error when implying a speed
+	positionSlack = 30 // m
+	// partialOperator is the share of messages with an operator location
+	// below which a track is warned about
+	partialOperator = 0.5
+)
+
+// Rules are the plausibility limits a track is held to
+type Rules struct {
+	// MaxSpeed is the fastest plausible ground speed in m/s
+	MaxSpeed float64
+	// MinRate is the broadcast rate in Hz below which a track is warned
+	// about (0 skips the check); receivers miss messages, so a low rate
+	// alone is not an error
+	MinRate float64
+}
+
+// Finding is one failed check
+type Finding struct {
+	Check    string `json:"check"`
+	Severity string `json:"severity"`
+	// Count is the number of messages affected, if the check is per
+	// message
+	Count   int    `json:"count,omitempty"`
+	Message string `json:"message"`
+}
+
+// Report is the compliance of one UAS's track
+type Report struct {
+	UASID  string    `json:"uas_id"`
+	SN     string    `json:"sn"`
+	Status string    `json:"status"`
+	Score  int       `json:"score"`
+	From   time.Time `json:"from"`
+	To     time.Time `json:"to"`
+	// Received counts detections, Messages the distinct broadcasts
+	// among them
+	Received int `json:"received"`
+	Messages int `json:"messages"`
+	// RateHz and MaxGapSeconds are measured over the distinct broadcasts
+	RateHz        float64   `json:"rate_hz"`
+	MaxGapSeconds float64   `json:"max_gap_s"`
+	Findings      []Finding `json:"findings"`
+}
+
+// Evaluate scores one UAS's detections, which must be oldest first,
+// against rules
+func Evaluate(detections []models.DroneDetection, rules Rules) *Report {
+	report := &Report{Received: len(detections), Findings: []Finding{}}
+	if len(detections) == 0 {
+		report.Status = StatusInsufficient
+		return report
+	}
+	first, last := detections[0], detections[len(detections)-1]
+	report.UASID, report.SN = first.UASID, first.SN
+	report.From, report.To = first.DetectionTime, last.DetectionTime
+
+	msgs := distinct(detections)
+	n := len(msgs)
+	report.Messages = n
+
+	var badFields, noPosition, withOperator, tooFast int
+	var fieldErr error
+	var fastest float64
+	var prev *models.DroneDetection
+	for i := range msgs {
+		d := &msgs[i]
+		if err := validDetection(d); err != nil {
+			badFields++
+			if fieldErr == nil {
+				fieldErr = err
+			}
+		}
+		if d.OperatorLatitude != 0 || d.OperatorLongitude != 0 {
+			withOperator++
+		}
+		if d.Latitude == 0 && d.Longitude == 0 {
+			noPosition++
+			continue
+		}
+
+		speed := d.SpeedHorizontal
+		if prev != nil {
+			dt := math.Max(d.DetectionTime.Sub(prev.DetectionTime).Seconds(), minInterval.Seconds())
+			dist := geo.Distance(prev.Latitude, prev.Longitude, d.Latitude, d.Longitude)
+			speed = math.Max(speed, math.Max(0, dist-positionSlack)/dt)
+		}
+		if speed > rules.MaxSpeed {
+			tooFast++
+			fastest = math.Max(fastest, speed)
+		}
+		prev = d
+	}
+
+	pass := map[string]float64{
+		CheckFields:   1 - float64(badFields)/float64(n),
+		CheckMessages: 1,
+		CheckOperator: float64(withOperator) / float64(n),
+		CheckSpeed:    1 - float64(tooFast)/float64(n),
+	}
+
+	if badFields > 0 {
+		report.add(CheckFields, SeverityError, badFields, fmt.Sprintf("%d of %d messages have values F3411 cannot encode (%v)", badFields, n, fieldErr))
+	}
+	// Every detection has a Basic ID, or the gateway would have refused it
+	if noPosition == n {
+		pass[CheckMessages] = 0.5
+		report.add(CheckMessages, SeverityError, noPosition, "no Location message with a position")
+	} else if noPosition > 0 {
+		report.add(CheckMessages, SeverityWarning, noPosition, fmt.Sprintf("%d of %d messages have no position", noPosition, n))
+	}
+	switch share := pass[CheckOperator]; {
+	case withOperator == 0:
+		report.add(CheckOperator, SeverityError, n, "no System message with an operator location")
+	case share < partialOperator:
+		report.add(CheckOperator, SeverityWarning, n-withOperator,
+			fmt.Sprintf("operator location in only %d of %d messages", withOperator, n))
+	}
+	if tooFast > 0 {
+		report.add(CheckSpeed, SeverityError, tooFast,
+			fmt.Sprintf("%d of %d messages imply up to %.0f m/s, above the %.0f m/s limit", tooFast, n, fastest, rules.MaxSpeed))
+	}
+
+	// The rate needs a few messages over more than an instant
+	span := msgs[n-1].DetectionTime.Sub(msgs[0].DetectionTime).Seconds()
+	if n >= minMessages && span > 0 && rules.MinRate > 0 {
+		report.RateHz = round(float64(n-1)/span, 2)
+		for i := 1; i < n; i++ {
+			gap := msgs[i].DetectionTime.Sub(msgs[i-1].DetectionTime).Seconds()
+			report.MaxGapSeconds = math.Max(report.MaxGapSeconds, round(gap, 1))
+		}
+		pass[CheckRate] = math.Min(1, report.RateHz/rules.MinRate)
+		if report.RateHz < rules.MinRate {
+			report.add(CheckRate, SeverityWarning, 0,
+				fmt.Sprintf("%.2f messages a second received, below %.2f", report.RateHz, rules.MinRate))
+		}
+	}
+
+	report.Score = score(pass)
+	report.Status = status(report.Findings, n)
+	return report
+}
+
+func (r *Report) add(check, severity string, count int, message string) {
+	r.Findings = append(r.Findings, Finding{Check: check, Severity: severity, Count: count, Message: message})
+}
+
+// distinct drops copies of a broadcast received by more than one node:
+// the same values within duplicateWindow of the last kept message
+func distinct(detections []models.DroneDetection) []models.DroneDetection {
+	var out []models.DroneDetection
+	for _, d := range detections {
+		if n := len(out); n > 0 && sameBroadcast(&out[n-1], &d) {
+			continue
+		}
+		out = append(out, d)
+	}
+	return out
+}
+
+func sameBroadcast(a, b *models.DroneDetection) bool {
+	return b.DetectionTime.Sub(a.DetectionTime) < duplicateWindow &&
+		a.Latitude == b.Latitude && a.Longitude == b.Longitude && a.Height == b.Height &&
+		a.Direction == b.Direction && a.SpeedHorizontal == b.SpeedHorizontal &&
+		a.SpeedVertical == b.SpeedVertical
+}
+
+// score weights the share of each check passed; checks that could not be
+// made are left out
+func score(pass map[string]float64) int {
+	var total, sum float64
+	for check, p := range pass {
+		total += weights[check]
+		sum += weights[check] * p
+	}
+	return int(math.Round(100 * sum / total))
+}
+
+// status is non-compliant on any error, otherwise insufficient for a
+// short track, warning on any warning and compliant if nothing was found
+func status(findings []Finding, messages int) string {
+	warned := false
+	for _, f := range findings {
+		if f.Severity == SeverityError {
+			return StatusNonCompliant
+		}
+		warned = true
+	}
+	switch {
+	case messages < minMessages:
+		return StatusInsufficient
+	case warned:
+		return StatusWarning
+	}
+	return StatusCompliant
+}
+
+// Rank orders statuses worst first, for sorting reports
+func Rank(status string) int {
+	switch status {
+	case StatusNonCompliant:
+		return 0
+	case StatusWarning:
+		return 1
+	case StatusInsufficient:
+		return 2
+	}
+	return 3
+}
+
+func round(v float64, places int) float64 {
+	p := math.Pow(10, float64(places))
+	return math.Round(v*p) / p
+}
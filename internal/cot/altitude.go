@@ -0,0 +1,88 @@
+package cot
+
+import (
+	"silentraven/internal/geoid"
+	"silentraven/internal/models"
+)
+
+// geoidModel supplies the MSL to HAE conversion
+var geoidModel = geoid.Default
+
+// UseGeoid converts pressure altitudes with m instead of the embedded grid
+func UseGeoid(m *geoid.Model) {
+	geoidModel = func() *geoid.Model { return m }
+}
+
+// UnknownError is the CoT sentinel for unknown hae/ce/le values
+const UnknownError = 9999999.0
+
+// horizontalAccuracy maps ASTM F3411 horizontal accuracy categories to
+// their upper bound in meters (index = enum value).
+var horizontalAccuracy = []float64{
+	UnknownError, // 0: >= 18.52 km or unknown
+	18520,        // 1: < 10 NM
+	7408,         // 2: < 4 NM
+	3704,         // 3: < 2 NM
+	1852,         // 4: < 1 NM
+	926,          // 5: < 0.5 NM
+	555.6,        // 6: < 0.3 NM
+	185.2,        // 7: < 0.1 NM
+	92.6,         // 8: < 0.05 NM
+	30,           // 9
+	10,           // 10
+	3,            // 11
+	1,            // 12
+}
+
+// verticalAccuracy maps ASTM F3411 vertical (geodetic and baro) accuracy
+// categories to their upper bound in meters.
+var verticalAccuracy = []float64{
+	UnknownError, // 0: >= 150 m or unknown
+	150,          // 1
+	45,           // 2
+	25,           // 3
+	10,           // 4
+	3,            // 5
+	1,            // 6
+}
+
+// CircularError returns the CoT ce for a horizontal accuracy category
+func CircularError(category int) float64 {
+	if category < 0 || category >= len(horizontalAccuracy) {
+		return UnknownError
+	}
+	return horizontalAccuracy[category]
+}
+
+// LinearError returns the CoT le for a vertical accuracy category
+func LinearError(category int) float64 {
+	if category < 0 || category >= len(verticalAccuracy) {
+		return UnknownError
+	}
+	return verticalAccuracy[category]
+}
+
+// Altitude picks the best available altitude source and returns it as
+// WGS-84 HAE together with its linear error.
+//
+// Preference order:
+//  1. Geodetic altitude (already HAE)
+//  2. Pressure altitude (MSL, converted with the EGM96 geoid)
+//  3. Height above takeoff/ground (reference unknown, le marked unknown)
+func Altitude(p models.IncomingPacket) (hae, le float64) {
+	if p.GeodeticAltitude != nil {
+		return *p.GeodeticAltitude, LinearError(p.VerticalAccuracy)
+	}
+
+	if p.PressureAltitude != nil {
+		model := geoidModel()
+		le = LinearError(p.BaroAccuracy)
+		if !model.Available() {
+			// Without a geoid the MSL→HAE offset can be off by ±100 m
+			le = UnknownError
+		}
+		return model.MSLToHAE(p.Latitude, p.Longitude, *p.PressureAltitude), le
+	}
+
+	return p.Height, UnknownError
+}
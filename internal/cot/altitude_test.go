@@ -0,0 +1,82 @@
+package cot
+
+import (
+	"testing"
+
+	"silentraven/internal/geoid"
+	"silentraven/internal/models"
+)
+
+// withGeoid replaces the geoid model for the duration of a test
+func withGeoid(t *testing.T, m *geoid.Model) {
+	t.Helper()
+	prev := geoidModel
+	geoidModel = func() *geoid.Model { return m }
+	t.Cleanup(func() { geoidModel = prev })
+}
+
+// flatGeoid has a 47 m undulation everywhere
+func flatGeoid(t *testing.T) *geoid.Model {
+	t.Helper()
+	m, err := geoid.NewModel(-90, 0, 90, 90, 3, 4, []float32{47, 47, 47, 47, 47, 47, 47, 47, 47, 47, 47, 47})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func float(v float64) *float64 { return &v }
+
+func TestCircularError(t *testing.T) {
+	tests := map[int]float64{-1: UnknownError, 0: UnknownError, 1: 18520, 9: 30, 12: 1, 13: UnknownError}
+	for category, want := range tests {
+		if got := CircularError(category); got != want {
+			t.Errorf("CircularError(%d) = %v, want %v", category, got, want)
+		}
+	}
+}
+
+func TestLinearError(t *testing.T) {
+	tests := map[int]float64{-1: UnknownError, 0: UnknownError, 1: 150, 4: 10, 6: 1, 7: UnknownError}
+	for category, want := range tests {
+		if got := LinearError(category); got != want {
+			t.Errorf("LinearError(%d) = %v, want %v", category, got, want)
+		}
+	}
+}
+
+func TestAltitude(t *testing.T) {
+	base := models.IncomingPacket{Latitude: 51.47, Longitude: -0.45, Height: 80, VerticalAccuracy: 4, BaroAccuracy: 3}
+	geodetic := base
+	geodetic.GeodeticAltitude = float(150)
+	geodetic.PressureAltitude = float(100)
+	pressure := base
+	pressure.PressureAltitude = float(100)
+
+	tests := []struct {
+		name    string
+		grid    bool
+		packet  models.IncomingPacket
+		hae, le float64
+	}{
+		{"geodetic is already HAE", true, geodetic, 150, 10},
+		{"geodetic without a grid", false, geodetic, 150, 10},
+		{"pressure converted with the grid", true, pressure, 147, 25},
+		{"pressure without a grid", false, pressure, 100, UnknownError},
+		{"height only", true, base, 80, UnknownError},
+		{"height only without a grid", false, base, 80, UnknownError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.grid {
+				withGeoid(t, flatGeoid(t))
+			} else {
+				withGeoid(t, &geoid.Model{})
+			}
+			hae, le := Altitude(tt.packet)
+			if hae != tt.hae || le != tt.le {
+				t.Errorf("Altitude = (%v, %v), want (%v, %v)", hae, le, tt.hae, tt.le)
+			}
+		})
+	}
+}
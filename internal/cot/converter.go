@@ -66,15 +66,31 @@ func ConvertToCoT(detection models.IncomingPacket) ([]byte, error) {
 	// Q = Unmanned Aerial System
 	cotType := "a-h-A-M-F-Q"
 
+	// Altitude as WGS-84 HAE with accuracy from the Remote ID enums
+	hae, le := Altitude(detection)
+
+	heightRef := "takeoff"
+	if detection.HeightType == models.HeightAboveGround {
+		heightRef = "AGL"
+	}
+
+	course, heading := float64(detection.Direction), fmt.Sprintf("%d°", detection.Direction)
+	if detection.Direction == models.DirectionUnknown {
+		course, heading = 0, "unknown heading"
+	}
+
 	// Build remarks with detection details
 	remarks := fmt.Sprintf(`Remote-ID Detection
 Node: %s
 Type: %s
-Speed: %.1f m/s @ %d°`,
+Speed: %.1f m/s @ %s
+Height: %.1f m (%s)`,
 		detection.SN,
 		detection.DroneType,
 		detection.SpeedHorizontal,
-		detection.Direction)
+		heading,
+		detection.Height,
+		heightRef)
 
 	event := Event{
 		Version: "2.0",
@@ -87,9 +103,9 @@ Speed: %.1f m/s @ %d°`,
 		Point: Point{
 			Lat: detection.Latitude,
 			Lon: detection.Longitude,
-			Hae: detection.Height,
-			Ce:  10.0, // Circular error (meters)
-			Le:  15.0, // Linear error (meters)
+			Hae: hae,
+			Ce:  CircularError(detection.HorizontalAccuracy), // Circular error (meters)
+			Le:  le,                                          // Linear error (meters)
 		},
 		Detail: Detail{
 			Contact: Contact{
@@ -97,7 +113,7 @@ Speed: %.1f m/s @ %d°`,
 			},
 			Remarks: remarks,
 			Track: Track{
-				Course: float64(detection.Direction),
+				Course: course,
 				Speed:  detection.SpeedHorizontal,
 			},
 		},
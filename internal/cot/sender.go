@@ -3,6 +3,7 @@ package cot
 import (
 	"fmt"
 	"net"
+	"strconv"
 	"time"
 )
 
@@ -50,7 +51,7 @@ type DirectSender struct {
 }
 
 func NewDirectSender(targetIP string, port int) (*DirectSender, error) {
-	addr, err := net.ResolveUDPAddr("udp", fmt.Sprintf("%s:%d", targetIP, port))
+	addr, err := net.ResolveUDPAddr("udp", net.JoinHostPort(targetIP, strconv.Itoa(port)))
 	if err != nil {
 		return nil, fmt.Errorf("resolve target addr: %w", err)
 	}
@@ -79,7 +80,7 @@ type TCPSender struct {
 }
 
 func NewTCPSender(serverIP string, port int) (*TCPSender, error) {
-	addr := fmt.Sprintf("%s:%d", serverIP, port)
+	addr := net.JoinHostPort(serverIP, strconv.Itoa(port))
 
 	conn, err := net.DialTimeout("tcp", addr, 10*time.Second)
 	if err != nil {
@@ -0,0 +1,301 @@
+// Package coverage estimates where sensor nodes can hear drones from the
+// positions they have actually reported. Each node's range is a polar
+// profile: the horizon is split into sectors and each sector's range is a
+// high percentile of the distances heard in that direction. Detections
+// from all nodes are also binned into a heatmap grid.
+package coverage
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"silentraven/internal/database"
+	"silentraven/internal/geo"
+	"silentraven/internal/models"
+)
+
+// metersPerDegreeLat is the length of one degree of latitude
+const metersPerDegreeLat = 111320.0
+
+// Options tunes coverage estimation
+type Options struct {
+	// Sectors is the number of equal bearing sectors per node
+	Sectors int
+	// Percentile (0-1] of heard distances used as a sector's range
+	Percentile float64
+	// MinDetections a sector needs before it is given a range
+	MinDetections int64
+	// MaxRangeMeters discards samples further than this from a node
+	MaxRangeMeters float64
+	// CellMeters is the heatmap grid cell size
+	CellMeters float64
+}
+
+// DefaultOptions returns the options used when none are given
+func DefaultOptions() Options {
+	return Options{
+		Sectors:        36,
+		Percentile:     0.95,
+		MinDetections:  3,
+		MaxRangeMeters: 50000,
+		CellMeters:     500,
+	}
+}
+
+// Validate checks the options for out-of-range values
+func (o Options) Validate() error {
+	if o.Sectors < 4 || o.Sectors > 360 {
+		return fmt.Errorf("sectors must be between 4 and 360, got %d", o.Sectors)
+	}
+	if o.Percentile <= 0 || o.Percentile > 1 {
+		return fmt.Errorf("percentile must be in (0, 1], got %g", o.Percentile)
+	}
+	if o.MaxRangeMeters <= 0 {
+		return fmt.Errorf("max range must be positive")
+	}
+	if o.CellMeters < 10 {
+		return fmt.Errorf("cell size must be at least 10 m, got %g", o.CellMeters)
+	}
+	return nil
+}
+
+// Sector is the estimated range of a node in one bearing sector
+type Sector struct {
+	// Bearing is the sector's centre, degrees clockwise from north
+	Bearing     float64 `json:"bearing"`
+	RangeMeters float64 `json:"range_meters"`
+	Detections  int64   `json:"detections"`
+}
+
+// NodeCoverage is the estimated coverage of one node
+type NodeCoverage struct {
+	NodeID    string  `json:"node_id"`
+	Name      string  `json:"name,omitempty"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	// Located is false when the registry has no position for the node;
+	// such nodes contribute to the grid but have no range polygon.
+	Located           bool     `json:"located"`
+	Detections        int64    `json:"detections"`
+	Discarded         int64    `json:"discarded"`
+	MedianRangeMeters float64  `json:"median_range_meters"`
+	MaxRangeMeters    float64  `json:"max_range_meters"`
+	AreaSqKm          float64  `json:"area_sq_km"`
+	Sectors           []Sector `json:"sectors,omitempty"`
+}
+
+// Cell is one heatmap grid cell
+type Cell struct {
+	MinLat     float64  `json:"min_lat"`
+	MinLon     float64  `json:"min_lon"`
+	MaxLat     float64  `json:"max_lat"`
+	MaxLon     float64  `json:"max_lon"`
+	Detections int64    `json:"detections"`
+	Nodes      []string `json:"nodes"`
+}
+
+// Map is the coverage estimate for a set of nodes
+type Map struct {
+	Options Options        `json:"options"`
+	Nodes   []NodeCoverage `json:"nodes"`
+	Grid    []Cell         `json:"grid"`
+}
+
+// Compute estimates coverage from snapped detection samples and the node
+// registry. Nodes that heard nothing are omitted.
+func Compute(nodes []models.SensorNode, samples []database.CoverageSample, opts Options) (*Map, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	registry := make(map[string]models.SensorNode, len(nodes))
+	for _, n := range nodes {
+		registry[n.NodeID] = n
+	}
+
+	byNode := make(map[string][]database.CoverageSample)
+	var order []string
+	for _, s := range samples {
+		if _, ok := byNode[s.NodeID]; !ok {
+			order = append(order, s.NodeID)
+		}
+		byNode[s.NodeID] = append(byNode[s.NodeID], s)
+	}
+	sort.Strings(order)
+
+	m := &Map{Options: opts, Nodes: make([]NodeCoverage, 0, len(order))}
+	for _, id := range order {
+		m.Nodes = append(m.Nodes, nodeCoverage(id, registry[id], byNode[id], opts))
+	}
+	m.Grid = grid(samples, opts.CellMeters)
+	return m, nil
+}
+
+// rangedSample is a sample's distance from its node
+type rangedSample struct {
+	meters float64
+	count  int64
+}
+
+func nodeCoverage(id string, node models.SensorNode, samples []database.CoverageSample, opts Options) NodeCoverage {
+	nc := NodeCoverage{
+		NodeID:    id,
+		Name:      node.Name,
+		Latitude:  node.Latitude,
+		Longitude: node.Longitude,
+		Located:   node.Latitude != 0 || node.Longitude != 0,
+	}
+
+	if !nc.Located {
+		for _, s := range samples {
+			nc.Detections += s.Detections
+		}
+		return nc
+	}
+
+	width := 360 / float64(opts.Sectors)
+	sectors := make([][]rangedSample, opts.Sectors)
+	var all []rangedSample
+
+	for _, s := range samples {
+		d := geo.Distance(node.Latitude, node.Longitude, s.Latitude, s.Longitude)
+		if d > opts.MaxRangeMeters {
+			nc.Discarded += s.Detections
+			continue
+		}
+		nc.Detections += s.Detections
+
+		rs := rangedSample{meters: d, count: s.Detections}
+		all = append(all, rs)
+
+		// Sector 0 is centred on north
+		b := geo.Bearing(node.Latitude, node.Longitude, s.Latitude, s.Longitude)
+		i := int(math.Mod(b+width/2, 360)/width) % opts.Sectors
+		sectors[i] = append(sectors[i], rs)
+	}
+
+	nc.MedianRangeMeters = percentile(all, 0.5)
+	nc.MaxRangeMeters = percentile(all, 1)
+
+	nc.Sectors = make([]Sector, opts.Sectors)
+	for i, ss := range sectors {
+		sec := Sector{Bearing: float64(i) * width}
+		for _, s := range ss {
+			sec.Detections += s.count
+		}
+		if sec.Detections >= opts.MinDetections {
+			sec.RangeMeters = percentile(ss, opts.Percentile)
+		}
+		nc.Sectors[i] = sec
+	}
+
+	// Sum of the triangles between adjacent sector vertices
+	sin := math.Sin(width * math.Pi / 180)
+	for i, sec := range nc.Sectors {
+		next := nc.Sectors[(i+1)%len(nc.Sectors)]
+		nc.AreaSqKm += 0.5 * sec.RangeMeters * next.RangeMeters * sin / 1e6
+	}
+
+	return nc
+}
+
+// Polygon returns the node's range as a closed [lon, lat] ring
+func (nc NodeCoverage) Polygon() [][]float64 {
+	if !nc.Located || len(nc.Sectors) == 0 {
+		return nil
+	}
+
+	ring := make([][]float64, 0, len(nc.Sectors)+1)
+	for _, sec := range nc.Sectors {
+		lat, lon := geo.Destination(nc.Latitude, nc.Longitude, sec.Bearing, sec.RangeMeters)
+		ring = append(ring, []float64{lon, lat})
+	}
+	return append(ring, ring[0])
+}
+
+// percentile returns the count-weighted p-quantile of the distances
+func percentile(samples []rangedSample, p float64) float64 {
+	if len(samples) == 0 {
+		return 0
+	}
+
+	sorted := make([]rangedSample, len(samples))
+	copy(sorted, samples)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].meters < sorted[j].meters })
+
+	var total int64
+	for _, s := range sorted {
+		total += s.count
+	}
+
+	target := p * float64(total)
+	var seen int64
+	for _, s := range sorted {
+		seen += s.count
+		if float64(seen) >= target {
+			return s.meters
+		}
+	}
+	return sorted[len(sorted)-1].meters
+}
+
+// grid bins samples into square cells of cellMeters. Longitude spacing is
+// taken at the mean latitude so cells are roughly square over a region.
+func grid(samples []database.CoverageSample, cellMeters float64) []Cell {
+	if len(samples) == 0 {
+		return []Cell{}
+	}
+
+	var latSum float64
+	var total int64
+	for _, s := range samples {
+		latSum += s.Latitude * float64(s.Detections)
+		total += s.Detections
+	}
+	meanLat := latSum / float64(total)
+
+	dLat := cellMeters / metersPerDegreeLat
+	dLon := cellMeters / (metersPerDegreeLat * math.Max(math.Cos(meanLat*math.Pi/180), 0.01))
+
+	type key struct{ row, col int64 }
+	type agg struct {
+		detections int64
+		nodes      map[string]bool
+	}
+	cells := make(map[key]*agg)
+
+	for _, s := range samples {
+		k := key{int64(math.Floor(s.Latitude / dLat)), int64(math.Floor(s.Longitude / dLon))}
+		a := cells[k]
+		if a == nil {
+			a = &agg{nodes: make(map[string]bool)}
+			cells[k] = a
+		}
+		a.detections += s.Detections
+		a.nodes[s.NodeID] = true
+	}
+
+	out := make([]Cell, 0, len(cells))
+	for k, a := range cells {
+		c := Cell{
+			MinLat:     float64(k.row) * dLat,
+			MinLon:     float64(k.col) * dLon,
+			MaxLat:     float64(k.row+1) * dLat,
+			MaxLon:     float64(k.col+1) * dLon,
+			Detections: a.detections,
+		}
+		for n := range a.nodes {
+			c.Nodes = append(c.Nodes, n)
+		}
+		sort.Strings(c.Nodes)
+		out = append(out, c)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].MinLat != out[j].MinLat {
+			return out[i].MinLat < out[j].MinLat
+		}
+		return out[i].MinLon < out[j].MinLon
+	})
+	return out
+}
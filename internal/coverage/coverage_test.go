@@ -0,0 +1,166 @@
+package coverage
+
+import (
+	"math"
+	"testing"
+
+	"silentraven/internal/database"
+	"silentraven/internal/geo"
+	"silentraven/internal/models"
+)
+
+var node = models.SensorNode{NodeID: "node-1", Name: "Roof", Latitude: 51.47, Longitude: -0.45}
+
+// at returns a sample heard by node-1 at bearing and distance from it
+func at(bearing, meters float64, detections int64) database.CoverageSample {
+	lat, lon := geo.Destination(node.Latitude, node.Longitude, bearing, meters)
+	return database.CoverageSample{NodeID: node.NodeID, Latitude: lat, Longitude: lon, Detections: detections}
+}
+
+func TestSectorsAroundNorth(t *testing.T) {
+	opts := DefaultOptions() // 36 sectors of 10 degrees, sector 0 on north
+	opts.MinDetections = 1
+	tests := []struct {
+		bearing float64
+		sector  int
+	}{
+		{0, 0},
+		{4.9, 0},
+		{355.1, 0},
+		{359.99, 0},
+		{5.1, 1},
+		{354.9, 35},
+		{90, 9},
+		{180, 18},
+	}
+	for _, tt := range tests {
+		nc := nodeCoverage(node.NodeID, node, []database.CoverageSample{at(tt.bearing, 1000, 1)}, opts)
+		for i, sec := range nc.Sectors {
+			if got := sec.Detections == 1; got != (i == tt.sector) {
+				t.Errorf("bearing %g: sector %d (%g°) has %d detections, want the sample in sector %d",
+					tt.bearing, i, sec.Bearing, sec.Detections, tt.sector)
+			}
+		}
+		if sec := nc.Sectors[tt.sector]; math.Abs(sec.RangeMeters-1000) > 1 {
+			t.Errorf("bearing %g: sector range = %g, want 1000", tt.bearing, sec.RangeMeters)
+		}
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	samples := []rangedSample{{300, 1}, {100, 1}, {200, 8}}
+	tests := []struct {
+		p    float64
+		want float64
+	}{
+		{0.01, 100},
+		{0.1, 100},
+		{0.11, 200},
+		{0.5, 200},
+		{0.9, 200},
+		{0.95, 300},
+		{1, 300},
+	}
+	for _, tt := range tests {
+		if got := percentile(samples, tt.p); got != tt.want {
+			t.Errorf("percentile(%g) = %g, want %g", tt.p, got, tt.want)
+		}
+	}
+	if got := percentile(nil, 0.5); got != 0 {
+		t.Errorf("percentile of nothing = %g", got)
+	}
+	if samples[0].meters != 300 {
+		t.Error("percentile reordered its input")
+	}
+}
+
+func TestArea(t *testing.T) {
+	opts := DefaultOptions()
+	opts.Sectors = 4
+	opts.MinDetections = 2
+
+	// A square with its corners 2 km out: four triangles of 2 km x 2 km / 2
+	var samples []database.CoverageSample
+	for _, b := range []float64{0, 90, 180, 270} {
+		samples = append(samples, at(b, 2000, 2))
+	}
+	nc := nodeCoverage(node.NodeID, node, samples, opts)
+	if math.Abs(nc.AreaSqKm-8) > 0.01 {
+		t.Errorf("area = %g km², want 8", nc.AreaSqKm)
+	}
+
+	// A sector without enough detections has no range, taking both of
+	// its triangles with it
+	samples[0].Detections = 1
+	nc = nodeCoverage(node.NodeID, node, samples, opts)
+	if nc.Sectors[0].RangeMeters != 0 || math.Abs(nc.AreaSqKm-4) > 0.01 {
+		t.Errorf("area with north unranged = %g km², north range %g; want 4 km², 0", nc.AreaSqKm, nc.Sectors[0].RangeMeters)
+	}
+
+	// Many sectors of the same range approach a circle
+	opts.Sectors = 360
+	opts.MinDetections = 1
+	samples = samples[:0]
+	for b := 0; b < 360; b++ {
+		samples = append(samples, at(float64(b), 1000, 1))
+	}
+	nc = nodeCoverage(node.NodeID, node, samples, opts)
+	if circle := math.Pi; math.Abs(nc.AreaSqKm-circle) > 0.01 {
+		t.Errorf("area = %g km², want about %g", nc.AreaSqKm, circle)
+	}
+}
+
+func TestCompute(t *testing.T) {
+	unlocated := models.SensorNode{NodeID: "node-2", Name: "Van"}
+	samples := []database.CoverageSample{
+		at(0, 1000, 5),
+		at(0, 900, 5),
+		at(90, 80000, 4), // beyond MaxRangeMeters
+		{NodeID: "node-2", Latitude: 51.48, Longitude: -0.44, Detections: 3},
+		{NodeID: "node-3", Latitude: 51.48, Longitude: -0.44, Detections: 2}, // not registered
+	}
+	m, err := Compute([]models.SensorNode{node, unlocated, {NodeID: "idle", Latitude: 51, Longitude: 0}}, samples, DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(m.Nodes) != 3 || m.Nodes[0].NodeID != "node-1" || m.Nodes[1].NodeID != "node-2" || m.Nodes[2].NodeID != "node-3" {
+		t.Fatalf("nodes = %+v, want node-1, node-2 and node-3 (idle heard nothing)", m.Nodes)
+	}
+	located := m.Nodes[0]
+	if !located.Located || located.Detections != 10 || located.Discarded != 4 || located.Name != "Roof" {
+		t.Errorf("node-1 = %+v", located)
+	}
+	if math.Abs(located.MaxRangeMeters-1000) > 1 || math.Abs(located.MedianRangeMeters-900) > 1 {
+		t.Errorf("node-1 ranges = median %g, max %g; want 900, 1000", located.MedianRangeMeters, located.MaxRangeMeters)
+	}
+	if ring := located.Polygon(); len(ring) != 37 || ring[0][0] != ring[36][0] || ring[0][1] != ring[36][1] {
+		t.Errorf("node-1 polygon has %d points and is not closed", len(ring))
+	}
+
+	for _, nc := range m.Nodes[1:] {
+		if nc.Located || nc.Sectors != nil || nc.AreaSqKm != 0 || nc.Polygon() != nil {
+			t.Errorf("%s without a position has a range: %+v", nc.NodeID, nc)
+		}
+	}
+	if m.Nodes[1].Detections != 3 || m.Nodes[1].Name != "Van" || m.Nodes[2].Detections != 2 {
+		t.Errorf("unlocated detections = %d, %d; want 3, 2", m.Nodes[1].Detections, m.Nodes[2].Detections)
+	}
+
+	// Unlocated nodes still count in the grid; all of a cell's nodes are listed
+	var total int64
+	shared := false
+	for _, c := range m.Grid {
+		total += c.Detections
+		if len(c.Nodes) == 2 && c.Nodes[0] == "node-2" && c.Nodes[1] == "node-3" && c.Detections == 5 {
+			shared = true
+		}
+	}
+	if total != 19 || !shared {
+		t.Errorf("grid = %+v; want 19 detections and one cell shared by node-2 and node-3", m.Grid)
+	}
+
+	if _, err := Compute(nil, samples, Options{}); err == nil {
+		t.Error("Compute accepted invalid options")
+	}
+}
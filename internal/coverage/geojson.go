@@ -0,0 +1,110 @@
+package coverage
+
+import (
+	"fmt"
+	"strings"
+
+	"silentraven/internal/export"
+)
+
+// GeoJSON layers
+const (
+	LayerNodes    = "nodes"    // node positions with range statistics
+	LayerRanges   = "ranges"   // per-node range polygons
+	LayerCombined = "combined" // all range polygons as one MultiPolygon
+	LayerGrid     = "grid"     // heatmap cells
+)
+
+// ParseLayers validates a comma-separated layer list ("" = all layers)
+func ParseLayers(s string) (map[string]bool, error) {
+	layers := map[string]bool{}
+	if s == "" {
+		s = strings.Join([]string{LayerNodes, LayerRanges, LayerCombined, LayerGrid}, ",")
+	}
+	for _, l := range strings.Split(s, ",") {
+		switch l = strings.TrimSpace(l); l {
+		case LayerNodes, LayerRanges, LayerCombined, LayerGrid:
+			layers[l] = true
+		default:
+			return nil, fmt.Errorf("unknown coverage layer %q (use nodes, ranges, combined or grid)", l)
+		}
+	}
+	return layers, nil
+}
+
+// GeoJSON renders the selected layers as a FeatureCollection. Every
+// feature carries a "kind" property naming its layer.
+func (m *Map) GeoJSON(layers map[string]bool) export.FeatureCollection {
+	fc := export.FeatureCollection{Type: "FeatureCollection", Features: []export.Feature{}}
+
+	var combined [][][][]float64
+	for _, nc := range m.Nodes {
+		if !nc.Located {
+			continue
+		}
+		props := map[string]interface{}{
+			"node_id":             nc.NodeID,
+			"name":                nc.Name,
+			"detections":          nc.Detections,
+			"discarded":           nc.Discarded,
+			"median_range_meters": nc.MedianRangeMeters,
+			"max_range_meters":    nc.MaxRangeMeters,
+			"area_sq_km":          nc.AreaSqKm,
+		}
+
+		if layers[LayerNodes] {
+			fc.Features = append(fc.Features, feature("node", "Point", []float64{nc.Longitude, nc.Latitude}, props))
+		}
+
+		ring := nc.Polygon()
+		if layers[LayerRanges] {
+			rangeProps := map[string]interface{}{"sectors": nc.Sectors}
+			for k, v := range props {
+				rangeProps[k] = v
+			}
+			fc.Features = append(fc.Features, feature("range", "Polygon", [][][]float64{ring}, rangeProps))
+		}
+		combined = append(combined, [][][]float64{ring})
+	}
+
+	if layers[LayerCombined] && len(combined) > 0 {
+		var nodes []string
+		var area float64
+		for _, nc := range m.Nodes {
+			if nc.Located {
+				nodes = append(nodes, nc.NodeID)
+				area += nc.AreaSqKm
+			}
+		}
+		fc.Features = append(fc.Features, feature("combined", "MultiPolygon", combined, map[string]interface{}{
+			"nodes": nodes,
+			// Overlapping ranges are counted once per node
+			"summed_area_sq_km": area,
+		}))
+	}
+
+	if layers[LayerGrid] {
+		for _, c := range m.Grid {
+			ring := [][]float64{
+				{c.MinLon, c.MinLat}, {c.MaxLon, c.MinLat}, {c.MaxLon, c.MaxLat},
+				{c.MinLon, c.MaxLat}, {c.MinLon, c.MinLat},
+			}
+			fc.Features = append(fc.Features, feature("cell", "Polygon", [][][]float64{ring}, map[string]interface{}{
+				"detections": c.Detections,
+				"nodes":      c.Nodes,
+				"node_count": len(c.Nodes),
+			}))
+		}
+	}
+
+	return fc
+}
+
+func feature(kind, geomType string, coords interface{}, props map[string]interface{}) export.Feature {
+	props["kind"] = kind
+	return export.Feature{
+		Type:       "Feature",
+		Geometry:   export.Geometry{Type: geomType, Coordinates: coords},
+		Properties: props,
+	}
+}
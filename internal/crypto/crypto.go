@@ -0,0 +1,192 @@
+// Package crypto signs and verifies detection packets with the sensor
+// node's ECDSA P-256 key.
+//
+// The signature covers the SHA-256 of the packet's JSON encoding with the
+// signature fields cleared. The encoded bytes travel with the packet in
+// IncomingPacket.Signed and the signature, base64 ASN.1 DER, in
+// IncomingPacket.Signature, so verification never depends on encoding
+// the packet again.
+package crypto
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+
+	"silentraven/internal/models"
+)
+
+var (
+	// ErrBadSignature is returned when a packet signature does not verify
+	ErrBadSignature = errors.New("invalid packet signature")
+	// ErrNotSigned is returned for a packet without its signed bytes
+	ErrNotSigned = errors.New("packet carries no signed payload")
+	// ErrPayloadMismatch is returned when a packet's fields differ from
+	// the payload its node signed
+	ErrPayloadMismatch = errors.New("packet does not match its signed payload")
+)
+
+// GenerateKey creates a new P-256 node key
+func GenerateKey() (*ecdsa.PrivateKey, error) {
+	return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+}
+
+// LoadOrCreateKey reads a PEM private key from path, creating one with
+// owner-only permissions if the file does not exist
+func LoadOrCreateKey(path string) (*ecdsa.PrivateKey, bool, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		key, err := ParsePrivateKey(data)
+		return key, false, err
+	}
+	if !os.IsNotExist(err) {
+		return nil, false, fmt.Errorf("failed to read node key: %w", err)
+	}
+
+	key, err := GenerateKey()
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to generate node key: %w", err)
+	}
+
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to encode node key: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, false, fmt.Errorf("failed to create key directory: %w", err)
+	}
+	block := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+	if err := os.WriteFile(path, block, 0o600); err != nil {
+		return nil, false, fmt.Errorf("failed to write node key: %w", err)
+	}
+	return key, true, nil
+}
+
+// ParsePrivateKey parses a PEM-encoded EC (SEC 1) or PKCS #8 private key
+func ParsePrivateKey(data []byte) (*ecdsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("node key is not PEM encoded")
+	}
+
+	if key, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse node key: %w", err)
+	}
+	key, ok := parsed.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("node key is not an ECDSA key")
+	}
+	return key, nil
+}
+
+// PublicKeyPEM returns the PKIX PEM encoding of the key's public half,
+// the form stored in the node registry
+func PublicKeyPEM(key *ecdsa.PrivateKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode public key: %w", err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})), nil
+}
+
+// ParsePublicKey parses a PKIX PEM public key
+func ParsePublicKey(data string) (*ecdsa.PublicKey, error) {
+	block, _ := pem.Decode([]byte(data))
+	if block == nil {
+		return nil, fmt.Errorf("public key is not PEM encoded")
+	}
+	parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public key: %w", err)
+	}
+	key, ok := parsed.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("public key is not an ECDSA key")
+	}
+	return key, nil
+}
+
+// Fingerprint is the hex SHA-256 of a public key's PKIX encoding
+func Fingerprint(pub *ecdsa.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode public key: %w", err)
+	}
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// KeyFingerprint returns the Fingerprint of a PKIX PEM public key
+func KeyFingerprint(data string) (string, error) {
+	pub, err := ParsePublicKey(data)
+	if err != nil {
+		return "", err
+	}
+	return Fingerprint(pub)
+}
+
+// SignPacket sets p.Signed to the packet's encoding and p.Signature to
+// its signature
+func SignPacket(key *ecdsa.PrivateKey, p *models.IncomingPacket) error {
+	p.Signature, p.Signed = "", nil
+	data, err := json.Marshal(p)
+	if err != nil {
+		return fmt.Errorf("failed to encode packet: %w", err)
+	}
+	digest := sha256.Sum256(data)
+	sig, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+	if err != nil {
+		return fmt.Errorf("failed to sign packet: %w", err)
+	}
+	p.Signed = data
+	p.Signature = base64.StdEncoding.EncodeToString(sig)
+	return nil
+}
+
+// VerifyPacket checks p.Signature over the bytes in p.Signed against the
+// node's public key, and that p says what those bytes say. The node ID
+// and timestamp may have been filled in after signing.
+func VerifyPacket(pub *ecdsa.PublicKey, p models.IncomingPacket) error {
+	if len(p.Signed) == 0 {
+		return ErrNotSigned
+	}
+	sig, err := base64.StdEncoding.DecodeString(p.Signature)
+	if err != nil || len(sig) == 0 {
+		return ErrBadSignature
+	}
+	digest := sha256.Sum256(p.Signed)
+	if !ecdsa.VerifyASN1(pub, digest[:], sig) {
+		return ErrBadSignature
+	}
+
+	var signed models.IncomingPacket
+	if err := json.Unmarshal(p.Signed, &signed); err != nil {
+		return fmt.Errorf("%w: %v", ErrPayloadMismatch, err)
+	}
+	p.Signature, p.Signed = "", nil
+	if signed.NodeID == "" {
+		p.NodeID = ""
+	}
+	if signed.Timestamp == "" {
+		p.Timestamp = ""
+	}
+	if !reflect.DeepEqual(signed, p) {
+		return ErrPayloadMismatch
+	}
+	return nil
+}
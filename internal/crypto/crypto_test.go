@@ -0,0 +1,93 @@
+package crypto
+
+import (
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"silentraven/internal/models"
+)
+
+func TestVerifyPacket(t *testing.T) {
+	key, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sign := func() models.IncomingPacket {
+		p := models.IncomingPacket{SN: "sn-1", UASID: "uas-1", Latitude: 51.47, Longitude: -0.45, Height: 80}
+		if err := SignPacket(key, &p); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+
+	tests := []struct {
+		name   string
+		modify func(p *models.IncomingPacket)
+		key    *ecdsa.PrivateKey
+		want   error
+	}{
+		{name: "as signed"},
+		{name: "gateway fills node and time", modify: func(p *models.IncomingPacket) {
+			p.NodeID, p.Timestamp = "node-1", "2026-01-02T03:04:05Z"
+		}},
+		{name: "field changed", modify: func(p *models.IncomingPacket) { p.Height = 20 }, want: ErrPayloadMismatch},
+		{name: "signed bytes changed", modify: func(p *models.IncomingPacket) {
+			p.Signed = []byte(string(p.Signed[:len(p.Signed)-1]) + " }")
+		}, want: ErrBadSignature},
+		{name: "no signed bytes", modify: func(p *models.IncomingPacket) { p.Signed = nil }, want: ErrNotSigned},
+		{name: "no signature", modify: func(p *models.IncomingPacket) { p.Signature = "" }, want: ErrBadSignature},
+		{name: "other key", key: other, want: ErrBadSignature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := sign()
+			if tt.modify != nil {
+				tt.modify(&p)
+			}
+			// Verification must survive a JSON round trip, as packets are
+			// queued and stored as JSON
+			data, err := json.Marshal(p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var received models.IncomingPacket
+			if err := json.Unmarshal(data, &received); err != nil {
+				t.Fatal(err)
+			}
+			pub := &key.PublicKey
+			if tt.key != nil {
+				pub = &tt.key.PublicKey
+			}
+			if err := VerifyPacket(pub, received); !errors.Is(err, tt.want) {
+				t.Errorf("VerifyPacket = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeyFingerprint(t *testing.T) {
+	key, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := PublicKeyPEM(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := Fingerprint(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := KeyFingerprint(pub); err != nil || got != want || len(got) != 64 {
+		t.Errorf("KeyFingerprint = %q, %v; want %q", got, err, want)
+	}
+	if _, err := KeyFingerprint("not a key"); err == nil {
+		t.Error("KeyFingerprint accepted a non-PEM key")
+	}
+}
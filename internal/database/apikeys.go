@@ -0,0 +1,122 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+
+	"silentraven/internal/models"
+)
+
+const apiKeyColumns = `
+			id, name, kind, subject, role, jurisdiction, hash, created_at, expires_at,
+			revoked_at, last_used_at, replaced_by`
+
+// CreateAPIKey stores a new key and fills in its CreatedAt
+func (db *DB) CreateAPIKey(k *models.APIKey) error {
+	err := db.conn.QueryRow(`
+		INSERT INTO api_keys (id, name, kind, subject, role, jurisdiction, hash, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING created_at
+	`, k.ID, k.Name, k.Kind, k.Subject, k.Role, k.Jurisdiction, k.Hash, nullTime(k.ExpiresAt)).Scan(&k.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create API key: %w", err)
+	}
+	return nil
+}
+
+// GetAPIKey returns one key or ErrNotFound
+func (db *DB) GetAPIKey(id string) (*models.APIKey, error) {
+	rows, err := db.conn.Query(`SELECT `+apiKeyColumns+` FROM api_keys WHERE id = $1`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query API key: %w", err)
+	}
+	defer rows.Close()
+
+	keys, err := scanAPIKeys(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, ErrNotFound
+	}
+	return &keys[0], nil
+}
+
+// ListAPIKeys returns every key, newest first
+func (db *DB) ListAPIKeys() ([]models.APIKey, error) {
+	rows, err := db.conn.Query(`SELECT ` + apiKeyColumns + ` FROM api_keys ORDER BY created_at DESC, id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query API keys: %w", err)
+	}
+	defer rows.Close()
+
+	return scanAPIKeys(rows)
+}
+
+// RevokeAPIKey revokes a key from at; revoking twice keeps the first time
+func (db *DB) RevokeAPIKey(id string, at time.Time) error {
+	res, err := db.conn.Exec(`UPDATE api_keys SET revoked_at = COALESCE(revoked_at, $2) WHERE id = $1`, id, at)
+	if err != nil {
+		return fmt.Errorf("failed to revoke API key: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ReplaceAPIKey records that a key was rotated to replacement and makes
+// it expire at expires, unless it already expires sooner
+func (db *DB) ReplaceAPIKey(id, replacement string, expires time.Time) error {
+	res, err := db.conn.Exec(`
+		UPDATE api_keys SET
+			replaced_by = $2,
+			expires_at = LEAST(COALESCE(expires_at, $3), $3)
+		WHERE id = $1
+	`, id, replacement, expires)
+	if err != nil {
+		return fmt.Errorf("failed to replace API key: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// TouchAPIKey records that a key was used at at
+func (db *DB) TouchAPIKey(id string, at time.Time) error {
+	_, err := db.conn.Exec(`
+		UPDATE api_keys SET last_used_at = GREATEST(COALESCE(last_used_at, $2), $2) WHERE id = $1
+	`, id, at)
+	if err != nil {
+		return fmt.Errorf("failed to record API key use: %w", err)
+	}
+	return nil
+}
+
+func scanAPIKeys(rows *sql.Rows) ([]models.APIKey, error) {
+	var keys []models.APIKey
+	for rows.Next() {
+		var k models.APIKey
+		var expires, revoked, used pq.NullTime
+		err := rows.Scan(&k.ID, &k.Name, &k.Kind, &k.Subject, &k.Role, &k.Jurisdiction, &k.Hash, &k.CreatedAt,
+			&expires, &revoked, &used, &k.ReplacedBy)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan API key: %w", err)
+		}
+		k.ExpiresAt, k.RevokedAt, k.LastUsedAt = expires.Time, revoked.Time, used.Time
+		keys = append(keys, k)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read API keys: %w", err)
+	}
+	return keys, nil
+}
+
+// nullTime stores the zero time as NULL
+func nullTime(t time.Time) pq.NullTime {
+	return pq.NullTime{Time: t, Valid: !t.IsZero()}
+}
@@ -0,0 +1,152 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"silentraven/internal/models"
+)
+
+const auditColumns = `
+			seq, time, actor, actor_kind, role, key_id, action, resource, status,
+			remote_addr, request_id, detail, prev_hash, hash`
+
+// auditLockKey names the advisory lock that serializes appends to the
+// audit chain
+const auditLockKey = 0x61756469 // "audi"
+
+// AuditFilter selects audit events. Zero-valued fields are ignored.
+type AuditFilter struct {
+	Actor  string
+	Action string
+	From   time.Time
+	To     time.Time
+	// AfterSeq continues from a previous page's last Seq
+	AfterSeq int64
+	Limit    int
+}
+
+// AppendAudit chains e onto the audit log: it fills in Seq, PrevHash and
+// Hash, and Time if unset. Appends are serialized on an advisory lock,
+// held until commit, so concurrent writers cannot fork the chain.
+func (db *DB) AppendAudit(e *models.AuditEvent) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin audit append: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Only appends take the lock; readers and other tables are unaffected
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1)`, auditLockKey); err != nil {
+		return fmt.Errorf("failed to lock audit log: %w", err)
+	}
+	var seq int64
+	var prev string
+	err = tx.QueryRow(`SELECT seq, hash FROM audit_log ORDER BY seq DESC LIMIT 1`).Scan(&seq, &prev)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("failed to read audit log head: %w", err)
+	}
+
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	e.Time = e.Time.UTC().Truncate(time.Microsecond)
+	e.Seq, e.PrevHash = seq+1, prev
+	e.Hash = e.ComputeHash()
+
+	_, err = tx.Exec(`
+		INSERT INTO audit_log (`+auditColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+	`, e.Seq, e.Time, e.Actor, e.ActorKind, e.Role, e.KeyID, e.Action, e.Resource, e.Status,
+		e.RemoteAddr, e.RequestID, e.Detail, e.PrevHash, e.Hash)
+	if err != nil {
+		return fmt.Errorf("failed to append audit event: %w", err)
+	}
+	return tx.Commit()
+}
+
+// QueryAudit returns audit events matching the filter in sequence order
+func (db *DB) QueryAudit(f AuditFilter) ([]models.AuditEvent, error) {
+	var conds []string
+	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	if f.Actor != "" {
+		conds = append(conds, "actor = "+arg(f.Actor))
+	}
+	if f.Action != "" {
+		conds = append(conds, "action = "+arg(f.Action))
+	}
+	if !f.From.IsZero() {
+		conds = append(conds, "time >= "+arg(f.From))
+	}
+	if !f.To.IsZero() {
+		conds = append(conds, "time <= "+arg(f.To))
+	}
+	if f.AfterSeq > 0 {
+		conds = append(conds, "seq > "+arg(f.AfterSeq))
+	}
+
+	query := `SELECT ` + auditColumns + ` FROM audit_log`
+	if len(conds) > 0 {
+		query += ` WHERE ` + strings.Join(conds, " AND ")
+	}
+	query += ` ORDER BY seq LIMIT ` + arg(pageSize(f.Limit))
+
+	rows, err := db.conn.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit log: %w", err)
+	}
+	defer rows.Close()
+
+	var events []models.AuditEvent
+	for rows.Next() {
+		e, err := scanAuditEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+	return events, nil
+}
+
+// StreamAudit calls fn for every audit event after seq afterSeq, in
+// sequence order, without loading the log into memory
+func (db *DB) StreamAudit(afterSeq int64, fn func(models.AuditEvent) error) error {
+	rows, err := db.conn.Query(`SELECT `+auditColumns+` FROM audit_log WHERE seq > $1 ORDER BY seq`, afterSeq)
+	if err != nil {
+		return fmt.Errorf("failed to stream audit log: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		e, err := scanAuditEvent(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read audit log: %w", err)
+	}
+	return nil
+}
+
+func scanAuditEvent(rows *sql.Rows) (models.AuditEvent, error) {
+	var e models.AuditEvent
+	err := rows.Scan(&e.Seq, &e.Time, &e.Actor, &e.ActorKind, &e.Role, &e.KeyID, &e.Action,
+		&e.Resource, &e.Status, &e.RemoteAddr, &e.RequestID, &e.Detail, &e.PrevHash, &e.Hash)
+	if err != nil {
+		return e, fmt.Errorf("failed to scan audit event: %w", err)
+	}
+	return e, nil
+}
@@ -0,0 +1,335 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+
+	"silentraven/internal/models"
+)
+
+// ErrInvalidTransition is returned for a case status change the workflow
+// does not allow
+var ErrInvalidTransition = errors.New("invalid case status change")
+
+const caseColumns = `
+			id, title, description, status, assignee, source, source_ref, uas_id,
+			created_by, created_at, updated_at, closed_at`
+
+const caseAttachmentColumns = `
+			id, case_id, kind, ref, from_time, to_time, sha256, note, added_by, added_at`
+
+// CaseFilter selects cases, newest first. Zero-valued fields are ignored.
+type CaseFilter struct {
+	Status   string
+	Assignee string
+	UASID    string
+	// BeforeID continues from a previous page's last ID
+	BeforeID int64
+	Limit    int
+}
+
+// CreateCase stores an open case and its attachments, filling in IDs,
+// Status and times
+func (db *DB) CreateCase(c *models.Case) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin case create: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`
+		INSERT INTO cases (title, description, assignee, source, source_ref, uas_id, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, status, created_at, updated_at
+	`, c.Title, c.Description, c.Assignee, c.Source, c.SourceRef, c.UASID, c.CreatedBy,
+	).Scan(&c.ID, &c.Status, &c.CreatedAt, &c.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create case: %w", err)
+	}
+	for i := range c.Attachments {
+		c.Attachments[i].CaseID = c.ID
+		if err := insertCaseAttachment(tx, &c.Attachments[i]); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetCase returns a case with its attachments, notes and history, or
+// ErrNotFound
+func (db *DB) GetCase(id int64) (*models.Case, error) {
+	rows, err := db.conn.Query(`SELECT `+caseColumns+` FROM cases WHERE id = $1`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query case %d: %w", id, err)
+	}
+	defer rows.Close()
+
+	cases, err := scanCases(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(cases) == 0 {
+		return nil, ErrNotFound
+	}
+	c := &cases[0]
+
+	if c.Attachments, err = db.caseAttachments(id); err != nil {
+		return nil, err
+	}
+	if c.Notes, err = db.caseNotes(id); err != nil {
+		return nil, err
+	}
+	if c.Events, err = db.caseEvents(id); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// ListCases returns cases matching the filter, newest first, without
+// their attachments, notes and history
+func (db *DB) ListCases(f CaseFilter) ([]models.Case, error) {
+	var conds []string
+	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	if f.Status != "" {
+		conds = append(conds, "status = "+arg(f.Status))
+	}
+	if f.Assignee != "" {
+		conds = append(conds, "assignee = "+arg(f.Assignee))
+	}
+	if f.UASID != "" {
+		conds = append(conds, "uas_id = "+arg(f.UASID))
+	}
+	if f.BeforeID > 0 {
+		conds = append(conds, "id < "+arg(f.BeforeID))
+	}
+
+	query := `SELECT ` + caseColumns + ` FROM cases`
+	if len(conds) > 0 {
+		query += ` WHERE ` + strings.Join(conds, " AND ")
+	}
+	query += ` ORDER BY id DESC LIMIT ` + arg(pageSize(f.Limit))
+
+	rows, err := db.conn.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query cases: %w", err)
+	}
+	defer rows.Close()
+
+	return scanCases(rows)
+}
+
+// ChangeCase sets a case's status or assignee to e.To and records the
+// change, filling in e's ID, From and At (if unset). Status changes must
+// follow the workflow or ErrInvalidTransition is returned.
+func (db *DB) ChangeCase(e *models.CaseEvent) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin case change: %w", err)
+	}
+	defer tx.Rollback()
+
+	var status, assignee string
+	err = tx.QueryRow(`SELECT status, assignee FROM cases WHERE id = $1 FOR UPDATE`, e.CaseID).Scan(&status, &assignee)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read case %d: %w", e.CaseID, err)
+	}
+
+	if e.At.IsZero() {
+		e.At = time.Now()
+	}
+	switch e.Field {
+	case models.CaseFieldStatus:
+		if !models.CanMoveCase(status, e.To) {
+			return fmt.Errorf("%w: %s to %s", ErrInvalidTransition, status, e.To)
+		}
+		e.From = status
+		// closed_at is kept for closed cases and cleared on reopening
+		_, err = tx.Exec(`
+			UPDATE cases SET
+				status = $2,
+				closed_at = CASE WHEN $2 = 'closed' THEN $3::timestamptz END,
+				updated_at = now()
+			WHERE id = $1
+		`, e.CaseID, e.To, e.At)
+	case models.CaseFieldAssignee:
+		e.From = assignee
+		_, err = tx.Exec(`UPDATE cases SET assignee = $2, updated_at = now() WHERE id = $1`, e.CaseID, e.To)
+	default:
+		return fmt.Errorf("unknown case field %q", e.Field)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update case %d: %w", e.CaseID, err)
+	}
+
+	err = tx.QueryRow(`
+		INSERT INTO case_events (case_id, field, from_value, to_value, actor, at, message)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id
+	`, e.CaseID, e.Field, e.From, e.To, e.Actor, e.At, e.Message).Scan(&e.ID)
+	if err != nil {
+		return fmt.Errorf("failed to record case event: %w", err)
+	}
+	return tx.Commit()
+}
+
+// AddCaseNote adds a note to a case, filling in its ID and CreatedAt
+func (db *DB) AddCaseNote(n *models.CaseNote) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin case note: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := touchCase(tx, n.CaseID); err != nil {
+		return err
+	}
+	err = tx.QueryRow(`
+		INSERT INTO case_notes (case_id, author, body)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at
+	`, n.CaseID, n.Author, n.Body).Scan(&n.ID, &n.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to add case note: %w", err)
+	}
+	return tx.Commit()
+}
+
+// AddCaseAttachment attaches a detection, track or evidence bundle to a
+// case, filling in its ID and AddedAt
+func (db *DB) AddCaseAttachment(a *models.CaseAttachment) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin case attachment: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := touchCase(tx, a.CaseID); err != nil {
+		return err
+	}
+	if err := insertCaseAttachment(tx, a); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// touchCase bumps a case's updated_at, returning ErrNotFound if it does
+// not exist
+func touchCase(tx *sql.Tx, id int64) error {
+	res, err := tx.Exec(`UPDATE cases SET updated_at = now() WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to update case %d: %w", id, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func insertCaseAttachment(tx *sql.Tx, a *models.CaseAttachment) error {
+	err := tx.QueryRow(`
+		INSERT INTO case_attachments (case_id, kind, ref, from_time, to_time, sha256, note, added_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, added_at
+	`, a.CaseID, a.Kind, a.Ref, nullTime(a.From), nullTime(a.To), a.SHA256, a.Note, a.AddedBy,
+	).Scan(&a.ID, &a.AddedAt)
+	if err != nil {
+		return fmt.Errorf("failed to attach %s to case %d: %w", a.Kind, a.CaseID, err)
+	}
+	return nil
+}
+
+func (db *DB) caseAttachments(id int64) ([]models.CaseAttachment, error) {
+	rows, err := db.conn.Query(`SELECT `+caseAttachmentColumns+` FROM case_attachments WHERE case_id = $1 ORDER BY id`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query case attachments: %w", err)
+	}
+	defer rows.Close()
+
+	var attachments []models.CaseAttachment
+	for rows.Next() {
+		var a models.CaseAttachment
+		var from, to pq.NullTime
+		err := rows.Scan(&a.ID, &a.CaseID, &a.Kind, &a.Ref, &from, &to, &a.SHA256, &a.Note, &a.AddedBy, &a.AddedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan case attachment: %w", err)
+		}
+		a.From, a.To = from.Time, to.Time
+		attachments = append(attachments, a)
+	}
+	return attachments, rows.Err()
+}
+
+func (db *DB) caseNotes(id int64) ([]models.CaseNote, error) {
+	rows, err := db.conn.Query(`
+		SELECT id, case_id, author, body, created_at
+		FROM case_notes WHERE case_id = $1 ORDER BY id
+	`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query case notes: %w", err)
+	}
+	defer rows.Close()
+
+	var notes []models.CaseNote
+	for rows.Next() {
+		var n models.CaseNote
+		if err := rows.Scan(&n.ID, &n.CaseID, &n.Author, &n.Body, &n.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan case note: %w", err)
+		}
+		notes = append(notes, n)
+	}
+	return notes, rows.Err()
+}
+
+func (db *DB) caseEvents(id int64) ([]models.CaseEvent, error) {
+	rows, err := db.conn.Query(`
+		SELECT id, case_id, field, from_value, to_value, actor, at, message
+		FROM case_events WHERE case_id = $1 ORDER BY id
+	`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query case events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []models.CaseEvent
+	for rows.Next() {
+		var e models.CaseEvent
+		if err := rows.Scan(&e.ID, &e.CaseID, &e.Field, &e.From, &e.To, &e.Actor, &e.At, &e.Message); err != nil {
+			return nil, fmt.Errorf("failed to scan case event: %w", err)
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+func scanCases(rows *sql.Rows) ([]models.Case, error) {
+	var cases []models.Case
+	for rows.Next() {
+		var c models.Case
+		var closed pq.NullTime
+		err := rows.Scan(
+			&c.ID, &c.Title, &c.Description, &c.Status, &c.Assignee, &c.Source, &c.SourceRef,
+			&c.UASID, &c.CreatedBy, &c.CreatedAt, &c.UpdatedAt, &closed,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan case: %w", err)
+		}
+		c.ClosedAt = closed.Time
+		cases = append(cases, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read cases: %w", err)
+	}
+	return cases, nil
+}
@@ -0,0 +1,50 @@
+package database
+
+import (
+	"fmt"
+	"time"
+)
+
+// CoverageGridDegrees is the resolution (about 11 m) at which detection
+// positions are snapped before coverage estimation, so hovering drones
+// collapse into a single weighted sample.
+const CoverageGridDegrees = 0.0001
+
+// CoverageSample is a snapped drone position heard by one node
+type CoverageSample struct {
+	NodeID     string  `json:"node_id"`
+	Latitude   float64 `json:"latitude"`
+	Longitude  float64 `json:"longitude"`
+	Detections int64   `json:"detections"`
+}
+
+// CoverageSamples returns the positions each node heard in [from, to),
+// snapped to CoverageGridDegrees. An empty nodeID returns every node.
+func (db *DB) CoverageSamples(from, to time.Time, nodeID string) ([]CoverageSample, error) {
+	rows, err := db.conn.Query(`
+		SELECT node_id,
+		       round(latitude / $4) * $4 AS lat,
+		       round(longitude / $4) * $4 AS lon,
+		       count(*)
+		FROM drone_detections
+		WHERE detection_time >= $1 AND detection_time < $2
+		  AND node_id <> '' AND ($3 = '' OR node_id = $3)
+		  AND NOT (latitude = 0 AND longitude = 0)
+		GROUP BY node_id, lat, lon
+		ORDER BY node_id
+	`, from, to, nodeID, CoverageGridDegrees)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query coverage samples: %w", err)
+	}
+	defer rows.Close()
+
+	var out []CoverageSample
+	for rows.Next() {
+		var s CoverageSample
+		if err := rows.Scan(&s.NodeID, &s.Latitude, &s.Longitude, &s.Detections); err != nil {
+			return nil, fmt.Errorf("failed to scan coverage sample: %w", err)
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
@@ -3,7 +3,7 @@ package database
 import (
 	"database/sql"
 	"fmt"
-	"log"
+	"silentraven/internal/logging"
 	"silentraven/internal/models"
 	"silentraven/pkg/config"
 	"time"
@@ -11,6 +11,8 @@ import (
 	_ "github.com/lib/pq"
 )
 
+var logger = logging.For("db")
+
 // DB wraps database connection and operations
 type DB struct {
 	conn *sql.DB
@@ -18,8 +20,17 @@ type DB struct {
 
 // New creates a new database connection
 func New(cfg *config.Config) (*DB, error) {
-	connStr := cfg.GetDBConnectionString()
+	db, err := Open(cfg.GetDBConnectionString())
+	if err != nil {
+		return nil, err
+	}
+	logger.Info("Database connected", "host", cfg.DBHost, "name", cfg.DBName)
+	return db, nil
+}
 
+// Open connects to the database at connStr, a libpq connection string
+// or postgres:// URL
+func Open(connStr string) (*DB, error) {
 	conn, err := sql.Open("postgres", connStr)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
@@ -27,6 +38,7 @@ func New(cfg *config.Config) (*DB, error) {
 
 	// Test connection
 	if err := conn.Ping(); err != nil {
+		conn.Close()
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
@@ -35,8 +47,6 @@ func New(cfg *config.Config) (*DB, error) {
 	conn.SetMaxIdleConns(5)
 	conn.SetConnMaxLifetime(5 * time.Minute)
 
-	log.Println("✅ Database connected successfully")
-
 	return &DB{conn: conn}, nil
 }
 
@@ -51,9 +61,9 @@ func (db *DB) InsertDroneDetection(detection *models.DroneDetection) error {
 		INSERT INTO drone_detections (
 			detection_time, sn, uas_id, drone_type, latitude, longitude, height,
 			direction, speed_horizontal, speed_vertical, operator_latitude, 
-			operator_longitude, node_id, signature, raw_data
+			operator_longitude, node_id, signature, key_fingerprint, raw_data
 		) VALUES (
-			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15
+			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16
 		) RETURNING id, created_at
 	`
 
@@ -73,6 +83,7 @@ func (db *DB) InsertDroneDetection(detection *models.DroneDetection) error {
 		detection.OperatorLongitude,
 		detection.NodeID,
 		detection.Signature,
+		detection.KeyFingerprint,
 		detection.RawData,
 	).Scan(&detection.ID, &detection.CreatedAt)
 
@@ -89,7 +100,7 @@ func (db *DB) GetRecentDetections(limit int) ([]models.DroneDetection, error) {
 		SELECT 
 			id, detection_time, sn, uas_id, drone_type, latitude, longitude, height,
 			direction, speed_horizontal, speed_vertical, operator_latitude, 
-			operator_longitude, node_id, signature, raw_data, created_at
+			operator_longitude, node_id, signature, key_fingerprint, raw_data, created_at
 		FROM drone_detections
 		ORDER BY detection_time DESC
 		LIMIT $1
@@ -101,6 +112,32 @@ func (db *DB) GetRecentDetections(limit int) ([]models.DroneDetection, error) {
 	}
 	defer rows.Close()
 
+	return scanDetections(rows)
+}
+
+// GetDetectionsForUAS retrieves one UAS's detections in [from, to], oldest first
+func (db *DB) GetDetectionsForUAS(uasID string, from, to time.Time) ([]models.DroneDetection, error) {
+	query := `
+		SELECT 
+			id, detection_time, sn, uas_id, drone_type, latitude, longitude, height,
+			direction, speed_horizontal, speed_vertical, operator_latitude, 
+			operator_longitude, node_id, signature, key_fingerprint, raw_data, created_at
+		FROM drone_detections
+		WHERE uas_id = $1 AND detection_time BETWEEN $2 AND $3
+		ORDER BY detection_time ASC
+	`
+
+	rows, err := db.conn.Query(query, uasID, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query detections for %s: %w", uasID, err)
+	}
+	defer rows.Close()
+
+	return scanDetections(rows)
+}
+
+// scanDetections reads all detection rows selected in column order
+func scanDetections(rows *sql.Rows) ([]models.DroneDetection, error) {
 	var detections []models.DroneDetection
 	for rows.Next() {
 		var d models.DroneDetection
@@ -108,13 +145,16 @@ func (db *DB) GetRecentDetections(limit int) ([]models.DroneDetection, error) {
 			&d.ID, &d.DetectionTime, &d.SN, &d.UASID, &d.DroneType,
 			&d.Latitude, &d.Longitude, &d.Height, &d.Direction,
 			&d.SpeedHorizontal, &d.SpeedVertical, &d.OperatorLatitude,
-			&d.OperatorLongitude, &d.NodeID, &d.Signature, &d.RawData, &d.CreatedAt,
+			&d.OperatorLongitude, &d.NodeID, &d.Signature, &d.KeyFingerprint, &d.RawData, &d.CreatedAt,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan detection: %w", err)
 		}
 		detections = append(detections, d)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read detections: %w", err)
+	}
 
 	return detections, nil
 }
@@ -0,0 +1,34 @@
+-- Base detection table (TimescaleDB hypertable when the extension is available)
+CREATE TABLE IF NOT EXISTS drone_detections (
+    id                 BIGSERIAL,
+    detection_time     TIMESTAMPTZ      NOT NULL,
+    sn                 TEXT             NOT NULL,
+    uas_id             TEXT             NOT NULL,
+    drone_type         TEXT             NOT NULL DEFAULT '',
+    latitude           DOUBLE PRECISION NOT NULL,
+    longitude          DOUBLE PRECISION NOT NULL,
+    height             DOUBLE PRECISION NOT NULL DEFAULT 0,
+    direction          INTEGER          NOT NULL DEFAULT 0,
+    speed_horizontal   DOUBLE PRECISION NOT NULL DEFAULT 0,
+    speed_vertical     DOUBLE PRECISION NOT NULL DEFAULT 0,
+    operator_latitude  DOUBLE PRECISION NOT NULL DEFAULT 0,
+    operator_longitude DOUBLE PRECISION NOT NULL DEFAULT 0,
+    node_id            TEXT             NOT NULL DEFAULT '',
+    signature          TEXT             NOT NULL DEFAULT '',
+    raw_data           TEXT             NOT NULL DEFAULT '',
+    created_at         TIMESTAMPTZ      NOT NULL DEFAULT now(),
+    PRIMARY KEY (id, detection_time)
+);
+
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM pg_available_extensions WHERE name = 'timescaledb') THEN
+        CREATE EXTENSION IF NOT EXISTS timescaledb;
+        PERFORM create_hypertable('drone_detections', 'detection_time', if_not_exists => TRUE);
+    END IF;
+END
+$$;
+
+CREATE INDEX IF NOT EXISTS idx_drone_detections_uas_time ON drone_detections (uas_id, detection_time DESC);
+CREATE INDEX IF NOT EXISTS idx_drone_detections_sn_time ON drone_detections (sn, detection_time DESC);
+CREATE INDEX IF NOT EXISTS idx_drone_detections_node_time ON drone_detections (node_id, detection_time DESC);
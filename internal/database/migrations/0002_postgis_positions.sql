@@ -0,0 +1,36 @@
+-- PostGIS geography columns for drone and operator positions, kept in sync
+-- by trigger so writers only need to set latitude/longitude.
+CREATE EXTENSION IF NOT EXISTS postgis;
+
+ALTER TABLE drone_detections ADD COLUMN IF NOT EXISTS position geography(Point, 4326);
+ALTER TABLE drone_detections ADD COLUMN IF NOT EXISTS operator_position geography(Point, 4326);
+
+CREATE OR REPLACE FUNCTION drone_detections_set_positions() RETURNS trigger AS $$
+BEGIN
+    NEW.position := ST_SetSRID(ST_MakePoint(NEW.longitude, NEW.latitude), 4326)::geography;
+    IF NEW.operator_latitude = 0 AND NEW.operator_longitude = 0 THEN
+        NEW.operator_position := NULL;
+    ELSE
+        NEW.operator_position := ST_SetSRID(ST_MakePoint(NEW.operator_longitude, NEW.operator_latitude), 4326)::geography;
+    END IF;
+    RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_drone_detections_positions ON drone_detections;
+CREATE TRIGGER trg_drone_detections_positions
+    BEFORE INSERT OR UPDATE OF latitude, longitude, operator_latitude, operator_longitude
+    ON drone_detections
+    FOR EACH ROW EXECUTE FUNCTION drone_detections_set_positions();
+
+-- Backfill rows written before this migration
+UPDATE drone_detections
+SET position = ST_SetSRID(ST_MakePoint(longitude, latitude), 4326)::geography,
+    operator_position = CASE
+        WHEN operator_latitude = 0 AND operator_longitude = 0 THEN NULL
+        ELSE ST_SetSRID(ST_MakePoint(operator_longitude, operator_latitude), 4326)::geography
+    END
+WHERE position IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_drone_detections_position ON drone_detections USING GIST (position);
+CREATE INDEX IF NOT EXISTS idx_drone_detections_operator_position ON drone_detections USING GIST (operator_position);
@@ -0,0 +1,41 @@
+-- Downsampled track points kept after raw detections expire
+CREATE TABLE IF NOT EXISTS drone_tracks (
+    uas_id      TEXT             NOT NULL,
+    bucket      TIMESTAMPTZ      NOT NULL,
+    sn          TEXT             NOT NULL,
+    drone_type  TEXT             NOT NULL DEFAULT '',
+    latitude    DOUBLE PRECISION NOT NULL,
+    longitude   DOUBLE PRECISION NOT NULL,
+    height      DOUBLE PRECISION NOT NULL DEFAULT 0,
+    samples     INTEGER          NOT NULL,
+    PRIMARY KEY (uas_id, bucket)
+);
+
+CREATE INDEX IF NOT EXISTS idx_drone_tracks_bucket ON drone_tracks (bucket DESC);
+
+-- Archives restored for investigations land here, never in the live table,
+-- so retention does not immediately drop them again.
+CREATE TABLE IF NOT EXISTS restored_detections (
+    LIKE drone_detections INCLUDING DEFAULTS,
+    archive_file TEXT        NOT NULL,
+    restored_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_restored_detections_uas_time ON restored_detections (uas_id, detection_time DESC);
+
+-- Native compression on the hypertable (policy interval is set by the archiver)
+DO $$
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM pg_extension WHERE extname = 'timescaledb'
+    ) AND EXISTS (
+        SELECT 1 FROM timescaledb_information.hypertables WHERE hypertable_name = 'drone_detections'
+    ) THEN
+        ALTER TABLE drone_detections SET (
+            timescaledb.compress,
+            timescaledb.compress_segmentby = 'uas_id',
+            timescaledb.compress_orderby = 'detection_time DESC'
+        );
+    END IF;
+END
+$$;
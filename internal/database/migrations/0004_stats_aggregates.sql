@@ -0,0 +1,45 @@
+-- Hourly per-UAS/node/type detection counts backing the statistics API.
+-- On TimescaleDB this is a continuous aggregate with real-time results;
+-- otherwise a plain materialized view refreshed by the API service.
+DO $$
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM pg_extension WHERE extname = 'timescaledb'
+    ) AND EXISTS (
+        SELECT 1 FROM timescaledb_information.hypertables WHERE hypertable_name = 'drone_detections'
+    ) THEN
+        EXECUTE $sql$
+            CREATE MATERIALIZED VIEW IF NOT EXISTS uas_hourly
+            WITH (timescaledb.continuous, timescaledb.materialized_only = false) AS
+            SELECT
+                time_bucket(INTERVAL '1 hour', detection_time) AS bucket,
+                uas_id,
+                node_id,
+                drone_type,
+                count(*) AS detections
+            FROM drone_detections
+            GROUP BY bucket, uas_id, node_id, drone_type
+            WITH NO DATA
+        $sql$;
+
+        PERFORM add_continuous_aggregate_policy('uas_hourly',
+            start_offset      => INTERVAL '3 days',
+            end_offset        => INTERVAL '1 hour',
+            schedule_interval => INTERVAL '15 minutes',
+            if_not_exists     => true);
+    ELSE
+        CREATE MATERIALIZED VIEW IF NOT EXISTS uas_hourly AS
+        SELECT
+            date_trunc('hour', detection_time) AS bucket,
+            uas_id,
+            node_id,
+            drone_type,
+            count(*) AS detections
+        FROM drone_detections
+        GROUP BY 1, 2, 3, 4
+        WITH NO DATA;
+
+        CREATE UNIQUE INDEX IF NOT EXISTS idx_uas_hourly_key ON uas_hourly (bucket, uas_id, node_id, drone_type);
+    END IF;
+END
+$$;
@@ -0,0 +1,27 @@
+-- Sensor node registry with persisted liveness
+CREATE TABLE IF NOT EXISTS sensor_nodes (
+    node_id      TEXT PRIMARY KEY,
+    name         TEXT             NOT NULL DEFAULT '',
+    latitude     DOUBLE PRECISION NOT NULL DEFAULT 0,
+    longitude    DOUBLE PRECISION NOT NULL DEFAULT 0,
+    altitude     DOUBLE PRECISION NOT NULL DEFAULT 0,
+    antenna_type TEXT             NOT NULL DEFAULT '',
+    firmware     TEXT             NOT NULL DEFAULT '',
+    owner        TEXT             NOT NULL DEFAULT '',
+    public_key   TEXT             NOT NULL DEFAULT '',
+    status       TEXT             NOT NULL DEFAULT 'unknown',
+    last_seen    TIMESTAMPTZ,
+    created_at   TIMESTAMPTZ      NOT NULL DEFAULT now(),
+    updated_at   TIMESTAMPTZ      NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS node_events (
+    id          BIGSERIAL PRIMARY KEY,
+    node_id     TEXT        NOT NULL REFERENCES sensor_nodes (node_id) ON DELETE CASCADE,
+    from_status TEXT        NOT NULL,
+    to_status   TEXT        NOT NULL,
+    at          TIMESTAMPTZ NOT NULL,
+    message     TEXT        NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS idx_node_events_node_at ON node_events (node_id, at DESC);
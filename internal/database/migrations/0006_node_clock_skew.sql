@@ -0,0 +1,2 @@
+-- Clock skew last reported by each node's edge agent (node minus gateway)
+ALTER TABLE sensor_nodes ADD COLUMN IF NOT EXISTS clock_skew_ms BIGINT NOT NULL DEFAULT 0;
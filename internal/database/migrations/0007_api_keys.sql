@@ -0,0 +1,15 @@
+-- Hashed API keys for sensor nodes and clients
+CREATE TABLE IF NOT EXISTS api_keys (
+    id           TEXT PRIMARY KEY,
+    name         TEXT        NOT NULL DEFAULT '',
+    kind         TEXT        NOT NULL,
+    subject      TEXT        NOT NULL,
+    hash         TEXT        NOT NULL,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    expires_at   TIMESTAMPTZ,
+    revoked_at   TIMESTAMPTZ,
+    last_used_at TIMESTAMPTZ,
+    replaced_by  TEXT        NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS idx_api_keys_subject ON api_keys (kind, subject);
@@ -0,0 +1,6 @@
+-- Roles and jurisdictions scoping client keys on the query API. Client
+-- keys issued before roles existed keep full read access.
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS role TEXT NOT NULL DEFAULT '';
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS jurisdiction TEXT NOT NULL DEFAULT '';
+
+UPDATE api_keys SET role = 'analyst' WHERE kind = 'client' AND role = '';
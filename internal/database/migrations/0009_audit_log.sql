@@ -0,0 +1,39 @@
+-- Hash-chained, append-only audit log
+CREATE TABLE IF NOT EXISTS audit_log (
+    seq         BIGINT PRIMARY KEY,
+    time        TIMESTAMPTZ NOT NULL,
+    actor       TEXT    NOT NULL,
+    actor_kind  TEXT    NOT NULL,
+    role        TEXT    NOT NULL DEFAULT '',
+    key_id      TEXT    NOT NULL DEFAULT '',
+    action      TEXT    NOT NULL,
+    resource    TEXT    NOT NULL DEFAULT '',
+    status      INTEGER NOT NULL DEFAULT 0,
+    remote_addr TEXT    NOT NULL DEFAULT '',
+    request_id  TEXT    NOT NULL DEFAULT '',
+    -- TEXT, not JSONB, so the hashed bytes come back unchanged
+    detail      TEXT    NOT NULL DEFAULT '',
+    prev_hash   TEXT    NOT NULL,
+    hash        TEXT    NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_time ON audit_log (time);
+CREATE INDEX IF NOT EXISTS idx_audit_log_actor ON audit_log (actor, time);
+
+-- Entries can only be added. The chain still detects edits by anyone
+-- able to disable the trigger.
+CREATE OR REPLACE FUNCTION audit_log_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS audit_log_no_update ON audit_log;
+CREATE TRIGGER audit_log_no_update
+    BEFORE UPDATE OR DELETE ON audit_log
+    FOR EACH ROW EXECUTE FUNCTION audit_log_append_only();
+
+DROP TRIGGER IF EXISTS audit_log_no_truncate ON audit_log;
+CREATE TRIGGER audit_log_no_truncate
+    BEFORE TRUNCATE ON audit_log
+    FOR EACH STATEMENT EXECUTE FUNCTION audit_log_append_only();
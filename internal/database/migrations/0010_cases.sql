@@ -0,0 +1,58 @@
+-- Enforcement cases with their attachments, notes and change history
+CREATE TABLE IF NOT EXISTS cases (
+    id          BIGSERIAL PRIMARY KEY,
+    title       TEXT        NOT NULL,
+    description TEXT        NOT NULL DEFAULT '',
+    status      TEXT        NOT NULL DEFAULT 'open'
+                CHECK (status IN ('open', 'investigating', 'referred', 'closed')),
+    assignee    TEXT        NOT NULL DEFAULT '',
+    source      TEXT        NOT NULL CHECK (source IN ('track', 'alert', 'manual')),
+    source_ref  TEXT        NOT NULL DEFAULT '',
+    uas_id      TEXT        NOT NULL DEFAULT '',
+    created_by  TEXT        NOT NULL,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    closed_at   TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_cases_status ON cases (status, id DESC);
+CREATE INDEX IF NOT EXISTS idx_cases_assignee ON cases (assignee, id DESC);
+CREATE INDEX IF NOT EXISTS idx_cases_uas ON cases (uas_id, id DESC);
+
+CREATE TABLE IF NOT EXISTS case_attachments (
+    id        BIGSERIAL PRIMARY KEY,
+    case_id   BIGINT      NOT NULL REFERENCES cases (id) ON DELETE CASCADE,
+    kind      TEXT        NOT NULL CHECK (kind IN ('detection', 'track', 'evidence')),
+    ref       TEXT        NOT NULL,
+    from_time TIMESTAMPTZ,
+    to_time   TIMESTAMPTZ,
+    sha256    TEXT        NOT NULL DEFAULT '',
+    note      TEXT        NOT NULL DEFAULT '',
+    added_by  TEXT        NOT NULL,
+    added_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_case_attachments_case ON case_attachments (case_id, id);
+
+CREATE TABLE IF NOT EXISTS case_notes (
+    id         BIGSERIAL PRIMARY KEY,
+    case_id    BIGINT      NOT NULL REFERENCES cases (id) ON DELETE CASCADE,
+    author     TEXT        NOT NULL,
+    body       TEXT        NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_case_notes_case ON case_notes (case_id, id);
+
+CREATE TABLE IF NOT EXISTS case_events (
+    id         BIGSERIAL PRIMARY KEY,
+    case_id    BIGINT      NOT NULL REFERENCES cases (id) ON DELETE CASCADE,
+    field      TEXT        NOT NULL,
+    from_value TEXT        NOT NULL,
+    to_value   TEXT        NOT NULL,
+    actor      TEXT        NOT NULL,
+    at         TIMESTAMPTZ NOT NULL,
+    message    TEXT        NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS idx_case_events_case ON case_events (case_id, id);
@@ -0,0 +1,23 @@
+-- Every public key a node has been registered with, so packets signed
+-- before a key rotation still verify against the key used at ingest
+CREATE TABLE IF NOT EXISTS node_keys (
+    node_id     TEXT        NOT NULL,
+    fingerprint TEXT        NOT NULL,
+    public_key  TEXT        NOT NULL,
+    added_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (node_id, fingerprint)
+);
+
+-- Fingerprint is the SHA-256 of the key's DER (PKIX) encoding
+INSERT INTO node_keys (node_id, fingerprint, public_key, added_at)
+SELECT node_id,
+       encode(sha256(decode(regexp_replace(public_key, '-----[A-Z ]+-----|\s', '', 'g'), 'base64')), 'hex'),
+       public_key,
+       updated_at
+FROM sensor_nodes
+WHERE public_key <> ''
+ON CONFLICT DO NOTHING;
+
+-- The node key a detection's signature verified against at ingest
+ALTER TABLE drone_detections ADD COLUMN IF NOT EXISTS key_fingerprint TEXT NOT NULL DEFAULT '';
+ALTER TABLE restored_detections ADD COLUMN IF NOT EXISTS key_fingerprint TEXT NOT NULL DEFAULT '';
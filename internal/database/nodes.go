@@ -0,0 +1,248 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+
+	"silentraven/internal/crypto"
+	"silentraven/internal/models"
+)
+
+// ErrNotFound is returned when a requested record does not exist
+var ErrNotFound = errors.New("not found")
+
+const nodeColumns = `
+			node_id, name, latitude, longitude, altitude, antenna_type, firmware,
+			owner, public_key, status, last_seen, clock_skew_ms, created_at, updated_at`
+
+// UpsertNode registers a node or updates its metadata, adding a new
+// public key to the node's key history. Status and last-seen are owned by
+// heartbeats and the monitor and are left untouched.
+func (db *DB) UpsertNode(n *models.SensorNode) error {
+	var fingerprint string
+	if n.PublicKey != "" {
+		var err error
+		if fingerprint, err = crypto.KeyFingerprint(n.PublicKey); err != nil {
+			return fmt.Errorf("failed to upsert node %s: %w", n.NodeID, err)
+		}
+	}
+
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin node upsert: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO sensor_nodes (
+			node_id, name, latitude, longitude, altitude, antenna_type, firmware, owner, public_key
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (node_id) DO UPDATE SET
+			name = EXCLUDED.name,
+			latitude = EXCLUDED.latitude,
+			longitude = EXCLUDED.longitude,
+			altitude = EXCLUDED.altitude,
+			antenna_type = EXCLUDED.antenna_type,
+			firmware = COALESCE(NULLIF(EXCLUDED.firmware, ''), sensor_nodes.firmware),
+			owner = EXCLUDED.owner,
+			public_key = EXCLUDED.public_key,
+			updated_at = now()
+		RETURNING firmware, status, created_at, updated_at
+	`
+
+	err = tx.QueryRow(query,
+		n.NodeID, n.Name, n.Latitude, n.Longitude, n.Altitude,
+		n.AntennaType, n.Firmware, n.Owner, n.PublicKey,
+	).Scan(&n.Firmware, &n.Status, &n.CreatedAt, &n.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to upsert node %s: %w", n.NodeID, err)
+	}
+
+	if fingerprint != "" {
+		_, err = tx.Exec(`
+			INSERT INTO node_keys (node_id, fingerprint, public_key)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (node_id, fingerprint) DO NOTHING
+		`, n.NodeID, fingerprint, n.PublicKey)
+		if err != nil {
+			return fmt.Errorf("failed to record key for node %s: %w", n.NodeID, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// NodeKeys returns every key the node has been registered with, oldest first
+func (db *DB) NodeKeys(nodeID string) ([]models.NodeKey, error) {
+	rows, err := db.conn.Query(`
+		SELECT node_id, fingerprint, public_key, added_at
+		FROM node_keys
+		WHERE node_id = $1
+		ORDER BY added_at, fingerprint
+	`, nodeID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query keys for node %s: %w", nodeID, err)
+	}
+	defer rows.Close()
+
+	var keys []models.NodeKey
+	for rows.Next() {
+		var k models.NodeKey
+		if err := rows.Scan(&k.NodeID, &k.Fingerprint, &k.PublicKey, &k.AddedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan node key: %w", err)
+		}
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+// GetNode returns one node or ErrNotFound
+func (db *DB) GetNode(nodeID string) (*models.SensorNode, error) {
+	rows, err := db.conn.Query(`SELECT `+nodeColumns+` FROM sensor_nodes WHERE node_id = $1`, nodeID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query node %s: %w", nodeID, err)
+	}
+	defer rows.Close()
+
+	nodes, err := scanNodes(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(nodes) == 0 {
+		return nil, ErrNotFound
+	}
+	return &nodes[0], nil
+}
+
+// ListNodes returns every registered node ordered by ID
+func (db *DB) ListNodes() ([]models.SensorNode, error) {
+	rows, err := db.conn.Query(`SELECT ` + nodeColumns + ` FROM sensor_nodes ORDER BY node_id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query nodes: %w", err)
+	}
+	defer rows.Close()
+
+	return scanNodes(rows)
+}
+
+// DeleteNode removes a node and its events
+func (db *DB) DeleteNode(nodeID string) error {
+	res, err := db.conn.Exec(`DELETE FROM sensor_nodes WHERE node_id = $1`, nodeID)
+	if err != nil {
+		return fmt.Errorf("failed to delete node %s: %w", nodeID, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// RecordHeartbeat stores a node's last-seen time (registering unknown
+// nodes) and any firmware, position or clock skew it reports.
+func (db *DB) RecordHeartbeat(hb models.NodeHeartbeat, at time.Time) error {
+	var lat, lon sql.NullFloat64
+	if hb.Latitude != nil && hb.Longitude != nil {
+		lat = sql.NullFloat64{Float64: *hb.Latitude, Valid: true}
+		lon = sql.NullFloat64{Float64: *hb.Longitude, Valid: true}
+	}
+	var skew sql.NullInt64
+	if hb.ClockSkewMs != nil {
+		skew = sql.NullInt64{Int64: *hb.ClockSkewMs, Valid: true}
+	}
+
+	query := `
+		INSERT INTO sensor_nodes (node_id, firmware, latitude, longitude, last_seen, clock_skew_ms)
+		VALUES ($1, $2, COALESCE($3, 0), COALESCE($4, 0), $5, COALESCE($6, 0))
+		ON CONFLICT (node_id) DO UPDATE SET
+			firmware = COALESCE(NULLIF(EXCLUDED.firmware, ''), sensor_nodes.firmware),
+			latitude = COALESCE($3, sensor_nodes.latitude),
+			longitude = COALESCE($4, sensor_nodes.longitude),
+			last_seen = GREATEST(sensor_nodes.last_seen, EXCLUDED.last_seen),
+			clock_skew_ms = COALESCE($6, sensor_nodes.clock_skew_ms),
+			updated_at = now()
+	`
+
+	if _, err := db.conn.Exec(query, hb.NodeID, hb.Firmware, lat, lon, at, skew); err != nil {
+		return fmt.Errorf("failed to record heartbeat for %s: %w", hb.NodeID, err)
+	}
+	return nil
+}
+
+// SetNodeStatus updates a node's status and records the transition
+func (db *DB) SetNodeStatus(event *models.NodeEvent) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin status update: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`UPDATE sensor_nodes SET status = $2, updated_at = now() WHERE node_id = $1`,
+		event.NodeID, event.ToStatus)
+	if err != nil {
+		return fmt.Errorf("failed to update node status: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+
+	err = tx.QueryRow(`
+		INSERT INTO node_events (node_id, from_status, to_status, at, message)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id
+	`, event.NodeID, event.FromStatus, event.ToStatus, event.At, event.Message).Scan(&event.ID)
+	if err != nil {
+		return fmt.Errorf("failed to record node event: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// NodeEvents returns a node's most recent status transitions
+func (db *DB) NodeEvents(nodeID string, limit int) ([]models.NodeEvent, error) {
+	rows, err := db.conn.Query(`
+		SELECT id, node_id, from_status, to_status, at, message
+		FROM node_events
+		WHERE node_id = $1
+		ORDER BY at DESC, id DESC
+		LIMIT $2
+	`, nodeID, pageSize(limit))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query node events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []models.NodeEvent
+	for rows.Next() {
+		var e models.NodeEvent
+		if err := rows.Scan(&e.ID, &e.NodeID, &e.FromStatus, &e.ToStatus, &e.At, &e.Message); err != nil {
+			return nil, fmt.Errorf("failed to scan node event: %w", err)
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+func scanNodes(rows *sql.Rows) ([]models.SensorNode, error) {
+	var nodes []models.SensorNode
+	for rows.Next() {
+		var n models.SensorNode
+		var lastSeen pq.NullTime
+		err := rows.Scan(
+			&n.NodeID, &n.Name, &n.Latitude, &n.Longitude, &n.Altitude, &n.AntennaType,
+			&n.Firmware, &n.Owner, &n.PublicKey, &n.Status, &lastSeen, &n.ClockSkewMs, &n.CreatedAt, &n.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan node: %w", err)
+		}
+		n.LastSeen = lastSeen.Time
+		nodes = append(nodes, n)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read nodes: %w", err)
+	}
+	return nodes, nil
+}
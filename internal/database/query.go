@@ -0,0 +1,342 @@
+package database
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"silentraven/internal/models"
+)
+
+const (
+	// DefaultPageSize is used when a filter has no limit
+	DefaultPageSize = 500
+	// MaxPageSize caps a single page of results
+	MaxPageSize = 10000
+)
+
+// detectionColumns lists drone_detections columns in scanDetections order
+const detectionColumns = `
+			id, detection_time, sn, uas_id, drone_type, latitude, longitude, height,
+			direction, speed_horizontal, speed_vertical, operator_latitude,
+			operator_longitude, node_id, signature, key_fingerprint, raw_data, created_at`
+
+// BoundingBox is a lat/lon rectangle (south-west and north-east corners)
+type BoundingBox struct {
+	MinLat, MinLon float64
+	MaxLat, MaxLon float64
+}
+
+// Radius selects points within Meters of a center point
+type Radius struct {
+	Lat, Lon float64
+	Meters   float64
+}
+
+// Area is a circle or a polygon
+type Area struct {
+	Radius  *Radius
+	Polygon []Point
+}
+
+// DetectionFilter selects drone detections. Zero-valued fields are ignored.
+type DetectionFilter struct {
+	UASID  string
+	SN     string
+	NodeID string
+
+	From time.Time
+	To   time.Time
+
+	BBox   *BoundingBox
+	Radius *Radius
+	// Polygon matches drone positions inside the ring (PostGIS)
+	Polygon []Point
+	// OperatorPolygon matches operator positions inside the ring (PostGIS)
+	OperatorPolygon []Point
+	// Scope confines drone positions to an area on top of the other
+	// spatial filters, for callers limited to a jurisdiction
+	Scope *Area
+
+	MinHeight *float64
+	MaxHeight *float64
+
+	// Cursor continues from a previous page's NextCursor
+	Cursor string
+	Limit  int
+	// Ascending orders oldest first (default newest first)
+	Ascending bool
+}
+
+// DetectionPage is one page of query results
+type DetectionPage struct {
+	Detections []models.DroneDetection `json:"detections"`
+	NextCursor string                  `json:"next_cursor,omitempty"`
+}
+
+// QueryDetections returns a page of detections matching the filter,
+// ordered by detection time with keyset pagination.
+func (db *DB) QueryDetections(f DetectionFilter) (*DetectionPage, error) {
+	limit := pageSize(f.Limit)
+
+	q := newQueryBuilder()
+	if err := q.applyFilter(f); err != nil {
+		return nil, err
+	}
+
+	order := "DESC"
+	cmp := "<"
+	if f.Ascending {
+		order = "ASC"
+		cmp = ">"
+	}
+
+	if f.Cursor != "" {
+		ts, id, err := DecodeCursor(f.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		q.where(fmt.Sprintf("(detection_time, id) %s (%s, %s)", cmp, q.arg(ts), q.arg(id)))
+	}
+
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM drone_detections
+		%s
+		ORDER BY detection_time %s, id %s
+		LIMIT %s
+	`, detectionColumns, q.whereClause(), order, order, q.arg(limit+1))
+
+	rows, err := db.conn.Query(query, q.args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query detections: %w", err)
+	}
+	defer rows.Close()
+
+	detections, err := scanDetections(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &DetectionPage{Detections: detections}
+	if len(detections) > limit {
+		page.Detections = detections[:limit]
+		last := page.Detections[limit-1]
+		page.NextCursor = EncodeCursor(last.DetectionTime, last.ID)
+	}
+
+	return page, nil
+}
+
+// LatestPerUAS returns the most recent detection of every UAS matching the
+// filter. Spatial and altitude filters apply to that latest position.
+func (db *DB) LatestPerUAS(f DetectionFilter) ([]models.DroneDetection, error) {
+	inner := newQueryBuilder()
+	if err := inner.applyTemporal(f); err != nil {
+		return nil, err
+	}
+
+	outer := &queryBuilder{args: inner.args}
+	if err := outer.applySpatial(f); err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf(`
+		WITH latest AS (
+			SELECT DISTINCT ON (uas_id) *
+			FROM drone_detections
+			%s
+			ORDER BY uas_id, detection_time DESC
+		)
+		SELECT %s
+		FROM latest
+		%s
+		ORDER BY detection_time DESC
+	`, inner.whereClause(), detectionColumns, outer.whereClause())
+
+	if f.Limit > 0 {
+		query += " LIMIT " + outer.arg(pageSize(f.Limit))
+	}
+
+	rows, err := db.conn.Query(query, outer.args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query latest detections: %w", err)
+	}
+	defer rows.Close()
+
+	return scanDetections(rows)
+}
+
+// queryBuilder accumulates WHERE conditions and positional arguments
+type queryBuilder struct {
+	conds []string
+	args  []interface{}
+}
+
+func newQueryBuilder() *queryBuilder {
+	return &queryBuilder{}
+}
+
+// arg appends a value and returns its placeholder
+func (q *queryBuilder) arg(v interface{}) string {
+	q.args = append(q.args, v)
+	return "$" + strconv.Itoa(len(q.args))
+}
+
+func (q *queryBuilder) where(cond string) {
+	q.conds = append(q.conds, cond)
+}
+
+func (q *queryBuilder) whereClause() string {
+	if len(q.conds) == 0 {
+		return ""
+	}
+	return "WHERE " + strings.Join(q.conds, " AND ")
+}
+
+func (q *queryBuilder) applyFilter(f DetectionFilter) error {
+	if err := q.applyTemporal(f); err != nil {
+		return err
+	}
+	return q.applySpatial(f)
+}
+
+// applyTemporal adds identity and time-window conditions
+func (q *queryBuilder) applyTemporal(f DetectionFilter) error {
+	if f.UASID != "" {
+		q.where("uas_id = " + q.arg(f.UASID))
+	}
+	if f.SN != "" {
+		q.where("sn = " + q.arg(f.SN))
+	}
+	if f.NodeID != "" {
+		q.where("node_id = " + q.arg(f.NodeID))
+	}
+	if !f.From.IsZero() && !f.To.IsZero() && !f.From.Before(f.To) {
+		return fmt.Errorf("invalid time window: from %s is not before to %s", f.From, f.To)
+	}
+	if !f.From.IsZero() {
+		q.where("detection_time >= " + q.arg(f.From))
+	}
+	if !f.To.IsZero() {
+		q.where("detection_time <= " + q.arg(f.To))
+	}
+	return nil
+}
+
+// applySpatial adds bounding box, radius and altitude band conditions
+func (q *queryBuilder) applySpatial(f DetectionFilter) error {
+	if b := f.BBox; b != nil {
+		if b.MinLat > b.MaxLat {
+			return fmt.Errorf("invalid bounding box: min latitude above max latitude")
+		}
+		if b.MinLon <= b.MaxLon {
+			q.where("ST_Intersects(position, " + q.envelope(b.MinLon, b.MinLat, b.MaxLon, b.MaxLat) + ")")
+		} else {
+			// Box crosses the antimeridian: split into two envelopes
+			q.where(fmt.Sprintf("(ST_Intersects(position, %s) OR ST_Intersects(position, %s))",
+				q.envelope(b.MinLon, b.MinLat, 180, b.MaxLat),
+				q.envelope(-180, b.MinLat, b.MaxLon, b.MaxLat)))
+		}
+	}
+
+	if r := f.Radius; r != nil {
+		if r.Meters <= 0 {
+			return fmt.Errorf("invalid radius: %.1f m", r.Meters)
+		}
+		q.where(fmt.Sprintf("ST_DWithin(position, %s, %s)",
+			q.geogPoint(Point{Lat: r.Lat, Lon: r.Lon}), q.arg(r.Meters)))
+	}
+
+	if len(f.Polygon) > 0 {
+		wkt, err := polygonWKT(f.Polygon)
+		if err != nil {
+			return err
+		}
+		q.where(fmt.Sprintf("ST_Intersects(position, ST_GeogFromText(%s))", q.arg(wkt)))
+	}
+	if len(f.OperatorPolygon) > 0 {
+		wkt, err := polygonWKT(f.OperatorPolygon)
+		if err != nil {
+			return err
+		}
+		q.where(fmt.Sprintf("ST_Intersects(operator_position, ST_GeogFromText(%s))", q.arg(wkt)))
+	}
+	if s := f.Scope; s != nil {
+		if err := q.applyArea(s); err != nil {
+			return err
+		}
+	}
+
+	if f.MinHeight != nil {
+		q.where("height >= " + q.arg(*f.MinHeight))
+	}
+	if f.MaxHeight != nil {
+		q.where("height <= " + q.arg(*f.MaxHeight))
+	}
+	return nil
+}
+
+// applyArea confines drone positions to a circle or polygon
+func (q *queryBuilder) applyArea(a *Area) error {
+	switch {
+	case a.Radius != nil:
+		if a.Radius.Meters <= 0 {
+			return fmt.Errorf("invalid scope radius: %.1f m", a.Radius.Meters)
+		}
+		q.where(fmt.Sprintf("ST_DWithin(position, %s, %s)",
+			q.geogPoint(Point{Lat: a.Radius.Lat, Lon: a.Radius.Lon}), q.arg(a.Radius.Meters)))
+	case len(a.Polygon) > 0:
+		wkt, err := polygonWKT(a.Polygon)
+		if err != nil {
+			return err
+		}
+		q.where(fmt.Sprintf("ST_Intersects(position, ST_GeogFromText(%s))", q.arg(wkt)))
+	default:
+		// An empty scope must not widen access to everything
+		q.where("FALSE")
+	}
+	return nil
+}
+
+func pageSize(limit int) int {
+	if limit <= 0 {
+		return DefaultPageSize
+	}
+	if limit > MaxPageSize {
+		return MaxPageSize
+	}
+	return limit
+}
+
+// EncodeCursor returns an opaque keyset cursor for (time, id)
+func EncodeCursor(t time.Time, id int64) string {
+	raw := fmt.Sprintf("%d:%d", t.UnixNano(), id)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodeCursor parses a cursor produced by EncodeCursor
+func DecodeCursor(cursor string) (time.Time, int64, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, 0, fmt.Errorf("invalid cursor: %w", err)
+	}
+
+	parts := strings.SplitN(string(raw), ":", 2)
+	if len(parts) != 2 {
+		return time.Time{}, 0, fmt.Errorf("invalid cursor")
+	}
+
+	nanos, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return time.Time{}, 0, fmt.Errorf("invalid cursor time: %w", err)
+	}
+	id, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return time.Time{}, 0, fmt.Errorf("invalid cursor id: %w", err)
+	}
+
+	return time.Unix(0, nanos), id, nil
+}
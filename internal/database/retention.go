@@ -0,0 +1,233 @@
+package database
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+
+	"silentraven/internal/models"
+)
+
+// RestoreTables lists the tables RestoreDetections may write to
+var RestoreTables = map[string]bool{
+	"restored_detections": true,
+	"drone_detections":    true,
+}
+
+// StreamDetections calls fn for every detection in [from, to), oldest first,
+// without loading the whole range into memory.
+func (db *DB) StreamDetections(from, to time.Time, fn func(models.DroneDetection) error) error {
+	query := `
+		SELECT ` + detectionColumns + `
+		FROM drone_detections
+		WHERE detection_time >= $1 AND detection_time < $2
+		ORDER BY detection_time ASC, id ASC
+	`
+
+	rows, err := db.conn.Query(query, from, to)
+	if err != nil {
+		return fmt.Errorf("failed to stream detections: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var d models.DroneDetection
+		err := rows.Scan(
+			&d.ID, &d.DetectionTime, &d.SN, &d.UASID, &d.DroneType,
+			&d.Latitude, &d.Longitude, &d.Height, &d.Direction,
+			&d.SpeedHorizontal, &d.SpeedVertical, &d.OperatorLatitude,
+			&d.OperatorLongitude, &d.NodeID, &d.Signature, &d.KeyFingerprint, &d.RawData, &d.CreatedAt,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to scan detection: %w", err)
+		}
+		if err := fn(d); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// OldestDetectionTime returns the time of the oldest stored detection
+// (zero if the table is empty)
+func (db *DB) OldestDetectionTime() (time.Time, error) {
+	var t pq.NullTime
+	if err := db.conn.QueryRow(`SELECT min(detection_time) FROM drone_detections`).Scan(&t); err != nil {
+		return time.Time{}, fmt.Errorf("failed to query oldest detection: %w", err)
+	}
+	return t.Time, nil
+}
+
+// DownsampleTracks keeps the last position per UAS per bucket for
+// detections in [from, to). Existing buckets are left untouched.
+func (db *DB) DownsampleTracks(from, to time.Time, bucket time.Duration) (int64, error) {
+	query := `
+		INSERT INTO drone_tracks (uas_id, bucket, sn, drone_type, latitude, longitude, height, samples)
+		SELECT DISTINCT ON (uas_id, b)
+			uas_id, b, sn, drone_type, latitude, longitude, height,
+			count(*) OVER (PARTITION BY uas_id, b)
+		FROM (
+			SELECT *, to_timestamp(floor(extract(epoch FROM detection_time) / $3) * $3) AS b
+			FROM drone_detections
+			WHERE detection_time >= $1 AND detection_time < $2
+		) d
+		ORDER BY uas_id, b, detection_time DESC
+		ON CONFLICT (uas_id, bucket) DO NOTHING
+	`
+
+	res, err := db.conn.Exec(query, from, to, bucket.Seconds())
+	if err != nil {
+		return 0, fmt.Errorf("failed to downsample tracks: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+// DropDetectionsBefore removes raw detections older than cutoff and
+// returns how many rows went. On a hypertable whole chunks are dropped,
+// so rows in a chunk that straddles cutoff stay until it is all older;
+// otherwise rows are deleted.
+func (db *DB) DropDetectionsBefore(cutoff time.Time) (int64, error) {
+	hyper, err := db.isHypertable("drone_detections")
+	if err != nil {
+		return 0, err
+	}
+	if !hyper {
+		res, err := db.conn.Exec(`DELETE FROM drone_detections WHERE detection_time < $1`, cutoff)
+		if err != nil {
+			return 0, fmt.Errorf("failed to delete detections: %w", err)
+		}
+		return res.RowsAffected()
+	}
+
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin chunk drop: %w", err)
+	}
+	defer tx.Rollback()
+
+	// drop_chunks reports chunks, not rows, so count the rows in the
+	// chunks that end by cutoff first
+	var dropped int64
+	err = tx.QueryRow(`
+		SELECT count(*) FROM drone_detections
+		WHERE detection_time < (
+			SELECT max(range_end) FROM timescaledb_information.chunks
+			WHERE hypertable_name = 'drone_detections' AND range_end <= $1
+		)
+	`, cutoff).Scan(&dropped)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count detections to drop: %w", err)
+	}
+	if _, err := tx.Exec(`SELECT drop_chunks('drone_detections', older_than => $1::timestamptz)`, cutoff); err != nil {
+		return 0, fmt.Errorf("failed to drop chunks: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit chunk drop: %w", err)
+	}
+	return dropped, nil
+}
+
+// DeleteTracksBefore removes downsampled track points older than cutoff
+func (db *DB) DeleteTracksBefore(cutoff time.Time) (int64, error) {
+	res, err := db.conn.Exec(`DELETE FROM drone_tracks WHERE bucket < $1`, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete tracks: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+// SetCompressionPolicy (re)creates the TimescaleDB compression policy.
+// It is a no-op when drone_detections is not a hypertable.
+func (db *DB) SetCompressionPolicy(after time.Duration) error {
+	hyper, err := db.isHypertable("drone_detections")
+	if err != nil || !hyper {
+		return err
+	}
+
+	if _, err := db.conn.Exec(`SELECT remove_compression_policy('drone_detections', if_exists => true)`); err != nil {
+		return fmt.Errorf("failed to remove compression policy: %w", err)
+	}
+	if after <= 0 {
+		return nil
+	}
+
+	interval := fmt.Sprintf("%d seconds", int64(after.Seconds()))
+	if _, err := db.conn.Exec(`SELECT add_compression_policy('drone_detections', $1::interval)`, interval); err != nil {
+		return fmt.Errorf("failed to add compression policy: %w", err)
+	}
+	return nil
+}
+
+// RestoreDetections bulk-loads archived detections into table using COPY
+func (db *DB) RestoreDetections(table, archiveFile string, detections []models.DroneDetection) error {
+	if !RestoreTables[table] {
+		return fmt.Errorf("cannot restore into table %q", table)
+	}
+
+	columns := []string{
+		"id", "detection_time", "sn", "uas_id", "drone_type", "latitude", "longitude", "height",
+		"direction", "speed_horizontal", "speed_vertical", "operator_latitude",
+		"operator_longitude", "node_id", "signature", "key_fingerprint", "raw_data", "created_at",
+	}
+	if table == "restored_detections" {
+		columns = append(columns, "archive_file")
+	}
+
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin restore: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(pq.CopyIn(table, columns...))
+	if err != nil {
+		return fmt.Errorf("failed to prepare COPY: %w", err)
+	}
+
+	for _, d := range detections {
+		values := []interface{}{
+			d.ID, d.DetectionTime, d.SN, d.UASID, d.DroneType, d.Latitude, d.Longitude, d.Height,
+			d.Direction, d.SpeedHorizontal, d.SpeedVertical, d.OperatorLatitude,
+			d.OperatorLongitude, d.NodeID, d.Signature, d.KeyFingerprint, d.RawData, d.CreatedAt,
+		}
+		if table == "restored_detections" {
+			values = append(values, archiveFile)
+		}
+		if _, err := stmt.Exec(values...); err != nil {
+			stmt.Close()
+			return fmt.Errorf("failed to copy detection %d: %w", d.ID, err)
+		}
+	}
+
+	if _, err := stmt.Exec(); err != nil {
+		stmt.Close()
+		return fmt.Errorf("failed to flush COPY: %w", err)
+	}
+	if err := stmt.Close(); err != nil {
+		return fmt.Errorf("failed to close COPY: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// isHypertable reports whether table is a TimescaleDB hypertable
+func (db *DB) isHypertable(table string) (bool, error) {
+	var exists bool
+	err := db.conn.QueryRow(`SELECT EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'timescaledb')`).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to check timescaledb: %w", err)
+	}
+	if !exists {
+		return false, nil
+	}
+
+	err = db.conn.QueryRow(
+		`SELECT EXISTS (SELECT 1 FROM timescaledb_information.hypertables WHERE hypertable_name = $1)`, table,
+	).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to check hypertable: %w", err)
+	}
+	return exists, nil
+}
@@ -0,0 +1,121 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+
+	"silentraven/internal/models"
+)
+
+// Point is a WGS-84 position
+type Point struct {
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+}
+
+// NearestDetection is a UAS's latest detection with its distance from a point
+type NearestDetection struct {
+	models.DroneDetection
+	DistanceMeters float64 `json:"distance_m"`
+}
+
+// NearestUAS returns the k UAS whose latest position (within the filter's
+// time window) is closest to center, nearest first.
+func (db *DB) NearestUAS(center Point, k int, f DetectionFilter) ([]NearestDetection, error) {
+	if k <= 0 {
+		k = 10
+	}
+
+	inner := newQueryBuilder()
+	if err := inner.applyTemporal(f); err != nil {
+		return nil, err
+	}
+
+	outer := &queryBuilder{args: inner.args}
+	if err := outer.applySpatial(f); err != nil {
+		return nil, err
+	}
+	target := outer.geogPoint(center)
+
+	query := fmt.Sprintf(`
+		WITH latest AS (
+			SELECT DISTINCT ON (uas_id) *
+			FROM drone_detections
+			%s
+			ORDER BY uas_id, detection_time DESC
+		)
+		SELECT %s, ST_Distance(position, %s)
+		FROM latest
+		%s
+		ORDER BY position <-> %s
+		LIMIT %s
+	`, inner.whereClause(), detectionColumns, target, outer.whereClause(), target, outer.arg(pageSize(k)))
+
+	rows, err := db.conn.Query(query, outer.args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query nearest UAS: %w", err)
+	}
+	defer rows.Close()
+
+	var results []NearestDetection
+	for rows.Next() {
+		var n NearestDetection
+		d := &n.DroneDetection
+		err := rows.Scan(
+			&d.ID, &d.DetectionTime, &d.SN, &d.UASID, &d.DroneType,
+			&d.Latitude, &d.Longitude, &d.Height, &d.Direction,
+			&d.SpeedHorizontal, &d.SpeedVertical, &d.OperatorLatitude,
+			&d.OperatorLongitude, &d.NodeID, &d.Signature, &d.KeyFingerprint, &d.RawData, &d.CreatedAt,
+			&n.DistanceMeters,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan nearest UAS: %w", err)
+		}
+		results = append(results, n)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read nearest UAS: %w", err)
+	}
+
+	return results, nil
+}
+
+// DetectionsInPolygon returns a page of detections whose drone position
+// intersects the polygon, combined with the rest of the filter.
+func (db *DB) DetectionsInPolygon(polygon []Point, f DetectionFilter) (*DetectionPage, error) {
+	f.Polygon = polygon
+	return db.QueryDetections(f)
+}
+
+// geogPoint adds a point argument and returns a geography expression
+func (q *queryBuilder) geogPoint(p Point) string {
+	return fmt.Sprintf("ST_SetSRID(ST_MakePoint(%s, %s), 4326)::geography", q.arg(p.Lon), q.arg(p.Lat))
+}
+
+// envelope adds a lon/lat rectangle and returns a geography expression
+func (q *queryBuilder) envelope(minLon, minLat, maxLon, maxLat float64) string {
+	return fmt.Sprintf("ST_MakeEnvelope(%s, %s, %s, %s, 4326)::geography",
+		q.arg(minLon), q.arg(minLat), q.arg(maxLon), q.arg(maxLat))
+}
+
+// polygonWKT validates a ring and returns it as EWKT, closing it if needed
+func polygonWKT(ring []Point) (string, error) {
+	if len(ring) < 3 {
+		return "", fmt.Errorf("polygon needs at least 3 points, got %d", len(ring))
+	}
+
+	points := ring
+	if first, last := ring[0], ring[len(ring)-1]; first != last {
+		points = append(append([]Point{}, ring...), first)
+	}
+
+	coords := make([]string, 0, len(points))
+	for _, p := range points {
+		if p.Lat < -90 || p.Lat > 90 || p.Lon < -180 || p.Lon > 180 {
+			return "", fmt.Errorf("polygon point out of range: %.6f,%.6f", p.Lat, p.Lon)
+		}
+		coords = append(coords, fmt.Sprintf("%f %f", p.Lon, p.Lat))
+	}
+
+	return "SRID=4326;POLYGON((" + strings.Join(coords, ", ") + "))", nil
+}
@@ -0,0 +1,215 @@
+package database
+
+import (
+	"fmt"
+	"time"
+)
+
+// StatsBuckets lists the supported time-bucket sizes for statistics
+var StatsBuckets = map[string]time.Duration{
+	"hour": time.Hour,
+	"day":  24 * time.Hour,
+	"week": 7 * 24 * time.Hour,
+}
+
+// StatsBucket is one time bucket of detection activity
+type StatsBucket struct {
+	Bucket     time.Time `json:"bucket"`
+	UniqueUAS  int64     `json:"unique_uas"`
+	Detections int64     `json:"detections"`
+}
+
+// NodeStat is detection activity for one sensor node
+type NodeStat struct {
+	NodeID     string `json:"node_id"`
+	UniqueUAS  int64  `json:"unique_uas"`
+	Detections int64  `json:"detections"`
+}
+
+// HourStat is detection activity for one hour of the day (UTC)
+type HourStat struct {
+	Hour       int   `json:"hour"`
+	Detections int64 `json:"detections"`
+}
+
+// DroneTypeStat is detection activity for one drone type
+type DroneTypeStat struct {
+	DroneType  string `json:"drone_type"`
+	UniqueUAS  int64  `json:"unique_uas"`
+	Detections int64  `json:"detections"`
+}
+
+// LiveSummary is the dashboard card data
+type LiveSummary struct {
+	TodayUniqueUAS int64     `json:"today_unique_uasids"`
+	OnlineDrones   int64     `json:"online_drones"`
+	NodesActive    int64     `json:"nodes_active"`
+	AsOf           time.Time `json:"as_of"`
+}
+
+// RefreshStats brings the uas_hourly aggregate up to date for [from, to)
+func (db *DB) RefreshStats(from, to time.Time) error {
+	hyper, err := db.isHypertable("drone_detections")
+	if err != nil {
+		return err
+	}
+
+	if hyper {
+		_, err := db.conn.Exec(`CALL refresh_continuous_aggregate('uas_hourly', $1::timestamptz, $2::timestamptz)`,
+			from.Truncate(time.Hour), to.Truncate(time.Hour))
+		if err != nil {
+			return fmt.Errorf("failed to refresh continuous aggregate: %w", err)
+		}
+		return nil
+	}
+
+	// A plain materialized view can only be refreshed concurrently once populated
+	var populated bool
+	err = db.conn.QueryRow(`SELECT ispopulated FROM pg_matviews WHERE matviewname = 'uas_hourly'`).Scan(&populated)
+	if err != nil {
+		return fmt.Errorf("failed to check uas_hourly: %w", err)
+	}
+
+	refresh := `REFRESH MATERIALIZED VIEW CONCURRENTLY uas_hourly`
+	if !populated {
+		refresh = `REFRESH MATERIALIZED VIEW uas_hourly`
+	}
+	if _, err := db.conn.Exec(refresh); err != nil {
+		return fmt.Errorf("failed to refresh uas_hourly: %w", err)
+	}
+	return nil
+}
+
+// StatsSeries returns unique UAS and detection counts per bucket in [from, to)
+func (db *DB) StatsSeries(bucket string, from, to time.Time) ([]StatsBucket, error) {
+	if _, ok := StatsBuckets[bucket]; !ok {
+		return nil, fmt.Errorf("unsupported bucket %q (use hour, day or week)", bucket)
+	}
+
+	rows, err := db.conn.Query(`
+		SELECT date_trunc($1, bucket) AS b, count(DISTINCT uas_id), sum(detections)::bigint
+		FROM uas_hourly
+		WHERE bucket >= $2 AND bucket < $3
+		GROUP BY b
+		ORDER BY b
+	`, bucket, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query stats series: %w", err)
+	}
+	defer rows.Close()
+
+	var out []StatsBucket
+	for rows.Next() {
+		var s StatsBucket
+		if err := rows.Scan(&s.Bucket, &s.UniqueUAS, &s.Detections); err != nil {
+			return nil, fmt.Errorf("failed to scan stats bucket: %w", err)
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
+
+// NodeStats returns per-node activity in [from, to), busiest first
+func (db *DB) NodeStats(from, to time.Time) ([]NodeStat, error) {
+	rows, err := db.conn.Query(`
+		SELECT node_id, count(DISTINCT uas_id), sum(detections)::bigint AS total
+		FROM uas_hourly
+		WHERE bucket >= $1 AND bucket < $2
+		GROUP BY node_id
+		ORDER BY total DESC
+	`, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query node stats: %w", err)
+	}
+	defer rows.Close()
+
+	var out []NodeStat
+	for rows.Next() {
+		var s NodeStat
+		if err := rows.Scan(&s.NodeID, &s.UniqueUAS, &s.Detections); err != nil {
+			return nil, fmt.Errorf("failed to scan node stat: %w", err)
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
+
+// BusiestHours returns detections per hour of day (UTC) in [from, to), busiest first
+func (db *DB) BusiestHours(from, to time.Time) ([]HourStat, error) {
+	rows, err := db.conn.Query(`
+		SELECT extract(hour FROM bucket AT TIME ZONE 'UTC')::int AS h, sum(detections)::bigint AS total
+		FROM uas_hourly
+		WHERE bucket >= $1 AND bucket < $2
+		GROUP BY h
+		ORDER BY total DESC, h
+	`, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query busiest hours: %w", err)
+	}
+	defer rows.Close()
+
+	var out []HourStat
+	for rows.Next() {
+		var s HourStat
+		if err := rows.Scan(&s.Hour, &s.Detections); err != nil {
+			return nil, fmt.Errorf("failed to scan hour stat: %w", err)
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
+
+// TopDroneTypes returns the drone types seen by the most distinct UAS
+func (db *DB) TopDroneTypes(from, to time.Time, limit int) ([]DroneTypeStat, error) {
+	if limit <= 0 {
+		limit = 10
+	}
+
+	rows, err := db.conn.Query(`
+		SELECT drone_type, count(DISTINCT uas_id) AS uas, sum(detections)::bigint AS total
+		FROM uas_hourly
+		WHERE bucket >= $1 AND bucket < $2
+		GROUP BY drone_type
+		ORDER BY uas DESC, total DESC
+		LIMIT $3
+	`, from, to, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query drone types: %w", err)
+	}
+	defer rows.Close()
+
+	var out []DroneTypeStat
+	for rows.Next() {
+		var s DroneTypeStat
+		if err := rows.Scan(&s.DroneType, &s.UniqueUAS, &s.Detections); err != nil {
+			return nil, fmt.Errorf("failed to scan drone type stat: %w", err)
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
+
+// LiveSummary returns today's unique UAS (from the aggregate) plus drones
+// and nodes seen within onlineWindow (from the recent raw chunk).
+func (db *DB) LiveSummary(onlineWindow time.Duration) (*LiveSummary, error) {
+	now := time.Now().UTC()
+	s := &LiveSummary{AsOf: now}
+
+	err := db.conn.QueryRow(`
+		SELECT count(DISTINCT uas_id) FROM uas_hourly WHERE bucket >= $1
+	`, now.Truncate(24*time.Hour)).Scan(&s.TodayUniqueUAS)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query today's unique UAS: %w", err)
+	}
+
+	err = db.conn.QueryRow(`
+		SELECT count(DISTINCT uas_id), count(DISTINCT node_id) FILTER (WHERE node_id <> '')
+		FROM drone_detections
+		WHERE detection_time > $1
+	`, now.Add(-onlineWindow)).Scan(&s.OnlineDrones, &s.NodesActive)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query online drones: %w", err)
+	}
+
+	return s, nil
+}
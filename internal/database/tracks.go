@@ -0,0 +1,49 @@
+package database
+
+import (
+	"fmt"
+
+	"silentraven/internal/models"
+)
+
+// Tracks returns detections matching the filter grouped into per-UAS
+// tracks, each ordered oldest first. Limit caps the total number of points.
+func (db *DB) Tracks(f DetectionFilter) ([]models.Track, error) {
+	q := newQueryBuilder()
+	if err := q.applyFilter(f); err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf(`
+		SELECT uas_id, sn, detection_time, latitude, longitude, height
+		FROM drone_detections
+		%s
+		ORDER BY uas_id, detection_time ASC
+		LIMIT %s
+	`, q.whereClause(), q.arg(pageSize(f.Limit)))
+
+	rows, err := db.conn.Query(query, q.args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tracks: %w", err)
+	}
+	defer rows.Close()
+
+	var tracks []models.Track
+	for rows.Next() {
+		var uasID, sn string
+		var p models.TrackPoint
+		if err := rows.Scan(&uasID, &sn, &p.Time, &p.Latitude, &p.Longitude, &p.Height); err != nil {
+			return nil, fmt.Errorf("failed to scan track point: %w", err)
+		}
+
+		if n := len(tracks); n == 0 || tracks[n-1].UASID != uasID {
+			tracks = append(tracks, models.Track{UASID: uasID, SN: sn})
+		}
+		tracks[len(tracks)-1].Points = append(tracks[len(tracks)-1].Points, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read tracks: %w", err)
+	}
+
+	return tracks, nil
+}
@@ -0,0 +1,521 @@
+// Package evidence builds signed evidence bundles for enforcement cases:
+// a zip of a UAS's raw Remote ID packets over a time window with their
+// signature checks, the receiving nodes and the node keys the packets
+// were verified against at ingest, the track as KML, a summary and
+// chain-of-custody metadata, fixed by a SHA-256 manifest signed with the
+// service's evidence key.
+package evidence
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"silentraven/internal/crypto"
+	"silentraven/internal/database"
+	"silentraven/internal/export"
+	"silentraven/internal/models"
+)
+
+// Files in a bundle
+const (
+	FilePackets    = "packets.ndjson"
+	FileSignatures = "signatures.json"
+	FileNodes      = "nodes.json"
+	FileTrack      = "track.kml"
+	FileSummary    = "summary.json"
+	FileCustody    = "custody.json"
+	// FileKeys holds the node public keys named by the signature checks
+	FileKeys = "keys.json"
+	// FileManifest lists the SHA-256 of every other file in sha256sum
+	// format, so it can also be checked with sha256sum -c
+	FileManifest = "MANIFEST.sha256"
+	// FileSignature is the base64 ECDSA signature of the manifest
+	FileSignature = "MANIFEST.sig"
+	// FileSigner is the PEM public key the manifest was signed with
+	FileSigner = "signer.pem"
+)
+
+// ErrNoDetections is returned when the window holds no detections
+var ErrNoDetections = errors.New("no detections for UAS in time window")
+
+// Signature check results
+const (
+	SigVerified = "verified"
+	SigInvalid  = "invalid"
+	SigUnsigned = "unsigned"
+	// SigUnverified marks a signed packet that did not verify against its
+	// node's key at ingest
+	SigUnverified  = "unverified"
+	SigUnknownNode = "unknown_node"
+	SigNoNodeKey   = "no_node_key"
+	SigUnreadable  = "unreadable"
+)
+
+// Source supplies the detections, node records and node keys for a bundle
+type Source interface {
+	GetDetectionsForUAS(uasID string, from, to time.Time) ([]models.DroneDetection, error)
+	// GetNode returns database.ErrNotFound for unknown nodes
+	GetNode(nodeID string) (*models.SensorNode, error)
+	NodeKeys(nodeID string) ([]models.NodeKey, error)
+}
+
+// Request says what to collect and who is collecting it
+type Request struct {
+	UASID string
+	From  time.Time
+	To    time.Time
+	// CaseRef is the enforcement case the bundle is for, if any
+	CaseRef string
+	// Collector is who asked for the bundle, as recorded in the audit log
+	Collector     string
+	CollectorKind string
+	// Tool names the program that built the bundle
+	Tool string
+}
+
+// Packet is one line of packets.ndjson: a detection's packet as received
+type Packet struct {
+	DetectionID int64     `json:"detection_id"`
+	ReceivedAt  time.Time `json:"received_at"`
+	NodeID      string    `json:"node_id"`
+	// Packet is the stored RawData, verbatim
+	Packet json.RawMessage `json:"packet"`
+}
+
+// SignatureCheck is the result of verifying one packet against the key
+// its node had when the packet was ingested
+type SignatureCheck struct {
+	DetectionID int64  `json:"detection_id"`
+	NodeID      string `json:"node_id"`
+	// KeyFingerprint names the key in keys.json the packet verified against
+	KeyFingerprint string `json:"key_fingerprint,omitempty"`
+	Status         string `json:"status"`
+	Detail         string `json:"detail,omitempty"`
+}
+
+// Summary describes what a bundle holds
+type Summary struct {
+	BundleID   string    `json:"bundle_id"`
+	UASID      string    `json:"uas_id"`
+	From       time.Time `json:"from"`
+	To         time.Time `json:"to"`
+	FirstSeen  time.Time `json:"first_seen"`
+	LastSeen   time.Time `json:"last_seen"`
+	Detections int       `json:"detections"`
+	Serials    []string  `json:"serials"`
+	DroneTypes []string  `json:"drone_types"`
+	Nodes      []string  `json:"nodes"`
+	// Signatures counts packets by signature check result
+	Signatures        map[string]int `json:"signatures"`
+	OperatorPositions int            `json:"operator_positions"`
+	MaxHeight         float64        `json:"max_height"`
+	MaxSpeed          float64        `json:"max_speed_horizontal"`
+	Bounds            Bounds         `json:"bounds"`
+}
+
+// Bounds is the area a track covers
+type Bounds struct {
+	MinLat float64 `json:"min_lat"`
+	MinLon float64 `json:"min_lon"`
+	MaxLat float64 `json:"max_lat"`
+	MaxLon float64 `json:"max_lon"`
+}
+
+// Custody is the chain-of-custody record of collection: who collected
+// the bundle, from where, when and with what. A signed bundle cannot
+// change, so later transfers are recorded with the case.
+type Custody struct {
+	BundleID      string    `json:"bundle_id"`
+	CaseRef       string    `json:"case_ref,omitempty"`
+	CollectedAt   time.Time `json:"collected_at"`
+	Collector     string    `json:"collector"`
+	CollectorKind string    `json:"collector_kind"`
+	Tool          string    `json:"tool"`
+	Host          string    `json:"host"`
+	Source        string    `json:"source"`
+	Query         Query     `json:"query"`
+	// SignerFingerprint identifies the key the manifest is signed with
+	SignerFingerprint string `json:"signer_fingerprint"`
+}
+
+// Query is the selection a bundle was built from
+type Query struct {
+	UASID string    `json:"uas_id"`
+	From  time.Time `json:"from"`
+	To    time.Time `json:"to"`
+}
+
+// Bundle describes a written bundle
+type Bundle struct {
+	Summary *Summary
+	// SHA256 is the digest of the whole archive, for referring to it
+	SHA256 string
+	Bytes  int64
+}
+
+// Build collects req's detections from src and writes a signed bundle to w
+func Build(w io.Writer, src Source, req Request, key *ecdsa.PrivateKey) (*Bundle, error) {
+	detections, err := src.GetDetectionsForUAS(req.UASID, req.From, req.To)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query detections: %w", err)
+	}
+	if len(detections) == 0 {
+		return nil, ErrNoDetections
+	}
+
+	id, err := newBundleID()
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := receivingNodes(src, detections)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := ingestKeys(src, detections)
+	if err != nil {
+		return nil, err
+	}
+	checks := checkSignatures(detections, nodes, keys)
+
+	pub, err := crypto.PublicKeyPEM(key)
+	if err != nil {
+		return nil, err
+	}
+	fingerprint, err := crypto.Fingerprint(&key.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	host, _ := os.Hostname()
+	collected := time.Now().UTC()
+
+	files := make(map[string][]byte)
+	if files[FilePackets], err = packets(detections); err != nil {
+		return nil, err
+	}
+	var kml bytes.Buffer
+	if err := export.WriteKML(&kml, req.UASID, detections); err != nil {
+		return nil, fmt.Errorf("failed to write track: %w", err)
+	}
+	files[FileTrack] = kml.Bytes()
+
+	summary := summarize(id, req, detections, checks)
+	custody := Custody{
+		BundleID:          id,
+		CaseRef:           req.CaseRef,
+		CollectedAt:       collected,
+		Collector:         req.Collector,
+		CollectorKind:     req.CollectorKind,
+		Tool:              req.Tool,
+		Host:              host,
+		Source:            "drone_detections",
+		Query:             Query{UASID: req.UASID, From: req.From.UTC(), To: req.To.UTC()},
+		SignerFingerprint: fingerprint,
+	}
+	for name, v := range map[string]any{
+		FileSignatures: checks,
+		FileNodes:      nodes,
+		FileKeys:       keys,
+		FileSummary:    summary,
+		FileCustody:    custody,
+	} {
+		if files[name], err = json.MarshalIndent(v, "", "  "); err != nil {
+			return nil, fmt.Errorf("failed to encode %s: %w", name, err)
+		}
+	}
+
+	manifest := writeManifest(files)
+	sig, err := sign(key, manifest)
+	if err != nil {
+		return nil, err
+	}
+	files[FileManifest] = manifest
+	files[FileSignature] = []byte(sig + "\n")
+	files[FileSigner] = []byte(pub)
+
+	h := sha256.New()
+	cw := &countingWriter{w: io.MultiWriter(w, h)}
+	if err := writeZip(cw, files, collected); err != nil {
+		return nil, err
+	}
+	return &Bundle{Summary: summary, SHA256: hex.EncodeToString(h.Sum(nil)), Bytes: cw.n}, nil
+}
+
+// receivingNodes returns the registry records of the nodes that received
+// detections, ordered by node ID; unregistered nodes are left out
+func receivingNodes(src Source, detections []models.DroneDetection) ([]models.SensorNode, error) {
+	nodes := []models.SensorNode{}
+	seen := make(map[string]bool)
+	for _, d := range detections {
+		if d.NodeID == "" || seen[d.NodeID] {
+			continue
+		}
+		seen[d.NodeID] = true
+		n, err := src.GetNode(d.NodeID)
+		if errors.Is(err, database.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get node %s: %w", d.NodeID, err)
+		}
+		nodes = append(nodes, *n)
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].NodeID < nodes[j].NodeID })
+	return nodes, nil
+}
+
+// ingestKeys returns the node keys the detections verified against at
+// ingest, ordered by node and fingerprint. Keys stay in a node's history
+// after rotation and deletion, so older packets keep their key.
+func ingestKeys(src Source, detections []models.DroneDetection) ([]models.NodeKey, error) {
+	used := make(map[string]map[string]bool)
+	for _, d := range detections {
+		if d.KeyFingerprint == "" {
+			continue
+		}
+		if used[d.NodeID] == nil {
+			used[d.NodeID] = make(map[string]bool)
+		}
+		used[d.NodeID][d.KeyFingerprint] = true
+	}
+
+	keys := []models.NodeKey{}
+	for _, nodeID := range sortedKeys(keysOf(used)) {
+		history, err := src.NodeKeys(nodeID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get keys for node %s: %w", nodeID, err)
+		}
+		for _, k := range history {
+			if used[nodeID][k.Fingerprint] {
+				keys = append(keys, k)
+			}
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].NodeID != keys[j].NodeID {
+			return keys[i].NodeID < keys[j].NodeID
+		}
+		return keys[i].Fingerprint < keys[j].Fingerprint
+	})
+	return keys, nil
+}
+
+// checkSignatures verifies each stored packet against the node key its
+// signature verified against at ingest
+func checkSignatures(detections []models.DroneDetection, nodes []models.SensorNode, keys []models.NodeKey) []SignatureCheck {
+	registered := make(map[string]bool)
+	for _, n := range nodes {
+		registered[n.NodeID] = true
+	}
+	parsed, keyErrs := parseKeys(keys)
+
+	checks := make([]SignatureCheck, 0, len(detections))
+	for _, d := range detections {
+		c := SignatureCheck{DetectionID: d.ID, NodeID: d.NodeID, KeyFingerprint: d.KeyFingerprint}
+		id := keyID{d.NodeID, d.KeyFingerprint}
+		var p models.IncomingPacket
+		switch {
+		case json.Unmarshal([]byte(d.RawData), &p) != nil:
+			c.Status, c.Detail = SigUnreadable, "stored packet is not valid JSON"
+		case p.Signature == "":
+			c.Status = SigUnsigned
+		case d.KeyFingerprint == "" && !registered[d.NodeID]:
+			c.Status = SigUnknownNode
+		case d.KeyFingerprint == "":
+			c.Status, c.Detail = SigUnverified, "no registered node key verified the packet at ingest"
+		case keyErrs[id] != nil:
+			c.Status, c.Detail = SigNoNodeKey, keyErrs[id].Error()
+		case parsed[id] == nil:
+			c.Status, c.Detail = SigNoNodeKey, "ingest key is not in the node's key history"
+		default:
+			c.Status = SigVerified
+			if err := crypto.VerifyPacket(parsed[id], p); err != nil {
+				c.Status, c.Detail = SigInvalid, err.Error()
+			}
+		}
+		checks = append(checks, c)
+	}
+	return checks
+}
+
+// keyID names one key of one node
+type keyID struct {
+	nodeID      string
+	fingerprint string
+}
+
+// parseKeys parses node keys, checking each matches its fingerprint
+func parseKeys(keys []models.NodeKey) (map[keyID]*ecdsa.PublicKey, map[keyID]error) {
+	parsed := make(map[keyID]*ecdsa.PublicKey)
+	errs := make(map[keyID]error)
+	for _, k := range keys {
+		id := keyID{k.NodeID, k.Fingerprint}
+		pub, err := crypto.ParsePublicKey(k.PublicKey)
+		if err != nil {
+			errs[id] = err
+			continue
+		}
+		if fp, err := crypto.Fingerprint(pub); err != nil || fp != k.Fingerprint {
+			errs[id] = fmt.Errorf("key does not match fingerprint %s", k.Fingerprint)
+			continue
+		}
+		parsed[id] = pub
+	}
+	return parsed, errs
+}
+
+// packets renders the stored packets as NDJSON
+func packets(detections []models.DroneDetection) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, d := range detections {
+		raw := json.RawMessage(d.RawData)
+		if !json.Valid(raw) {
+			// Keep unreadable packets, as a JSON string
+			raw, _ = json.Marshal(d.RawData)
+		}
+		p := Packet{DetectionID: d.ID, ReceivedAt: d.CreatedAt.UTC(), NodeID: d.NodeID, Packet: raw}
+		if err := enc.Encode(p); err != nil {
+			return nil, fmt.Errorf("failed to encode packet %d: %w", d.ID, err)
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func summarize(id string, req Request, detections []models.DroneDetection, checks []SignatureCheck) *Summary {
+	first := detections[0]
+	s := &Summary{
+		BundleID:   id,
+		UASID:      req.UASID,
+		From:       req.From.UTC(),
+		To:         req.To.UTC(),
+		FirstSeen:  first.DetectionTime.UTC(),
+		LastSeen:   detections[len(detections)-1].DetectionTime.UTC(),
+		Detections: len(detections),
+		Signatures: make(map[string]int),
+		Bounds:     Bounds{MinLat: first.Latitude, MinLon: first.Longitude, MaxLat: first.Latitude, MaxLon: first.Longitude},
+	}
+	serials, types, nodes := make(map[string]bool), make(map[string]bool), make(map[string]bool)
+	operators := make(map[[2]float64]bool)
+	for _, d := range detections {
+		addNonEmpty(serials, d.SN)
+		addNonEmpty(types, d.DroneType)
+		addNonEmpty(nodes, d.NodeID)
+		if d.OperatorLatitude != 0 || d.OperatorLongitude != 0 {
+			operators[[2]float64{d.OperatorLatitude, d.OperatorLongitude}] = true
+		}
+		s.MaxHeight = max(s.MaxHeight, d.Height)
+		s.MaxSpeed = max(s.MaxSpeed, d.SpeedHorizontal)
+		s.Bounds.MinLat = min(s.Bounds.MinLat, d.Latitude)
+		s.Bounds.MinLon = min(s.Bounds.MinLon, d.Longitude)
+		s.Bounds.MaxLat = max(s.Bounds.MaxLat, d.Latitude)
+		s.Bounds.MaxLon = max(s.Bounds.MaxLon, d.Longitude)
+	}
+	for _, c := range checks {
+		s.Signatures[c.Status]++
+	}
+	s.Serials, s.DroneTypes, s.Nodes = sortedKeys(serials), sortedKeys(types), sortedKeys(nodes)
+	s.OperatorPositions = len(operators)
+	return s
+}
+
+func addNonEmpty(set map[string]bool, s string) {
+	if s != "" {
+		set[s] = true
+	}
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// writeManifest lists files' SHA-256 digests in sha256sum format, by name
+func writeManifest(files map[string][]byte) []byte {
+	var buf bytes.Buffer
+	for _, name := range sortedNames(files) {
+		sum := sha256.Sum256(files[name])
+		fmt.Fprintf(&buf, "%s  %s\n", hex.EncodeToString(sum[:]), name)
+	}
+	return buf.Bytes()
+}
+
+// writeZip writes files in name order with the manifest first, all
+// stamped with the collection time
+func writeZip(w io.Writer, files map[string][]byte, modified time.Time) error {
+	names := append([]string{FileManifest, FileSignature, FileSigner}, sortedNames(files)...)
+	zw := zip.NewWriter(w)
+	written := make(map[string]bool)
+	for _, name := range names {
+		if written[name] {
+			continue
+		}
+		written[name] = true
+		f, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modified})
+		if err != nil {
+			return fmt.Errorf("failed to write bundle: %w", err)
+		}
+		if _, err := f.Write(files[name]); err != nil {
+			return fmt.Errorf("failed to write bundle: %w", err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("failed to write bundle: %w", err)
+	}
+	return nil
+}
+
+func sortedNames(files map[string][]byte) []string {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// sign signs the SHA-256 of data, returning the base64 ASN.1 signature
+func sign(key *ecdsa.PrivateKey, data []byte) (string, error) {
+	digest := sha256.Sum256(data)
+	sig, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign manifest: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(sig), nil
+}
+
+// newBundleID returns a random bundle ID
+func newBundleID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate bundle ID: %w", err)
+	}
+	return "ev_" + hex.EncodeToString(b), nil
+}
@@ -0,0 +1,321 @@
+package evidence
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"silentraven/internal/crypto"
+	"silentraven/internal/models"
+	"silentraven/internal/storage"
+)
+
+var base = time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+// fixture is a store holding a node and detections ingested from it
+type fixture struct {
+	t     *testing.T
+	store *storage.MemoryStore
+	// evidenceKey signs bundles
+	evidenceKey *ecdsa.PrivateKey
+}
+
+func newFixture(t *testing.T) *fixture {
+	return &fixture{t: t, store: storage.NewMemoryStore(), evidenceKey: newKey(t)}
+}
+
+func newKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+// register (re)registers node-1 with key
+func (fx *fixture) register(key *ecdsa.PrivateKey) {
+	fx.t.Helper()
+	pub, err := crypto.PublicKeyPEM(key)
+	if err != nil {
+		fx.t.Fatal(err)
+	}
+	if err := fx.store.UpsertNode(&models.SensorNode{NodeID: "node-1", PublicKey: pub}); err != nil {
+		fx.t.Fatal(err)
+	}
+}
+
+// ingest stores a packet signed by key the way the edge agent, gateway
+// and ingestion do: signed without node or time, which the gateway fills
+// in, and recorded with the fingerprint of the key it verified against
+func (fx *fixture) ingest(key *ecdsa.PrivateKey, at time.Time, verified bool) {
+	fx.t.Helper()
+	p := models.IncomingPacket{SN: "sn-1", UASID: "uas-1", Latitude: 51.47, Longitude: -0.45, Height: 80}
+	if err := crypto.SignPacket(key, &p); err != nil {
+		fx.t.Fatal(err)
+	}
+	p.NodeID, p.Timestamp = "node-1", at.Format(time.RFC3339)
+	raw, err := json.Marshal(p)
+	if err != nil {
+		fx.t.Fatal(err)
+	}
+	d := &models.DroneDetection{
+		DetectionTime: at, SN: p.SN, UASID: p.UASID, Latitude: p.Latitude, Longitude: p.Longitude,
+		Height: p.Height, NodeID: p.NodeID, Signature: p.Signature, RawData: string(raw),
+	}
+	if verified {
+		d.KeyFingerprint, _ = crypto.Fingerprint(&key.PublicKey)
+	}
+	if err := fx.store.InsertDroneDetection(d); err != nil {
+		fx.t.Fatal(err)
+	}
+}
+
+// build returns a bundle of uas-1's detections around base
+func (fx *fixture) build() ([]byte, *Bundle) {
+	fx.t.Helper()
+	var buf bytes.Buffer
+	req := Request{UASID: "uas-1", From: base.Add(-time.Hour), To: base.Add(time.Hour), Collector: "tester", Tool: "test"}
+	bundle, err := Build(&buf, fx.store, req, fx.evidenceKey)
+	if err != nil {
+		fx.t.Fatalf("Build: %v", err)
+	}
+	return buf.Bytes(), bundle
+}
+
+func verify(t *testing.T, data []byte, trusted *ecdsa.PublicKey) *Report {
+	t.Helper()
+	report, err := Verify(bytes.NewReader(data), int64(len(data)), trusted)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	return report
+}
+
+// unzip returns a bundle's files by name
+func unzip(t *testing.T, data []byte) map[string][]byte {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := make(map[string][]byte)
+	for _, f := range zr.File {
+		if files[f.Name], err = readFile(f); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return files
+}
+
+func rezip(t *testing.T, files map[string][]byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := writeZip(&buf, files, base); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func checksOf(t *testing.T, files map[string][]byte) []SignatureCheck {
+	t.Helper()
+	var checks []SignatureCheck
+	if err := json.Unmarshal(files[FileSignatures], &checks); err != nil {
+		t.Fatal(err)
+	}
+	return checks
+}
+
+func hasProblem(r *Report, substr string) bool {
+	for _, p := range r.Problems {
+		if strings.Contains(p, substr) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestRoundTrip(t *testing.T) {
+	fx := newFixture(t)
+	nodeKey := newKey(t)
+	fx.register(nodeKey)
+	for i := range 3 {
+		fx.ingest(nodeKey, base.Add(time.Duration(i)*time.Second), true)
+	}
+
+	data, bundle := fx.build()
+	if bundle.Summary.Detections != 3 || bundle.Summary.Signatures[SigVerified] != 3 {
+		t.Errorf("summary = %+v, want 3 verified detections", bundle.Summary)
+	}
+
+	report := verify(t, data, &fx.evidenceKey.PublicKey)
+	if !report.OK() || !report.Trusted {
+		t.Fatalf("report = %+v, want OK and trusted", report)
+	}
+	if report.PacketsVerified != 3 {
+		t.Errorf("PacketsVerified = %d, want 3", report.PacketsVerified)
+	}
+	if report.Custody.Collector != "tester" || report.Custody.SignerFingerprint != report.SignerFingerprint {
+		t.Errorf("custody = %+v", report.Custody)
+	}
+	for _, name := range []string{FilePackets, FileSignatures, FileNodes, FileKeys, FileTrack, FileSummary, FileCustody} {
+		found := false
+		for _, f := range report.Files {
+			found = found || f == name
+		}
+		if !found {
+			t.Errorf("manifest does not cover %s", name)
+		}
+	}
+}
+
+// Packets ingested before a key rotation still verify against the key
+// they were ingested with
+func TestKeyRotation(t *testing.T) {
+	fx := newFixture(t)
+	oldKey, rotated := newKey(t), newKey(t)
+	fx.register(oldKey)
+	fx.ingest(oldKey, base, true)
+	fx.register(rotated)
+	fx.ingest(rotated, base.Add(time.Second), true)
+
+	data, bundle := fx.build()
+	if bundle.Summary.Signatures[SigVerified] != 2 {
+		t.Fatalf("signatures = %v, want 2 verified", bundle.Summary.Signatures)
+	}
+	var keys []models.NodeKey
+	if err := json.Unmarshal(unzip(t, data)[FileKeys], &keys); err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 {
+		t.Errorf("keys.json holds %d keys, want both", len(keys))
+	}
+	if report := verify(t, data, nil); !report.OK() || report.PacketsVerified != 2 {
+		t.Errorf("report = %+v", report)
+	}
+}
+
+func TestSignatureStatuses(t *testing.T) {
+	fx := newFixture(t)
+	nodeKey := newKey(t)
+	fx.register(nodeKey)
+	fx.ingest(nodeKey, base, true)
+	// Signed, but not by the node's registered key
+	fx.ingest(newKey(t), base.Add(time.Second), false)
+
+	data, _ := fx.build()
+	checks := checksOf(t, unzip(t, data))
+	if len(checks) != 2 || checks[0].Status != SigVerified || checks[1].Status != SigUnverified {
+		t.Errorf("checks = %+v, want verified then unverified", checks)
+	}
+	if report := verify(t, data, nil); !report.OK() || report.PacketsVerified != 1 {
+		t.Errorf("report = %+v", report)
+	}
+}
+
+func TestVerifyRejects(t *testing.T) {
+	fx := newFixture(t)
+	nodeKey := newKey(t)
+	fx.register(nodeKey)
+	fx.ingest(nodeKey, base, true)
+	data, _ := fx.build()
+
+	tests := []struct {
+		name    string
+		modify  func(files map[string][]byte)
+		trusted *ecdsa.PublicKey
+		want    string
+	}{
+		{
+			name:   "tampered file",
+			modify: func(files map[string][]byte) { files[FileTrack] = append(files[FileTrack], ' ') },
+			want:   FileTrack + " does not match its SHA-256",
+		},
+		{
+			name:   "file missing from bundle",
+			modify: func(files map[string][]byte) { delete(files, FileSummary) },
+			want:   FileSummary + " is missing",
+		},
+		{
+			name: "file missing from manifest",
+			modify: func(files map[string][]byte) {
+				var kept []string
+				for _, line := range strings.SplitAfter(string(files[FileManifest]), "\n") {
+					if !strings.HasSuffix(line, "  "+FileNodes+"\n") {
+						kept = append(kept, line)
+					}
+				}
+				files[FileManifest] = []byte(strings.Join(kept, ""))
+			},
+			want: FileNodes + " is not in the manifest",
+		},
+		{
+			name:   "extra file",
+			modify: func(files map[string][]byte) { files["notes.txt"] = []byte("added later") },
+			want:   "notes.txt is not in the manifest",
+		},
+		{
+			name:    "untrusted signer",
+			trusted: &newKey(t).PublicKey,
+			want:    "not the trusted key",
+		},
+		{
+			name:   "manifest signature",
+			modify: func(files map[string][]byte) { files[FileSignature] = []byte("AAAA\n") },
+			want:   "manifest signature does not verify",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := unzip(t, data)
+			if tt.modify != nil {
+				tt.modify(files)
+			}
+			report := verify(t, rezip(t, files), tt.trusted)
+			if report.OK() || !hasProblem(report, tt.want) {
+				t.Errorf("problems = %q, want one containing %q", report.Problems, tt.want)
+			}
+		})
+	}
+}
+
+// A bundle re-signed by its own key after its packets were altered still
+// fails: Verify checks the packets, not just the manifest
+func TestVerifyRechecksPackets(t *testing.T) {
+	fx := newFixture(t)
+	nodeKey := newKey(t)
+	fx.register(nodeKey)
+	fx.ingest(nodeKey, base, true)
+	data, _ := fx.build()
+
+	files := unzip(t, data)
+	files[FilePackets] = bytes.Replace(files[FilePackets], []byte(`"Height":80`), []byte(`"Height":20`), 1)
+	delete(files, FileManifest)
+	delete(files, FileSignature)
+	delete(files, FileSigner)
+	manifest := writeManifest(files)
+	sig, err := sign(fx.evidenceKey, manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, _ := crypto.PublicKeyPEM(fx.evidenceKey)
+	files[FileManifest], files[FileSignature], files[FileSigner] = manifest, []byte(sig+"\n"), []byte(pub)
+
+	report := verify(t, rezip(t, files), &fx.evidenceKey.PublicKey)
+	if report.OK() || !hasProblem(report, crypto.ErrPayloadMismatch.Error()) {
+		t.Errorf("problems = %q, want a payload mismatch", report.Problems)
+	}
+}
+
+func TestBuildWithoutDetections(t *testing.T) {
+	fx := newFixture(t)
+	var buf bytes.Buffer
+	_, err := Build(&buf, fx.store, Request{UASID: "uas-1", From: base, To: base.Add(time.Hour)}, fx.evidenceKey)
+	if err != ErrNoDetections {
+		t.Errorf("Build = %v, want ErrNoDetections", err)
+	}
+}
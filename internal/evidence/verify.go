@@ -0,0 +1,242 @@
+package evidence
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"silentraven/internal/crypto"
+	"silentraven/internal/models"
+)
+
+// Report is the outcome of verifying a bundle
+type Report struct {
+	Custody Custody
+	// Files lists the files the manifest covers
+	Files []string
+	// SignerFingerprint identifies the key that signed the manifest
+	SignerFingerprint string
+	// Trusted is set when the signer is the trusted key passed to Verify
+	Trusted bool
+	// PacketsVerified counts packet signatures re-checked against keys.json
+	PacketsVerified int
+	Problems        []string
+}
+
+// OK reports whether the bundle verified
+func (r *Report) OK() bool {
+	return len(r.Problems) == 0
+}
+
+func (r *Report) add(format string, args ...any) {
+	r.Problems = append(r.Problems, fmt.Sprintf(format, args...))
+}
+
+// Verify checks a bundle: every file is listed in the manifest with a
+// matching SHA-256, the manifest signature verifies against the bundled
+// signer key and, if trusted is not nil, the signer is that key. The
+// bundled key alone only shows the bundle is intact, not who made it.
+// Every packet the bundle reports as verified is checked again against
+// the bundled node key it names.
+func Verify(r io.ReaderAt, size int64, trusted *ecdsa.PublicKey) (*Report, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open bundle: %w", err)
+	}
+	files := make(map[string][]byte)
+	for _, f := range zr.File {
+		if _, dup := files[f.Name]; dup {
+			return nil, fmt.Errorf("bundle contains %s twice", f.Name)
+		}
+		data, err := readFile(f)
+		if err != nil {
+			return nil, err
+		}
+		files[f.Name] = data
+	}
+
+	report := &Report{}
+	manifest, ok := files[FileManifest]
+	if !ok {
+		report.add("missing %s", FileManifest)
+		return report, nil
+	}
+	listed, err := parseManifest(manifest)
+	if err != nil {
+		report.add("%v", err)
+		return report, nil
+	}
+
+	for _, name := range sortedNames(files) {
+		if name == FileManifest || name == FileSignature || name == FileSigner {
+			continue
+		}
+		if _, ok := listed[name]; !ok {
+			report.add("%s is not in the manifest", name)
+		}
+	}
+	for _, name := range sortedKeys(keysOf(listed)) {
+		report.Files = append(report.Files, name)
+		data, ok := files[name]
+		if !ok {
+			report.add("%s is missing", name)
+			continue
+		}
+		sum := sha256.Sum256(data)
+		if hex.EncodeToString(sum[:]) != listed[name] {
+			report.add("%s does not match its SHA-256 in the manifest", name)
+		}
+	}
+
+	checkSignature(report, files, manifest, trusted)
+
+	if data, ok := files[FileCustody]; ok {
+		if err := json.Unmarshal(data, &report.Custody); err != nil {
+			report.add("%s: %v", FileCustody, err)
+		}
+	}
+	if report.Custody.SignerFingerprint != "" && report.SignerFingerprint != "" &&
+		report.Custody.SignerFingerprint != report.SignerFingerprint {
+		report.add("%s names a different signer", FileCustody)
+	}
+	checkPackets(report, files)
+	return report, nil
+}
+
+// checkPackets re-verifies the packets signatures.json reports verified
+func checkPackets(report *Report, files map[string][]byte) {
+	var checks []SignatureCheck
+	var keys []models.NodeKey
+	for name, v := range map[string]any{FileSignatures: &checks, FileKeys: &keys} {
+		data, ok := files[name]
+		if !ok {
+			report.add("missing %s", name)
+			return
+		}
+		if err := json.Unmarshal(data, v); err != nil {
+			report.add("%s: %v", name, err)
+			return
+		}
+	}
+
+	packets := make(map[int64]Packet)
+	sc := bufio.NewScanner(bytes.NewReader(files[FilePackets]))
+	sc.Buffer(nil, 1<<20)
+	for line := 1; sc.Scan(); line++ {
+		var p Packet
+		if err := json.Unmarshal(sc.Bytes(), &p); err != nil {
+			report.add("%s line %d: %v", FilePackets, line, err)
+			return
+		}
+		packets[p.DetectionID] = p
+	}
+	if err := sc.Err(); err != nil {
+		report.add("%s: %v", FilePackets, err)
+		return
+	}
+
+	parsed, keyErrs := parseKeys(keys)
+	for _, c := range checks {
+		if c.Status != SigVerified {
+			continue
+		}
+		id := keyID{c.NodeID, c.KeyFingerprint}
+		p, ok := packets[c.DetectionID]
+		var packet models.IncomingPacket
+		switch {
+		case !ok:
+			report.add("detection %d is not in %s", c.DetectionID, FilePackets)
+		case p.NodeID != c.NodeID:
+			report.add("detection %d: %s names node %s", c.DetectionID, FilePackets, p.NodeID)
+		case keyErrs[id] != nil:
+			report.add("detection %d: node %s key %s: %v", c.DetectionID, c.NodeID, c.KeyFingerprint, keyErrs[id])
+		case parsed[id] == nil:
+			report.add("detection %d: node %s key %s is not in %s", c.DetectionID, c.NodeID, c.KeyFingerprint, FileKeys)
+		case json.Unmarshal(p.Packet, &packet) != nil:
+			report.add("detection %d: packet is not valid JSON", c.DetectionID)
+		default:
+			if err := crypto.VerifyPacket(parsed[id], packet); err != nil {
+				report.add("detection %d: %v", c.DetectionID, err)
+				continue
+			}
+			report.PacketsVerified++
+		}
+	}
+}
+
+// checkSignature verifies the manifest signature and the signer
+func checkSignature(report *Report, files map[string][]byte, manifest []byte, trusted *ecdsa.PublicKey) {
+	signerPEM, ok := files[FileSigner]
+	if !ok {
+		report.add("missing %s", FileSigner)
+		return
+	}
+	signer, err := crypto.ParsePublicKey(string(signerPEM))
+	if err != nil {
+		report.add("%s: %v", FileSigner, err)
+		return
+	}
+	if report.SignerFingerprint, err = crypto.Fingerprint(signer); err != nil {
+		report.add("%s: %v", FileSigner, err)
+		return
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(files[FileSignature])))
+	digest := sha256.Sum256(manifest)
+	switch {
+	case files[FileSignature] == nil:
+		report.add("missing %s", FileSignature)
+	case err != nil || !ecdsa.VerifyASN1(signer, digest[:], sig):
+		report.add("manifest signature does not verify")
+	}
+
+	if trusted != nil {
+		report.Trusted = trusted.Equal(signer)
+		if !report.Trusted {
+			report.add("signed by %s, not the trusted key", report.SignerFingerprint)
+		}
+	}
+}
+
+// parseManifest reads sha256sum lines into a map of name to digest
+func parseManifest(data []byte) (map[string]string, error) {
+	listed := make(map[string]string)
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; sc.Scan(); line++ {
+		sum, name, ok := strings.Cut(sc.Text(), "  ")
+		if !ok || len(sum) != sha256.Size*2 || name == "" {
+			return nil, fmt.Errorf("%s line %d is malformed", FileManifest, line)
+		}
+		listed[name] = sum
+	}
+	return listed, sc.Err()
+}
+
+func readFile(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", f.Name, err)
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", f.Name, err)
+	}
+	return data, nil
+}
+
+func keysOf[V any](m map[string]V) map[string]bool {
+	set := make(map[string]bool, len(m))
+	for k := range m {
+		set[k] = true
+	}
+	return set
+}
@@ -0,0 +1,110 @@
+// Package export renders drone detections as KML/KMZ and GeoJSON for
+// Google Earth and GIS tools.
+package export
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"silentraven/internal/models"
+)
+
+// Format is an export file format
+type Format string
+
+const (
+	FormatKML     Format = "kml"
+	FormatKMZ     Format = "kmz"
+	FormatGeoJSON Format = "geojson"
+)
+
+// ParseFormat validates a format name
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(s); f {
+	case FormatKML, FormatKMZ, FormatGeoJSON:
+		return f, nil
+	case "":
+		return FormatGeoJSON, nil
+	default:
+		return "", fmt.Errorf("unsupported export format %q (use kml, kmz or geojson)", s)
+	}
+}
+
+// ContentType returns the MIME type for the format
+func (f Format) ContentType() string {
+	switch f {
+	case FormatKML:
+		return "application/vnd.google-earth.kml+xml"
+	case FormatKMZ:
+		return "application/vnd.google-earth.kmz"
+	default:
+		return "application/geo+json"
+	}
+}
+
+// Extension returns the file extension for the format
+func (f Format) Extension() string {
+	if f == FormatGeoJSON {
+		return "geojson"
+	}
+	return string(f)
+}
+
+// Write renders detections (ordered by time) in the given format
+func Write(w io.Writer, f Format, uasID string, detections []models.DroneDetection) error {
+	switch f {
+	case FormatKML:
+		return WriteKML(w, uasID, detections)
+	case FormatKMZ:
+		return WriteKMZ(w, uasID, detections)
+	case FormatGeoJSON:
+		return WriteGeoJSON(w, uasID, detections)
+	default:
+		return fmt.Errorf("unsupported export format %q", f)
+	}
+}
+
+// OperatorPosition is a distinct reported operator location
+type OperatorPosition struct {
+	Latitude  float64
+	Longitude float64
+	FirstSeen time.Time
+	LastSeen  time.Time
+}
+
+// located returns the detections that carry a drone position, dropping
+// those without a Location message heard (reported as 0,0)
+func located(detections []models.DroneDetection) []models.DroneDetection {
+	points := make([]models.DroneDetection, 0, len(detections))
+	for _, d := range detections {
+		if d.Latitude != 0 || d.Longitude != 0 {
+			points = append(points, d)
+		}
+	}
+	return points
+}
+
+// operatorPositions collapses consecutive identical operator locations,
+// skipping detections without one.
+func operatorPositions(detections []models.DroneDetection) []OperatorPosition {
+	var positions []OperatorPosition
+	for _, d := range detections {
+		if d.OperatorLatitude == 0 && d.OperatorLongitude == 0 {
+			continue
+		}
+		if n := len(positions); n > 0 &&
+			positions[n-1].Latitude == d.OperatorLatitude &&
+			positions[n-1].Longitude == d.OperatorLongitude {
+			positions[n-1].LastSeen = d.DetectionTime
+			continue
+		}
+		positions = append(positions, OperatorPosition{
+			Latitude:  d.OperatorLatitude,
+			Longitude: d.OperatorLongitude,
+			FirstSeen: d.DetectionTime,
+			LastSeen:  d.DetectionTime,
+		})
+	}
+	return positions
+}
@@ -0,0 +1,118 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"silentraven/internal/models"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden output in testdata")
+
+// flight is a short track at 4 Hz: one detection before a Location
+// message was heard, an operator who moves once and an unknown direction
+func flight() []models.DroneDetection {
+	t0 := time.Date(2026, 5, 4, 10, 30, 0, 0, time.UTC)
+	at := func(ms int) time.Time { return t0.Add(time.Duration(ms) * time.Millisecond) }
+	return []models.DroneDetection{
+		{ID: 1, DetectionTime: at(0), SN: "1581F5FJD228400M", UASID: "uas-1", DroneType: "Multirotor",
+			Direction: models.DirectionUnknown, NodeID: "node-1"},
+		{ID: 2, DetectionTime: at(250), SN: "1581F5FJD228400M", UASID: "uas-1", DroneType: "Multirotor",
+			Latitude: 51.4700, Longitude: -0.4543, Height: 30, Direction: 90, SpeedHorizontal: 4.5,
+			OperatorLatitude: 51.4695, OperatorLongitude: -0.4550, NodeID: "node-1"},
+		{ID: 3, DetectionTime: at(500), SN: "1581F5FJD228400M", UASID: "uas-1", DroneType: "Multirotor",
+			Latitude: 51.4701, Longitude: -0.4541, Height: 31.5, Direction: models.DirectionUnknown, SpeedHorizontal: 4.5,
+			OperatorLatitude: 51.4695, OperatorLongitude: -0.4550, NodeID: "node-1"},
+		{ID: 4, DetectionTime: at(750), SN: "1581F5FJD228400M", UASID: "uas-1", DroneType: "Multirotor",
+			Latitude: 51.4702, Longitude: -0.4539, Height: 33, Direction: 92, SpeedHorizontal: 4.6, SpeedVertical: 1,
+			OperatorLatitude: 51.4696, OperatorLongitude: -0.4551, NodeID: "node-2"},
+	}
+}
+
+// checkGolden compares got with testdata/name, rewriting it with -update
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.MkdirAll("testdata", 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("output differs from %s (rerun with -update if intended):\n%s", path, got)
+	}
+}
+
+func TestKMLGolden(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteKML(&buf, "uas-1", flight()); err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "track.kml", buf.Bytes())
+
+	kml := buf.String()
+	if strings.Contains(kml, "0.000000 0.000000") {
+		t.Error("detection without a position drawn at 0,0")
+	}
+	if n := strings.Count(kml, "<when>"); n != 3 {
+		t.Errorf("track has %d times, want 3", n)
+	}
+	if !strings.Contains(kml, "<when>2026-05-04T10:30:00.25Z</when>") {
+		t.Error("track times lost their sub-second part")
+	}
+}
+
+func TestGeoJSONGolden(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteGeoJSON(&buf, "uas-1", flight()); err != nil {
+		t.Fatal(err)
+	}
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, buf.Bytes(), "", "  "); err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "track.geojson", indented.Bytes())
+
+	fc := BuildGeoJSON("uas-1", flight())
+	kinds := make(map[string]int)
+	for _, f := range fc.Features {
+		kinds[f.Properties["kind"].(string)]++
+		if f.Properties["kind"] != "detection" {
+			continue
+		}
+		_, hasDirection := f.Properties["direction"]
+		if id := f.Properties["id"].(int64); hasDirection == (id == 3) {
+			t.Errorf("detection %d: direction present = %v", id, hasDirection)
+		}
+	}
+	if kinds["track"] != 1 || kinds["detection"] != 3 || kinds["operator"] != 2 {
+		t.Errorf("features by kind = %v, want 1 track, 3 detections, 2 operator positions", kinds)
+	}
+}
+
+func TestEmptyTrack(t *testing.T) {
+	unlocated := flight()[:1]
+	var buf bytes.Buffer
+	if err := WriteKML(&buf, "uas-1", unlocated); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), "gx:Track") {
+		t.Error("KML has a track without any positions")
+	}
+	if fc := BuildGeoJSON("uas-1", unlocated); len(fc.Features) != 0 {
+		t.Errorf("GeoJSON features = %+v, want none", fc.Features)
+	}
+}
@@ -0,0 +1,101 @@
+package export
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+
+	"silentraven/internal/models"
+)
+
+// FeatureCollection is a GeoJSON FeatureCollection
+type FeatureCollection struct {
+	Type     string    `json:"type"`
+	Features []Feature `json:"features"`
+}
+
+// Feature is a GeoJSON Feature
+type Feature struct {
+	Type       string                 `json:"type"`
+	Geometry   Geometry               `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// Geometry is a GeoJSON geometry (Point or LineString)
+type Geometry struct {
+	Type        string      `json:"type"`
+	Coordinates interface{} `json:"coordinates"`
+}
+
+// BuildGeoJSON returns a FeatureCollection with the flight path as a
+// LineString, each detection as a Point and each operator position as a
+// Point. Detections without a drone position are left out. The third
+// coordinate is the Remote ID Height, above takeoff or ground.
+func BuildGeoJSON(uasID string, detections []models.DroneDetection) FeatureCollection {
+	fc := FeatureCollection{Type: "FeatureCollection", Features: []Feature{}}
+	points := located(detections)
+
+	if len(points) > 1 {
+		coords := make([][]float64, 0, len(points))
+		times := make([]string, 0, len(points))
+		for _, d := range points {
+			coords = append(coords, []float64{d.Longitude, d.Latitude, d.Height})
+			times = append(times, d.DetectionTime.UTC().Format(time.RFC3339Nano))
+		}
+		fc.Features = append(fc.Features, Feature{
+			Type:     "Feature",
+			Geometry: Geometry{Type: "LineString", Coordinates: coords},
+			Properties: map[string]interface{}{
+				"kind":   "track",
+				"uas_id": uasID,
+				"start":  times[0],
+				"end":    times[len(times)-1],
+				"times":  times,
+			},
+		})
+	}
+
+	for _, d := range points {
+		props := map[string]interface{}{
+			"kind":             "detection",
+			"id":               d.ID,
+			"uas_id":           d.UASID,
+			"sn":               d.SN,
+			"drone_type":       d.DroneType,
+			"time":             d.DetectionTime.UTC().Format(time.RFC3339Nano),
+			"height":           d.Height,
+			"speed_horizontal": d.SpeedHorizontal,
+			"speed_vertical":   d.SpeedVertical,
+			"node_id":          d.NodeID,
+		}
+		// An unknown direction is left out rather than given as 361
+		if d.Direction != models.DirectionUnknown {
+			props["direction"] = d.Direction
+		}
+		fc.Features = append(fc.Features, Feature{
+			Type:       "Feature",
+			Geometry:   Geometry{Type: "Point", Coordinates: []float64{d.Longitude, d.Latitude, d.Height}},
+			Properties: props,
+		})
+	}
+
+	for _, op := range operatorPositions(detections) {
+		fc.Features = append(fc.Features, Feature{
+			Type:     "Feature",
+			Geometry: Geometry{Type: "Point", Coordinates: []float64{op.Longitude, op.Latitude}},
+			Properties: map[string]interface{}{
+				"kind":       "operator",
+				"uas_id":     uasID,
+				"first_seen": op.FirstSeen.UTC().Format(time.RFC3339Nano),
+				"last_seen":  op.LastSeen.UTC().Format(time.RFC3339Nano),
+			},
+		})
+	}
+
+	return fc
+}
+
+// WriteGeoJSON writes the FeatureCollection for the detections
+func WriteGeoJSON(w io.Writer, uasID string, detections []models.DroneDetection) error {
+	return json.NewEncoder(w).Encode(BuildGeoJSON(uasID, detections))
+}
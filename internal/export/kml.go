@@ -0,0 +1,98 @@
+package export
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"silentraven/internal/models"
+)
+
+// WriteKML writes a KML document containing a time-stamped gx:Track for the
+// drone and a placemark for every distinct operator position. Detections
+// without a drone position are counted but left off the track.
+//
+// Track heights are the Remote ID Height, which the broadcast gives above
+// the takeoff point or above ground, so they are drawn relativeToGround:
+// exact for height above ground, approximate for height above takeoff.
+func WriteKML(w io.Writer, uasID string, detections []models.DroneDetection) error {
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprint(bw, xml.Header)
+	fmt.Fprint(bw, `<kml xmlns="http://www.opengis.net/kml/2.2" xmlns:gx="http://www.google.com/kml/ext/2.2">`+"\n")
+	fmt.Fprint(bw, "<Document>\n")
+	fmt.Fprintf(bw, "  <name>%s</name>\n", escape("UAS "+uasID))
+	fmt.Fprint(bw, kmlStyles)
+
+	if points := located(detections); len(points) > 0 {
+		first, last := detections[0], detections[len(detections)-1]
+
+		fmt.Fprint(bw, "  <Placemark>\n")
+		fmt.Fprintf(bw, "    <name>%s</name>\n", escape(uasID))
+		fmt.Fprintf(bw, "    <description>%s</description>\n", escape(fmt.Sprintf(
+			"Type: %s\nSerial: %s\nDetections: %d\nFrom: %s\nTo: %s",
+			last.DroneType, last.SN, len(detections),
+			first.DetectionTime.UTC().Format(time.RFC3339),
+			last.DetectionTime.UTC().Format(time.RFC3339))))
+		fmt.Fprint(bw, "    <styleUrl>#drone</styleUrl>\n")
+		fmt.Fprint(bw, "    <gx:Track>\n")
+		fmt.Fprint(bw, "      <altitudeMode>relativeToGround</altitudeMode>\n")
+		// Broadcasts repeat several times a second, so keep sub-second times
+		for _, d := range points {
+			fmt.Fprintf(bw, "      <when>%s</when>\n", d.DetectionTime.UTC().Format(time.RFC3339Nano))
+		}
+		for _, d := range points {
+			fmt.Fprintf(bw, "      <gx:coord>%f %f %.1f</gx:coord>\n", d.Longitude, d.Latitude, d.Height)
+		}
+		fmt.Fprint(bw, "    </gx:Track>\n")
+		fmt.Fprint(bw, "  </Placemark>\n")
+	}
+
+	for _, op := range operatorPositions(detections) {
+		fmt.Fprint(bw, "  <Placemark>\n")
+		fmt.Fprintf(bw, "    <name>%s</name>\n", escape("Operator "+uasID))
+		fmt.Fprintf(bw, "    <TimeSpan><begin>%s</begin><end>%s</end></TimeSpan>\n",
+			op.FirstSeen.UTC().Format(time.RFC3339Nano), op.LastSeen.UTC().Format(time.RFC3339Nano))
+		fmt.Fprint(bw, "    <styleUrl>#operator</styleUrl>\n")
+		fmt.Fprintf(bw, "    <Point><coordinates>%f,%f,0</coordinates></Point>\n", op.Longitude, op.Latitude)
+		fmt.Fprint(bw, "  </Placemark>\n")
+	}
+
+	fmt.Fprint(bw, "</Document>\n</kml>\n")
+	return bw.Flush()
+}
+
+// WriteKMZ writes the KML document zipped as doc.kml
+func WriteKMZ(w io.Writer, uasID string, detections []models.DroneDetection) error {
+	zw := zip.NewWriter(w)
+
+	f, err := zw.Create("doc.kml")
+	if err != nil {
+		return fmt.Errorf("create doc.kml: %w", err)
+	}
+	if err := WriteKML(f, uasID, detections); err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+const kmlStyles = `  <Style id="drone">
+    <IconStyle><color>ff0000ff</color><Icon><href>http://maps.google.com/mapfiles/kml/shapes/airports.png</href></Icon></IconStyle>
+    <LineStyle><color>ff0000ff</color><width>3</width></LineStyle>
+  </Style>
+  <Style id="operator">
+    <IconStyle><color>ff00ffff</color><Icon><href>http://maps.google.com/mapfiles/kml/shapes/man.png</href></Icon></IconStyle>
+  </Style>
+`
+
+// escape returns s with XML special characters escaped
+func escape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
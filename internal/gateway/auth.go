@@ -0,0 +1,38 @@
+package gateway
+
+import (
+	"errors"
+	"net/http"
+
+	"silentraven/internal/auth"
+	"silentraven/internal/models"
+)
+
+var (
+	// errWrongNode rejects a node key submitting data for another node
+	errWrongNode = errors.New("node_id does not match the node's API key")
+	// errNoIngest rejects a client key or token without the ingest
+	// permission, e.g. a dashboard's
+	errNoIngest = errors.New("only node keys and ingest clients may submit data")
+)
+
+// nodeFor returns the node ID a request may submit data for. A node key
+// pins it to the key's node: an empty claimed ID is filled in and a
+// different one is rejected. Client keys and tokens with the ingest
+// permission, and unauthenticated requests (AUTH_MODE=off), may claim
+// any node; other callers may submit nothing.
+func nodeFor(r *http.Request, claimed string) (string, error) {
+	p := auth.FromContext(r.Context())
+	switch {
+	case p == nil:
+		return claimed, nil
+	case p.Kind == models.KeyKindNode:
+		if claimed != "" && claimed != p.Subject {
+			return "", errWrongNode
+		}
+		return p.Subject, nil
+	case !p.Can(auth.Ingest):
+		return "", errNoIngest
+	}
+	return claimed, nil
+}
@@ -0,0 +1,150 @@
+package gateway
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"silentraven/internal/auth"
+	"silentraven/internal/compliance"
+	"silentraven/internal/logging"
+	"silentraven/internal/metrics"
+	"silentraven/internal/models"
+	"silentraven/internal/tracing"
+)
+
+// maxBatchSize caps the packets accepted in one batch upload
+const maxBatchSize = 1000
+
+// BatchRequest is a batch of detections uploaded by an edge agent
+type BatchRequest struct {
+	NodeID  string                  `json:"node_id"`
+	Packets []models.IncomingPacket `json:"packets"`
+}
+
+// BatchError reports a rejected packet by its index in the batch
+type BatchError struct {
+	Index int    `json:"index"`
+	Error string `json:"error"`
+}
+
+// BatchResult is returned for a batch upload. ServerTime lets agents
+// estimate their clock skew.
+type BatchResult struct {
+	Accepted   int          `json:"accepted"`
+	Spooled    bool         `json:"spooled"`
+	Rejected   int          `json:"rejected"`
+	Errors     []BatchError `json:"errors,omitempty"`
+	ServerTime string       `json:"server_time"`
+}
+
+// handleDetectionBatch queues a batch of detections. Invalid packets are
+// rejected individually; the rest are published (or spooled) together,
+// so a 2xx means every accepted packet is durable.
+func (g *Gateway) handleDetectionBatch(w http.ResponseWriter, r *http.Request) {
+	receivedAt := time.Now()
+	ctx, span := tracing.Tracer().Start(tracing.FromRequest(r), "gateway.detection_batch",
+		trace.WithSpanKind(trace.SpanKindServer))
+	defer span.End()
+
+	var req BatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.WarnContext(ctx, "Invalid batch JSON", logging.Err(err))
+		metrics.ValidationFailures.WithLabelValues("invalid_json").Inc()
+		tracing.Fail(span, err)
+		sendJSON(w, http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid JSON format",
+		})
+		return
+	}
+	if len(req.Packets) > maxBatchSize {
+		metrics.ValidationFailures.WithLabelValues("batch_too_large").Inc()
+		sendJSON(w, http.StatusRequestEntityTooLarge, models.APIResponse{
+			Success: false,
+			Error:   "Batch exceeds 1000 packets",
+		})
+		return
+	}
+	nodeID, err := nodeFor(r, req.NodeID)
+	if err != nil {
+		tracing.Fail(span, err)
+		auth.Forbid(w, r, err)
+		return
+	}
+	req.NodeID = nodeID
+	metrics.BatchSize.Observe(float64(len(req.Packets)))
+	span.SetAttributes(
+		attribute.String("node.id", req.NodeID),
+		attribute.Int("batch.size", len(req.Packets)),
+	)
+
+	result := BatchResult{}
+	msgs := make([]kafka.Message, 0, len(req.Packets))
+	for i, packet := range req.Packets {
+		if packet.NodeID == "" {
+			packet.NodeID = req.NodeID
+		} else if _, err := nodeFor(r, packet.NodeID); err != nil {
+			result.Errors = append(result.Errors, BatchError{Index: i, Error: err.Error()})
+			continue
+		}
+		if packet.SN == "" || packet.UASID == "" {
+			metrics.ValidationFailures.WithLabelValues("missing_fields").Inc()
+			result.Errors = append(result.Errors, BatchError{Index: i, Error: "Missing required fields: SN or UASID"})
+			continue
+		}
+		if err := compliance.Validate(&packet); err != nil {
+			metrics.ValidationFailures.WithLabelValues("invalid_fields").Inc()
+			result.Errors = append(result.Errors, BatchError{Index: i, Error: err.Error()})
+			continue
+		}
+		if packet.Timestamp == "" {
+			packet.Timestamp = receivedAt.Format(time.RFC3339)
+		}
+
+		packetJSON, err := json.Marshal(packet)
+		if err != nil {
+			result.Errors = append(result.Errors, BatchError{Index: i, Error: "Internal processing error"})
+			continue
+		}
+		msgs = append(msgs, kafka.Message{
+			Key:   []byte(packet.UASID),
+			Value: packetJSON,
+			Time:  receivedAt,
+		})
+	}
+	result.Rejected = len(result.Errors)
+	span.SetAttributes(attribute.Int("batch.rejected", result.Rejected))
+
+	if len(msgs) > 0 {
+		spooled, err := g.publish(ctx, msgs...)
+		if err != nil {
+			logger.ErrorContext(ctx, "Failed to queue batch", logging.Node(req.NodeID), "packets", len(msgs), logging.Err(err))
+			tracing.Fail(span, err)
+			sendJSON(w, http.StatusServiceUnavailable, models.APIResponse{
+				Success: false,
+				Error:   "Failed to queue batch",
+			})
+			return
+		}
+		result.Accepted, result.Spooled = len(msgs), spooled
+	}
+
+	detectionLog.InfoContext(ctx, "Received batch",
+		logging.Node(req.NodeID), "accepted", result.Accepted, "rejected", result.Rejected, "spooled", result.Spooled)
+
+	status := http.StatusOK
+	if result.Spooled {
+		status = http.StatusAccepted
+	}
+	result.ServerTime = time.Now().UTC().Format(time.RFC3339Nano)
+	sendJSON(w, status, models.APIResponse{
+		Success: true,
+		Message: "Batch received",
+		Data:    result,
+	})
+}
@@ -0,0 +1,453 @@
+// Package gateway receives detections and heartbeats from sensor nodes
+// over HTTP and publishes them to Redpanda, spooling detections to disk
+// while the broker is unavailable.
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"silentraven/internal/auth"
+	"silentraven/internal/compliance"
+	"silentraven/internal/logging"
+	"silentraven/internal/metrics"
+	"silentraven/internal/models"
+	"silentraven/internal/queue"
+	"silentraven/internal/spool"
+	"silentraven/internal/tracing"
+	"silentraven/pkg/config"
+)
+
+var (
+	logger = logging.For("gateway")
+	// detectionLog samples the per-detection lines
+	detectionLog = logging.Sampled(logger)
+)
+
+// errMissingFields rejects a detection without an SN or UAS ID
+var errMissingFields = errors.New("missing required fields: SN or UASID")
+
+// publishTimeout bounds a direct write to Redpanda before the detection
+// is spooled instead
+const publishTimeout = 5 * time.Second
+
+// Gateway is the node-facing HTTP service
+type Gateway struct {
+	config          *config.Config
+	writer          queue.Writer
+	heartbeatWriter queue.Writer
+	spool           *spool.Spool
+	auth            *auth.Authenticator
+	router          *mux.Router
+}
+
+// New creates a gateway publishing detections to writer and heartbeats
+// to heartbeatWriter. Requests under /api/v1 must authenticate with authn
+// unless it is nil.
+func New(cfg *config.Config, writer, heartbeatWriter queue.Writer, authn *auth.Authenticator) (*Gateway, error) {
+	g := &Gateway{
+		config:          cfg,
+		writer:          writer,
+		heartbeatWriter: heartbeatWriter,
+		auth:            authn,
+		router:          mux.NewRouter(),
+	}
+
+	// Spool detections to disk while Redpanda is unavailable
+	if cfg.SpoolMaxMB > 0 {
+		sp, err := spool.Open(cfg.SpoolDir, spool.Options{MaxBytes: int64(cfg.SpoolMaxMB) << 20})
+		if err != nil {
+			return nil, err
+		}
+		g.spool = sp
+		metrics.RegisterSpool(sp)
+		logger.Info("Spooling detections to disk", "dir", cfg.SpoolDir, "max_mb", cfg.SpoolMaxMB)
+	}
+
+	g.setupRoutes()
+	return g, nil
+}
+
+// Handler returns the gateway's HTTP routes
+func (g *Gateway) Handler() http.Handler {
+	return g.router
+}
+
+// Drain forwards spooled detections once Redpanda is reachable, until
+// ctx is cancelled
+func (g *Gateway) Drain(ctx context.Context) {
+	if g.spool != nil {
+		g.spool.Drain(ctx, g.forwardSpooled, 100, 30*time.Second)
+	}
+}
+
+// Close closes all connections
+func (g *Gateway) Close() {
+	if g.writer != nil {
+		g.writer.Close()
+	}
+	if g.heartbeatWriter != nil {
+		g.heartbeatWriter.Close()
+	}
+	if g.spool != nil {
+		g.spool.Close()
+	}
+}
+
+// setupRoutes configures HTTP routes
+func (g *Gateway) setupRoutes() {
+	g.router.Use(logging.Middleware, metrics.Middleware)
+
+	// Health check
+	g.router.HandleFunc("/health", g.handleHealth).Methods("GET")
+
+	// Everything else needs a node key, or a client key or token with
+	// the ingest permission for detections and heartbeats
+	api := g.router.PathPrefix("/api/v1").Subrouter()
+	api.Use(g.auth.Middleware())
+
+	// Receive drone detection
+	api.HandleFunc("/detection", g.handleDetection).Methods("POST")
+	api.HandleFunc("/detections/batch", g.handleDetectionBatch).Methods("POST")
+
+	// Sensor node heartbeats
+	api.HandleFunc("/nodes/heartbeat", g.handleHeartbeat).Methods("POST")
+	api.HandleFunc("/nodes/{node_id}/heartbeat", g.handleHeartbeat).Methods("POST")
+
+	// Store-and-forward spool status
+	api.HandleFunc("/spool", g.handleSpool).Methods("GET")
+
+	// Test endpoint
+	api.HandleFunc("/test", g.handleTest).Methods("GET")
+}
+
+// handleHealth returns service health status
+func (g *Gateway) handleHealth(w http.ResponseWriter, r *http.Request) {
+	data := map[string]string{
+		"service": "gateway",
+		"status":  "running",
+		"version": "1.0.0",
+	}
+	if g.spool != nil {
+		data["spool_depth"] = strconv.FormatInt(g.spool.Depth(), 10)
+	}
+
+	response := models.APIResponse{
+		Success: true,
+		Message: "Gateway service is healthy",
+		Data:    data,
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// handleSpool returns store-and-forward spool statistics
+func (g *Gateway) handleSpool(w http.ResponseWriter, r *http.Request) {
+	if g.spool == nil {
+		sendJSON(w, http.StatusOK, models.APIResponse{
+			Success: true,
+			Message: "Spool disabled",
+		})
+		return
+	}
+
+	sendJSON(w, http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    g.spool.Stats(),
+	})
+}
+
+// handleTest returns test response
+func (g *Gateway) handleTest(w http.ResponseWriter, r *http.Request) {
+	response := models.APIResponse{
+		Success: true,
+		Message: "Test endpoint working",
+		Data: map[string]string{
+			"timestamp": time.Now().Format(time.RFC3339),
+		},
+	}
+	sendJSON(w, http.StatusOK, response)
+}
+
+// handleDetection processes incoming drone detection
+func (g *Gateway) handleDetection(w http.ResponseWriter, r *http.Request) {
+	ctx, span := tracing.Tracer().Start(tracing.FromRequest(r), "gateway.detection",
+		trace.WithSpanKind(trace.SpanKindServer))
+	defer span.End()
+
+	// Parse incoming packet
+	var packet models.IncomingPacket
+	if err := json.NewDecoder(r.Body).Decode(&packet); err != nil {
+		logger.WarnContext(ctx, "Invalid JSON", logging.Err(err))
+		metrics.ValidationFailures.WithLabelValues("invalid_json").Inc()
+		tracing.Fail(span, err)
+		response := models.APIResponse{
+			Success: false,
+			Error:   "Invalid JSON format",
+		}
+		sendJSON(w, http.StatusBadRequest, response)
+		return
+	}
+
+	// Node keys may only submit for their own node
+	nodeID, err := nodeFor(r, packet.NodeID)
+	if err != nil {
+		tracing.Fail(span, err)
+		auth.Forbid(w, r, err)
+		return
+	}
+	packet.NodeID = nodeID
+
+	// Validate required fields
+	if packet.SN == "" || packet.UASID == "" {
+		logger.WarnContext(ctx, "Missing required fields",
+			"sn", packet.SN, logging.UAS(packet.UASID), logging.Node(packet.NodeID))
+		metrics.ValidationFailures.WithLabelValues("missing_fields").Inc()
+		tracing.Fail(span, errMissingFields)
+		response := models.APIResponse{
+			Success: false,
+			Error:   "Missing required fields: SN or UASID",
+		}
+		sendJSON(w, http.StatusBadRequest, response)
+		return
+	}
+	if err := compliance.Validate(&packet); err != nil {
+		logger.WarnContext(ctx, "Position off the globe",
+			"sn", packet.SN, logging.UAS(packet.UASID), logging.Node(packet.NodeID), logging.Err(err))
+		metrics.ValidationFailures.WithLabelValues("invalid_fields").Inc()
+		tracing.Fail(span, err)
+		response := models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		}
+		sendJSON(w, http.StatusBadRequest, response)
+		return
+	}
+
+	// Add timestamp if not present
+	if packet.Timestamp == "" {
+		packet.Timestamp = time.Now().Format(time.RFC3339)
+	}
+
+	span.SetAttributes(
+		attribute.String("uas.id", packet.UASID),
+		attribute.String("uas.sn", packet.SN),
+		attribute.String("node.id", packet.NodeID),
+	)
+
+	// Log received detection
+	detectionLog.InfoContext(ctx, "Received detection",
+		logging.UAS(packet.UASID), "sn", packet.SN, "drone_type", packet.DroneType, logging.Node(packet.NodeID))
+
+	// Convert to JSON for Kafka
+	packetJSON, err := json.Marshal(packet)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to marshal packet", logging.UAS(packet.UASID), logging.Err(err))
+		tracing.Fail(span, err)
+		response := models.APIResponse{
+			Success: false,
+			Error:   "Internal processing error",
+		}
+		sendJSON(w, http.StatusInternalServerError, response)
+		return
+	}
+
+	// Publish to Redpanda, spooling to disk if it is unavailable
+	spooled, err := g.publish(ctx, kafka.Message{
+		Key:   []byte(packet.UASID),
+		Value: packetJSON,
+		Time:  time.Now(),
+	})
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to queue detection", logging.UAS(packet.UASID), logging.Err(err))
+		tracing.Fail(span, err)
+		response := models.APIResponse{
+			Success: false,
+			Error:   "Failed to queue message",
+		}
+		sendJSON(w, http.StatusServiceUnavailable, response)
+		return
+	}
+
+	status, message := http.StatusOK, "Detection received and queued"
+	if spooled {
+		status, message = http.StatusAccepted, "Detection received and spooled"
+	} else {
+		detectionLog.InfoContext(ctx, "Published detection", logging.UAS(packet.UASID))
+	}
+
+	// Send success response
+	response := models.APIResponse{
+		Success: true,
+		Message: message,
+		Data: map[string]string{
+			"uas_id":    packet.UASID,
+			"sn":        packet.SN,
+			"timestamp": packet.Timestamp,
+		},
+	}
+	sendJSON(w, status, response)
+}
+
+// publish writes msgs to Redpanda. With a spool configured, the messages
+// are spooled instead when the write fails or a backlog is still draining
+// (so detections stay in order); spooled reports which path was taken.
+// Spooled records don't keep message headers, so their traces end here.
+func (g *Gateway) publish(ctx context.Context, msgs ...kafka.Message) (spooled bool, err error) {
+	ctx, span := tracing.StartPublish(ctx, g.config.KafkaTopic, msgs)
+	defer func() {
+		span.SetAttributes(attribute.Bool("spooled", spooled))
+		if err != nil {
+			tracing.Fail(span, err)
+		}
+		span.End()
+	}()
+
+	if g.spool == nil {
+		return false, g.write(ctx, msgs...)
+	}
+
+	if g.spool.Depth() == 0 {
+		writeCtx, cancel := context.WithTimeout(ctx, publishTimeout)
+		err := g.write(writeCtx, msgs...)
+		cancel()
+		if err == nil {
+			return false, nil
+		}
+		logger.WarnContext(ctx, "Redpanda unavailable, spooling", logging.Err(err))
+		span.RecordError(err)
+	}
+
+	// All or none, so a client retrying after a failure cannot duplicate
+	// the part of its batch that was already spooled
+	records := make([]spool.Record, len(msgs))
+	for i, msg := range msgs {
+		records[i] = spool.Record{Key: msg.Key, Value: msg.Value, Time: msg.Time}
+	}
+	if err := g.spool.AppendBatch(records); err != nil {
+		return false, err
+	}
+	span.AddEvent("spooled", trace.WithAttributes(attribute.Int64("spool.depth", g.spool.Depth())))
+	return true, nil
+}
+
+// forwardSpooled publishes a batch of spooled detections to Redpanda,
+// starting a new trace for them
+func (g *Gateway) forwardSpooled(ctx context.Context, records []spool.Record) error {
+	msgs := make([]kafka.Message, len(records))
+	for i, rec := range records {
+		msgs[i] = kafka.Message{Key: rec.Key, Value: rec.Value, Time: rec.Time}
+	}
+
+	ctx, span := tracing.StartPublish(ctx, g.config.KafkaTopic, msgs)
+	defer span.End()
+	span.SetAttributes(attribute.Bool("spool.drain", true))
+
+	err := g.write(ctx, msgs...)
+	if err != nil {
+		tracing.Fail(span, err)
+	}
+	return err
+}
+
+// write publishes msgs to the detection topic, recording latency and
+// errors
+func (g *Gateway) write(ctx context.Context, msgs ...kafka.Message) error {
+	start := time.Now()
+	err := g.writer.WriteMessages(ctx, msgs...)
+	metrics.ObservePublish(g.config.KafkaTopic, len(msgs), start, err)
+	return err
+}
+
+// handleHeartbeat queues a sensor node heartbeat. The node ID comes from
+// the path, the body or a node_id query parameter (the legacy form); an
+// empty body is a bare liveness ping.
+func (g *Gateway) handleHeartbeat(w http.ResponseWriter, r *http.Request) {
+	var hb models.NodeHeartbeat
+	if err := json.NewDecoder(r.Body).Decode(&hb); err != nil && err != io.EOF {
+		logger.WarnContext(r.Context(), "Invalid heartbeat JSON", logging.Err(err))
+		metrics.ValidationFailures.WithLabelValues("invalid_json").Inc()
+		sendJSON(w, http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid JSON format",
+		})
+		return
+	}
+
+	if nodeID := mux.Vars(r)["node_id"]; nodeID != "" {
+		hb.NodeID = nodeID
+	}
+	if hb.NodeID == "" {
+		hb.NodeID = r.URL.Query().Get("node_id")
+	}
+	nodeID, err := nodeFor(r, hb.NodeID)
+	if err != nil {
+		auth.Forbid(w, r, err)
+		return
+	}
+	hb.NodeID = nodeID
+	if hb.NodeID == "" {
+		metrics.ValidationFailures.WithLabelValues("missing_node_id").Inc()
+		sendJSON(w, http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Missing required field: node_id",
+		})
+		return
+	}
+
+	receivedAt := time.Now()
+	if hb.Timestamp == "" {
+		hb.Timestamp = receivedAt.Format(time.RFC3339)
+	}
+
+	hbJSON, err := json.Marshal(hb)
+	if err != nil {
+		logger.ErrorContext(r.Context(), "Failed to marshal heartbeat", logging.Node(hb.NodeID), logging.Err(err))
+		sendJSON(w, http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Internal processing error",
+		})
+		return
+	}
+
+	start := time.Now()
+	err = g.heartbeatWriter.WriteMessages(r.Context(), kafka.Message{
+		Key:   []byte(hb.NodeID),
+		Value: hbJSON,
+		Time:  receivedAt,
+	})
+	metrics.ObservePublish(g.config.KafkaHeartbeatTopic, 1, start, err)
+	if err != nil {
+		logger.ErrorContext(r.Context(), "Failed to publish heartbeat", logging.Node(hb.NodeID), logging.Err(err))
+		sendJSON(w, http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to queue heartbeat",
+		})
+		return
+	}
+
+	sendJSON(w, http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Heartbeat received",
+		Data: map[string]string{
+			"node_id":   hb.NodeID,
+			"last_seen": hb.Timestamp,
+		},
+	})
+}
+
+// sendJSON sends JSON response
+func sendJSON(w http.ResponseWriter, statusCode int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(data)
+}
@@ -0,0 +1,151 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+
+	"silentraven/internal/auth"
+	"silentraven/internal/models"
+	"silentraven/internal/queue"
+	"silentraven/internal/spool"
+	"silentraven/pkg/config"
+)
+
+// brokenWriter stands in for an unreachable Redpanda
+type brokenWriter struct{}
+
+func (brokenWriter) WriteMessages(context.Context, ...kafka.Message) error {
+	return errors.New("broker unavailable")
+}
+func (brokenWriter) Close() error { return nil }
+
+func TestBatchIsNotPartlySpooled(t *testing.T) {
+	g, err := New(&config.Config{}, brokenWriter{}, brokenWriter{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Room for about one packet, not three
+	g.spool, err = spool.Open(t.TempDir(), spool.Options{MaxBytes: 400})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.spool.Close()
+
+	body := `{"node_id":"node-1","packets":[
+		{"SN":"sn-1","UASID":"uas-1","Latitude":51.47,"Longitude":-0.45},
+		{"SN":"sn-1","UASID":"uas-1","Latitude":51.48,"Longitude":-0.45},
+		{"SN":"sn-1","UASID":"uas-1","Latitude":51.49,"Longitude":-0.45}]}`
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/detections/batch", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	g.router.ServeHTTP(w, r)
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want 503: %s", w.Code, w.Body)
+	}
+	if d := g.spool.Depth(); d != 0 {
+		t.Errorf("spool holds %d records of a refused batch, want 0", d)
+	}
+}
+
+func TestIngestNeedsNodeKeyOrIngestRole(t *testing.T) {
+	node := &auth.Principal{Kind: models.KeyKindNode, Subject: "node-1"}
+	tests := []struct {
+		name      string
+		principal *auth.Principal
+		claimed   string
+		status    int
+	}{
+		{"auth off", nil, "node-2", http.StatusOK},
+		{"own node", node, "node-1", http.StatusOK},
+		{"node fills in", node, "", http.StatusOK},
+		{"other node", node, "node-2", http.StatusForbidden},
+		{"ingest client", &auth.Principal{Kind: models.KeyKindClient, Role: auth.RoleIngest}, "node-2", http.StatusOK},
+		{"public client", &auth.Principal{Kind: models.KeyKindClient, Role: auth.RolePublic}, "node-2", http.StatusForbidden},
+		{"partner client", &auth.Principal{Kind: models.KeyKindClient, Role: auth.RolePartner, Jurisdiction: "north"}, "node-2", http.StatusForbidden},
+		{"admin user", &auth.Principal{Kind: auth.KindUser, Role: auth.RoleAdmin}, "", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, err := New(&config.Config{KafkaTopic: "drone-detections"},
+				queue.NewMemoryTopic("drone-detections", 8), queue.NewMemoryTopic("node-heartbeats", 8), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			requests := map[string]string{
+				"/api/v1/detection":        `{"SN":"sn-1","UASID":"uas-1","node_id":"` + tt.claimed + `"}`,
+				"/api/v1/detections/batch": `{"node_id":"` + tt.claimed + `","packets":[{"SN":"sn-1","UASID":"uas-1"}]}`,
+				"/api/v1/nodes/heartbeat":  `{"node_id":"` + tt.claimed + `"}`,
+			}
+			for path, body := range requests {
+				r := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+				r = r.WithContext(auth.WithPrincipal(r.Context(), tt.principal))
+				w := httptest.NewRecorder()
+				g.router.ServeHTTP(w, r)
+				if w.Code != tt.status {
+					t.Errorf("%s: status = %d, want %d: %s", path, w.Code, tt.status, w.Body)
+				}
+			}
+		})
+	}
+}
+
+func TestMetricsNotOnIngestPort(t *testing.T) {
+	g, err := New(&config.Config{}, brokenWriter{}, brokenWriter{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	g.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("/metrics status = %d, want 404", w.Code)
+	}
+}
+
+func TestOutOfRangeValuesAreQueued(t *testing.T) {
+	topic := queue.NewMemoryTopic("drone-detections", 8)
+	g, err := New(&config.Config{KafkaTopic: "drone-detections"}, topic, queue.NewMemoryTopic("node-heartbeats", 8), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		body   string
+		status int
+	}{
+		{`{"SN":"sn-1","UASID":"uas-1","Direction":400,"SpeedVertical":80,"Timestamp":"yesterday"}`, http.StatusOK},
+		{`{"SN":"sn-1","UASID":"uas-1","Direction":361}`, http.StatusOK},
+		{`{"SN":"sn-1","UASID":"uas-1","Latitude":91}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		g.router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/detection", strings.NewReader(tt.body)))
+		if w.Code != tt.status {
+			t.Errorf("%s: status = %d, want %d: %s", tt.body, w.Code, tt.status, w.Body)
+		}
+	}
+}
+
+func TestAuthorizeBeforeValidating(t *testing.T) {
+	g, err := New(&config.Config{}, brokenWriter{}, brokenWriter{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	public := &auth.Principal{Kind: models.KeyKindClient, Role: auth.RolePublic}
+	for path, body := range map[string]string{
+		"/api/v1/detection":        `{"Latitude":91}`,
+		"/api/v1/detections/batch": `{"packets":[{"Latitude":91}]}`,
+	} {
+		r := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		r = r.WithContext(auth.WithPrincipal(r.Context(), public))
+		w := httptest.NewRecorder()
+		g.router.ServeHTTP(w, r)
+		if w.Code != http.StatusForbidden {
+			t.Errorf("%s: status = %d, want 403: %s", path, w.Code, w.Body)
+		}
+	}
+}
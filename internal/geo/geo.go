@@ -0,0 +1,37 @@
+// Package geo holds the spherical-earth helpers shared by the services
+package geo
+
+import "math"
+
+// EarthRadiusMeters is the mean earth radius
+const EarthRadiusMeters = 6371008.8
+
+const rad = math.Pi / 180
+
+// Distance is the haversine great-circle distance in meters
+func Distance(lat1, lon1, lat2, lon2 float64) float64 {
+	dLat := (lat2 - lat1) * rad
+	dLon := (lon2 - lon1) * rad
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * EarthRadiusMeters * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+// Bearing is the initial great-circle bearing in degrees [0, 360)
+func Bearing(lat1, lon1, lat2, lon2 float64) float64 {
+	phi1, phi2 := lat1*rad, lat2*rad
+	dLon := (lon2 - lon1) * rad
+	y := math.Sin(dLon) * math.Cos(phi2)
+	x := math.Cos(phi1)*math.Sin(phi2) - math.Sin(phi1)*math.Cos(phi2)*math.Cos(dLon)
+	return math.Mod(math.Atan2(y, x)/rad+360, 360)
+}
+
+// Destination returns the point meters away along a great circle
+func Destination(lat, lon, bearingDeg, meters float64) (float64, float64) {
+	phi1, lam1, theta := lat*rad, lon*rad, bearingDeg*rad
+	delta := meters / EarthRadiusMeters
+
+	phi2 := math.Asin(math.Sin(phi1)*math.Cos(delta) + math.Cos(phi1)*math.Sin(delta)*math.Cos(theta))
+	lam2 := lam1 + math.Atan2(math.Sin(theta)*math.Sin(delta)*math.Cos(phi1), math.Cos(delta)-math.Sin(phi1)*math.Sin(phi2))
+	return phi2 / rad, math.Mod(lam2/rad+540, 360) - 180
+}
@@ -0,0 +1,52 @@
+package geo
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDistance(t *testing.T) {
+	tests := []struct {
+		name                   string
+		lat1, lon1, lat2, lon2 float64
+		want                   float64
+	}{
+		{"same point", 51.47, -0.45, 51.47, -0.45, 0},
+		{"one degree of latitude", 0, 0, 1, 0, 111195},
+		{"across the antimeridian", 0, 179.5, 0, -179.5, 111195},
+		{"Heathrow to JFK", 51.4700, -0.4543, 40.6413, -73.7781, 5540000},
+	}
+	for _, tt := range tests {
+		got := Distance(tt.lat1, tt.lon1, tt.lat2, tt.lon2)
+		if math.Abs(got-tt.want) > tt.want*0.001+1 {
+			t.Errorf("%s: Distance = %.0f, want %.0f", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestBearing(t *testing.T) {
+	tests := []struct {
+		lat2, lon2, want float64
+	}{
+		{1, 0, 0}, {0, 1, 90}, {-1, 0, 180}, {0, -1, 270},
+	}
+	for _, tt := range tests {
+		if got := Bearing(0, 0, tt.lat2, tt.lon2); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("Bearing to (%v, %v) = %v, want %v", tt.lat2, tt.lon2, got, tt.want)
+		}
+	}
+}
+
+func TestDestinationRoundTrip(t *testing.T) {
+	lat, lon := 51.47, -0.45
+	for _, bearing := range []float64{0, 45, 135, 270} {
+		lat2, lon2 := Destination(lat, lon, bearing, 5000)
+		if d := Distance(lat, lon, lat2, lon2); math.Abs(d-5000) > 0.01 {
+			t.Errorf("bearing %v: destination is %.3f m away, want 5000", bearing, d)
+		}
+		b := Bearing(lat, lon, lat2, lon2)
+		if diff := math.Abs(math.Remainder(b-bearing, 360)); diff > 1e-6 {
+			t.Errorf("bearing %v: destination is at bearing %v", bearing, b)
+		}
+	}
+}
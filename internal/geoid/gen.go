@@ -0,0 +1,83 @@
+//go:build ignore
+
+// gen converts the NGA WW15MGH.GRD EGM96 geoid file into the GTX grid
+// embedded by the geoid package, optionally downsampling it.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"silentraven/internal/geoid"
+)
+
+func main() {
+	in := flag.String("in", "WW15MGH.GRD", "NGA EGM96 grid file")
+	out := flag.String("out", "data/egm96-1deg.gtx", "output GTX file")
+	step := flag.Int("step", 4, "keep every n-th grid point (4 = 1 degree)")
+	flag.Parse()
+
+	f, err := os.Open(*in)
+	if err != nil {
+		log.Fatal("Open grid failed:", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Split(bufio.ScanWords)
+
+	var values []float64
+	for scanner.Scan() {
+		v, err := strconv.ParseFloat(strings.TrimSpace(scanner.Text()), 64)
+		if err != nil {
+			log.Fatal("Parse grid value failed:", err)
+		}
+		values = append(values, v)
+	}
+	if err := scanner.Err(); err != nil {
+		log.Fatal("Read grid failed:", err)
+	}
+	if len(values) < 6 {
+		log.Fatal("Grid file is too short")
+	}
+
+	// Header: south north west east dlat dlon
+	south, north, west, east, dlat, dlon := values[0], values[1], values[2], values[3], values[4], values[5]
+	body := values[6:]
+	rows := int((north-south)/dlat) + 1
+	cols := int((east-west)/dlon) + 1
+	if len(body) != rows*cols {
+		log.Fatalf("Expected %d values, got %d", rows*cols, len(body))
+	}
+
+	// The NGA file runs north to south; GTX runs south to north
+	outRows := (rows-1) / *step + 1
+	outCols := (cols-1) / *step + 1
+	heights := make([]float32, 0, outRows*outCols)
+	for r := 0; r < outRows; r++ {
+		srcRow := rows - 1 - r*(*step)
+		for c := 0; c < outCols; c++ {
+			heights = append(heights, float32(body[srcRow*cols+c*(*step)]))
+		}
+	}
+
+	model, err := geoid.NewModel(south, west, dlat*float64(*step), dlon*float64(*step), outRows, outCols, heights)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	w, err := os.Create(*out)
+	if err != nil {
+		log.Fatal("Create output failed:", err)
+	}
+	defer w.Close()
+
+	if err := model.WriteGTX(w); err != nil {
+		log.Fatal("Write GTX failed:", err)
+	}
+	log.Printf("Wrote %dx%d grid to %s", outRows, outCols, *out)
+}
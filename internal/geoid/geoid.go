@@ -0,0 +1,196 @@
+// Package geoid converts between mean sea level (MSL) and WGS-84 height
+// above ellipsoid (HAE) using an EGM96 geoid undulation grid.
+package geoid
+
+//go:generate go run gen.go -in WW15MGH.GRD -out data/egm96-1deg.gtx -step 4
+
+import (
+	"embed"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path"
+	"strings"
+	"sync"
+)
+
+//go:embed data
+var gridFS embed.FS
+
+// Model holds a regular lat/lon grid of geoid undulations (meters)
+type Model struct {
+	lat0, lon0 float64 // south-west corner (degrees)
+	dlat, dlon float64 // grid spacing (degrees)
+	rows, cols int
+	heights    []float32 // row-major, south to north, west to east
+}
+
+var (
+	defaultOnce  sync.Once
+	defaultModel *Model
+)
+
+// Default returns the embedded EGM96 model. When no grid is embedded the
+// returned model reports zero undulation everywhere.
+func Default() *Model {
+	defaultOnce.Do(func() {
+		defaultModel = loadEmbedded()
+	})
+	return defaultModel
+}
+
+func loadEmbedded() *Model {
+	entries, err := gridFS.ReadDir("data")
+	if err != nil {
+		return &Model{}
+	}
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".gtx") {
+			continue
+		}
+		f, err := gridFS.Open(path.Join("data", e.Name()))
+		if err != nil {
+			continue
+		}
+		m, err := ReadGTX(f)
+		f.Close()
+		if err == nil {
+			return m
+		}
+	}
+	return &Model{}
+}
+
+// Load reads a GTX grid file, such as PROJ's egm96_15.gtx
+func Load(name string) (*Model, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open geoid grid: %w", err)
+	}
+	defer f.Close()
+	return ReadGTX(f)
+}
+
+// ReadGTX parses a grid in the PROJ GTX format
+func ReadGTX(r io.Reader) (*Model, error) {
+	var header struct {
+		Lat0, Lon0 float64
+		DLat, DLon float64
+		Rows, Cols int32
+	}
+	if err := binary.Read(r, binary.BigEndian, &header); err != nil {
+		return nil, fmt.Errorf("read GTX header: %w", err)
+	}
+	if header.Rows <= 1 || header.Cols <= 1 || header.DLat <= 0 || header.DLon <= 0 {
+		return nil, fmt.Errorf("invalid GTX header: %+v", header)
+	}
+
+	heights := make([]float32, int(header.Rows)*int(header.Cols))
+	if err := binary.Read(r, binary.BigEndian, heights); err != nil {
+		return nil, fmt.Errorf("read GTX body: %w", err)
+	}
+
+	return &Model{
+		lat0:    header.Lat0,
+		lon0:    header.Lon0,
+		dlat:    header.DLat,
+		dlon:    header.DLon,
+		rows:    int(header.Rows),
+		cols:    int(header.Cols),
+		heights: heights,
+	}, nil
+}
+
+// WriteGTX writes the model in the PROJ GTX format
+func (m *Model) WriteGTX(w io.Writer) error {
+	header := struct {
+		Lat0, Lon0 float64
+		DLat, DLon float64
+		Rows, Cols int32
+	}{m.lat0, m.lon0, m.dlat, m.dlon, int32(m.rows), int32(m.cols)}
+
+	if err := binary.Write(w, binary.BigEndian, header); err != nil {
+		return err
+	}
+	return binary.Write(w, binary.BigEndian, m.heights)
+}
+
+// NewModel builds a model from a south-to-north, west-to-east grid
+func NewModel(lat0, lon0, dlat, dlon float64, rows, cols int, heights []float32) (*Model, error) {
+	if rows*cols != len(heights) {
+		return nil, fmt.Errorf("grid size %dx%d does not match %d heights", rows, cols, len(heights))
+	}
+	return &Model{
+		lat0: lat0, lon0: lon0,
+		dlat: dlat, dlon: dlon,
+		rows: rows, cols: cols,
+		heights: heights,
+	}, nil
+}
+
+// Available reports whether the model carries real grid data
+func (m *Model) Available() bool {
+	return m != nil && len(m.heights) > 0
+}
+
+// Undulation returns the geoid height N (meters) above the WGS-84
+// ellipsoid at the given position, using bilinear interpolation.
+func (m *Model) Undulation(lat, lon float64) float64 {
+	if !m.Available() {
+		return 0
+	}
+
+	lat = math.Max(-90, math.Min(90, lat))
+	lon = math.Mod(lon-m.lon0, 360)
+	if lon < 0 {
+		lon += 360
+	}
+
+	y := (lat - m.lat0) / m.dlat
+	x := lon / m.dlon
+
+	r0 := clamp(int(math.Floor(y)), 0, m.rows-2)
+	c0 := int(math.Floor(x))
+	fy := y - float64(r0)
+	fx := x - float64(c0)
+
+	h00 := m.at(r0, c0)
+	h01 := m.at(r0, c0+1)
+	h10 := m.at(r0+1, c0)
+	h11 := m.at(r0+1, c0+1)
+
+	south := h00 + (h01-h00)*fx
+	north := h10 + (h11-h10)*fx
+	return south + (north-south)*fy
+}
+
+// MSLToHAE converts an orthometric (MSL) height to height above ellipsoid
+func (m *Model) MSLToHAE(lat, lon, msl float64) float64 {
+	return msl + m.Undulation(lat, lon)
+}
+
+// HAEToMSL converts a height above ellipsoid to an orthometric (MSL) height
+func (m *Model) HAEToMSL(lat, lon, hae float64) float64 {
+	return hae - m.Undulation(lat, lon)
+}
+
+// at returns the grid value, wrapping columns around the globe
+func (m *Model) at(row, col int) float64 {
+	col %= m.cols
+	if col < 0 {
+		col += m.cols
+	}
+	return float64(m.heights[row*m.cols+col])
+}
+
+func clamp(v, lo, hi int) int {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
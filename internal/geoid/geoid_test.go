@@ -0,0 +1,164 @@
+package geoid
+
+import (
+	"bytes"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testModel is a 3x4 global grid at 90° spacing whose heights rise by 1 m
+// per column and 10 m per row
+func testModel(t *testing.T) *Model {
+	t.Helper()
+	m, err := NewModel(-90, 0, 90, 90, 3, 4, []float32{
+		0, 1, 2, 3,
+		10, 11, 12, 13,
+		20, 21, 22, 23,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestUndulationInterpolates(t *testing.T) {
+	m := testModel(t)
+	tests := []struct {
+		name     string
+		lat, lon float64
+		want     float64
+	}{
+		{"grid point", 0, 90, 11},
+		{"between columns", 0, 45, 10.5},
+		{"between rows", 45, 180, 17},
+		{"cell centre", -45, 135, 6.5},
+		{"negative longitude", 0, -90, 13},
+		{"wraps east of the last column", 0, 315, 11.5},
+		{"clamps past the pole", 100, 0, 20},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := m.Undulation(tt.lat, tt.lon); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("Undulation(%v, %v) = %v, want %v", tt.lat, tt.lon, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHeightConversion(t *testing.T) {
+	m := testModel(t)
+	if got := m.MSLToHAE(0, 90, 100); got != 111 {
+		t.Errorf("MSLToHAE = %v, want 111", got)
+	}
+	if got := m.HAEToMSL(0, 90, 111); got != 100 {
+		t.Errorf("HAEToMSL = %v, want 100", got)
+	}
+}
+
+func TestEmptyModel(t *testing.T) {
+	for _, m := range []*Model{nil, {}} {
+		if m.Available() {
+			t.Errorf("%v reports a grid", m)
+		}
+		if got := m.Undulation(51.5, -0.1); got != 0 {
+			t.Errorf("Undulation without a grid = %v, want 0", got)
+		}
+	}
+}
+
+func TestGTXRoundTrip(t *testing.T) {
+	m := testModel(t)
+	var buf bytes.Buffer
+	if err := m.WriteGTX(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() != 40+4*12 {
+		t.Errorf("GTX is %d bytes, want %d", buf.Len(), 40+4*12)
+	}
+	got, err := ReadGTX(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Undulation(-45, 135) != m.Undulation(-45, 135) {
+		t.Error("grid changed in a GTX round trip")
+	}
+}
+
+func TestReadGTXRejectsBadInput(t *testing.T) {
+	var buf bytes.Buffer
+	bad := &Model{rows: 1, cols: 4, dlat: 1, dlon: 1, heights: make([]float32, 4)}
+	if err := bad.WriteGTX(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadGTX(&buf); err == nil {
+		t.Error("ReadGTX accepted a one-row grid")
+	}
+
+	buf.Reset()
+	testModel(t).WriteGTX(&buf)
+	if _, err := ReadGTX(bytes.NewReader(buf.Bytes()[:buf.Len()-4])); err == nil {
+		t.Error("ReadGTX accepted a truncated grid")
+	}
+}
+
+func TestNewModelChecksSize(t *testing.T) {
+	if _, err := NewModel(-90, 0, 90, 90, 3, 4, make([]float32, 11)); err == nil {
+		t.Error("NewModel accepted too few heights")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "test.gtx")
+	f, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := testModel(t).WriteGTX(f); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	m, err := Load(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := m.Undulation(-45, 135); got != 6.5 {
+		t.Errorf("Undulation from the loaded grid = %v, want 6.5", got)
+	}
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.gtx")); err == nil {
+		t.Error("Load of a missing file succeeded")
+	}
+}
+
+// TestEGM96ReferencePoints checks the embedded grid, or the one named by
+// GEOID_GRID, against NGA's EGM96 test values. Bilinear interpolation on
+// the 1° grid is within a couple of meters of the full 15' model.
+func TestEGM96ReferencePoints(t *testing.T) {
+	m := Default()
+	if name := os.Getenv("GEOID_GRID"); name != "" {
+		var err error
+		if m, err = Load(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !m.Available() {
+		t.Skip("no EGM96 grid embedded or set with GEOID_GRID; see data/README.md")
+	}
+	tests := []struct {
+		lat, lon, want float64
+	}{
+		{38.6281550, 269.7791550, -31.628},
+		{-14.6212170, 305.0211140, -2.969},
+		{46.8743190, 102.4487290, -43.575},
+		{-23.6174460, 133.8747120, 15.871},
+		{38.6254730, 359.9995000, 50.066},
+		{-0.4667440, 0.0023000, 17.329},
+	}
+	for _, tt := range tests {
+		if got := m.Undulation(tt.lat, tt.lon); math.Abs(got-tt.want) > 2 {
+			t.Errorf("Undulation(%v, %v) = %.3f, want %.3f ± 2", tt.lat, tt.lon, got, tt.want)
+		}
+	}
+}
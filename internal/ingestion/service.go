@@ -0,0 +1,220 @@
+// Package ingestion consumes detections and node heartbeats from
+// Redpanda and persists them.
+package ingestion
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel/attribute"
+
+	"silentraven/internal/logging"
+	"silentraven/internal/metrics"
+	"silentraven/internal/models"
+	"silentraven/internal/nodes"
+	"silentraven/internal/queue"
+	"silentraven/internal/storage"
+	"silentraven/internal/tracing"
+)
+
+var (
+	logger = logging.For("ingestion")
+	// detectionLog samples the per-detection lines
+	detectionLog = logging.Sampled(logger)
+)
+
+// activeTracks counts UAS with a stored detection in the last five minutes
+var activeTracks = metrics.NewActiveTracks(5 * time.Minute)
+
+// Service is the ingestion consumer
+type Service struct {
+	store           storage.Store
+	reader          queue.Reader
+	heartbeatReader queue.Reader
+	monitor         *nodes.Monitor
+	keys            *nodeKeys
+}
+
+// NewService creates an ingestion service consuming detections from
+// reader and heartbeats from heartbeatReader
+func NewService(store storage.Store, reader, heartbeatReader queue.Reader, monitor *nodes.Monitor) *Service {
+	return &Service{
+		store:           store,
+		reader:          reader,
+		heartbeatReader: heartbeatReader,
+		monitor:         monitor,
+		keys:            newNodeKeys(store),
+	}
+}
+
+// Close closes all connections
+func (s *Service) Close() {
+	if s.reader != nil {
+		s.reader.Close()
+	}
+	if s.heartbeatReader != nil {
+		s.heartbeatReader.Close()
+	}
+}
+
+// ProcessHeartbeats records node heartbeats until ctx is cancelled
+func (s *Service) ProcessHeartbeats(ctx context.Context) {
+	for {
+		m, err := s.heartbeatReader.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			logger.Error("Failed to fetch heartbeat", logging.Err(err))
+			time.Sleep(time.Second)
+			continue
+		}
+		metrics.ObserveLag(m.Topic, m.Partition, m.Offset, m.HighWaterMark)
+
+		var hb models.NodeHeartbeat
+		if err := json.Unmarshal(m.Value, &hb); err != nil {
+			logger.Warn("Invalid heartbeat", "offset", m.Offset, logging.Err(err))
+			metrics.MessagesConsumed.WithLabelValues(m.Topic, "error").Inc()
+		} else if err := s.monitor.Heartbeat(hb); err != nil {
+			logger.Error("Failed to record heartbeat", logging.Node(hb.NodeID), logging.Err(err))
+			metrics.MessagesConsumed.WithLabelValues(m.Topic, "error").Inc()
+		} else {
+			metrics.MessagesConsumed.WithLabelValues(m.Topic, "ok").Inc()
+		}
+
+		if err := s.heartbeatReader.CommitMessages(ctx, m); err != nil {
+			logger.Warn("Failed to commit heartbeat", logging.Err(err))
+		}
+	}
+}
+
+// ProcessMessages reads and processes messages from Redpanda
+func (s *Service) ProcessMessages(ctx context.Context) error {
+	messageCount := 0
+
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Info("Stopped consuming detections", "processed", messageCount)
+			return nil
+		default:
+			// Read message with timeout
+			m, err := s.reader.FetchMessage(ctx)
+			if err != nil {
+				if err == context.Canceled {
+					return nil
+				}
+				logger.Error("Failed to fetch message", logging.Err(err))
+				time.Sleep(time.Second)
+				continue
+			}
+			metrics.ObserveLag(m.Topic, m.Partition, m.Offset, m.HighWaterMark)
+
+			// Process the message
+			if err := s.processMessage(ctx, m); err != nil {
+				metrics.MessagesConsumed.WithLabelValues(m.Topic, "error").Inc()
+			} else {
+				messageCount++
+				metrics.MessagesConsumed.WithLabelValues(m.Topic, "ok").Inc()
+			}
+
+			// Commit the message
+			if err := s.reader.CommitMessages(ctx, m); err != nil {
+				logger.Warn("Failed to commit message", "offset", m.Offset, logging.Err(err))
+			}
+		}
+	}
+}
+
+// processMessage processes a single message, continuing the trace
+// started by the gateway
+func (s *Service) processMessage(ctx context.Context, m kafka.Message) (err error) {
+	ctx, span := tracing.StartConsume(ctx, "ingestion.process", m)
+	defer func() {
+		if err != nil {
+			logger.ErrorContext(ctx, "Failed to process message", "offset", m.Offset, logging.Err(err))
+			tracing.Fail(span, err)
+		}
+		span.End()
+	}()
+
+	// Parse incoming packet
+	var packet models.IncomingPacket
+	if err := json.Unmarshal(m.Value, &packet); err != nil {
+		return err
+	}
+	span.SetAttributes(attribute.String("uas.id", packet.UASID), attribute.String("uas.sn", packet.SN))
+
+	detectionLog.DebugContext(ctx, "Processing detection",
+		logging.UAS(packet.UASID), "sn", packet.SN, "drone_type", packet.DroneType, logging.Node(packet.NodeID))
+
+	// Convert to database model
+	detection := &models.DroneDetection{
+		DetectionTime:     time.Now(),
+		SN:                packet.SN,
+		UASID:             packet.UASID,
+		DroneType:         packet.DroneType,
+		Latitude:          packet.Latitude,
+		Longitude:         packet.Longitude,
+		Height:            packet.Height,
+		Direction:         packet.Direction,
+		SpeedHorizontal:   packet.SpeedHorizontal,
+		SpeedVertical:     packet.SpeedVertical,
+		OperatorLatitude:  packet.OperatorLatitude,
+		OperatorLongitude: packet.OperatorLongitude,
+		NodeID:            packet.NodeID,
+		Signature:         packet.Signature,
+	}
+
+	// Parse timestamp if provided
+	if packet.Timestamp != "" {
+		if ts, err := time.Parse(time.RFC3339, packet.Timestamp); err == nil {
+			detection.DetectionTime = ts
+		}
+	}
+
+	// Record which node key the signature verified against, so evidence
+	// built later checks it against that key and not a rotated one.
+	// Detections are stored either way.
+	result, fingerprint, err := s.keys.verify(packet)
+	if err != nil {
+		logger.WarnContext(ctx, "Failed to look up node key", logging.Node(packet.NodeID), logging.Err(err))
+		result = sigNoNodeKey
+	}
+	metrics.SignatureChecks.WithLabelValues(result).Inc()
+	detection.KeyFingerprint = fingerprint
+	span.SetAttributes(attribute.String("signature", result))
+
+	// Store raw JSON data, with the signed payload
+	rawJSON, _ := json.Marshal(packet)
+	detection.RawData = string(rawJSON)
+
+	// Insert into database
+	if err := s.insert(ctx, detection); err != nil {
+		return err
+	}
+	activeTracks.Seen(detection.UASID)
+
+	detectionLog.InfoContext(ctx, "Stored detection",
+		"detection_id", detection.ID, logging.UAS(detection.UASID), logging.Node(detection.NodeID))
+	return nil
+}
+
+// insert stores detection, recording its latency and a database span
+func (s *Service) insert(ctx context.Context, detection *models.DroneDetection) error {
+	_, span := tracing.Tracer().Start(ctx, "db.insert")
+	defer span.End()
+
+	start := time.Now()
+	err := s.store.InsertDroneDetection(detection)
+	metrics.DBInsertDuration.Observe(time.Since(start).Seconds())
+	if err != nil {
+		metrics.DBInsertErrors.Inc()
+		tracing.Fail(span, err)
+		return err
+	}
+	span.SetAttributes(attribute.Int64("detection.id", detection.ID))
+	return nil
+}
@@ -0,0 +1,166 @@
+package ingestion
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"silentraven/internal/crypto"
+	"silentraven/internal/database"
+	"silentraven/internal/gateway"
+	"silentraven/internal/models"
+	"silentraven/internal/queue"
+	"silentraven/internal/storage"
+	"silentraven/internal/tracing"
+	"silentraven/pkg/config"
+)
+
+// TestTraceFollowsDetection sends a detection through the gateway, the
+// message headers and ingestion, and checks every span joins the trace
+// the caller started
+func TestTraceFollowsDetection(t *testing.T) {
+	ctx := context.Background()
+	exporter := tracetest.NewInMemoryExporter()
+	prev := otel.GetTracerProvider()
+	tp := tracing.Setup("test", exporter, 1)
+	defer func() {
+		tp.Shutdown(ctx)
+		otel.SetTracerProvider(prev)
+	}()
+
+	const (
+		traceID      = "4bf92f3577b34da6a3ce929d0e0e4736"
+		callerSpanID = "00f067aa0ba902b7"
+	)
+	topic := queue.NewMemoryTopic("drone-detections", 1)
+	g, err := gateway.New(&config.Config{KafkaTopic: "drone-detections"}, topic, queue.NewMemoryTopic("node-heartbeats", 1), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := `{"SN":"sn-1","UASID":"uas-1","Latitude":51.47,"Longitude":-0.45,"node_id":"node-1"}`
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/detection", strings.NewReader(body))
+	r.Header.Set("traceparent", "00-"+traceID+"-"+callerSpanID+"-01")
+	w := httptest.NewRecorder()
+	g.Handler().ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+
+	m, err := topic.FetchMessage(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := storage.NewMemoryStore()
+	s := NewService(store, topic, nil, nil)
+	if err := s.processMessage(ctx, m); err != nil {
+		t.Fatal(err)
+	}
+	if err := tp.ForceFlush(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := exporter.GetSpans()
+	byName := map[string]tracetest.SpanStub{}
+	for _, span := range spans {
+		byName[span.Name] = span
+		if got := span.SpanContext.TraceID().String(); got != traceID {
+			t.Errorf("span %q is in trace %s, want %s", span.Name, got, traceID)
+		}
+	}
+	for _, name := range []string{"gateway.detection", "publish drone-detections", "ingestion.process", "db.insert"} {
+		if _, ok := byName[name]; !ok {
+			t.Errorf("no %q span among %d spans", name, len(spans))
+		}
+	}
+
+	// Each hop is the child of the one before
+	parents := map[string]string{
+		"publish drone-detections": "gateway.detection",
+		"ingestion.process":        "publish drone-detections",
+		"db.insert":                "ingestion.process",
+	}
+	for child, parent := range parents {
+		if got, want := byName[child].Parent.SpanID(), byName[parent].SpanContext.SpanID(); got != want {
+			t.Errorf("%q has parent %s, want %q (%s)", child, got, parent, want)
+		}
+	}
+	if got := byName["gateway.detection"].Parent.SpanID().String(); got != callerSpanID {
+		t.Errorf("gateway.detection has parent %s, want the caller's %s", got, callerSpanID)
+	}
+}
+
+// TestRecordsIngestKey checks detections are stored with the fingerprint
+// of the node key their signature verified against, and stored without
+// one otherwise
+func TestRecordsIngestKey(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, _ := crypto.PublicKeyPEM(key)
+	fingerprint, _ := crypto.Fingerprint(&key.PublicKey)
+	other, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := storage.NewMemoryStore()
+	if err := store.UpsertNode(&models.SensorNode{NodeID: "node-1", PublicKey: pub}); err != nil {
+		t.Fatal(err)
+	}
+	s := NewService(store, nil, nil, nil)
+
+	tests := []struct {
+		name    string
+		uasID   string
+		nodeID  string
+		signer  *ecdsa.PrivateKey
+		tamper  bool
+		wantKey string
+	}{
+		{name: "verified", uasID: "uas-ok", nodeID: "node-1", signer: key, wantKey: fingerprint},
+		{name: "other key", uasID: "uas-other", nodeID: "node-1", signer: other},
+		{name: "altered after signing", uasID: "uas-tampered", nodeID: "node-1", signer: key, tamper: true},
+		{name: "unknown node", uasID: "uas-unknown", nodeID: "node-2", signer: key},
+		{name: "unsigned", uasID: "uas-unsigned", nodeID: "node-1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := models.IncomingPacket{SN: "sn", UASID: tt.uasID, Latitude: 51.47, Longitude: -0.45, Height: 50}
+			if tt.signer != nil {
+				if err := crypto.SignPacket(tt.signer, &p); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.tamper {
+				p.Height = 400
+			}
+			// Filled in by the gateway after signing
+			p.NodeID = tt.nodeID
+			value, _ := json.Marshal(p)
+			if err := s.processMessage(context.Background(), kafka.Message{Value: value}); err != nil {
+				t.Fatal(err)
+			}
+
+			page, err := store.QueryDetections(database.DetectionFilter{UASID: tt.uasID})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(page.Detections) != 1 {
+				t.Fatalf("stored %d detections, want 1", len(page.Detections))
+			}
+			if got := page.Detections[0].KeyFingerprint; got != tt.wantKey {
+				t.Errorf("KeyFingerprint = %q, want %q", got, tt.wantKey)
+			}
+		})
+	}
+}
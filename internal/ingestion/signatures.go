@@ -0,0 +1,94 @@
+package ingestion
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"sync"
+	"time"
+
+	"silentraven/internal/crypto"
+	"silentraven/internal/database"
+	"silentraven/internal/logging"
+	"silentraven/internal/models"
+	"silentraven/internal/storage"
+)
+
+// Signature check results, as in the signature_checks_total metric
+const (
+	sigVerified  = "verified"
+	sigUnsigned  = "unsigned"
+	sigNoNodeKey = "no_node_key"
+	sigInvalid   = "invalid"
+)
+
+// keyCacheTTL bounds how long a rotated node key keeps being used
+const keyCacheTTL = 30 * time.Second
+
+// nodeKeys looks up nodes' registered public keys, caching them briefly
+type nodeKeys struct {
+	store storage.NodeStore
+
+	mu    sync.Mutex
+	cache map[string]cachedNodeKey
+}
+
+type cachedNodeKey struct {
+	pub         *ecdsa.PublicKey // nil when the node has no usable key
+	fingerprint string
+	fetched     time.Time
+}
+
+func newNodeKeys(store storage.NodeStore) *nodeKeys {
+	return &nodeKeys{store: store, cache: make(map[string]cachedNodeKey)}
+}
+
+// key returns the node's current public key and its fingerprint, or a nil
+// key for unknown nodes and nodes without one
+func (k *nodeKeys) key(nodeID string) (*ecdsa.PublicKey, string, error) {
+	now := time.Now()
+	k.mu.Lock()
+	cached, ok := k.cache[nodeID]
+	k.mu.Unlock()
+	if ok && now.Sub(cached.fetched) < keyCacheTTL {
+		return cached.pub, cached.fingerprint, nil
+	}
+
+	cached = cachedNodeKey{fetched: now}
+	n, err := k.store.GetNode(nodeID)
+	switch {
+	case errors.Is(err, database.ErrNotFound):
+	case err != nil:
+		return nil, "", err
+	case n.PublicKey != "":
+		if pub, err := crypto.ParsePublicKey(n.PublicKey); err == nil {
+			cached.pub = pub
+			cached.fingerprint, _ = crypto.Fingerprint(pub)
+		} else {
+			logger.Warn("Node has an unusable public key", logging.Node(nodeID), logging.Err(err))
+		}
+	}
+
+	k.mu.Lock()
+	k.cache[nodeID] = cached
+	k.mu.Unlock()
+	return cached.pub, cached.fingerprint, nil
+}
+
+// verify checks packet's signature against its node's current key,
+// returning the check result and, when verified, the key's fingerprint
+func (k *nodeKeys) verify(packet models.IncomingPacket) (string, string, error) {
+	if packet.Signature == "" || packet.NodeID == "" {
+		return sigUnsigned, "", nil
+	}
+	pub, fingerprint, err := k.key(packet.NodeID)
+	if err != nil {
+		return "", "", err
+	}
+	if pub == nil {
+		return sigNoNodeKey, "", nil
+	}
+	if err := crypto.VerifyPacket(pub, packet); err != nil {
+		return sigInvalid, "", nil
+	}
+	return sigVerified, fingerprint, nil
+}
@@ -0,0 +1,67 @@
+package logging
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// Levels is a default level with per-component overrides
+type Levels struct {
+	Default    slog.Level
+	Components map[string]slog.Level
+}
+
+// For returns the level for component
+func (l Levels) For(component string) slog.Level {
+	if level, ok := l.Components[component]; ok {
+		return level
+	}
+	return l.Default
+}
+
+// ParseLevels parses "info" or "warn,gateway=debug,spool=error". An empty
+// spec means info.
+func ParseLevels(spec string) (Levels, error) {
+	levels := Levels{Default: slog.LevelInfo}
+
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		component, name, override := strings.Cut(part, "=")
+		if !override {
+			name = component
+		}
+		level, err := parseLevel(name)
+		if err != nil {
+			return Levels{}, err
+		}
+
+		if !override {
+			levels.Default = level
+			continue
+		}
+		if levels.Components == nil {
+			levels.Components = make(map[string]slog.Level)
+		}
+		levels.Components[strings.TrimSpace(component)] = level
+	}
+	return levels, nil
+}
+
+func parseLevel(name string) (slog.Level, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return 0, fmt.Errorf("invalid log level %q: want debug, info, warn or error", name)
+}
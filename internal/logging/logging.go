@@ -0,0 +1,203 @@
+// Package logging is the services' structured logger, built on log/slog.
+// Each package logs through a component logger from For; LOG_LEVEL sets
+// a default level and optional per-component overrides, e.g.
+// "info,gateway=debug,spool=warn". Records carry the request ID and the
+// OpenTelemetry trace and span IDs from the context they are logged with.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync/atomic"
+
+	"silentraven/pkg/config"
+)
+
+// Standard field names
+const (
+	KeyComponent = "component"
+	KeyService   = "service"
+	KeyRequestID = "request_id"
+	KeyTraceID   = "trace_id"
+	KeySpanID    = "span_id"
+	KeyUAS       = "uas_id"
+	KeyNode      = "node_id"
+	KeyError     = "error"
+)
+
+// Options configures the root logger
+type Options struct {
+	// Service is added to every record
+	Service string
+	// Level is a default level and optional component=level overrides
+	Level string
+	// Format is "text" or "json"
+	Format string
+	// SampleInitial records per message per second pass a sampled logger
+	// before only every SampleThereafter-th does; 0 disables sampling
+	SampleInitial    int
+	SampleThereafter int
+	// Output defaults to stderr
+	Output io.Writer
+}
+
+// state is the active configuration, swapped atomically by Setup so
+// loggers created at package init pick it up
+type state struct {
+	root             slog.Handler
+	levels           Levels
+	sampleInitial    int
+	sampleThereafter int
+}
+
+var current atomic.Pointer[state]
+
+func init() {
+	current.Store(&state{
+		root:   slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug}),
+		levels: Levels{Default: slog.LevelInfo},
+	})
+}
+
+// Configure sets up logging for service from LOG_LEVEL, LOG_FORMAT and
+// the sampling settings
+func Configure(cfg *config.Config, service string) error {
+	return Setup(Options{
+		Service:          service,
+		Level:            cfg.LogLevel,
+		Format:           cfg.LogFormat,
+		SampleInitial:    cfg.LogSampleInitial,
+		SampleThereafter: cfg.LogSampleThereafter,
+	})
+}
+
+// Setup replaces the root logger. The standard library's log package is
+// redirected to it at info level, so remaining log.Printf calls come out
+// structured too.
+func Setup(opts Options) error {
+	levels, err := ParseLevels(opts.Level)
+	if err != nil {
+		return err
+	}
+
+	out := opts.Output
+	if out == nil {
+		out = os.Stderr
+	}
+	// Components filter by level themselves; the root passes everything
+	hopts := &slog.HandlerOptions{Level: slog.LevelDebug}
+
+	var root slog.Handler
+	switch strings.ToLower(opts.Format) {
+	case "", "text":
+		root = slog.NewTextHandler(out, hopts)
+	case "json":
+		root = slog.NewJSONHandler(out, hopts)
+	default:
+		return fmt.Errorf("invalid log format %q: want text or json", opts.Format)
+	}
+	if opts.Service != "" {
+		root = root.WithAttrs([]slog.Attr{slog.String(KeyService, opts.Service)})
+	}
+
+	thereafter := opts.SampleThereafter
+	if thereafter < 1 {
+		thereafter = 1
+	}
+	current.Store(&state{
+		root:             root,
+		levels:           levels,
+		sampleInitial:    opts.SampleInitial,
+		sampleThereafter: thereafter,
+	})
+
+	slog.SetDefault(For(opts.Service))
+	return nil
+}
+
+// For returns the logger for component. It may be called before Setup.
+func For(component string) *slog.Logger {
+	return slog.New(&handler{component: component})
+}
+
+// Fatal logs msg at error level and exits
+func Fatal(l *slog.Logger, msg string, args ...any) {
+	l.Error(msg, args...)
+	os.Exit(1)
+}
+
+// UAS is the UAS ID field
+func UAS(id string) slog.Attr {
+	return slog.String(KeyUAS, id)
+}
+
+// Node is the sensor node ID field
+func Node(id string) slog.Attr {
+	return slog.String(KeyNode, id)
+}
+
+// Err is the error field
+func Err(err error) slog.Attr {
+	return slog.Any(KeyError, err)
+}
+
+// handler routes a component's records to the current root handler,
+// applying the component's level and adding context fields
+type handler struct {
+	component string
+	// ops replays WithAttrs and WithGroup onto the root, which may be
+	// replaced after this handler was derived
+	ops []func(slog.Handler) slog.Handler
+	// derived caches the root with ops applied, per configuration
+	derived atomic.Pointer[derived]
+}
+
+type derived struct {
+	state *state
+	next  slog.Handler
+}
+
+func (h *handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= current.Load().levels.For(h.component)
+}
+
+func (h *handler) Handle(ctx context.Context, r slog.Record) error {
+	r.AddAttrs(contextAttrs(ctx)...)
+	return h.next().Handle(ctx, r)
+}
+
+// next returns the current root with the component and ops applied
+func (h *handler) next() slog.Handler {
+	st := current.Load()
+	if d := h.derived.Load(); d != nil && d.state == st {
+		return d.next
+	}
+
+	next := st.root
+	if h.component != "" {
+		next = next.WithAttrs([]slog.Attr{slog.String(KeyComponent, h.component)})
+	}
+	for _, op := range h.ops {
+		next = op(next)
+	}
+	h.derived.Store(&derived{state: st, next: next})
+	return next
+}
+
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return h.with(func(next slog.Handler) slog.Handler { return next.WithAttrs(attrs) })
+}
+
+func (h *handler) WithGroup(name string) slog.Handler {
+	return h.with(func(next slog.Handler) slog.Handler { return next.WithGroup(name) })
+}
+
+func (h *handler) with(op func(slog.Handler) slog.Handler) *handler {
+	ops := make([]func(slog.Handler) slog.Handler, len(h.ops), len(h.ops)+1)
+	copy(ops, h.ops)
+	return &handler{component: h.component, ops: append(ops, op)}
+}
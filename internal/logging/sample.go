@@ -0,0 +1,77 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Sampled returns a logger for high-volume lines such as per-detection
+// logs. Each message is logged the first SampleInitial times per second,
+// then only every SampleThereafter-th time; warnings and errors are
+// never dropped.
+func Sampled(l *slog.Logger) *slog.Logger {
+	return slog.New(&sampler{next: l.Handler(), counts: &sampleCounts{m: make(map[string]*sampleCount)}})
+}
+
+type sampler struct {
+	next   slog.Handler
+	counts *sampleCounts
+}
+
+type sampleCounts struct {
+	mu sync.Mutex
+	m  map[string]*sampleCount
+}
+
+type sampleCount struct {
+	second int64
+	n      int
+}
+
+func (s *sampler) Enabled(ctx context.Context, level slog.Level) bool {
+	return s.next.Enabled(ctx, level)
+}
+
+func (s *sampler) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level < slog.LevelWarn && !s.counts.keep(r.Message, r.Time) {
+		return nil
+	}
+	return s.next.Handle(ctx, r)
+}
+
+func (s *sampler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &sampler{next: s.next.WithAttrs(attrs), counts: s.counts}
+}
+
+func (s *sampler) WithGroup(name string) slog.Handler {
+	return &sampler{next: s.next.WithGroup(name), counts: s.counts}
+}
+
+// keep counts a record with message msg and reports whether to log it
+func (c *sampleCounts) keep(msg string, at time.Time) bool {
+	st := current.Load()
+	if st.sampleInitial <= 0 {
+		return true
+	}
+
+	second := at.Unix()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	count, ok := c.m[msg]
+	if !ok {
+		count = &sampleCount{}
+		c.m[msg] = count
+	}
+	if count.second != second {
+		count.second, count.n = second, 0
+	}
+	count.n++
+
+	if count.n <= st.sampleInitial {
+		return true
+	}
+	return (count.n-st.sampleInitial)%st.sampleThereafter == 0
+}
@@ -0,0 +1,264 @@
+// Package metrics defines the Prometheus metrics shared by the services.
+// Collectors register with the default registry; each service exposes
+// the ones it uses on /metrics, either on its own router or through
+// Serve.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"silentraven/internal/logging"
+	"silentraven/internal/spool"
+)
+
+var logger = logging.For("metrics")
+
+const namespace = "silentraven"
+
+var (
+	// HTTPRequests counts handled requests by route template
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests handled, by route, method and status code.",
+	}, []string{"route", "method", "code"})
+
+	// HTTPDuration tracks request latency by route template
+	HTTPDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency, by route and method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method"})
+
+	// ValidationFailures counts rejected payloads by reason
+	ValidationFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "validation_failures_total",
+		Help:      "Payloads rejected by validation, by reason.",
+	}, []string{"reason"})
+
+	// AuthFailures counts rejected requests by reason
+	AuthFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "auth_failures_total",
+		Help:      "Requests rejected by authentication, by reason.",
+	}, []string{"reason"})
+
+	// AuditEvents counts audit log entries by action
+	AuditEvents = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "audit_events_total",
+		Help:      "Audit log entries appended, by action.",
+	}, []string{"action"})
+
+	// AuditFailures counts audit entries that could not be stored
+	AuditFailures = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "audit_failures_total",
+		Help:      "Audit log entries that failed to be appended.",
+	})
+
+	// PublishDuration tracks Redpanda write latency by topic
+	PublishDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "kafka_publish_duration_seconds",
+		Help:      "Latency of writes to Redpanda, by topic.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 2, 14),
+	}, []string{"topic"})
+
+	// PublishErrors counts failed Redpanda writes by topic
+	PublishErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "kafka_publish_errors_total",
+		Help:      "Failed writes to Redpanda, by topic.",
+	}, []string{"topic"})
+
+	// MessagesPublished counts messages written to Redpanda by topic
+	MessagesPublished = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "kafka_messages_published_total",
+		Help:      "Messages written to Redpanda, by topic.",
+	}, []string{"topic"})
+
+	// MessagesConsumed counts fetched messages by topic and outcome
+	MessagesConsumed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "kafka_messages_consumed_total",
+		Help:      "Messages fetched from Redpanda, by topic and result (ok or error).",
+	}, []string{"topic", "result"})
+
+	// ConsumerLag is the number of messages behind the partition high
+	// water mark, as of the last fetch
+	ConsumerLag = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "kafka_consumer_lag",
+		Help:      "Messages between the last fetched offset and the high water mark, by topic and partition.",
+	}, []string{"topic", "partition"})
+
+	// DBInsertDuration tracks detection insert latency
+	DBInsertDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_insert_duration_seconds",
+		Help:      "Latency of detection inserts.",
+		Buckets:   prometheus.ExponentialBuckets(0.0005, 2, 14),
+	})
+
+	// DBInsertErrors counts failed detection inserts
+	DBInsertErrors = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "db_insert_errors_total",
+		Help:      "Failed detection inserts.",
+	})
+
+	// SignatureChecks counts ingest-time packet signature checks by result
+	SignatureChecks = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "signature_checks_total",
+		Help:      "Packet signature checks at ingest, by result (verified, unsigned, no_node_key, invalid).",
+	}, []string{"result"})
+
+	// BatchSize tracks the number of packets per detection batch
+	BatchSize = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "batch_size",
+		Help:      "Packets per detection batch received from sensor nodes.",
+		Buckets:   []float64{1, 5, 10, 25, 50, 100, 250, 500, 1000},
+	})
+
+	// CoTSends counts CoT events sent by sink
+	CoTSends = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cot_sends_total",
+		Help:      "CoT events sent to TAK, by sink.",
+	}, []string{"sink"})
+
+	// CoTFailures counts failed CoT sends by sink
+	CoTFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cot_send_failures_total",
+		Help:      "CoT events that could not be sent, by sink.",
+	}, []string{"sink"})
+)
+
+// Handler serves the default registry in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// Serve exposes /metrics on addr in the background, for services
+// without an HTTP API. An empty addr disables it.
+func Serve(addr string) {
+	if addr == "" {
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", Handler())
+	server := &http.Server{
+		Addr:         addr,
+		Handler:      mux,
+		ReadTimeout:  5 * time.Second,
+		WriteTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		logger.Info("Metrics listening", "addr", addr, "path", "/metrics")
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logger.Error("Metrics server failed", logging.Err(err))
+		}
+	}()
+}
+
+// Middleware records request counts and latency for a mux router. Routes
+// are labelled by path template so IDs in paths don't explode the
+// series count.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := "unmatched"
+		if cur := mux.CurrentRoute(r); cur != nil {
+			if tpl, err := cur.GetPathTemplate(); err == nil {
+				route = tpl
+			}
+		}
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		start := time.Now()
+		next.ServeHTTP(rec, r)
+
+		HTTPDuration.WithLabelValues(route, r.Method).Observe(time.Since(start).Seconds())
+		HTTPRequests.WithLabelValues(route, r.Method, strconv.Itoa(rec.status)).Inc()
+	})
+}
+
+// statusRecorder captures the status code written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// ObservePublish records the outcome of writing n messages to topic
+func ObservePublish(topic string, n int, start time.Time, err error) {
+	PublishDuration.WithLabelValues(topic).Observe(time.Since(start).Seconds())
+	if err != nil {
+		PublishErrors.WithLabelValues(topic).Inc()
+		return
+	}
+	MessagesPublished.WithLabelValues(topic).Add(float64(n))
+}
+
+// ObserveLag records the consumer lag implied by a fetched message.
+// kafka-go sets HighWaterMark on every fetched message, so this needs
+// no extra round trip to the broker.
+func ObserveLag(topic string, partition int, offset, highWaterMark int64) {
+	lag := highWaterMark - offset - 1
+	if lag < 0 {
+		lag = 0
+	}
+	ConsumerLag.WithLabelValues(topic, strconv.Itoa(partition)).Set(float64(lag))
+}
+
+// RegisterSpool exports a spool's backlog statistics
+func RegisterSpool(s *spool.Spool) {
+	stat := func(name, help string, counter bool, value func(spool.Stats) float64) {
+		opts := prometheus.Opts{Namespace: namespace, Subsystem: "spool", Name: name, Help: help}
+		f := func() float64 { return value(s.Stats()) }
+		if counter {
+			promauto.NewCounterFunc(prometheus.CounterOpts(opts), f)
+		} else {
+			promauto.NewGaugeFunc(prometheus.GaugeOpts(opts), f)
+		}
+	}
+
+	stat("depth", "Detections spooled and not yet delivered.", false,
+		func(st spool.Stats) float64 { return float64(st.Depth) })
+	stat("bytes", "Size of the spool on disk.", false,
+		func(st spool.Stats) float64 { return float64(st.Bytes) })
+	stat("segments", "Spool segment files on disk.", false,
+		func(st spool.Stats) float64 { return float64(st.Segments) })
+	stat("rejected_total", "Detections rejected because the spool was full.", true,
+		func(st spool.Stats) float64 { return float64(st.Rejected) })
+	stat("drained_total", "Spooled detections delivered to Redpanda.", true,
+		func(st spool.Stats) float64 { return float64(st.Drained) })
+	stat("send_failures_total", "Failed attempts to forward a spooled batch.", true,
+		func(st spool.Stats) float64 { return float64(st.Failures) })
+	stat("stalled_seconds", "How long the batch at the head of the spool has been failing; 0 while draining.", false,
+		func(st spool.Stats) float64 { return st.StalledSeconds })
+}
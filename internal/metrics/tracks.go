@@ -0,0 +1,54 @@
+package metrics
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// ActiveTracks counts distinct UAS seen within a sliding window and
+// exports the count as silentraven_active_tracks
+type ActiveTracks struct {
+	window time.Duration
+
+	mu   sync.Mutex
+	seen map[string]time.Time
+}
+
+// NewActiveTracks registers the active track gauge. A UAS stays active
+// until window has passed without a detection.
+func NewActiveTracks(window time.Duration) *ActiveTracks {
+	t := &ActiveTracks{window: window, seen: make(map[string]time.Time)}
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "active_tracks",
+		Help:      "Distinct UAS detected within the activity window.",
+		ConstLabels: prometheus.Labels{
+			"window": window.String(),
+		},
+	}, func() float64 { return float64(t.Count()) })
+	return t
+}
+
+// Seen marks uasID as detected now
+func (t *ActiveTracks) Seen(uasID string) {
+	t.mu.Lock()
+	t.seen[uasID] = time.Now()
+	t.mu.Unlock()
+}
+
+// Count expires stale tracks and returns the number still active
+func (t *ActiveTracks) Count() int {
+	cutoff := time.Now().Add(-t.window)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for id, last := range t.seen {
+		if last.Before(cutoff) {
+			delete(t.seen, id)
+		}
+	}
+	return len(t.seen)
+}
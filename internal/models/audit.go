@@ -0,0 +1,54 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// AuditEvent is one entry of the append-only audit log. Each entry's Hash
+// covers its fields and the previous entry's hash, so changing, removing
+// or reordering entries breaks the chain.
+type AuditEvent struct {
+	// Seq numbers entries from 1 without gaps
+	Seq  int64     `json:"seq" db:"seq"`
+	Time time.Time `json:"time" db:"time"`
+	// Actor is the principal's subject, or the OS user for CLI tools
+	Actor     string `json:"actor" db:"actor"`
+	ActorKind string `json:"actor_kind" db:"actor_kind"`
+	Role      string `json:"role,omitempty" db:"role"`
+	KeyID     string `json:"key_id,omitempty" db:"key_id"`
+	// Action is e.g. "access", "export", "node.update" or "config.reload"
+	Action   string `json:"action" db:"action"`
+	Resource string `json:"resource" db:"resource"`
+	// Status is the HTTP status for API requests
+	Status     int    `json:"status,omitempty" db:"status"`
+	RemoteAddr string `json:"remote_addr,omitempty" db:"remote_addr"`
+	RequestID  string `json:"request_id,omitempty" db:"request_id"`
+	// Detail is a JSON object with action-specific fields
+	Detail   string `json:"detail,omitempty" db:"detail"`
+	PrevHash string `json:"prev_hash" db:"prev_hash"`
+	Hash     string `json:"hash" db:"hash"`
+}
+
+// ComputeHash returns the chain hash of e: the hex SHA-256 of its fields
+// and PrevHash. Time is hashed at microsecond precision, as Postgres
+// stores it.
+func (e *AuditEvent) ComputeHash() string {
+	fields := []string{
+		strconv.FormatInt(e.Seq, 10),
+		e.Time.UTC().Truncate(time.Microsecond).Format(time.RFC3339Nano),
+		e.Actor, e.ActorKind, e.Role, e.KeyID,
+		e.Action, e.Resource, strconv.Itoa(e.Status),
+		e.RemoteAddr, e.RequestID, e.Detail,
+		e.PrevHash,
+	}
+	h := sha256.New()
+	// Length prefixes stop one field's value imitating a field boundary
+	for _, f := range fields {
+		fmt.Fprintf(h, "%d:%s;", len(f), f)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
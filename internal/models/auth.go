@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// API key kinds
+const (
+	// KeyKindNode keys authenticate one sensor node to the gateway
+	KeyKindNode = "node"
+	// KeyKindClient keys authenticate an integration or dashboard
+	KeyKindClient = "client"
+)
+
+// APIKey is a stored API key. Only a hash of the secret is kept.
+type APIKey struct {
+	ID   string `json:"id" db:"id"`
+	Name string `json:"name" db:"name"`
+	Kind string `json:"kind" db:"kind"`
+	// Subject is the node ID for node keys and the client name otherwise
+	Subject string `json:"subject" db:"subject"`
+	// Role and Jurisdiction limit what a client key can see on the query
+	// API; node keys have neither
+	Role         string `json:"role,omitempty" db:"role"`
+	Jurisdiction string `json:"jurisdiction,omitempty" db:"jurisdiction"`
+	// Hash is the hex SHA-256 of the key's secret
+	Hash       string    `json:"-" db:"hash"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+	ExpiresAt  time.Time `json:"expires_at,omitempty" db:"expires_at"` // zero never expires
+	RevokedAt  time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+	LastUsedAt time.Time `json:"last_used_at,omitempty" db:"last_used_at"`
+	// ReplacedBy is the ID of the key this one was rotated to
+	ReplacedBy string `json:"replaced_by,omitempty" db:"replaced_by"`
+}
+
+// Active reports whether the key may be used at t
+func (k *APIKey) Active(t time.Time) bool {
+	if !k.RevokedAt.IsZero() && !t.Before(k.RevokedAt) {
+		return false
+	}
+	return k.ExpiresAt.IsZero() || t.Before(k.ExpiresAt)
+}
@@ -0,0 +1,125 @@
+package models
+
+import (
+	"time"
+)
+
+// Case statuses, in workflow order
+const (
+	CaseOpen          = "open"
+	CaseInvestigating = "investigating"
+	CaseReferred      = "referred"
+	CaseClosed        = "closed"
+)
+
+// caseTransitions lists the statuses each status may move to. Referred
+// cases go back to investigating if the receiving agency asks for more,
+// and closed cases can be reopened.
+var caseTransitions = map[string][]string{
+	CaseOpen:          {CaseInvestigating, CaseClosed},
+	CaseInvestigating: {CaseOpen, CaseReferred, CaseClosed},
+	CaseReferred:      {CaseInvestigating, CaseClosed},
+	CaseClosed:        {CaseOpen},
+}
+
+// ValidCaseStatus reports whether s is a case status
+func ValidCaseStatus(s string) bool {
+	_, ok := caseTransitions[s]
+	return ok
+}
+
+// CanMoveCase reports whether a case may move from one status to another
+func CanMoveCase(from, to string) bool {
+	for _, s := range caseTransitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// What a case was opened from
+const (
+	CaseFromTrack  = "track"
+	CaseFromAlert  = "alert"
+	CaseFromManual = "manual"
+)
+
+// Case attachment kinds
+const (
+	// AttachDetection refers to one detection by ID
+	AttachDetection = "detection"
+	// AttachTrack refers to a UAS's detections over a time window
+	AttachTrack = "track"
+	// AttachEvidence refers to an evidence bundle by ID and SHA-256
+	AttachEvidence = "evidence"
+)
+
+// Case fields whose changes are recorded as CaseEvents
+const (
+	CaseFieldStatus   = "status"
+	CaseFieldAssignee = "assignee"
+)
+
+// Case is an enforcement case
+type Case struct {
+	ID          int64  `json:"id" db:"id"`
+	Title       string `json:"title" db:"title"`
+	Description string `json:"description" db:"description"`
+	Status      string `json:"status" db:"status"`
+	// Assignee is the subject of the user working the case
+	Assignee string `json:"assignee" db:"assignee"`
+	// Source is CaseFromTrack, CaseFromAlert or CaseFromManual, and
+	// SourceRef identifies the alert, if any
+	Source    string    `json:"source" db:"source"`
+	SourceRef string    `json:"source_ref" db:"source_ref"`
+	UASID     string    `json:"uas_id" db:"uas_id"`
+	CreatedBy string    `json:"created_by" db:"created_by"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+	ClosedAt  time.Time `json:"closed_at,omitempty" db:"closed_at"`
+
+	// Filled in for a single case
+	Attachments []CaseAttachment `json:"attachments,omitempty"`
+	Notes       []CaseNote       `json:"notes,omitempty"`
+	Events      []CaseEvent      `json:"events,omitempty"`
+}
+
+// CaseAttachment links a detection, track or evidence bundle to a case
+type CaseAttachment struct {
+	ID     int64  `json:"id" db:"id"`
+	CaseID int64  `json:"case_id" db:"case_id"`
+	Kind   string `json:"kind" db:"kind"`
+	// Ref is the detection ID, UAS ID or bundle ID
+	Ref string `json:"ref" db:"ref"`
+	// From and To bound a track
+	From time.Time `json:"from,omitempty" db:"from_time"`
+	To   time.Time `json:"to,omitempty" db:"to_time"`
+	// SHA256 is an evidence bundle's archive digest
+	SHA256  string    `json:"sha256,omitempty" db:"sha256"`
+	Note    string    `json:"note" db:"note"`
+	AddedBy string    `json:"added_by" db:"added_by"`
+	AddedAt time.Time `json:"added_at" db:"added_at"`
+}
+
+// CaseNote is a free-text note on a case
+type CaseNote struct {
+	ID        int64     `json:"id" db:"id"`
+	CaseID    int64     `json:"case_id" db:"case_id"`
+	Author    string    `json:"author" db:"author"`
+	Body      string    `json:"body" db:"body"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// CaseEvent records a change of a case's status or assignee
+type CaseEvent struct {
+	ID     int64     `json:"id" db:"id"`
+	CaseID int64     `json:"case_id" db:"case_id"`
+	Field  string    `json:"field" db:"field"`
+	From   string    `json:"from" db:"from_value"`
+	To     string    `json:"to" db:"to_value"`
+	Actor  string    `json:"actor" db:"actor"`
+	At     time.Time `json:"at" db:"at"`
+	// Message says why, e.g. the agency a case was referred to
+	Message string `json:"message" db:"message"`
+}
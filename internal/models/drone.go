@@ -21,10 +21,32 @@ type DroneDetection struct {
 	OperatorLongitude float64   `json:"operator_longitude" db:"operator_longitude"`
 	NodeID            string    `json:"node_id" db:"node_id"`
 	Signature         string    `json:"signature" db:"signature"`
-	RawData           string    `json:"raw_data" db:"raw_data"`
-	CreatedAt         time.Time `json:"created_at" db:"created_at"`
+	// KeyFingerprint identifies the node key the signature verified
+	// against at ingest; empty if it did not verify
+	KeyFingerprint string    `json:"key_fingerprint,omitempty" db:"key_fingerprint"`
+	RawData        string    `json:"raw_data" db:"raw_data"`
+	CreatedAt      time.Time `json:"created_at" db:"created_at"`
 }
 
+// Track is a single UAS flight path ordered by time
+type Track struct {
+	UASID  string       `json:"uas_id"`
+	SN     string       `json:"sn"`
+	Points []TrackPoint `json:"points"`
+}
+
+// TrackPoint is one position along a track
+type TrackPoint struct {
+	Time      time.Time `json:"ts"`
+	Latitude  float64   `json:"lat"`
+	Longitude float64   `json:"lon"`
+	Height    float64   `json:"height"`
+}
+
+// DirectionUnknown is the F3411 direction of a UA whose course is not
+// known; known directions are 0-359 degrees
+const DirectionUnknown = 361
+
 // IncomingPacket represents the raw data from sensor nodes
 type IncomingPacket struct {
 	SN                string  `json:"SN"`
@@ -39,10 +61,26 @@ type IncomingPacket struct {
 	OperatorLatitude  float64 `json:"OperatorLatitude"`
 	OperatorLongitude float64 `json:"OperatorLongitude"`
 	Signature         string  `json:"signature,omitempty"`
-	NodeID            string  `json:"node_id,omitempty"`
-	Timestamp         string  `json:"timestamp,omitempty"`
+	// Signed is the packet's JSON encoding exactly as the node signed it
+	Signed    []byte `json:"signed,omitempty"`
+	NodeID    string `json:"node_id,omitempty"`
+	Timestamp string `json:"timestamp,omitempty"`
+
+	// Remote ID Location/Vector altitude fields (optional, nil when not broadcast)
+	GeodeticAltitude   *float64 `json:"GeodeticAltitude,omitempty"` // WGS-84 HAE (m)
+	PressureAltitude   *float64 `json:"PressureAltitude,omitempty"` // barometric, referenced to MSL (m)
+	HeightType         int      `json:"HeightType,omitempty"`       // 0 = above takeoff, 1 = AGL
+	HorizontalAccuracy int      `json:"HorizontalAccuracy,omitempty"`
+	VerticalAccuracy   int      `json:"VerticalAccuracy,omitempty"`
+	BaroAccuracy       int      `json:"BaroAccuracy,omitempty"`
 }
 
+// Remote ID height reference (ASTM F3411 HeightType)
+const (
+	HeightAboveTakeoff = 0
+	HeightAboveGround  = 1
+)
+
 // APIResponse is a standard API response wrapper
 type APIResponse struct {
 	Success bool        `json:"success"`
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"time"
+)
+
+// Sensor node connectivity states
+const (
+	NodeUnknown  = "unknown"
+	NodeOnline   = "online"
+	NodeDegraded = "degraded"
+	NodeOffline  = "offline"
+)
+
+// SensorNode is a registered Remote ID receiver
+type SensorNode struct {
+	NodeID      string    `json:"node_id" db:"node_id"`
+	Name        string    `json:"name" db:"name"`
+	Latitude    float64   `json:"latitude" db:"latitude"`
+	Longitude   float64   `json:"longitude" db:"longitude"`
+	Altitude    float64   `json:"altitude" db:"altitude"`
+	AntennaType string    `json:"antenna_type" db:"antenna_type"`
+	Firmware    string    `json:"firmware" db:"firmware"`
+	Owner       string    `json:"owner" db:"owner"`
+	PublicKey   string    `json:"public_key" db:"public_key"`
+	Status      string    `json:"status" db:"status"`
+	LastSeen    time.Time `json:"last_seen" db:"last_seen"`
+	ClockSkewMs int64     `json:"clock_skew_ms" db:"clock_skew_ms"` // last reported, node minus server
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// NodeKey is a public key a node has been registered with; keys stay on
+// record after rotation so older signatures can still be checked
+type NodeKey struct {
+	NodeID      string    `json:"node_id" db:"node_id"`
+	Fingerprint string    `json:"fingerprint" db:"fingerprint"`
+	PublicKey   string    `json:"public_key" db:"public_key"`
+	AddedAt     time.Time `json:"added_at" db:"added_at"`
+}
+
+// NodeHeartbeat is the periodic liveness report sent by a node
+type NodeHeartbeat struct {
+	NodeID        string   `json:"node_id"`
+	Timestamp     string   `json:"timestamp,omitempty"`
+	Firmware      string   `json:"firmware,omitempty"`
+	Status        string   `json:"status,omitempty"` // self-reported: "ok" or "degraded"
+	UptimeSeconds int64    `json:"uptime_seconds,omitempty"`
+	Detections    int64    `json:"detections,omitempty"` // frames decoded since last heartbeat
+	Latitude      *float64 `json:"latitude,omitempty"`
+	Longitude     *float64 `json:"longitude,omitempty"`
+	ClockSkewMs   *int64   `json:"clock_skew_ms,omitempty"` // node clock minus gateway clock
+}
+
+// NodeEvent records a node status transition
+type NodeEvent struct {
+	ID         int64     `json:"id" db:"id"`
+	NodeID     string    `json:"node_id" db:"node_id"`
+	FromStatus string    `json:"from_status" db:"from_status"`
+	ToStatus   string    `json:"to_status" db:"to_status"`
+	At         time.Time `json:"at" db:"at"`
+	Message    string    `json:"message" db:"message"`
+}
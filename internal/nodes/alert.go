@@ -0,0 +1,76 @@
+package nodes
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"silentraven/internal/logging"
+	"silentraven/internal/models"
+)
+
+// Alerter is notified when a node goes offline or comes back
+type Alerter interface {
+	Alert(event models.NodeEvent) error
+}
+
+// LogAlerter writes alerts to the service log
+type LogAlerter struct{}
+
+// Alert logs the event
+func (LogAlerter) Alert(event models.NodeEvent) error {
+	if event.ToStatus == models.NodeOffline {
+		logger.Error("Node down", logging.Node(event.NodeID), "message", event.Message)
+	} else {
+		logger.Info("Node recovered", logging.Node(event.NodeID), "status", event.ToStatus)
+	}
+	return nil
+}
+
+// WebhookAlerter posts alerts as JSON to a URL and also logs them
+type WebhookAlerter struct {
+	URL    string
+	client *http.Client
+}
+
+// NewWebhookAlerter creates an alerter posting to url
+func NewWebhookAlerter(url string) *WebhookAlerter {
+	return &WebhookAlerter{
+		URL:    url,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// webhookPayload is the JSON body posted for each alert
+type webhookPayload struct {
+	Type  string           `json:"type"`
+	Event models.NodeEvent `json:"event"`
+}
+
+// Alert posts the event to the webhook
+func (a *WebhookAlerter) Alert(event models.NodeEvent) error {
+	LogAlerter{}.Alert(event)
+
+	payload := webhookPayload{Type: "node_up", Event: event}
+	if event.ToStatus == models.NodeOffline {
+		payload.Type = "node_down"
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal alert: %w", err)
+	}
+
+	resp, err := a.client.Post(a.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to post alert: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("alert webhook returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,196 @@
+// Package nodes tracks sensor node liveness: heartbeats update a node's
+// last-seen time and a monitor moves nodes between online, degraded and
+// offline, raising alerts when a node goes down or recovers.
+package nodes
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"silentraven/internal/logging"
+	"silentraven/internal/models"
+	"silentraven/internal/storage"
+)
+
+var logger = logging.For("nodes")
+
+// Thresholds configures when a silent node changes state
+type Thresholds struct {
+	// DegradedAfter is how long without a heartbeat before a node is degraded
+	DegradedAfter time.Duration
+	// OfflineAfter is how long without a heartbeat before a node is offline
+	OfflineAfter time.Duration
+}
+
+// Validate checks the thresholds for inconsistent values
+func (t Thresholds) Validate() error {
+	if t.DegradedAfter <= 0 {
+		return fmt.Errorf("degraded threshold must be positive")
+	}
+	if t.OfflineAfter <= t.DegradedAfter {
+		return fmt.Errorf("offline threshold (%s) must exceed degraded threshold (%s)", t.OfflineAfter, t.DegradedAfter)
+	}
+	return nil
+}
+
+// Monitor records heartbeats and applies status transitions
+type Monitor struct {
+	store      storage.NodeStore
+	thresholds Thresholds
+	alerter    Alerter
+	now        func() time.Time
+
+	mu       sync.Mutex
+	reported map[string]string // self-reported status from the latest heartbeat
+}
+
+// NewMonitor creates a node monitor. A nil alerter logs alerts.
+func NewMonitor(store storage.NodeStore, thresholds Thresholds, alerter Alerter) (*Monitor, error) {
+	if err := thresholds.Validate(); err != nil {
+		return nil, err
+	}
+	if alerter == nil {
+		alerter = LogAlerter{}
+	}
+	return &Monitor{
+		store:      store,
+		thresholds: thresholds,
+		alerter:    alerter,
+		now:        time.Now,
+		reported:   make(map[string]string),
+	}, nil
+}
+
+// Heartbeat persists a heartbeat and immediately re-evaluates the node, so
+// a node coming back is marked online without waiting for the next check.
+func (m *Monitor) Heartbeat(hb models.NodeHeartbeat) error {
+	if hb.NodeID == "" {
+		return fmt.Errorf("heartbeat missing node_id")
+	}
+
+	at := m.now()
+	if hb.Timestamp != "" {
+		if ts, err := time.Parse(time.RFC3339, hb.Timestamp); err == nil && !ts.After(at) {
+			at = ts
+		}
+	}
+
+	if err := m.store.RecordHeartbeat(hb, at); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	m.reported[hb.NodeID] = hb.Status
+	m.mu.Unlock()
+
+	node, err := m.store.GetNode(hb.NodeID)
+	if err != nil {
+		return err
+	}
+	return m.evaluate(*node)
+}
+
+// Run checks every node each interval until ctx is cancelled
+func (m *Monitor) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := m.Check(); err != nil {
+			logger.Warn("Node check failed", logging.Err(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check re-evaluates every registered node
+func (m *Monitor) Check() error {
+	nodes, err := m.store.ListNodes()
+	if err != nil {
+		return err
+	}
+	for _, n := range nodes {
+		if err := m.evaluate(n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Status returns the state a node should be in at now
+func (m *Monitor) Status(n models.SensorNode, now time.Time) string {
+	if n.LastSeen.IsZero() {
+		return models.NodeUnknown
+	}
+
+	silent := now.Sub(n.LastSeen)
+	switch {
+	case silent >= m.thresholds.OfflineAfter:
+		return models.NodeOffline
+	case silent >= m.thresholds.DegradedAfter:
+		return models.NodeDegraded
+	}
+
+	m.mu.Lock()
+	reported := m.reported[n.NodeID]
+	m.mu.Unlock()
+	if reported == models.NodeDegraded {
+		return models.NodeDegraded
+	}
+	return models.NodeOnline
+}
+
+// evaluate records and alerts on a status change for one node
+func (m *Monitor) evaluate(n models.SensorNode) error {
+	now := m.now()
+	status := m.Status(n, now)
+	if status == n.Status {
+		return nil
+	}
+
+	event := &models.NodeEvent{
+		NodeID:     n.NodeID,
+		FromStatus: n.Status,
+		ToStatus:   status,
+		At:         now,
+		Message:    m.transitionMessage(n, status, now),
+	}
+	if err := m.store.SetNodeStatus(event); err != nil {
+		return err
+	}
+
+	logger.Info("Node status changed", logging.Node(n.NodeID), "from", event.FromStatus, "to", event.ToStatus)
+
+	if status == models.NodeOffline || event.FromStatus == models.NodeOffline {
+		if err := m.alerter.Alert(*event); err != nil {
+			logger.Warn("Failed to send node alert", logging.Node(n.NodeID), logging.Err(err))
+		}
+	}
+	return nil
+}
+
+func (m *Monitor) transitionMessage(n models.SensorNode, status string, now time.Time) string {
+	silent := now.Sub(n.LastSeen).Round(time.Second)
+
+	switch status {
+	case models.NodeOffline:
+		return fmt.Sprintf("no heartbeat for %s", silent)
+	case models.NodeDegraded:
+		if silent < m.thresholds.DegradedAfter {
+			return "node reported degraded"
+		}
+		return fmt.Sprintf("last heartbeat %s ago", silent)
+	case models.NodeOnline:
+		if n.Status == models.NodeOffline {
+			return "heartbeat resumed"
+		}
+	}
+	return ""
+}
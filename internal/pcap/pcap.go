@@ -0,0 +1,140 @@
+// Package pcap reads packet capture files in the classic libpcap and
+// pcapng formats.
+package pcap
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Link types used for Remote ID captures
+const (
+	LinkTypeIEEE80211     = 105 // raw 802.11
+	LinkTypeRadiotap      = 127 // 802.11 with radiotap header
+	LinkTypeBluetoothLE   = 251 // BLE link layer
+	LinkTypeBluetoothPHDR = 256 // BLE link layer with pseudo-header
+)
+
+// maxSnapLen guards against reading a garbage record length
+const maxSnapLen = 1 << 20
+
+// Packet is one captured packet
+type Packet struct {
+	Time     time.Time
+	LinkType uint32
+	Data     []byte
+}
+
+// Reader reads packets from a classic pcap stream
+type Reader struct {
+	r        *bufio.Reader
+	order    binary.ByteOrder
+	nanos    bool
+	linkType uint32
+}
+
+// NewReader reads the file header and returns a packet reader
+func NewReader(r io.Reader) (*Reader, error) {
+	br := bufio.NewReader(r)
+
+	var header [24]byte
+	if _, err := io.ReadFull(br, header[:]); err != nil {
+		return nil, fmt.Errorf("failed to read pcap header: %w", err)
+	}
+
+	pr := &Reader{r: br}
+	switch magic := binary.LittleEndian.Uint32(header[0:4]); magic {
+	case 0xa1b2c3d4:
+		pr.order = binary.LittleEndian
+	case 0xa1b23c4d:
+		pr.order, pr.nanos = binary.LittleEndian, true
+	case 0xd4c3b2a1:
+		pr.order = binary.BigEndian
+	case 0x4d3cb2a1:
+		pr.order, pr.nanos = binary.BigEndian, true
+	default:
+		return nil, fmt.Errorf("not a pcap file (magic %#x)", magic)
+	}
+
+	pr.linkType = pr.order.Uint32(header[20:24]) & 0x0FFFFFFF
+	return pr, nil
+}
+
+// LinkType returns the capture's link-layer header type
+func (r *Reader) LinkType() uint32 {
+	return r.linkType
+}
+
+// Next returns the next packet, or io.EOF at the end of the file
+func (r *Reader) Next() (Packet, error) {
+	var header [16]byte
+	if _, err := io.ReadFull(r.r, header[:]); err != nil {
+		if err == io.EOF {
+			return Packet{}, io.EOF
+		}
+		return Packet{}, fmt.Errorf("truncated pcap record header: %w", err)
+	}
+
+	sec := r.order.Uint32(header[0:4])
+	frac := r.order.Uint32(header[4:8])
+	inclLen := r.order.Uint32(header[8:12])
+	if inclLen > maxSnapLen {
+		return Packet{}, fmt.Errorf("pcap record too large: %d bytes", inclLen)
+	}
+
+	data := make([]byte, inclLen)
+	if _, err := io.ReadFull(r.r, data); err != nil {
+		return Packet{}, fmt.Errorf("truncated pcap record: %w", err)
+	}
+
+	nsec := int64(frac) * 1000
+	if r.nanos {
+		nsec = int64(frac)
+	}
+
+	return Packet{
+		Time:     time.Unix(int64(sec), nsec).UTC(),
+		LinkType: r.linkType,
+		Data:     data,
+	}, nil
+}
+
+// Writer writes a classic pcap stream with microsecond timestamps
+type Writer struct {
+	w io.Writer
+}
+
+// NewWriter writes the file header for linkType
+func NewWriter(w io.Writer, linkType uint32) (*Writer, error) {
+	var header [24]byte
+	binary.LittleEndian.PutUint32(header[0:4], 0xa1b2c3d4)
+	binary.LittleEndian.PutUint16(header[4:6], 2)
+	binary.LittleEndian.PutUint16(header[6:8], 4)
+	binary.LittleEndian.PutUint32(header[16:20], maxSnapLen)
+	binary.LittleEndian.PutUint32(header[20:24], linkType)
+
+	if _, err := w.Write(header[:]); err != nil {
+		return nil, fmt.Errorf("failed to write pcap header: %w", err)
+	}
+	return &Writer{w: w}, nil
+}
+
+// Write appends one packet
+func (w *Writer) Write(t time.Time, data []byte) error {
+	var header [16]byte
+	binary.LittleEndian.PutUint32(header[0:4], uint32(t.Unix()))
+	binary.LittleEndian.PutUint32(header[4:8], uint32(t.Nanosecond()/1000))
+	binary.LittleEndian.PutUint32(header[8:12], uint32(len(data)))
+	binary.LittleEndian.PutUint32(header[12:16], uint32(len(data)))
+
+	if _, err := w.w.Write(header[:]); err != nil {
+		return fmt.Errorf("failed to write pcap record: %w", err)
+	}
+	if _, err := w.w.Write(data); err != nil {
+		return fmt.Errorf("failed to write pcap record: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package pcap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestWriterRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, LinkTypeRadiotap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t0 := time.Date(2026, 3, 1, 12, 0, 0, 250_000_000, time.UTC)
+	packets := [][]byte{{1, 2, 3}, {}, bytes.Repeat([]byte{0xAB}, 300)}
+	for i, data := range packets {
+		if err := w.Write(t0.Add(time.Duration(i)*time.Second), data); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r, err := Open(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.(*Reader).LinkType() != LinkTypeRadiotap {
+		t.Errorf("link type = %d", r.(*Reader).LinkType())
+	}
+	for i, want := range packets {
+		pkt, err := r.Next()
+		if err != nil {
+			t.Fatalf("packet %d: %v", i, err)
+		}
+		if !pkt.Time.Equal(t0.Add(time.Duration(i)*time.Second)) || pkt.LinkType != LinkTypeRadiotap || !bytes.Equal(pkt.Data, want) {
+			t.Errorf("packet %d = %v %d %x", i, pkt.Time, pkt.LinkType, pkt.Data)
+		}
+	}
+	if _, err := r.Next(); err != io.EOF {
+		t.Errorf("after last packet: %v, want io.EOF", err)
+	}
+}
+
+// classic builds a pcap file in the given byte order and resolution
+func classic(order binary.AppendByteOrder, nanos bool, linkType uint32, sec, frac uint32, data []byte) []byte {
+	magic := uint32(0xa1b2c3d4)
+	if nanos {
+		magic = 0xa1b23c4d
+	}
+	b := order.AppendUint32(nil, magic)
+	b = order.AppendUint16(b, 2)
+	b = order.AppendUint16(b, 4)
+	b = append(b, make([]byte, 8)...)
+	b = order.AppendUint32(b, maxSnapLen)
+	b = order.AppendUint32(b, linkType)
+	b = order.AppendUint32(b, sec)
+	b = order.AppendUint32(b, frac)
+	b = order.AppendUint32(b, uint32(len(data)))
+	b = order.AppendUint32(b, uint32(len(data)))
+	return append(b, data...)
+}
+
+func TestReaderFormats(t *testing.T) {
+	tests := []struct {
+		name  string
+		order binary.AppendByteOrder
+		nanos bool
+		frac  uint32
+		want  time.Time
+	}{
+		{"little endian micros", binary.LittleEndian, false, 500_000, time.Unix(1_700_000_000, 500_000_000)},
+		{"little endian nanos", binary.LittleEndian, true, 123_456_789, time.Unix(1_700_000_000, 123_456_789)},
+		{"big endian micros", binary.BigEndian, false, 1, time.Unix(1_700_000_000, 1_000)},
+		{"big endian nanos", binary.BigEndian, true, 7, time.Unix(1_700_000_000, 7)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := Open(bytes.NewReader(classic(tt.order, tt.nanos, LinkTypeBluetoothLE, 1_700_000_000, tt.frac, []byte{9, 8, 7})))
+			if err != nil {
+				t.Fatal(err)
+			}
+			pkt, err := r.Next()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !pkt.Time.Equal(tt.want) || pkt.LinkType != LinkTypeBluetoothLE || !bytes.Equal(pkt.Data, []byte{9, 8, 7}) {
+				t.Errorf("packet = %v %d %x", pkt.Time, pkt.LinkType, pkt.Data)
+			}
+		})
+	}
+}
+
+func TestReaderErrors(t *testing.T) {
+	good := classic(binary.LittleEndian, false, LinkTypeIEEE80211, 1, 0, []byte{1, 2, 3, 4})
+
+	if _, err := NewReader(bytes.NewReader(append([]byte{0, 0, 0, 0}, good[4:]...))); err == nil {
+		t.Error("bad magic accepted")
+	}
+	if _, err := NewReader(bytes.NewReader(good[:10])); err == nil {
+		t.Error("short header accepted")
+	}
+
+	r, err := NewReader(bytes.NewReader(good[:len(good)-1]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Next(); err == nil || err == io.EOF {
+		t.Errorf("truncated record: %v, want an error", err)
+	}
+
+	huge := append([]byte{}, good...)
+	binary.LittleEndian.PutUint32(huge[32:36], maxSnapLen+1)
+	r, _ = NewReader(bytes.NewReader(huge))
+	if _, err := r.Next(); err == nil {
+		t.Error("oversized record accepted")
+	}
+}
@@ -0,0 +1,207 @@
+package pcap
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// pcapng block types
+const (
+	blockSectionHeader  = 0x0A0D0D0A
+	blockInterface      = 0x00000001
+	blockPacketObsolete = 0x00000002
+	blockSimplePacket   = 0x00000003
+	blockEnhancedPacket = 0x00000006
+)
+
+// byteOrderMagic is written in the native order of the section
+const byteOrderMagic = 0x1A2B3C4D
+
+// PacketReader is implemented by the pcap and pcapng readers
+type PacketReader interface {
+	// Next returns the next packet, or io.EOF at the end of the file
+	Next() (Packet, error)
+}
+
+// Open detects the capture format (pcap or pcapng) and returns a reader
+func Open(r io.Reader) (PacketReader, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(4)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read capture header: %w", err)
+	}
+	if binary.LittleEndian.Uint32(magic) == blockSectionHeader {
+		return NewNgReader(br)
+	}
+	return NewReader(br)
+}
+
+// ngInterface is an interface description from a pcapng section
+type ngInterface struct {
+	linkType uint32
+	// units per second of the interface's timestamps
+	resolution uint64
+}
+
+// NgReader reads packets from a pcapng stream. Each interface keeps its
+// own link type, so packets of one file may carry different link types.
+type NgReader struct {
+	r          *bufio.Reader
+	order      binary.ByteOrder
+	interfaces []ngInterface
+}
+
+// NewNgReader reads the first section header and returns a packet reader
+func NewNgReader(r io.Reader) (*NgReader, error) {
+	nr := &NgReader{r: bufio.NewReader(r)}
+
+	blockType, _, err := nr.readBlock()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pcapng header: %w", err)
+	}
+	if blockType != blockSectionHeader {
+		return nil, fmt.Errorf("not a pcapng file (first block %#x)", blockType)
+	}
+	return nr, nil
+}
+
+// Next returns the next packet, or io.EOF at the end of the file
+func (r *NgReader) Next() (Packet, error) {
+	for {
+		blockType, body, err := r.readBlock()
+		if err != nil {
+			return Packet{}, err
+		}
+
+		switch blockType {
+		case blockSectionHeader:
+			// A new section resets interfaces (and possibly byte order)
+			r.interfaces = r.interfaces[:0]
+		case blockInterface:
+			if err := r.addInterface(body); err != nil {
+				return Packet{}, err
+			}
+		case blockEnhancedPacket, blockPacketObsolete:
+			return r.packet(blockType, body)
+		case blockSimplePacket:
+			if len(body) < 4 || len(r.interfaces) == 0 {
+				return Packet{}, fmt.Errorf("malformed pcapng simple packet block")
+			}
+			n := min(int(r.order.Uint32(body[0:4])), len(body)-4)
+			return Packet{LinkType: r.interfaces[0].linkType, Data: body[4 : 4+n]}, nil
+		}
+		// Statistics, name resolution and custom blocks are skipped
+	}
+}
+
+// readBlock returns the type and body (without the trailing length) of
+// the next block; a section header also sets the byte order
+func (r *NgReader) readBlock() (uint32, []byte, error) {
+	var header [8]byte
+	if _, err := io.ReadFull(r.r, header[:]); err != nil {
+		if err == io.EOF {
+			return 0, nil, io.EOF
+		}
+		return 0, nil, fmt.Errorf("truncated pcapng block header: %w", err)
+	}
+
+	blockType := binary.LittleEndian.Uint32(header[0:4])
+	if blockType == blockSectionHeader {
+		// The byte-order magic follows the length; peek it to read the length
+		bom, err := r.r.Peek(4)
+		if err != nil {
+			return 0, nil, fmt.Errorf("truncated pcapng section header: %w", err)
+		}
+		switch {
+		case binary.LittleEndian.Uint32(bom) == byteOrderMagic:
+			r.order = binary.LittleEndian
+		case binary.BigEndian.Uint32(bom) == byteOrderMagic:
+			r.order = binary.BigEndian
+		default:
+			return 0, nil, fmt.Errorf("invalid pcapng byte-order magic")
+		}
+	} else if r.order == nil {
+		return 0, nil, fmt.Errorf("pcapng block before section header")
+	} else {
+		blockType = r.order.Uint32(header[0:4])
+	}
+
+	total := r.order.Uint32(header[4:8])
+	if total < 12 || total%4 != 0 || total > maxSnapLen+64 {
+		return 0, nil, fmt.Errorf("invalid pcapng block length %d", total)
+	}
+
+	rest := make([]byte, total-8)
+	if _, err := io.ReadFull(r.r, rest); err != nil {
+		return 0, nil, fmt.Errorf("truncated pcapng block: %w", err)
+	}
+	return blockType, rest[:len(rest)-4], nil
+}
+
+// addInterface records an Interface Description Block
+func (r *NgReader) addInterface(body []byte) error {
+	if len(body) < 8 {
+		return fmt.Errorf("malformed pcapng interface block")
+	}
+	iface := ngInterface{
+		linkType:   uint32(r.order.Uint16(body[0:2])),
+		resolution: 1e6,
+	}
+
+	// Options: code(2) length(2) value padded to 4 bytes
+	for opts := body[8:]; len(opts) >= 4; {
+		code, n := r.order.Uint16(opts[0:2]), int(r.order.Uint16(opts[2:4]))
+		if code == 0 || 4+n > len(opts) {
+			break
+		}
+		// if_tsresol: power of ten, or of two with the high bit set
+		if code == 9 && n == 1 {
+			v := opts[4]
+			if v&0x80 != 0 {
+				iface.resolution = 1 << (v & 0x7F)
+			} else {
+				iface.resolution = uint64(math.Pow10(int(v)))
+			}
+		}
+		opts = opts[4+(n+3)&^3:]
+	}
+
+	r.interfaces = append(r.interfaces, iface)
+	return nil
+}
+
+// packet decodes an Enhanced (or obsolete) Packet Block
+func (r *NgReader) packet(blockType uint32, body []byte) (Packet, error) {
+	if len(body) < 20 {
+		return Packet{}, fmt.Errorf("malformed pcapng packet block")
+	}
+
+	// The obsolete block has a 16-bit interface ID and a drops counter in
+	// place of the 32-bit interface ID; both layouts match from byte 4
+	ifaceID := r.order.Uint32(body[0:4])
+	if blockType == blockPacketObsolete {
+		ifaceID = uint32(r.order.Uint16(body[0:2]))
+	}
+	if int(ifaceID) >= len(r.interfaces) {
+		return Packet{}, fmt.Errorf("pcapng packet for unknown interface %d", ifaceID)
+	}
+	iface := r.interfaces[ifaceID]
+
+	ts := uint64(r.order.Uint32(body[4:8]))<<32 | uint64(r.order.Uint32(body[8:12]))
+	capLen := int(r.order.Uint32(body[12:16]))
+	if capLen > len(body)-20 {
+		return Packet{}, fmt.Errorf("truncated pcapng packet: %d bytes", capLen)
+	}
+
+	sec := ts / iface.resolution
+	nsec := (ts % iface.resolution) * 1e9 / iface.resolution
+	return Packet{
+		Time:     time.Unix(int64(sec), int64(nsec)).UTC(),
+		LinkType: iface.linkType,
+		Data:     body[20 : 20+capLen],
+	}, nil
+}
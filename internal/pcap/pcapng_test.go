@@ -0,0 +1,131 @@
+package pcap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+	"time"
+)
+
+// ngFile builds pcapng blocks in one byte order
+type ngFile struct {
+	order binary.AppendByteOrder
+	buf   bytes.Buffer
+}
+
+func (f *ngFile) block(blockType uint32, body []byte) {
+	for len(body)%4 != 0 {
+		body = append(body, 0)
+	}
+	total := uint32(12 + len(body))
+	b := f.order.AppendUint32(nil, blockType)
+	b = f.order.AppendUint32(b, total)
+	b = append(b, body...)
+	f.buf.Write(f.order.AppendUint32(b, total))
+}
+
+func (f *ngFile) section() {
+	body := f.order.AppendUint32(nil, byteOrderMagic)
+	body = f.order.AppendUint16(body, 1)
+	body = f.order.AppendUint16(body, 0)
+	f.block(blockSectionHeader, binary.LittleEndian.AppendUint64(body, ^uint64(0)))
+}
+
+// iface adds an interface; tsresol 0 leaves the default microseconds
+func (f *ngFile) iface(linkType uint16, tsresol byte) {
+	body := f.order.AppendUint16(nil, linkType)
+	body = append(body, 0, 0, 0, 0, 0, 0)
+	if tsresol != 0 {
+		body = f.order.AppendUint16(body, 9)
+		body = f.order.AppendUint16(body, 1)
+		body = append(body, tsresol, 0, 0, 0)
+		body = append(body, 0, 0, 0, 0) // opt_endofopt
+	}
+	f.block(blockInterface, body)
+}
+
+func (f *ngFile) packet(ifaceID uint32, ts uint64, data []byte) {
+	body := f.order.AppendUint32(nil, ifaceID)
+	body = f.order.AppendUint32(body, uint32(ts>>32))
+	body = f.order.AppendUint32(body, uint32(ts))
+	body = f.order.AppendUint32(body, uint32(len(data)))
+	body = f.order.AppendUint32(body, uint32(len(data)))
+	f.block(blockEnhancedPacket, append(body, data...))
+}
+
+func TestNgReader(t *testing.T) {
+	for _, order := range []binary.AppendByteOrder{binary.LittleEndian, binary.BigEndian} {
+		t.Run(order.String(), func(t *testing.T) {
+			f := &ngFile{order: order}
+			f.section()
+			f.iface(LinkTypeRadiotap, 0)
+			f.iface(LinkTypeBluetoothLE, 9)
+			f.iface(LinkTypeIEEE80211, 0x80|10) // 1/1024 s
+			f.packet(0, 1_700_000_000_250_000, []byte{1})
+			f.block(5, []byte{0, 0, 0, 0}) // interface statistics, skipped
+			f.packet(1, 1_700_000_000_000_000_123, []byte{2, 2})
+			f.packet(2, 1_700_000_000<<10|512, []byte{3, 3, 3})
+			f.block(blockSimplePacket, append(order.AppendUint32(nil, 2), 4, 4))
+
+			// A new section resets the interfaces
+			f.section()
+			f.iface(LinkTypeBluetoothPHDR, 0)
+			f.packet(0, 1_700_000_001_000_000, []byte{5})
+
+			want := []Packet{
+				{time.Unix(1_700_000_000, 250_000_000), LinkTypeRadiotap, []byte{1}},
+				{time.Unix(1_700_000_000, 123), LinkTypeBluetoothLE, []byte{2, 2}},
+				{time.Unix(1_700_000_000, 500_000_000), LinkTypeIEEE80211, []byte{3, 3, 3}},
+				{time.Time{}, LinkTypeRadiotap, []byte{4, 4}},
+				{time.Unix(1_700_000_001, 0), LinkTypeBluetoothPHDR, []byte{5}},
+			}
+
+			r, err := Open(bytes.NewReader(f.buf.Bytes()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, w := range want {
+				pkt, err := r.Next()
+				if err != nil {
+					t.Fatalf("packet %d: %v", i, err)
+				}
+				if !pkt.Time.Equal(w.Time) || pkt.LinkType != w.LinkType || !bytes.Equal(pkt.Data, w.Data) {
+					t.Errorf("packet %d = %v %d %x, want %v %d %x", i, pkt.Time, pkt.LinkType, pkt.Data, w.Time, w.LinkType, w.Data)
+				}
+			}
+			if _, err := r.Next(); err != io.EOF {
+				t.Errorf("after last packet: %v, want io.EOF", err)
+			}
+		})
+	}
+}
+
+func TestNgReaderErrors(t *testing.T) {
+	f := &ngFile{order: binary.LittleEndian}
+	f.section()
+	f.packet(0, 0, []byte{1})
+	r, err := NewNgReader(bytes.NewReader(f.buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Next(); err == nil {
+		t.Error("packet for an undeclared interface accepted")
+	}
+
+	f = &ngFile{order: binary.LittleEndian}
+	f.section()
+	f.iface(LinkTypeIEEE80211, 0)
+	f.packet(0, 0, []byte{1, 2, 3, 4})
+	data := f.buf.Bytes()
+	r, _ = NewNgReader(bytes.NewReader(data[:len(data)-2]))
+	if _, err := r.Next(); err == nil || err == io.EOF {
+		t.Errorf("truncated block: %v, want an error", err)
+	}
+
+	bad := append([]byte{}, data...)
+	binary.LittleEndian.PutUint32(bad[8:12], 0x12345678)
+	if _, err := Open(bytes.NewReader(bad)); err == nil {
+		t.Error("bad byte-order magic accepted")
+	}
+}
@@ -0,0 +1,82 @@
+// Package queue connects the services to Redpanda. Writer and Reader are
+// the subsets of kafka-go used by the gateway and ingestion, so an
+// in-memory topic can stand in for the broker in benchmarks and demos.
+package queue
+
+import (
+	"context"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"silentraven/pkg/config"
+)
+
+// Writer publishes messages to a topic
+type Writer interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+// Reader consumes messages from a topic as part of a consumer group
+type Reader interface {
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+	CommitMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+// Consumer groups
+const (
+	GroupIngestion   = "silentraven-ingestion"
+	GroupNodeMonitor = "silentraven-node-monitor"
+)
+
+// NewDetectionWriter returns the gateway's detection topic writer
+func NewDetectionWriter(cfg *config.Config) *kafka.Writer {
+	return &kafka.Writer{
+		Addr:         kafka.TCP(cfg.KafkaBrokers),
+		Topic:        cfg.KafkaTopic,
+		Balancer:     &kafka.LeastBytes{},
+		BatchSize:    10,
+		BatchTimeout: 10 * time.Millisecond,
+		RequiredAcks: kafka.RequireOne,
+		Async:        false,
+	}
+}
+
+// NewHeartbeatWriter returns the node heartbeat topic writer. Heartbeats
+// go to their own topic, keyed by node, so ingestion can track liveness.
+func NewHeartbeatWriter(cfg *config.Config) *kafka.Writer {
+	return &kafka.Writer{
+		Addr:         kafka.TCP(cfg.KafkaBrokers),
+		Topic:        cfg.KafkaHeartbeatTopic,
+		Balancer:     &kafka.Hash{},
+		BatchTimeout: 10 * time.Millisecond,
+		RequiredAcks: kafka.RequireOne,
+	}
+}
+
+// NewDetectionReader returns the ingestion detection consumer
+func NewDetectionReader(cfg *config.Config) *kafka.Reader {
+	return kafka.NewReader(kafka.ReaderConfig{
+		Brokers:        []string{cfg.KafkaBrokers},
+		Topic:          cfg.KafkaTopic,
+		GroupID:        GroupIngestion,
+		MinBytes:       10e3, // 10KB
+		MaxBytes:       10e6, // 10MB
+		CommitInterval: time.Second,
+		StartOffset:    kafka.LastOffset,
+	})
+}
+
+// NewHeartbeatReader returns the node heartbeat consumer
+func NewHeartbeatReader(cfg *config.Config) *kafka.Reader {
+	return kafka.NewReader(kafka.ReaderConfig{
+		Brokers:        []string{cfg.KafkaBrokers},
+		Topic:          cfg.KafkaHeartbeatTopic,
+		GroupID:        GroupNodeMonitor,
+		MaxBytes:       1e6, // 1MB
+		CommitInterval: time.Second,
+		StartOffset:    kafka.LastOffset,
+	})
+}
@@ -0,0 +1,73 @@
+// Package reload applies configuration changes when a service receives
+// SIGHUP
+package reload
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"silentraven/internal/logging"
+	"silentraven/pkg/config"
+)
+
+var logger = logging.For("config")
+
+// Hooks are a service's callbacks from Watch; either may be nil
+type Hooks struct {
+	// Apply is called with each new configuration that is applied, so
+	// the service can pick up its own reloadable settings
+	Apply func(next *config.Config)
+	// Audit is called for every reload that changes any setting, with
+	// the reason when it was rejected and nothing was applied
+	Audit func(prev, next *config.Config, err error)
+}
+
+// Watch reloads svc's configuration on each SIGHUP until ctx is done.
+// Logging settings apply at once and hooks.Apply picks up the rest. A
+// configuration that changes settings needing a restart is rejected as a
+// whole and nothing in it is applied.
+func Watch(ctx context.Context, svc config.Service, cfg *config.Config, hooks Hooks) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	current := cfg
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+		}
+
+		next, err := config.Load(svc)
+		if err != nil {
+			logger.Error("Config reload failed; keeping the running configuration", logging.Err(err))
+			continue
+		}
+		changed, restart := current.Changes(next)
+		if len(changed) == 0 && len(restart) == 0 {
+			logger.Info("Configuration unchanged")
+			continue
+		}
+
+		err = current.CheckReload(next)
+		if err == nil {
+			err = logging.Configure(next, svc.Name)
+		}
+		if hooks.Audit != nil {
+			hooks.Audit(current, next, err)
+		}
+		if err != nil {
+			logger.Error("Config reload rejected; keeping the running configuration", logging.Err(err))
+			continue
+		}
+
+		if hooks.Apply != nil {
+			hooks.Apply(next)
+		}
+		current = next
+		logger.Info("Configuration reloaded", "settings", changed)
+	}
+}
@@ -0,0 +1,150 @@
+package remoteid
+
+import (
+	"sync"
+	"time"
+
+	"silentraven/internal/models"
+)
+
+// Assembler merges the messages each transmitter broadcasts separately
+// (Basic ID, System, Location...) into detection packets. A packet is
+// emitted for every new Location once the transmitter's ID is known.
+type Assembler struct {
+	// NodeID is stamped on every packet
+	NodeID string
+	// TTL drops transmitter state not refreshed for this long
+	TTL time.Duration
+
+	mu      sync.Mutex
+	senders map[string]*senderState
+}
+
+type senderState struct {
+	serial   string
+	registry string
+	basic    BasicID
+	system   *System
+	lastLoc  Location
+	hasLoc   bool
+	lastSeen time.Time
+}
+
+// NewAssembler creates an assembler for one node
+func NewAssembler(nodeID string) *Assembler {
+	return &Assembler{
+		NodeID:  nodeID,
+		TTL:     5 * time.Minute,
+		senders: make(map[string]*senderState),
+	}
+}
+
+// Add feeds the messages received from sender (usually its MAC address)
+// at time at and returns any packets that are now complete.
+func (a *Assembler) Add(sender string, at time.Time, msgs []Message) []models.IncomingPacket {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.expire(at)
+
+	s := a.senders[sender]
+	if s == nil {
+		s = &senderState{}
+		a.senders[sender] = s
+	}
+	s.lastSeen = at
+
+	// Identity and system data first so a pack's Location uses them
+	var locs []*Location
+	for _, m := range msgs {
+		switch {
+		case m.BasicID != nil:
+			s.basic = *m.BasicID
+			switch m.BasicID.IDType {
+			case IDTypeNone:
+			case IDTypeSerialNumber:
+				s.serial = m.BasicID.ID
+			default:
+				s.registry = m.BasicID.ID
+			}
+		case m.System != nil:
+			s.system = m.System
+		case m.Location != nil:
+			locs = append(locs, m.Location)
+		}
+	}
+
+	var out []models.IncomingPacket
+	for _, loc := range locs {
+		// The same Location is rebroadcast several times a second
+		if s.hasLoc && *loc == s.lastLoc {
+			continue
+		}
+		s.lastLoc, s.hasLoc = *loc, true
+
+		if p, ok := a.packet(s, loc, at); ok {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// packet builds a detection from the sender state and a Location
+func (a *Assembler) packet(s *senderState, loc *Location, at time.Time) (models.IncomingPacket, bool) {
+	sn, uasID := s.serial, s.registry
+	if sn == "" {
+		sn = uasID
+	}
+	if uasID == "" {
+		uasID = sn
+	}
+	if sn == "" || (loc.Latitude == 0 && loc.Longitude == 0) {
+		return models.IncomingPacket{}, false
+	}
+
+	p := models.IncomingPacket{
+		SN:                 sn,
+		UASID:              uasID,
+		DroneType:          s.basic.DroneType(),
+		Latitude:           loc.Latitude,
+		Longitude:          loc.Longitude,
+		Direction:          loc.Direction,
+		HeightType:         loc.HeightType,
+		HorizontalAccuracy: loc.HorizAccuracy,
+		VerticalAccuracy:   loc.VertAccuracy,
+		BaroAccuracy:       loc.BaroAccuracy,
+		NodeID:             a.NodeID,
+		Timestamp:          at.UTC().Format(time.RFC3339Nano),
+	}
+	if loc.SpeedHorizontal != UnknownSpeed {
+		p.SpeedHorizontal = loc.SpeedHorizontal
+	}
+	if loc.SpeedVertical != UnknownVerticalSpeed {
+		p.SpeedVertical = loc.SpeedVertical
+	}
+	if loc.Height != UnknownAltitude {
+		p.Height = loc.Height
+	}
+	if loc.GeodeticAlt != UnknownAltitude {
+		alt := loc.GeodeticAlt
+		p.GeodeticAltitude = &alt
+	}
+	if loc.PressureAlt != UnknownAltitude {
+		alt := loc.PressureAlt
+		p.PressureAltitude = &alt
+	}
+	if s.system != nil {
+		p.OperatorLatitude = s.system.OperatorLatitude
+		p.OperatorLongitude = s.system.OperatorLongitude
+	}
+	return p, true
+}
+
+// expire drops transmitters not heard from within TTL
+func (a *Assembler) expire(now time.Time) {
+	for k, s := range a.senders {
+		if now.Sub(s.lastSeen) > a.TTL {
+			delete(a.senders, k)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package remoteid
+
+import (
+	"encoding/binary"
+	"math"
+	"net"
+	"time"
+)
+
+// Encoding is the inverse of Decode, used to synthesise broadcasts for
+// simulators and capture fixtures. Values are clamped to their fields.
+
+// protocolVersion is the F3411-22a message version
+const protocolVersion = 2
+
+// EncodeBasicID encodes a Basic ID message
+func EncodeBasicID(b BasicID) []byte {
+	m := header(TypeBasicID)
+	m[1] = byte(b.IDType<<4) | byte(b.UAType&0x0F)
+	copy(m[2:22], b.ID)
+	return m
+}
+
+// EncodeLocation encodes a Location/Vector message
+func EncodeLocation(l Location) []byte {
+	m := header(TypeLocation)
+
+	flags := byte(l.Status<<4) | byte(l.HeightType&1)<<2
+	switch dir := l.Direction % 360; {
+	case l.Direction == UnknownDirection || l.Direction < 0 || l.Direction > 360:
+		flags |= 0x02
+		m[2] = 181
+	case dir >= 180:
+		flags |= 0x02
+		m[2] = byte(dir - 180)
+	default:
+		m[2] = byte(dir)
+	}
+
+	switch speed := l.SpeedHorizontal; {
+	case speed == UnknownSpeed || speed < 0:
+		m[3] = 255
+	case speed <= 254*0.25:
+		m[3] = byte(math.Round(speed / 0.25))
+	default:
+		flags |= 0x01
+		m[3] = byte(math.Min(254, math.Round((speed-255*0.25)/0.75)))
+	}
+	m[1] = flags
+
+	m[4] = byte(int8(math.Max(-126, math.Min(126, math.Round(l.SpeedVertical/0.5)))))
+	putLatLon(m[5:9], l.Latitude)
+	putLatLon(m[9:13], l.Longitude)
+	putAltitude(m[13:15], l.PressureAlt)
+	putAltitude(m[15:17], l.GeodeticAlt)
+	putAltitude(m[17:19], l.Height)
+	m[19] = byte(l.VertAccuracy<<4) | byte(l.HorizAccuracy&0x0F)
+	m[20] = byte(l.BaroAccuracy<<4) | byte(l.SpeedAccuracy&0x0F)
+	binary.LittleEndian.PutUint16(m[21:23], uint16(l.TenthsOfHour))
+	return m
+}
+
+// EncodeSystem encodes a System message
+func EncodeSystem(s System) []byte {
+	m := header(TypeSystem)
+	m[1] = byte(s.OperatorLocationType & 0x03)
+	putLatLon(m[2:6], s.OperatorLatitude)
+	putLatLon(m[6:10], s.OperatorLongitude)
+	binary.LittleEndian.PutUint16(m[10:12], uint16(s.AreaCount))
+	m[12] = byte(s.AreaRadius / 10)
+	putAltitude(m[13:15], UnknownAltitude)
+	putAltitude(m[15:17], UnknownAltitude)
+	putAltitude(m[18:20], s.OperatorAltitude)
+	if !s.Timestamp.IsZero() {
+		binary.LittleEndian.PutUint32(m[20:24], uint32(s.Timestamp.Sub(systemEpoch).Seconds()))
+	}
+	return m
+}
+
+// EncodeOperatorID encodes an Operator ID message
+func EncodeOperatorID(o OperatorID) []byte {
+	m := header(TypeOperatorID)
+	m[1] = byte(o.IDType)
+	copy(m[2:22], o.ID)
+	return m
+}
+
+// EncodePack wraps up to nine encoded messages in a message pack
+func EncodePack(msgs ...[]byte) []byte {
+	if len(msgs) > 9 {
+		msgs = msgs[:9]
+	}
+	m := make([]byte, 3, 3+len(msgs)*MessageSize)
+	m[0] = byte(TypePack<<4) | protocolVersion
+	m[1] = MessageSize
+	m[2] = byte(len(msgs))
+	for _, msg := range msgs {
+		m = append(m, msg[:MessageSize]...)
+	}
+	return m
+}
+
+// TenthsOfHour returns the Location timestamp field for t
+func TenthsOfHour(t time.Time) int {
+	return (t.Minute()*60+t.Second())*10 + t.Nanosecond()/1e8
+}
+
+func header(msgType int) []byte {
+	m := make([]byte, MessageSize)
+	m[0] = byte(msgType<<4) | protocolVersion
+	return m
+}
+
+func putLatLon(b []byte, deg float64) {
+	binary.LittleEndian.PutUint32(b, uint32(int32(math.Round(deg*1e7))))
+}
+
+func putAltitude(b []byte, meters float64) {
+	v := math.Round((meters + 1000) / 0.5)
+	binary.LittleEndian.PutUint16(b, uint16(math.Max(0, math.Min(65535, v))))
+}
+
+// EncodeBeacon wraps a payload in an 802.11 beacon from sender, carried
+// in the ASD-STAN vendor-specific element. The frame has no FCS.
+func EncodeBeacon(sender net.HardwareAddr, counter byte, payload []byte) []byte {
+	f := wifiHeader(0x80, broadcastMAC, sender, sender)
+	f = append(f, make([]byte, 8)...) // timestamp
+	f = append(f, 0x64, 0x00)         // beacon interval, 100 TU
+	f = append(f, 0x01, 0x00)         // capabilities: ESS
+	f = append(f, 0, 0)               // empty SSID
+
+	ie := append(append([]byte{}, oui...), appCode, counter)
+	ie = append(ie, payload...)
+	f = append(f, 221, byte(len(ie)))
+	return append(f, ie...)
+}
+
+// EncodeNANAction wraps a payload in a Wi-Fi NAN Service Discovery Frame
+// from sender, published as the Remote ID service. The frame has no FCS.
+func EncodeNANAction(sender net.HardwareAddr, counter byte, payload []byte) []byte {
+	f := wifiHeader(0xD0, nanNetworkMAC, sender, nanClusterMAC)
+	f = append(f, 0x04, 0x09)
+	f = append(f, wfaOUI...)
+	f = append(f, nanOUIType)
+
+	// Service Descriptor Attribute: publish with service info
+	attr := append([]byte{}, nanServiceID...)
+	attr = append(attr, 1, 0, 0x10, byte(1+len(payload)), counter)
+	attr = append(attr, payload...)
+	f = append(f, 0x03, 0, 0)
+	binary.LittleEndian.PutUint16(f[len(f)-2:], uint16(len(attr)))
+	return append(f, attr...)
+}
+
+// EncodeBLEAdvertising wraps one message in a BLE ADV_NONCONN_IND
+// link-layer packet from sender. Legacy advertising has room for a single
+// message, not a pack. The CRC is left zero.
+func EncodeBLEAdvertising(sender net.HardwareAddr, counter byte, msg []byte) []byte {
+	ad := []byte{byte(5 + MessageSize), 0x16}
+	ad = append(ad, serviceUUID...)
+	ad = append(ad, appCode, counter)
+	ad = append(ad, msg[:MessageSize]...)
+
+	ll := binary.LittleEndian.AppendUint32(nil, bleAdvAccessAddress)
+	ll = append(ll, 0x02|0x40, byte(6+len(ad))) // ADV_NONCONN_IND, random address
+	for i := len(sender) - 1; i >= 0; i-- {
+		ll = append(ll, sender[i])
+	}
+	ll = append(ll, ad...)
+	return append(ll, 0, 0, 0)
+}
+
+var (
+	broadcastMAC  = net.HardwareAddr{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}
+	nanNetworkMAC = net.HardwareAddr{0x51, 0x6F, 0x9A, 0x01, 0x00, 0x00}
+	nanClusterMAC = net.HardwareAddr{0x50, 0x6F, 0x9A, 0x01, 0x00, 0x00}
+)
+
+// wifiHeader is a management frame header with the given frame control
+func wifiHeader(fc byte, addr1, addr2, addr3 net.HardwareAddr) []byte {
+	h := []byte{fc, 0, 0, 0}
+	h = append(h, addr1...)
+	h = append(h, addr2...)
+	h = append(h, addr3...)
+	return append(h, 0, 0)
+}
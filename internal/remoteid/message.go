@@ -0,0 +1,258 @@
+// Package remoteid decodes ASTM F3411 (OpenDroneID) broadcast Remote ID
+// messages and assembles them into detection packets.
+package remoteid
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"time"
+
+	"silentraven/internal/models"
+)
+
+// MessageSize is the fixed length of every Remote ID message
+const MessageSize = 25
+
+// Message types (high nibble of the header byte)
+const (
+	TypeBasicID    = 0x0
+	TypeLocation   = 0x1
+	TypeAuth       = 0x2
+	TypeSelfID     = 0x3
+	TypeSystem     = 0x4
+	TypeOperatorID = 0x5
+	TypePack       = 0xF
+)
+
+// Basic ID types
+const (
+	IDTypeNone         = 0
+	IDTypeSerialNumber = 1
+	IDTypeCAARegistry  = 2
+	IDTypeUTMAssigned  = 3
+	IDTypeSpecific     = 4
+)
+
+// uaTypes names the Basic ID UA type enumeration
+var uaTypes = [...]string{
+	"None", "Aeroplane", "Multirotor", "Gyroplane", "Hybrid Lift", "Ornithopter",
+	"Glider", "Kite", "Free Balloon", "Captive Balloon", "Airship",
+	"Parachute", "Rocket", "Tethered", "Ground Obstacle", "Other",
+}
+
+// Unknown-value sentinels after scaling
+const (
+	UnknownDirection     = models.DirectionUnknown
+	UnknownSpeed         = 255
+	UnknownVerticalSpeed = 63
+	UnknownAltitude      = -1000
+)
+
+// BasicID identifies the UA
+type BasicID struct {
+	IDType int
+	UAType int
+	ID     string
+}
+
+// DroneType returns the UA type name
+func (b BasicID) DroneType() string {
+	if b.UAType >= 0 && b.UAType < len(uaTypes) {
+		return uaTypes[b.UAType]
+	}
+	return "Other"
+}
+
+// Location is the Location/Vector message
+type Location struct {
+	Status          int
+	HeightType      int
+	Direction       int     // degrees from north, UnknownDirection if unknown
+	SpeedHorizontal float64 // m/s, UnknownSpeed if unknown
+	SpeedVertical   float64 // m/s, UnknownVerticalSpeed if unknown
+	Latitude        float64
+	Longitude       float64
+	PressureAlt     float64 // m, UnknownAltitude if unknown
+	GeodeticAlt     float64 // m, UnknownAltitude if unknown
+	Height          float64 // m, UnknownAltitude if unknown
+	HorizAccuracy   int
+	VertAccuracy    int
+	BaroAccuracy    int
+	SpeedAccuracy   int
+	// TenthsOfHour is the timestamp in 1/10 s after the full hour (0xFFFF = unknown)
+	TenthsOfHour int
+}
+
+// SelfID is a free-text description of the operation
+type SelfID struct {
+	DescType    int
+	Description string
+}
+
+// System carries operator location and operating area
+type System struct {
+	OperatorLocationType int
+	OperatorLatitude     float64
+	OperatorLongitude    float64
+	AreaCount            int
+	AreaRadius           int // m
+	OperatorAltitude     float64
+	Timestamp            time.Time
+}
+
+// OperatorID identifies the operator
+type OperatorID struct {
+	IDType int
+	ID     string
+}
+
+// Message is one decoded Remote ID message; exactly one payload field is set
+type Message struct {
+	Type       int
+	Version    int
+	BasicID    *BasicID
+	Location   *Location
+	SelfID     *SelfID
+	System     *System
+	OperatorID *OperatorID
+}
+
+// systemEpoch is the reference for System message timestamps
+var systemEpoch = time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// Decode parses one message or a message pack into its messages.
+// Authentication messages are recognised but not decoded.
+func Decode(b []byte) ([]Message, error) {
+	if len(b) < MessageSize {
+		return nil, fmt.Errorf("remote id message too short: %d bytes", len(b))
+	}
+
+	if int(b[0]>>4) == TypePack {
+		return decodePack(b)
+	}
+
+	m, err := decodeOne(b[:MessageSize])
+	if err != nil {
+		return nil, err
+	}
+	return []Message{m}, nil
+}
+
+func decodePack(b []byte) ([]Message, error) {
+	size, count := int(b[1]), int(b[2])
+	if size != MessageSize {
+		return nil, fmt.Errorf("unsupported message pack entry size %d", size)
+	}
+	if count > 9 || len(b) < 3+count*size {
+		return nil, fmt.Errorf("message pack truncated: %d messages in %d bytes", count, len(b))
+	}
+
+	msgs := make([]Message, 0, count)
+	for i := 0; i < count; i++ {
+		entry := b[3+i*size : 3+(i+1)*size]
+		if int(entry[0]>>4) == TypePack {
+			return nil, fmt.Errorf("nested message pack")
+		}
+		m, err := decodeOne(entry)
+		if err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, m)
+	}
+	return msgs, nil
+}
+
+func decodeOne(b []byte) (Message, error) {
+	m := Message{Type: int(b[0] >> 4), Version: int(b[0] & 0x0F)}
+
+	switch m.Type {
+	case TypeBasicID:
+		m.BasicID = &BasicID{
+			IDType: int(b[1] >> 4),
+			UAType: int(b[1] & 0x0F),
+			ID:     cString(b[2:22]),
+		}
+	case TypeLocation:
+		m.Location = decodeLocation(b)
+	case TypeAuth:
+		// Authentication pages are not needed for detections
+	case TypeSelfID:
+		m.SelfID = &SelfID{DescType: int(b[1]), Description: cString(b[2:25])}
+	case TypeSystem:
+		m.System = &System{
+			OperatorLocationType: int(b[1] & 0x03),
+			OperatorLatitude:     latLon(b[2:6]),
+			OperatorLongitude:    latLon(b[6:10]),
+			AreaCount:            int(binary.LittleEndian.Uint16(b[10:12])),
+			AreaRadius:           int(b[12]) * 10,
+			OperatorAltitude:     altitude(b[18:20]),
+		}
+		if ts := binary.LittleEndian.Uint32(b[20:24]); ts != 0 {
+			m.System.Timestamp = systemEpoch.Add(time.Duration(ts) * time.Second)
+		}
+	case TypeOperatorID:
+		m.OperatorID = &OperatorID{IDType: int(b[1]), ID: cString(b[2:22])}
+	default:
+		return m, fmt.Errorf("unknown remote id message type %d", m.Type)
+	}
+	return m, nil
+}
+
+func decodeLocation(b []byte) *Location {
+	flags := b[1]
+	loc := &Location{
+		Status:     int(flags >> 4),
+		HeightType: int(flags>>2) & 1,
+	}
+
+	// Direction: 0-179 plus 180 when the E/W segment bit is set
+	switch dir := int(b[2]); {
+	case dir >= 180:
+		loc.Direction = UnknownDirection
+	case flags&0x02 != 0:
+		loc.Direction = dir + 180
+	default:
+		loc.Direction = dir
+	}
+
+	// Horizontal speed: 0.25 m/s steps, or 0.75 m/s steps above 63.75 m/s
+	switch speed := float64(b[3]); {
+	case b[3] == 255:
+		loc.SpeedHorizontal = UnknownSpeed
+	case flags&0x01 != 0:
+		loc.SpeedHorizontal = speed*0.75 + 255*0.25
+	default:
+		loc.SpeedHorizontal = speed * 0.25
+	}
+
+	loc.SpeedVertical = float64(int8(b[4])) * 0.5
+	loc.Latitude = latLon(b[5:9])
+	loc.Longitude = latLon(b[9:13])
+	loc.PressureAlt = altitude(b[13:15])
+	loc.GeodeticAlt = altitude(b[15:17])
+	loc.Height = altitude(b[17:19])
+	loc.VertAccuracy = int(b[19] >> 4)
+	loc.HorizAccuracy = int(b[19] & 0x0F)
+	loc.BaroAccuracy = int(b[20] >> 4)
+	loc.SpeedAccuracy = int(b[20] & 0x0F)
+	loc.TenthsOfHour = int(binary.LittleEndian.Uint16(b[21:23]))
+	return loc
+}
+
+// latLon decodes a little-endian int32 in 1e-7 degrees
+func latLon(b []byte) float64 {
+	return float64(int32(binary.LittleEndian.Uint32(b))) * 1e-7
+}
+
+// altitude decodes a little-endian uint16 in 0.5 m steps offset by -1000 m
+func altitude(b []byte) float64 {
+	return float64(binary.LittleEndian.Uint16(b))*0.5 - 1000
+}
+
+func cString(b []byte) string {
+	if i := strings.IndexByte(string(b), 0); i >= 0 {
+		b = b[:i]
+	}
+	return strings.TrimSpace(string(b))
+}
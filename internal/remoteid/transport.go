@@ -0,0 +1,286 @@
+package remoteid
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+
+	"silentraven/internal/pcap"
+)
+
+// Broadcast transports
+const (
+	TransportBLE        = "ble"
+	TransportWiFiBeacon = "wifi-beacon"
+	TransportWiFiNAN    = "wifi-nan"
+)
+
+// appCode identifies OpenDroneID in BLE service data and Wi-Fi vendor IEs
+const appCode = 0x0D
+
+// serviceUUID is the ASTM 16-bit BLE service UUID (little endian on air)
+var serviceUUID = []byte{0xFA, 0xFF}
+
+// oui is the ASD-STAN OUI used in Wi-Fi vendor-specific elements
+var oui = []byte{0xFA, 0x0B, 0xBC}
+
+// wfaOUI and nanOUIType mark a Wi-Fi Aware (NAN) vendor action frame
+var wfaOUI = []byte{0x50, 0x6F, 0x9A}
+
+const nanOUIType = 0x13
+
+// nanServiceID is the NAN service ID of Remote ID: the first 48 bits of
+// the SHA-256 of the service name
+var nanServiceID = func() []byte {
+	sum := sha256.Sum256([]byte("org.opendroneid.remoteid"))
+	return sum[:6]
+}()
+
+// bleAdvAccessAddress is the fixed access address of advertising packets
+const bleAdvAccessAddress = 0x8E89BED6
+
+// Frame is a Remote ID payload pulled out of a captured packet
+type Frame struct {
+	Sender    string // transmitter MAC / BLE advertiser address
+	RSSI      int    // dBm, 0 if unknown
+	Transport string
+	Payload   []byte // one message or a message pack
+}
+
+// Extract returns the Remote ID payload carried by a captured packet of
+// the given link type, if any.
+func Extract(linkType uint32, data []byte) (Frame, bool) {
+	switch linkType {
+	case pcap.LinkTypeRadiotap:
+		rssi, fcs, frame, ok := parseRadiotap(data)
+		if !ok {
+			return Frame{}, false
+		}
+		if fcs && len(frame) > 4 {
+			frame = frame[:len(frame)-4]
+		}
+		f, ok := fromWiFi(frame)
+		f.RSSI = rssi
+		return f, ok
+	case pcap.LinkTypeIEEE80211:
+		return fromWiFi(data)
+	case pcap.LinkTypeBluetoothLE:
+		return fromBLE(data)
+	case pcap.LinkTypeBluetoothPHDR:
+		if len(data) < 10 {
+			return Frame{}, false
+		}
+		f, ok := fromBLE(data[10:])
+		if binary.LittleEndian.Uint16(data[8:10])&0x0002 != 0 {
+			f.RSSI = int(int8(data[1]))
+		}
+		return f, ok
+	}
+	return Frame{}, false
+}
+
+// FromAdvertising finds Remote ID service data in BLE advertising data
+func FromAdvertising(ad []byte) ([]byte, bool) {
+	for len(ad) >= 2 {
+		n := int(ad[0])
+		if n == 0 || n+1 > len(ad) {
+			return nil, false
+		}
+		field := ad[1 : n+1]
+		ad = ad[n+1:]
+
+		// Service Data - 16-bit UUID: uuid(2) app code(1) counter(1) message
+		if field[0] == 0x16 && len(field) >= 5+MessageSize &&
+			bytes.Equal(field[1:3], serviceUUID) && field[3] == appCode {
+			return field[5:], true
+		}
+	}
+	return nil, false
+}
+
+// FromBeaconIEs finds the Remote ID vendor-specific element in the
+// information elements of a Wi-Fi beacon
+func FromBeaconIEs(ies []byte) ([]byte, bool) {
+	for len(ies) >= 2 {
+		id, n := ies[0], int(ies[1])
+		if 2+n > len(ies) {
+			return nil, false
+		}
+		body := ies[2 : 2+n]
+		ies = ies[2+n:]
+
+		// Vendor Specific: OUI(3) type(1) counter(1) message pack
+		if id == 221 && len(body) >= 5+MessageSize && bytes.Equal(body[0:3], oui) && body[3] == appCode {
+			return body[5:], true
+		}
+	}
+	return nil, false
+}
+
+// FromNANAction finds the Remote ID service info in the body of a NAN
+// Service Discovery Frame (a public vendor-specific action frame)
+func FromNANAction(body []byte) ([]byte, bool) {
+	// Category 4 (public), action 9 (vendor specific), WFA OUI, NAN type
+	if len(body) < 6 || body[0] != 0x04 || body[1] != 0x09 ||
+		!bytes.Equal(body[2:5], wfaOUI) || body[5] != nanOUIType {
+		return nil, false
+	}
+
+	attrs := body[6:]
+	for len(attrs) >= 3 {
+		id, n := attrs[0], int(binary.LittleEndian.Uint16(attrs[1:3]))
+		if 3+n > len(attrs) {
+			return nil, false
+		}
+		attr := attrs[3 : 3+n]
+		attrs = attrs[3+n:]
+
+		// Service Descriptor Attribute: service ID(6) instance(1)
+		// requestor(1) control(1) then optional fields
+		if id != 0x03 || len(attr) < 9 || !bytes.Equal(attr[0:6], nanServiceID) {
+			continue
+		}
+		control, rest := attr[8], attr[9:]
+		if control&0x40 != 0 { // binding bitmap
+			if len(rest) < 2 {
+				return nil, false
+			}
+			rest = rest[2:]
+		}
+		for _, bit := range []byte{0x04, 0x08} { // matching filter, service response filter
+			if control&bit != 0 {
+				if len(rest) < 1 || 1+int(rest[0]) > len(rest) {
+					return nil, false
+				}
+				rest = rest[1+int(rest[0]):]
+			}
+		}
+		if control&0x10 == 0 || len(rest) < 1 {
+			return nil, false
+		}
+
+		// Service info: counter(1) then the message pack
+		info := rest[1:]
+		if int(rest[0]) < len(info) {
+			info = info[:rest[0]]
+		}
+		if len(info) < 1+MessageSize {
+			return nil, false
+		}
+		return info[1:], true
+	}
+	return nil, false
+}
+
+// fromWiFi handles an 802.11 beacon or NAN action frame
+func fromWiFi(frame []byte) (Frame, bool) {
+	if len(frame) < 24 {
+		return Frame{}, false
+	}
+	frameType, subtype := (frame[0]>>2)&0x03, frame[0]>>4
+	if frameType != 0 {
+		return Frame{}, false
+	}
+	sender := mac(frame[10:16], false)
+
+	switch subtype {
+	case 8:
+		// Management header (24) + beacon fixed parameters (12)
+		if len(frame) < 36 {
+			return Frame{}, false
+		}
+		if payload, ok := FromBeaconIEs(frame[36:]); ok {
+			return Frame{Sender: sender, Transport: TransportWiFiBeacon, Payload: payload}, true
+		}
+	case 13:
+		if payload, ok := FromNANAction(frame[24:]); ok {
+			return Frame{Sender: sender, Transport: TransportWiFiNAN, Payload: payload}, true
+		}
+	}
+	return Frame{}, false
+}
+
+// fromBLE handles a BLE link-layer legacy advertising packet
+func fromBLE(ll []byte) (Frame, bool) {
+	if len(ll) < 6+6 || binary.LittleEndian.Uint32(ll[0:4]) != bleAdvAccessAddress {
+		return Frame{}, false
+	}
+
+	// ADV_IND, ADV_NONCONN_IND and ADV_SCAN_IND carry AdvA + AdvData
+	switch ll[4] & 0x0F {
+	case 0, 2, 6:
+	default:
+		return Frame{}, false
+	}
+
+	n := int(ll[5])
+	if n < 6 || 6+n > len(ll) {
+		return Frame{}, false
+	}
+	pdu := ll[6 : 6+n]
+
+	payload, ok := FromAdvertising(pdu[6:])
+	if !ok {
+		return Frame{}, false
+	}
+	return Frame{Sender: mac(pdu[0:6], true), Transport: TransportBLE, Payload: payload}, true
+}
+
+// parseRadiotap returns the antenna signal, whether the frame carries an
+// FCS, and the 802.11 frame following the radiotap header
+func parseRadiotap(b []byte) (rssi int, fcs bool, frame []byte, ok bool) {
+	if len(b) < 8 || b[0] != 0 {
+		return 0, false, nil, false
+	}
+	length := int(binary.LittleEndian.Uint16(b[2:4]))
+	if length < 8 || length > len(b) {
+		return 0, false, nil, false
+	}
+
+	present := binary.LittleEndian.Uint32(b[4:8])
+	offset := 8
+	for p := present; p&(1<<31) != 0; {
+		if offset+4 > length {
+			return 0, false, nil, false
+		}
+		p = binary.LittleEndian.Uint32(b[offset : offset+4])
+		offset += 4
+	}
+
+	// Walk the leading fields up to the antenna signal
+	fields := []struct{ size, align int }{
+		{8, 8}, // TSFT
+		{1, 1}, // Flags
+		{1, 1}, // Rate
+		{4, 2}, // Channel
+		{2, 1}, // FHSS
+		{1, 1}, // dBm antenna signal
+	}
+	for bit, f := range fields {
+		if present&(1<<bit) == 0 {
+			continue
+		}
+		offset = (offset + f.align - 1) &^ (f.align - 1)
+		if offset+f.size > length {
+			break
+		}
+		switch bit {
+		case 1:
+			fcs = b[offset]&0x10 != 0
+		case 5:
+			rssi = int(int8(b[offset]))
+		}
+		offset += f.size
+	}
+
+	return rssi, fcs, b[length:], true
+}
+
+// mac formats a 6-byte address; BLE addresses are sent least significant byte first
+func mac(b []byte, reversed bool) string {
+	if reversed {
+		return fmt.Sprintf("%02X:%02X:%02X:%02X:%02X:%02X", b[5], b[4], b[3], b[2], b[1], b[0])
+	}
+	return fmt.Sprintf("%02X:%02X:%02X:%02X:%02X:%02X", b[0], b[1], b[2], b[3], b[4], b[5])
+}
@@ -0,0 +1,184 @@
+package remoteid
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"net"
+	"testing"
+
+	"silentraven/internal/pcap"
+)
+
+var testSender = net.HardwareAddr{0x60, 0x60, 0x1F, 0x12, 0x34, 0x56}
+
+func testPack() []byte {
+	return EncodePack(
+		EncodeBasicID(BasicID{IDType: IDTypeSerialNumber, UAType: 2, ID: "1581F45TESTSERIAL"}),
+		EncodeLocation(Location{Direction: 90, SpeedHorizontal: 5, Latitude: 51.5, Longitude: -0.12, Height: 40}),
+	)
+}
+
+// radiotap prefixes an 802.11 frame with flags (FCS present) and antenna
+// signal, and appends the FCS
+func radiotap(frame []byte, rssi int8) []byte {
+	b := []byte{0, 0, 10, 0, 0x22, 0, 0, 0, 0x10, byte(rssi)}
+	b = append(b, frame...)
+	return binary.LittleEndian.AppendUint32(b, crc32.ChecksumIEEE(frame))
+}
+
+// phdr prefixes a BLE packet with a pseudo-header carrying the signal
+func phdr(ll []byte, rssi int8) []byte {
+	h := make([]byte, 10)
+	h[0], h[1] = 37, byte(rssi)
+	binary.LittleEndian.PutUint16(h[8:10], 0x0002)
+	return append(h, ll...)
+}
+
+func TestExtract(t *testing.T) {
+	pack := testPack()
+	single := EncodeLocation(Location{Direction: 10, Latitude: 1, Longitude: 2})
+
+	tests := []struct {
+		name      string
+		linkType  uint32
+		data      []byte
+		transport string
+		sender    string
+		rssi      int
+		payload   []byte
+	}{
+		{"beacon", pcap.LinkTypeIEEE80211, EncodeBeacon(testSender, 1, pack), TransportWiFiBeacon, "60:60:1F:12:34:56", 0, pack},
+		{"beacon radiotap", pcap.LinkTypeRadiotap, radiotap(EncodeBeacon(testSender, 1, pack), -61), TransportWiFiBeacon, "60:60:1F:12:34:56", -61, pack},
+		{"nan", pcap.LinkTypeIEEE80211, EncodeNANAction(testSender, 7, pack), TransportWiFiNAN, "60:60:1F:12:34:56", 0, pack},
+		{"nan radiotap", pcap.LinkTypeRadiotap, radiotap(EncodeNANAction(testSender, 7, pack), -75), TransportWiFiNAN, "60:60:1F:12:34:56", -75, pack},
+		{"ble", pcap.LinkTypeBluetoothLE, EncodeBLEAdvertising(testSender, 3, single), TransportBLE, "60:60:1F:12:34:56", 0, single},
+		{"ble phdr", pcap.LinkTypeBluetoothPHDR, phdr(EncodeBLEAdvertising(testSender, 3, single), -80), TransportBLE, "60:60:1F:12:34:56", -80, single},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, ok := Extract(tt.linkType, tt.data)
+			if !ok {
+				t.Fatal("no Remote ID payload found")
+			}
+			if f.Transport != tt.transport || f.Sender != tt.sender || f.RSSI != tt.rssi {
+				t.Errorf("got %s from %s at %d dBm, want %s from %s at %d dBm",
+					f.Transport, f.Sender, f.RSSI, tt.transport, tt.sender, tt.rssi)
+			}
+			if !bytes.Equal(f.Payload, tt.payload) {
+				t.Errorf("payload = %x, want %x", f.Payload, tt.payload)
+			}
+			if _, err := Decode(f.Payload); err != nil {
+				t.Errorf("payload does not decode: %v", err)
+			}
+		})
+	}
+}
+
+// TestExtractNANOptionalFields uses a hand-built SDF with a binding bitmap
+// and matching filter ahead of the service info, and a non-Remote ID
+// service descriptor before it
+func TestExtractNANOptionalFields(t *testing.T) {
+	pack := testPack()
+
+	other := []byte{1, 2, 3, 4, 5, 6, 1, 0, 0x10, 2, 0xAA, 0xBB}
+	rid := append([]byte{}, nanServiceID...)
+	rid = append(rid, 1, 0, 0x10|0x40|0x04, // service info, binding bitmap, matching filter
+		0x01, 0x00, // binding bitmap
+		3, 0xDE, 0xAD, 0xBE, // matching filter
+		byte(1+len(pack)), 9)
+	rid = append(rid, pack...)
+
+	f := []byte{0xD0, 0, 0, 0}
+	f = append(f, nanNetworkMAC...)
+	f = append(f, testSender...)
+	f = append(f, nanClusterMAC...)
+	f = append(f, 0, 0, 0x04, 0x09, 0x50, 0x6F, 0x9A, 0x13)
+	for _, attr := range [][]byte{other, rid} {
+		f = append(f, 0x03)
+		f = binary.LittleEndian.AppendUint16(f, uint16(len(attr)))
+		f = append(f, attr...)
+	}
+
+	got, ok := Extract(pcap.LinkTypeIEEE80211, f)
+	if !ok || got.Transport != TransportWiFiNAN || !bytes.Equal(got.Payload, pack) {
+		t.Fatalf("Extract = %+v, %v; want the NAN pack", got, ok)
+	}
+}
+
+func TestExtractRejects(t *testing.T) {
+	pack := testPack()
+
+	otherVendor := EncodeBeacon(testSender, 1, pack)
+	otherVendor[len(otherVendor)-len(pack)-5] = 0x00 // first OUI byte
+
+	dataFrame := EncodeBeacon(testSender, 1, pack)
+	dataFrame[0] = 0x08
+
+	plainBeacon := EncodeBeacon(testSender, 1, nil)
+	plainBeacon = plainBeacon[:len(plainBeacon)-7] // drop the vendor element
+
+	wrongAccess := EncodeBLEAdvertising(testSender, 1, pack[3:])
+	wrongAccess[0] ^= 0xFF
+
+	scanReq := EncodeBLEAdvertising(testSender, 1, pack[3:])
+	scanReq[4] = 0x03
+
+	tests := []struct {
+		name     string
+		linkType uint32
+		data     []byte
+	}{
+		{"other vendor element", pcap.LinkTypeIEEE80211, otherVendor},
+		{"data frame", pcap.LinkTypeIEEE80211, dataFrame},
+		{"beacon without Remote ID", pcap.LinkTypeIEEE80211, plainBeacon},
+		{"bad radiotap version", pcap.LinkTypeRadiotap, append([]byte{1}, radiotap(EncodeBeacon(testSender, 1, pack), -50)[1:]...)},
+		{"wrong access address", pcap.LinkTypeBluetoothLE, wrongAccess},
+		{"scan request", pcap.LinkTypeBluetoothLE, scanReq},
+		{"unknown link type", 1, EncodeBeacon(testSender, 1, pack)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if f, ok := Extract(tt.linkType, tt.data); ok {
+				t.Errorf("Extract found %+v", f)
+			}
+		})
+	}
+}
+
+// TestExtractTruncated feeds every prefix of each frame, which must not
+// panic and must not yield a payload shorter than a message
+func TestExtractTruncated(t *testing.T) {
+	pack := testPack()
+	frames := []struct {
+		linkType uint32
+		data     []byte
+	}{
+		{pcap.LinkTypeRadiotap, radiotap(EncodeBeacon(testSender, 1, pack), -61)},
+		{pcap.LinkTypeIEEE80211, EncodeNANAction(testSender, 1, pack)},
+		{pcap.LinkTypeBluetoothPHDR, phdr(EncodeBLEAdvertising(testSender, 1, pack[3:]), -70)},
+	}
+	for _, fr := range frames {
+		for n := 0; n < len(fr.data); n++ {
+			if f, ok := Extract(fr.linkType, fr.data[:n]); ok && len(f.Payload) < MessageSize {
+				t.Errorf("link type %d, %d bytes: %d byte payload", fr.linkType, n, len(f.Payload))
+			}
+		}
+	}
+}
+
+func TestDirectionRoundTrip(t *testing.T) {
+	tests := []struct{ in, want int }{
+		{0, 0}, {179, 179}, {180, 180}, {359, 359}, {360, 0},
+		{UnknownDirection, UnknownDirection}, {-1, UnknownDirection},
+	}
+	for _, tt := range tests {
+		msgs, err := Decode(EncodeLocation(Location{Direction: tt.in}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := msgs[0].Location.Direction; got != tt.want {
+			t.Errorf("direction %d decoded as %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,249 @@
+// Package retention enforces data retention: raw detections are
+// downsampled into tracks, archived to disk and dropped once they expire;
+// downsampled tracks are kept for a longer period.
+package retention
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"silentraven/internal/archive"
+	"silentraven/internal/database"
+	"silentraven/internal/logging"
+	"silentraven/internal/models"
+)
+
+var logger = logging.For("retention")
+
+// Policy configures retention
+type Policy struct {
+	// RawRetention is how long raw detections stay in drone_detections
+	RawRetention time.Duration
+	// TrackRetention is how long downsampled track points are kept
+	TrackRetention time.Duration
+	// TrackBucket is the downsampling interval for tracks
+	TrackBucket time.Duration
+	// CompressAfter enables TimescaleDB compression for older chunks (0 = off)
+	CompressAfter time.Duration
+	// ArchiveDir receives NDJSON archives before raw data is dropped ("" = no archive)
+	ArchiveDir string
+	// ArchivePeriod is the time span of each archive file
+	ArchivePeriod time.Duration
+}
+
+// Validate checks the policy for inconsistent values
+func (p Policy) Validate() error {
+	if p.RawRetention <= 0 {
+		return fmt.Errorf("raw retention must be positive")
+	}
+	if p.TrackRetention < p.RawRetention {
+		return fmt.Errorf("track retention (%s) must not be shorter than raw retention (%s)", p.TrackRetention, p.RawRetention)
+	}
+	if p.TrackBucket <= 0 {
+		return fmt.Errorf("track bucket must be positive")
+	}
+	if p.ArchivePeriod <= 0 || 24*time.Hour%p.ArchivePeriod != 0 {
+		return fmt.Errorf("archive period must evenly divide 24h, got %s", p.ArchivePeriod)
+	}
+	return nil
+}
+
+// Manager applies a retention policy to the database
+type Manager struct {
+	db     *database.DB
+	policy Policy
+	now    func() time.Time
+
+	// done is the end of the last period downsampled (and archived);
+	// later passes start there instead of at the oldest detection,
+	// which stays put on a hypertable until its whole chunk expires
+	done time.Time
+}
+
+// NewManager creates a retention manager
+func NewManager(db *database.DB, policy Policy) (*Manager, error) {
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+	return &Manager{db: db, policy: policy, now: time.Now}, nil
+}
+
+// Result summarizes one retention pass
+type Result struct {
+	Archived    []archive.Entry
+	TrackPoints int64
+	// DroppedRaw and DroppedTracks count rows
+	DroppedRaw    int64
+	DroppedTracks int64
+}
+
+// Run applies the compression policy, then enforces retention every
+// interval until ctx is cancelled.
+func (m *Manager) Run(ctx context.Context, interval time.Duration) error {
+	if err := m.db.SetCompressionPolicy(m.policy.CompressAfter); err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if res, err := m.RunOnce(ctx); err != nil {
+			logger.Error("Retention pass failed", logging.Err(err))
+		} else {
+			logger.Info("Retention pass complete", "archived_files", len(res.Archived), "track_points", res.TrackPoints,
+				"dropped_raw", res.DroppedRaw, "dropped_track_points", res.DroppedTracks)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce downsamples and archives every expired period not yet done,
+// then drops expired raw detections and track points. Periods already in
+// the manifest were downsampled before they were archived, so after a
+// restart only unarchived periods are downsampled again.
+func (m *Manager) RunOnce(ctx context.Context) (*Result, error) {
+	res := &Result{}
+	now := m.now().UTC()
+	cutoff := now.Add(-m.policy.RawRetention).Truncate(m.policy.ArchivePeriod)
+
+	oldest, err := m.db.OldestDetectionTime()
+	if err != nil {
+		return res, err
+	}
+
+	var manifest *archive.Manifest
+	if m.policy.ArchiveDir != "" {
+		if manifest, err = archive.LoadManifest(m.policy.ArchiveDir); err != nil {
+			return res, err
+		}
+	}
+
+	if !oldest.IsZero() {
+		start := oldest.UTC().Truncate(m.policy.ArchivePeriod)
+		if start.Before(m.done) {
+			start = m.done
+		}
+		for from := start; from.Before(cutoff); from = from.Add(m.policy.ArchivePeriod) {
+			if ctx.Err() != nil {
+				return res, ctx.Err()
+			}
+			to := from.Add(m.policy.ArchivePeriod)
+			if manifest != nil && manifest.Has(from, to) {
+				m.done = to
+				continue
+			}
+
+			n, err := m.db.DownsampleTracks(from, to, m.policy.TrackBucket)
+			if err != nil {
+				return res, err
+			}
+			res.TrackPoints += n
+
+			if manifest != nil {
+				entry, err := m.archivePeriod(from, to)
+				if err != nil {
+					return res, err
+				}
+				manifest.Entries = append(manifest.Entries, entry)
+				if err := manifest.Save(m.policy.ArchiveDir); err != nil {
+					return res, err
+				}
+				res.Archived = append(res.Archived, entry)
+			}
+			m.done = to
+		}
+	}
+
+	if res.DroppedRaw, err = m.db.DropDetectionsBefore(cutoff); err != nil {
+		return res, err
+	}
+	if res.DroppedTracks, err = m.db.DeleteTracksBefore(now.Add(-m.policy.TrackRetention)); err != nil {
+		return res, err
+	}
+
+	return res, nil
+}
+
+// archivePeriod writes all detections in [from, to) to one archive file
+func (m *Manager) archivePeriod(from, to time.Time) (archive.Entry, error) {
+	w, err := archive.Create(m.policy.ArchiveDir, from, to)
+	if err != nil {
+		return archive.Entry{}, err
+	}
+
+	err = m.db.StreamDetections(from, to, func(d models.DroneDetection) error {
+		return w.Write(d)
+	})
+	if err != nil {
+		w.Abort()
+		return archive.Entry{}, err
+	}
+
+	entry, err := w.Close()
+	if err != nil {
+		return archive.Entry{}, err
+	}
+
+	logger.Info("Archived detections", "rows", entry.Rows, "file", filepath.Join(m.policy.ArchiveDir, entry.File))
+	return entry, nil
+}
+
+// Restore loads one archive file (verified against the manifest) into
+// table in batches and returns the number of rows restored.
+func Restore(db *database.DB, dir, file, table string) (int64, error) {
+	manifest, err := archive.LoadManifest(dir)
+	if err != nil {
+		return 0, err
+	}
+
+	var expected *archive.Entry
+	for i := range manifest.Entries {
+		if manifest.Entries[i].File == file {
+			expected = &manifest.Entries[i]
+			break
+		}
+	}
+	if expected == nil {
+		return 0, fmt.Errorf("%s is not listed in %s", file, filepath.Join(dir, archive.ManifestFile))
+	}
+
+	// Verify the whole file before loading anything
+	if err := archive.Read(filepath.Join(dir, file), expected, func(models.DroneDetection) error { return nil }); err != nil {
+		return 0, err
+	}
+
+	const batchSize = 5000
+	var restored int64
+	batch := make([]models.DroneDetection, 0, batchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := db.RestoreDetections(table, file, batch); err != nil {
+			return err
+		}
+		restored += int64(len(batch))
+		batch = batch[:0]
+		return nil
+	}
+
+	err = archive.Read(filepath.Join(dir, file), nil, func(d models.DroneDetection) error {
+		batch = append(batch, d)
+		if len(batch) == batchSize {
+			return flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return restored, err
+	}
+	return restored, flush()
+}
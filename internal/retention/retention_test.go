@@ -0,0 +1,153 @@
+package retention
+
+import (
+	"database/sql"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"silentraven/internal/archive"
+	"silentraven/internal/database"
+	"silentraven/internal/models"
+)
+
+func TestValidate(t *testing.T) {
+	day := 24 * time.Hour
+	valid := Policy{RawRetention: 90 * day, TrackRetention: 365 * day, TrackBucket: 10 * time.Second, ArchivePeriod: day}
+	if err := valid.Validate(); err != nil {
+		t.Fatalf("valid policy: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(p *Policy)
+		ok     bool
+	}{
+		{"tracks kept as long as raw", func(p *Policy) { p.TrackRetention = p.RawRetention }, true},
+		{"hourly archives", func(p *Policy) { p.ArchivePeriod = time.Hour }, true},
+		{"no raw retention", func(p *Policy) { p.RawRetention = 0 }, false},
+		{"negative raw retention", func(p *Policy) { p.RawRetention = -day }, false},
+		{"tracks shorter than raw", func(p *Policy) { p.TrackRetention = p.RawRetention - time.Second }, false},
+		{"no track bucket", func(p *Policy) { p.TrackBucket = 0 }, false},
+		{"no archive period", func(p *Policy) { p.ArchivePeriod = 0 }, false},
+		{"archive period not dividing a day", func(p *Policy) { p.ArchivePeriod = 7 * time.Hour }, false},
+		{"archive period over a day", func(p *Policy) { p.ArchivePeriod = 2 * day }, false},
+	}
+	for _, tt := range tests {
+		p := valid
+		tt.modify(&p)
+		if err := p.Validate(); (err == nil) != tt.ok {
+			t.Errorf("%s: Validate = %v, want ok %v", tt.name, err, tt.ok)
+		}
+	}
+}
+
+// archived writes one archive with its manifest entry and returns the
+// file name
+func archived(t *testing.T, dir string, from, to time.Time, rows int) string {
+	t.Helper()
+	w, err := archive.Create(dir, from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < rows; i++ {
+		if err := w.Write(models.DroneDetection{ID: int64(i + 1), DetectionTime: from, UASID: "uas-1"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	entry, err := w.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := archive.LoadManifest(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Entries = append(m.Entries, entry)
+	if err := m.Save(dir); err != nil {
+		t.Fatal(err)
+	}
+	return entry.File
+}
+
+// Restore verifies the file against the manifest before it touches the
+// database, so these run without one
+func TestRestoreRejectsBadArchive(t *testing.T) {
+	from := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(24 * time.Hour)
+
+	t.Run("checksum mismatch", func(t *testing.T) {
+		dir := t.TempDir()
+		file := archived(t, dir, from, to, 3)
+
+		// Overwrite the file with a different archive of the same period
+		w, err := archive.Create(dir, from, to)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Write(models.DroneDetection{ID: 9, DetectionTime: from, UASID: "uas-9"}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		n, err := Restore(nil, dir, file, "restored_detections")
+		if err == nil || !strings.Contains(err.Error(), "checksum mismatch") || n != 0 {
+			t.Errorf("Restore = %d, %v; want a checksum mismatch", n, err)
+		}
+	})
+
+	t.Run("not in manifest", func(t *testing.T) {
+		dir := t.TempDir()
+		archived(t, dir, from, to, 1)
+		other := archive.FileName(to, to.Add(24*time.Hour))
+		if n, err := Restore(nil, dir, other, "restored_detections"); err == nil || n != 0 {
+			t.Errorf("Restore of an unlisted file = %d, %v", n, err)
+		}
+	})
+}
+
+// TestRestorePostgres restores an archive into restored_detections in
+// the database at TEST_DATABASE_URL and reads the rows back
+func TestRestorePostgres(t *testing.T) {
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	db, err := database.Open(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := db.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+	conn, err := sql.Open("postgres", url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	from := time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC)
+	dir := t.TempDir()
+	file := archived(t, dir, from, from.Add(24*time.Hour), 3)
+	if _, err := conn.Exec(`DELETE FROM restored_detections WHERE archive_file = $1`, file); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := Restore(db, dir, file, "restored_detections")
+	if err != nil || n != 3 {
+		t.Fatalf("Restore = %d, %v; want 3 rows", n, err)
+	}
+	var rows int
+	var ids int64
+	err = conn.QueryRow(`SELECT count(*), sum(id) FROM restored_detections WHERE archive_file = $1 AND uas_id = 'uas-1'`, file).Scan(&rows, &ids)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows != 3 || ids != 6 {
+		t.Errorf("restored %d rows with ids summing to %d, want 3 rows summing to 6", rows, ids)
+	}
+}
@@ -0,0 +1,25 @@
+package sim
+
+import "silentraven/internal/geo"
+
+// Point is a WGS-84 position in degrees
+type Point struct {
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+}
+
+// Distance returns the great-circle distance to q in meters
+func (p Point) Distance(q Point) float64 {
+	return geo.Distance(p.Lat, p.Lon, q.Lat, q.Lon)
+}
+
+// Bearing returns the initial bearing to q in degrees [0, 360)
+func (p Point) Bearing(q Point) float64 {
+	return geo.Bearing(p.Lat, p.Lon, q.Lat, q.Lon)
+}
+
+// Move returns the point meters away along bearingDeg
+func (p Point) Move(bearingDeg, meters float64) Point {
+	lat, lon := geo.Destination(p.Lat, p.Lon, bearingDeg, meters)
+	return Point{Lat: lat, Lon: lon}
+}
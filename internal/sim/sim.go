@@ -0,0 +1,265 @@
+// Package sim simulates Remote ID traffic: many synthetic UAS flying
+// missions over an area watched by virtual sensor nodes. Given the same
+// scenario and seed a simulation is fully deterministic.
+package sim
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"silentraven/internal/models"
+)
+
+// Circle is a circular area
+type Circle struct {
+	Center Point   `json:"center"`
+	Radius float64 `json:"radius_m"`
+}
+
+// Node is a virtual sensor node
+type Node struct {
+	ID       string
+	Position Point
+	Range    float64 // m
+}
+
+// Scenario describes a simulation
+type Scenario struct {
+	Center    Point
+	Radius    float64 // m, area the UAS operate in
+	Drones    int
+	Nodes     int
+	NodeRange float64 // m
+	// Mix weights the flight profiles, e.g. {"waypoint": 3, "incursion": 1}
+	Mix map[string]float64
+	// Geofence is the protected area incursion flights enter
+	Geofence Circle
+	// SpoofRate is the fraction of UAS broadcasting a cloned identity
+	SpoofRate float64
+	// DropoutRate is the signal dropouts per UAS per minute
+	DropoutRate float64
+	Seed        int64
+}
+
+// DefaultScenario returns a mixed scenario around center
+func DefaultScenario(center Point) Scenario {
+	return Scenario{
+		Center:      center,
+		Radius:      3000,
+		Drones:      10,
+		Nodes:       3,
+		NodeRange:   3000,
+		Mix:         map[string]float64{ProfileWaypoint: 4, ProfileLoiter: 2, ProfileHop: 2, ProfileIncursion: 1},
+		Geofence:    Circle{Center: center, Radius: 500},
+		SpoofRate:   0.05,
+		DropoutRate: 0.2,
+		Seed:        1,
+	}
+}
+
+// Validate checks the scenario
+func (sc Scenario) Validate() error {
+	if sc.Drones <= 0 || sc.Nodes <= 0 {
+		return fmt.Errorf("scenario needs at least one drone and one node")
+	}
+	if sc.Radius <= 0 || sc.NodeRange <= 0 {
+		return fmt.Errorf("radius and node range must be positive")
+	}
+	if sc.SpoofRate < 0 || sc.SpoofRate > 1 || sc.DropoutRate < 0 {
+		return fmt.Errorf("invalid spoof or dropout rate")
+	}
+	total := 0.0
+	for profile, w := range sc.Mix {
+		if !validProfile(profile) {
+			return fmt.Errorf("unknown flight profile %q", profile)
+		}
+		if w < 0 {
+			return fmt.Errorf("negative weight for profile %q", profile)
+		}
+		total += w
+	}
+	if total == 0 {
+		return fmt.Errorf("profile mix is empty")
+	}
+	return nil
+}
+
+// ParseMix parses "waypoint=4,loiter=2,incursion=1"
+func ParseMix(s string) (map[string]float64, error) {
+	mix := make(map[string]float64)
+	for _, part := range strings.Split(s, ",") {
+		name, weight, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid mix entry %q (want profile=weight)", part)
+		}
+		w, err := strconv.ParseFloat(weight, 64)
+		if err != nil || !validProfile(name) {
+			return nil, fmt.Errorf("invalid mix entry %q", part)
+		}
+		mix[name] = w
+	}
+	return mix, nil
+}
+
+func validProfile(name string) bool {
+	for _, p := range Profiles {
+		if p == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Simulator advances a scenario in virtual time. It is not safe for
+// concurrent use.
+type Simulator struct {
+	scenario Scenario
+	rng      *rand.Rand
+	now      time.Time
+	uas      []*UAS
+	nodes    []Node
+}
+
+// New builds the fleet and nodes for a scenario starting at start
+func New(sc Scenario, start time.Time) (*Simulator, error) {
+	if err := sc.Validate(); err != nil {
+		return nil, err
+	}
+
+	s := &Simulator{
+		scenario: sc,
+		rng:      rand.New(rand.NewSource(sc.Seed)),
+		now:      start,
+	}
+
+	// One node in the middle, the rest on a ring
+	s.nodes = append(s.nodes, Node{ID: "sim-node-01", Position: sc.Center, Range: sc.NodeRange})
+	for i := 1; i < sc.Nodes; i++ {
+		angle := float64(i-1) * 360 / float64(sc.Nodes-1)
+		s.nodes = append(s.nodes, Node{
+			ID:       fmt.Sprintf("sim-node-%02d", i+1),
+			Position: sc.Center.Move(angle, sc.Radius*0.6),
+			Range:    sc.NodeRange,
+		})
+	}
+
+	droneTypes := []string{"Multirotor", "Multirotor", "Multirotor", "Aeroplane", "Hybrid Lift"}
+	for i := 0; i < sc.Drones; i++ {
+		u := &UAS{
+			SN:          fmt.Sprintf("SIM%04d-%06d", s.rng.Intn(10000), i+1),
+			DroneType:   droneTypes[s.rng.Intn(len(droneTypes))],
+			Profile:     s.pickProfile(),
+			dropoutRate: sc.DropoutRate / 60,
+		}
+		u.UASID = fmt.Sprintf("FA-SIM-%06d", i+1)
+
+		switch u.Profile {
+		case ProfileIncursion:
+			// Launch from outside the geofence
+			out := sc.Geofence.Radius + 1000 + s.rng.Float64()*2000
+			u.Home = sc.Geofence.Center.Move(s.rng.Float64()*360, out)
+		default:
+			u.Home = randomPoint(sc.Center, sc.Radius, s.rng)
+		}
+		u.Position = u.Home
+		u.Phase = PhaseGround
+		// Stagger launches over the first minute
+		u.phaseLeft = time.Duration(s.rng.Intn(60)) * time.Second
+		s.uas = append(s.uas, u)
+	}
+
+	// Spoofers clone an earlier aircraft's identity
+	for i, u := range s.uas {
+		if i > 0 && s.rng.Float64() < sc.SpoofRate {
+			victim := s.uas[s.rng.Intn(i)]
+			u.SN, u.UASID, u.Spoofer = victim.SN, victim.UASID, true
+		}
+	}
+	return s, nil
+}
+
+// pickProfile draws a profile according to the mix weights
+func (s *Simulator) pickProfile() string {
+	names := make([]string, 0, len(s.scenario.Mix))
+	total := 0.0
+	for name, w := range s.scenario.Mix {
+		names = append(names, name)
+		total += w
+	}
+	// Map iteration order is random; sort for determinism
+	sort.Strings(names)
+
+	x := s.rng.Float64() * total
+	for _, name := range names {
+		if x -= s.scenario.Mix[name]; x < 0 {
+			return name
+		}
+	}
+	return names[len(names)-1]
+}
+
+// Now returns the current simulation time
+func (s *Simulator) Now() time.Time {
+	return s.now
+}
+
+// Nodes returns the virtual sensor nodes
+func (s *Simulator) Nodes() []Node {
+	return s.nodes
+}
+
+// Fleet returns the simulated aircraft
+func (s *Simulator) Fleet() []*UAS {
+	return s.uas
+}
+
+// Step advances the simulation by dt and returns the broadcasts heard,
+// one packet per node in range of each airborne, audible aircraft
+func (s *Simulator) Step(dt time.Duration) []models.IncomingPacket {
+	s.now = s.now.Add(dt)
+
+	var packets []models.IncomingPacket
+	for _, u := range s.uas {
+		u.step(dt, &s.scenario, s.rng)
+		if !u.Flying() || u.Silent {
+			continue
+		}
+		for _, n := range s.nodes {
+			if n.Position.Distance(u.Position) <= n.Range {
+				packets = append(packets, s.packet(u, n.ID))
+			}
+		}
+	}
+	return packets
+}
+
+// packet renders an aircraft's broadcast as heard by a node
+func (s *Simulator) packet(u *UAS, nodeID string) models.IncomingPacket {
+	return models.IncomingPacket{
+		SN:                u.SN,
+		UASID:             u.UASID,
+		DroneType:         u.DroneType,
+		Direction:         int(math.Round(u.Heading)) % 360,
+		SpeedHorizontal:   round(u.Speed, 2),
+		SpeedVertical:     round(u.VSpeed, 1),
+		Latitude:          round(u.Position.Lat, 7),
+		Longitude:         round(u.Position.Lon, 7),
+		Height:            round(u.Height, 1),
+		OperatorLatitude:  round(u.Home.Lat, 7),
+		OperatorLongitude: round(u.Home.Lon, 7),
+		NodeID:            nodeID,
+		Timestamp:         s.now.UTC().Format(time.RFC3339Nano),
+		HeightType:        models.HeightAboveTakeoff,
+	}
+}
+
+// round rounds to the precision Remote ID can carry
+func round(v float64, places int) float64 {
+	p := math.Pow10(places)
+	return math.Round(v*p) / p
+}
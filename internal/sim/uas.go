@@ -0,0 +1,197 @@
+package sim
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+// Flight profiles
+const (
+	ProfileWaypoint  = "waypoint"  // multi-leg mission returning home
+	ProfileLoiter    = "loiter"    // orbit a point for a few minutes
+	ProfileHop       = "hop"       // takeoff, hover, land
+	ProfileIncursion = "incursion" // fly from outside into the geofence
+)
+
+// Profiles lists every flight profile
+var Profiles = []string{ProfileWaypoint, ProfileLoiter, ProfileHop, ProfileIncursion}
+
+// Flight phases
+const (
+	PhaseGround  = "ground"
+	PhaseTakeoff = "takeoff"
+	PhaseCruise  = "cruise"
+	PhaseLoiter  = "loiter"
+	PhaseHover   = "hover"
+	PhaseLanding = "landing"
+)
+
+const (
+	climbRate   = 3.0 // m/s
+	descentRate = 2.0 // m/s
+	arriveAt    = 5.0 // m from a waypoint
+)
+
+// UAS is one simulated aircraft
+type UAS struct {
+	SN        string
+	UASID     string
+	DroneType string
+	Profile   string
+
+	Position Point
+	Home     Point   // takeoff point, also the operator location
+	Height   float64 // m above takeoff
+	Heading  float64 // degrees
+	Speed    float64 // horizontal m/s
+	VSpeed   float64 // vertical m/s
+	Phase    string
+
+	// Spoofer broadcasts another aircraft's identity
+	Spoofer bool
+	// Silent is set while the aircraft is in a signal dropout
+	Silent bool
+
+	cruiseSpeed  float64
+	cruiseHeight float64
+	route        []Point
+	leg          int
+	orbitCenter  Point
+	orbitRadius  float64
+	phaseLeft    time.Duration // remaining hover/loiter/ground time
+	silentLeft   time.Duration
+	dropoutRate  float64 // dropouts per second
+}
+
+// Flying reports whether the aircraft is airborne (and broadcasting)
+func (u *UAS) Flying() bool {
+	return u.Phase != PhaseGround
+}
+
+// step advances the aircraft by dt
+func (u *UAS) step(dt time.Duration, sc *Scenario, rng *rand.Rand) {
+	secs := dt.Seconds()
+
+	// Dropouts: the aircraft keeps flying but goes unheard
+	if u.silentLeft > 0 {
+		u.silentLeft -= dt
+	} else if u.Flying() && rng.Float64() < u.dropoutRate*secs {
+		u.silentLeft = time.Duration(5+rng.Intn(25)) * time.Second
+	}
+	u.Silent = u.silentLeft > 0
+
+	switch u.Phase {
+	case PhaseGround:
+		u.Speed, u.VSpeed = 0, 0
+		if u.phaseLeft -= dt; u.phaseLeft <= 0 {
+			u.plan(sc, rng)
+		}
+
+	case PhaseTakeoff:
+		u.Speed, u.VSpeed = 0, climbRate
+		u.Height = math.Min(u.cruiseHeight, u.Height+climbRate*secs)
+		if u.Height >= u.cruiseHeight {
+			u.VSpeed = 0
+			switch u.Profile {
+			case ProfileHop:
+				u.Phase, u.phaseLeft = PhaseHover, time.Duration(20+rng.Intn(40))*time.Second
+			default:
+				u.Phase = PhaseCruise
+			}
+		}
+
+	case PhaseHover:
+		u.Speed, u.VSpeed = 0, 0
+		if u.phaseLeft -= dt; u.phaseLeft <= 0 {
+			u.Phase = PhaseLanding
+		}
+
+	case PhaseCruise:
+		u.VSpeed = 0
+		if u.leg >= len(u.route) {
+			// Back over home: land
+			u.Phase = PhaseLanding
+			break
+		}
+		if u.flyTo(u.route[u.leg], secs) {
+			u.leg++
+			if u.Profile == ProfileLoiter && u.leg == 1 {
+				u.Phase = PhaseLoiter
+			}
+		}
+
+	case PhaseLoiter:
+		u.orbit(secs)
+		if u.phaseLeft -= dt; u.phaseLeft <= 0 {
+			u.Phase = PhaseCruise
+		}
+
+	case PhaseLanding:
+		u.Speed, u.VSpeed = 0, -descentRate
+		u.Height = math.Max(0, u.Height-descentRate*secs)
+		if u.Height == 0 {
+			u.VSpeed = 0
+			u.Phase, u.phaseLeft = PhaseGround, time.Duration(30+rng.Intn(90))*time.Second
+		}
+	}
+}
+
+// flyTo moves toward target at cruise speed and reports arrival
+func (u *UAS) flyTo(target Point, secs float64) bool {
+	dist := u.Position.Distance(target)
+	if dist <= arriveAt {
+		return true
+	}
+	u.Heading = u.Position.Bearing(target)
+	u.Speed = u.cruiseSpeed
+	u.Position = u.Position.Move(u.Heading, math.Min(dist, u.cruiseSpeed*secs))
+	return false
+}
+
+// orbit circles orbitCenter clockwise at cruise speed
+func (u *UAS) orbit(secs float64) {
+	around := u.orbitCenter.Bearing(u.Position)
+	// Angular step for the distance flown along the circle
+	around += (u.cruiseSpeed * secs / u.orbitRadius) * 180 / math.Pi
+	u.Position = u.orbitCenter.Move(around, u.orbitRadius)
+	u.Heading = math.Mod(around+90, 360)
+	u.Speed = u.cruiseSpeed
+}
+
+// plan prepares the next flight from home
+func (u *UAS) plan(sc *Scenario, rng *rand.Rand) {
+	u.Position, u.Height, u.leg = u.Home, 0, 0
+	u.cruiseSpeed = 6 + rng.Float64()*10
+	u.cruiseHeight = 40 + rng.Float64()*80
+	u.route = u.route[:0]
+
+	switch u.Profile {
+	case ProfileWaypoint:
+		for i := 3 + rng.Intn(4); i > 0; i-- {
+			u.route = append(u.route, randomPoint(u.Home, sc.Radius/2, rng))
+		}
+	case ProfileLoiter:
+		u.orbitRadius = 50 + rng.Float64()*150
+		u.orbitCenter = randomPoint(u.Home, sc.Radius/3, rng)
+		u.route = append(u.route, u.orbitCenter.Move(rng.Float64()*360, u.orbitRadius))
+		u.phaseLeft = time.Duration(120+rng.Intn(180)) * time.Second
+	case ProfileHop:
+		u.cruiseHeight = 10 + rng.Float64()*20
+	case ProfileIncursion:
+		// Straight through the geofence toward its far side
+		if sc.Geofence.Radius > 0 {
+			across := u.Home.Bearing(sc.Geofence.Center)
+			u.route = append(u.route,
+				sc.Geofence.Center,
+				sc.Geofence.Center.Move(across, sc.Geofence.Radius/2))
+		}
+	}
+	u.route = append(u.route, u.Home)
+	u.Phase = PhaseTakeoff
+}
+
+// randomPoint returns a uniformly distributed point within radius of c
+func randomPoint(c Point, radius float64, rng *rand.Rand) Point {
+	return c.Move(rng.Float64()*360, radius*math.Sqrt(rng.Float64()))
+}
@@ -0,0 +1,83 @@
+package spool
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"time"
+)
+
+// Record is one spooled message
+type Record struct {
+	Key   []byte
+	Value []byte
+	Time  time.Time
+}
+
+// headerSize is the length and checksum prefix of every frame
+const headerSize = 8
+
+// maxRecordSize guards against reading a garbage length prefix
+const maxRecordSize = 16 << 20
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// errCorrupt marks a frame that fails its checksum or is truncated
+var errCorrupt = errors.New("corrupt spool record")
+
+// encode frames a record as
+//
+//	len uint32 | crc32c uint32 | time int64 | keylen uint32 | key | value
+func encode(r Record) []byte {
+	payload := 8 + 4 + len(r.Key) + len(r.Value)
+	buf := make([]byte, headerSize+payload)
+
+	p := buf[headerSize:]
+	binary.BigEndian.PutUint64(p[0:8], uint64(r.Time.UnixNano()))
+	binary.BigEndian.PutUint32(p[8:12], uint32(len(r.Key)))
+	copy(p[12:], r.Key)
+	copy(p[12+len(r.Key):], r.Value)
+
+	binary.BigEndian.PutUint32(buf[0:4], uint32(payload))
+	binary.BigEndian.PutUint32(buf[4:8], crc32.Checksum(p, crcTable))
+	return buf
+}
+
+// decode reads one frame, returning the record and its encoded size.
+// io.EOF means a clean end; errCorrupt a torn or damaged frame.
+func decode(r io.Reader) (Record, int64, error) {
+	var header [headerSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		if err == io.EOF {
+			return Record{}, 0, io.EOF
+		}
+		return Record{}, 0, errCorrupt
+	}
+
+	size := binary.BigEndian.Uint32(header[0:4])
+	if size < 12 || size > maxRecordSize {
+		return Record{}, 0, errCorrupt
+	}
+
+	p := make([]byte, size)
+	if _, err := io.ReadFull(r, p); err != nil {
+		return Record{}, 0, errCorrupt
+	}
+	if crc32.Checksum(p, crcTable) != binary.BigEndian.Uint32(header[4:8]) {
+		return Record{}, 0, errCorrupt
+	}
+
+	keyLen := binary.BigEndian.Uint32(p[8:12])
+	if int(keyLen) > len(p)-12 {
+		return Record{}, 0, fmt.Errorf("%w: key length %d", errCorrupt, keyLen)
+	}
+
+	rec := Record{
+		Time:  time.Unix(0, int64(binary.BigEndian.Uint64(p[0:8]))),
+		Key:   p[12 : 12+keyLen],
+		Value: p[12+keyLen:],
+	}
+	return rec, int64(headerSize + size), nil
+}
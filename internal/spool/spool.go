@@ -0,0 +1,573 @@
+// Package spool is a disk-backed write-ahead log for messages that could
+// not be delivered yet. Records are appended to fixed-size segment files
+// and fsynced before Append returns; a drainer reads them back in order
+// and commits its position once they are delivered. Fully delivered
+// segments are deleted, and total disk usage is capped.
+package spool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"silentraven/internal/logging"
+)
+
+var logger = logging.For("spool")
+
+// ErrFull is returned by Append when the spool has reached MaxBytes
+var ErrFull = errors.New("spool is full")
+
+const (
+	segmentExt = ".wal"
+	cursorFile = "cursor"
+
+	// stuckAttempts is the number of failed sends of the same head batch
+	// after which Drain reports the spool as stuck
+	stuckAttempts = 10
+)
+
+// Options bounds spool disk usage
+type Options struct {
+	// MaxBytes caps the total size of all segment files
+	MaxBytes int64
+	// SegmentBytes is the size at which a new segment is started
+	SegmentBytes int64
+}
+
+// Position is a read offset within a segment
+type Position struct {
+	Segment uint64
+	Offset  int64
+}
+
+// Stats describes the spool backlog
+type Stats struct {
+	Depth    int64 `json:"depth"`
+	Bytes    int64 `json:"bytes"`
+	Segments int   `json:"segments"`
+	Rejected int64 `json:"rejected"`
+	Drained  int64 `json:"drained"`
+	// Failures counts failed sends; HeadFailures and StalledSeconds
+	// describe the batch at the head of the spool while it keeps
+	// failing, and Stuck is set once Drain has reported it
+	Failures       int64   `json:"failures"`
+	HeadFailures   int64   `json:"head_failures"`
+	StalledSeconds float64 `json:"stalled_seconds"`
+	Stuck          bool    `json:"stuck"`
+}
+
+// Spool is a durable FIFO of records
+type Spool struct {
+	dir  string
+	opts Options
+
+	mu       sync.Mutex
+	segments []uint64 // ascending; the last one is active
+	sizes    map[uint64]int64
+	active   *os.File
+	cursor   Position
+	depth    int64
+	rejected int64
+	drained  int64
+	notify   chan struct{}
+
+	failures     int64
+	headFailures int64
+	stalledSince time.Time // first failed send of the head batch
+}
+
+// Open opens or creates a spool in dir and recovers its backlog. A torn
+// record at the end of a segment (from a crash mid-write) is truncated.
+func Open(dir string, opts Options) (*Spool, error) {
+	if opts.MaxBytes <= 0 {
+		return nil, fmt.Errorf("spool max bytes must be positive")
+	}
+	if opts.SegmentBytes <= 0 || opts.SegmentBytes > opts.MaxBytes {
+		opts.SegmentBytes = min(64<<20, opts.MaxBytes)
+	}
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create spool directory: %w", err)
+	}
+
+	s := &Spool{
+		dir:    dir,
+		opts:   opts,
+		sizes:  make(map[uint64]int64),
+		notify: make(chan struct{}, 1),
+	}
+
+	if err := s.loadSegments(); err != nil {
+		return nil, err
+	}
+	if err := s.loadCursor(); err != nil {
+		return nil, err
+	}
+	if err := s.recover(); err != nil {
+		return nil, err
+	}
+	if err := s.openActive(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Append durably adds a record to the end of the spool
+func (s *Spool) Append(r Record) error {
+	return s.AppendBatch([]Record{r})
+}
+
+// AppendBatch durably adds records to the end of the spool, all or none:
+// ErrFull or a write error leaves the spool as it was. A batch is written
+// to one segment, which may take it past SegmentBytes.
+func (s *Spool) AppendBatch(records []Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+	var batch []byte
+	for _, r := range records {
+		batch = append(batch, encode(r)...)
+	}
+	size := int64(len(batch))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.totalBytes()+size > s.opts.MaxBytes {
+		s.rejected += int64(len(records))
+		return ErrFull
+	}
+
+	id := s.activeID()
+	if s.sizes[id] > 0 && s.sizes[id]+size > s.opts.SegmentBytes {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+		id = s.activeID()
+	}
+
+	if err := s.write(batch); err != nil {
+		// Cut off whatever part of the batch made it to disk
+		if terr := s.active.Truncate(s.sizes[id]); terr != nil {
+			logger.Error("Failed to roll back partial spool write", logging.Err(terr))
+		}
+		return err
+	}
+
+	s.sizes[id] += size
+	s.depth += int64(len(records))
+
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// write appends frames to the active segment and syncs it
+func (s *Spool) write(frames []byte) error {
+	if _, err := s.active.Write(frames); err != nil {
+		return fmt.Errorf("failed to write spool record: %w", err)
+	}
+	if err := s.active.Sync(); err != nil {
+		return fmt.Errorf("failed to sync spool: %w", err)
+	}
+	return nil
+}
+
+// Peek returns up to max records from the read position without
+// consuming them, and the position just past the last one returned.
+// Segments are read outside the lock so Append is never held up by disk
+// reads; only the bytes present when Peek was called are read. Peek and
+// Commit are for a single drainer.
+func (s *Spool) Peek(max int) ([]Record, Position, error) {
+	s.mu.Lock()
+	pos := s.cursor
+	depth := s.depth
+	segments := append([]uint64(nil), s.segments...)
+	sizes := make(map[uint64]int64, len(s.sizes))
+	for id, n := range s.sizes {
+		sizes[id] = n
+	}
+	s.mu.Unlock()
+
+	start := pos
+	var records []Record
+	for len(records) < max && depth > int64(len(records)) {
+		f, err := os.Open(s.segmentPath(pos.Segment))
+		if err != nil {
+			return nil, start, fmt.Errorf("failed to open spool segment: %w", err)
+		}
+		if _, err := f.Seek(pos.Offset, io.SeekStart); err != nil {
+			f.Close()
+			return nil, start, fmt.Errorf("failed to seek spool segment: %w", err)
+		}
+
+		r := io.LimitReader(f, sizes[pos.Segment]-pos.Offset)
+		for len(records) < max {
+			rec, n, err := decode(r)
+			if err != nil {
+				break
+			}
+			records = append(records, rec)
+			pos.Offset += n
+		}
+		f.Close()
+
+		last := segments[len(segments)-1]
+		if len(records) >= max || pos.Segment == last {
+			break
+		}
+		i := sort.Search(len(segments), func(i int) bool { return segments[i] > pos.Segment })
+		pos = Position{Segment: segments[i]}
+	}
+
+	return records, pos, nil
+}
+
+// Commit marks n records up to pos as delivered and deletes segments
+// that are no longer needed.
+func (s *Spool) Commit(pos Position, n int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cursor = pos
+	s.depth -= int64(n)
+	s.drained += int64(n)
+
+	// Nothing left: start over with a fresh segment so the active one
+	// does not keep already-delivered bytes around.
+	if s.depth <= 0 {
+		s.depth = 0
+		if s.sizes[s.activeID()] > 0 {
+			if err := s.rotate(); err != nil {
+				return err
+			}
+		}
+		s.cursor = Position{Segment: s.activeID()}
+	}
+
+	if err := s.saveCursor(); err != nil {
+		return err
+	}
+
+	for len(s.segments) > 1 && s.segments[0] < s.cursor.Segment {
+		id := s.segments[0]
+		if err := os.Remove(s.segmentPath(id)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove spool segment: %w", err)
+		}
+		delete(s.sizes, id)
+		s.segments = s.segments[1:]
+	}
+	return nil
+}
+
+// Stats returns the current backlog
+func (s *Spool) Stats() Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var stalled float64
+	if !s.stalledSince.IsZero() {
+		stalled = time.Since(s.stalledSince).Seconds()
+	}
+
+	return Stats{
+		Depth:    s.depth,
+		Bytes:    s.totalBytes(),
+		Segments: len(s.segments),
+		Rejected: s.rejected,
+		Drained:  s.drained,
+
+		Failures:       s.failures,
+		HeadFailures:   s.headFailures,
+		StalledSeconds: stalled,
+		Stuck:          s.headFailures >= stuckAttempts,
+	}
+}
+
+// Depth returns the number of undelivered records
+func (s *Spool) Depth() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.depth
+}
+
+// Drain delivers spooled records in order until ctx is cancelled. send
+// must deliver the whole batch or return an error; failed batches are
+// retried with backoff up to maxBackoff. Records behind a failing batch
+// wait for it, so a head batch that keeps failing is logged as an error
+// and reported in Stats until it goes through.
+func (s *Spool) Drain(ctx context.Context, send func(ctx context.Context, records []Record) error, batch int, maxBackoff time.Duration) {
+	initial := min(500*time.Millisecond, maxBackoff)
+	backoff := initial
+
+	for {
+		records, pos, err := s.Peek(batch)
+		if err != nil {
+			logger.Error("Failed to read spool", "dir", s.dir, logging.Err(err))
+		}
+
+		if len(records) == 0 {
+			// Wait for new records (or a periodic re-check)
+			select {
+			case <-ctx.Done():
+				return
+			case <-s.notify:
+			case <-time.After(maxBackoff):
+			}
+			continue
+		}
+
+		if err := send(ctx, records); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			attempts, stalled := s.sendFailed()
+			if attempts == stuckAttempts {
+				logger.Error("Spool is stuck on a batch that keeps failing", "dir", s.dir, "records", len(records),
+					"attempts", attempts, "stalled", stalled.Round(time.Second), "pending", s.Depth(), logging.Err(err))
+			} else {
+				logger.Warn("Spool drain failed", "pending", s.Depth(), "attempts", attempts, "retry_in", backoff, logging.Err(err))
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			backoff = min(backoff*2, maxBackoff)
+			continue
+		}
+		backoff = initial
+		if attempts, stalled := s.sendSucceeded(); attempts >= stuckAttempts {
+			logger.Info("Spool is moving again", "dir", s.dir, "attempts", attempts, "stalled", stalled.Round(time.Second))
+		}
+
+		if err := s.Commit(pos, len(records)); err != nil {
+			logger.Error("Failed to commit spool position", "dir", s.dir, logging.Err(err))
+			continue
+		}
+		logger.Info("Drained spooled records", "records", len(records), "pending", s.Depth())
+	}
+}
+
+// sendFailed records a failed send of the head batch and returns how
+// many times in a row it has failed and for how long
+func (s *Spool) sendFailed() (int64, time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.failures++
+	s.headFailures++
+	if s.stalledSince.IsZero() {
+		s.stalledSince = time.Now()
+	}
+	return s.headFailures, time.Since(s.stalledSince)
+}
+
+// sendSucceeded clears the head failure count, returning what it was
+func (s *Spool) sendSucceeded() (int64, time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	attempts := s.headFailures
+	var stalled time.Duration
+	if !s.stalledSince.IsZero() {
+		stalled = time.Since(s.stalledSince)
+	}
+	s.headFailures, s.stalledSince = 0, time.Time{}
+	return attempts, stalled
+}
+
+// Close closes the active segment
+func (s *Spool) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.active == nil {
+		return nil
+	}
+	err := s.active.Close()
+	s.active = nil
+	return err
+}
+
+func (s *Spool) segmentPath(id uint64) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%020d%s", id, segmentExt))
+}
+
+func (s *Spool) activeID() uint64 {
+	return s.segments[len(s.segments)-1]
+}
+
+func (s *Spool) totalBytes() int64 {
+	var total int64
+	for _, n := range s.sizes {
+		total += n
+	}
+	return total
+}
+
+// loadSegments lists existing segment files
+func (s *Spool) loadSegments() error {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return fmt.Errorf("failed to read spool directory: %w", err)
+	}
+
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), segmentExt)
+		if !ok || e.IsDir() {
+			continue
+		}
+		id, err := strconv.ParseUint(name, 10, 64)
+		if err != nil {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			return fmt.Errorf("failed to stat spool segment: %w", err)
+		}
+		s.segments = append(s.segments, id)
+		s.sizes[id] = info.Size()
+	}
+	sort.Slice(s.segments, func(i, j int) bool { return s.segments[i] < s.segments[j] })
+
+	if len(s.segments) == 0 {
+		s.segments = []uint64{1}
+		s.sizes[1] = 0
+	}
+	return nil
+}
+
+// loadCursor reads the committed read position
+func (s *Spool) loadCursor() error {
+	data, err := os.ReadFile(filepath.Join(s.dir, cursorFile))
+	if os.IsNotExist(err) {
+		s.cursor = Position{Segment: s.segments[0]}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read spool cursor: %w", err)
+	}
+
+	if _, err := fmt.Sscanf(string(data), "%d %d", &s.cursor.Segment, &s.cursor.Offset); err != nil {
+		return fmt.Errorf("invalid spool cursor %q: %w", strings.TrimSpace(string(data)), err)
+	}
+
+	// Segments before the cursor were delivered but not yet deleted
+	if s.cursor.Segment < s.segments[0] {
+		s.cursor = Position{Segment: s.segments[0]}
+	}
+	return nil
+}
+
+// saveCursor atomically persists the read position
+func (s *Spool) saveCursor() error {
+	path := filepath.Join(s.dir, cursorFile)
+	tmp := path + ".tmp"
+
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o640)
+	if err != nil {
+		return fmt.Errorf("failed to write spool cursor: %w", err)
+	}
+	fmt.Fprintf(f, "%d %d\n", s.cursor.Segment, s.cursor.Offset)
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to sync spool cursor: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write spool cursor: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// recover counts undelivered records and truncates torn writes
+func (s *Spool) recover() error {
+	for _, id := range s.segments {
+		if id < s.cursor.Segment {
+			continue
+		}
+
+		offset := int64(0)
+		if id == s.cursor.Segment {
+			offset = s.cursor.Offset
+		}
+
+		valid, count, err := scanSegment(s.segmentPath(id), offset)
+		if err != nil {
+			return err
+		}
+		s.depth += count
+
+		if valid < s.sizes[id] {
+			logger.Warn("Truncating torn spool segment", "segment", id, "bytes", valid)
+			if err := os.Truncate(s.segmentPath(id), valid); err != nil {
+				return fmt.Errorf("failed to truncate spool segment: %w", err)
+			}
+			s.sizes[id] = valid
+		}
+	}
+
+	if s.depth > 0 {
+		logger.Info("Recovered spooled records", "records", s.depth, "dir", s.dir)
+	}
+	return nil
+}
+
+// scanSegment counts records after offset and returns the end of the
+// last valid one
+func scanSegment(path string, offset int64) (int64, int64, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return 0, 0, nil
+	}
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to open spool segment: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return 0, 0, fmt.Errorf("failed to seek spool segment: %w", err)
+	}
+
+	var count int64
+	for {
+		_, n, err := decode(f)
+		if err != nil {
+			return offset, count, nil
+		}
+		offset += n
+		count++
+	}
+}
+
+// openActive opens the last segment for appending
+func (s *Spool) openActive() error {
+	f, err := os.OpenFile(s.segmentPath(s.activeID()), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+	if err != nil {
+		return fmt.Errorf("failed to open spool segment: %w", err)
+	}
+	s.active = f
+	return nil
+}
+
+// rotate closes the active segment and starts the next one
+func (s *Spool) rotate() error {
+	if err := s.active.Close(); err != nil {
+		return fmt.Errorf("failed to close spool segment: %w", err)
+	}
+
+	id := s.activeID() + 1
+	s.segments = append(s.segments, id)
+	s.sizes[id] = 0
+	return s.openActive()
+}
@@ -0,0 +1,213 @@
+package spool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func records(n int, prefix string) []Record {
+	out := make([]Record, n)
+	for i := range out {
+		out[i] = Record{
+			Key:   []byte(prefix),
+			Value: []byte(fmt.Sprintf("%s-%d", prefix, i)),
+			Time:  time.Unix(1700000000, int64(i)),
+		}
+	}
+	return out
+}
+
+func frameSize(r Record) int64 { return int64(len(encode(r))) }
+
+func TestAppendBatchIsAllOrNothing(t *testing.T) {
+	batch := records(4, "a")
+	// Room for the first batch and two more records, not a second batch
+	max := 6 * frameSize(batch[0])
+	s, err := Open(t.TempDir(), Options{MaxBytes: max})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if err := s.AppendBatch(batch); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AppendBatch(records(4, "b")); !errors.Is(err, ErrFull) {
+		t.Fatalf("AppendBatch past MaxBytes = %v, want ErrFull", err)
+	}
+
+	stats := s.Stats()
+	if stats.Depth != 4 {
+		t.Errorf("depth = %d after a rejected batch, want 4", stats.Depth)
+	}
+	if stats.Rejected != 4 {
+		t.Errorf("rejected = %d, want 4", stats.Rejected)
+	}
+	got, _, err := s.Peek(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 4 {
+		t.Fatalf("Peek returned %d records, want 4", len(got))
+	}
+	for i, r := range got {
+		if string(r.Value) != fmt.Sprintf("a-%d", i) {
+			t.Errorf("record %d = %q", i, r.Value)
+		}
+	}
+}
+
+func TestAppendBatchSurvivesReopen(t *testing.T) {
+	dir := t.TempDir()
+	batch := records(3, "a")
+	// Small segments force the second batch into a new one
+	opts := Options{MaxBytes: 1 << 20, SegmentBytes: 2 * frameSize(batch[0])}
+
+	s, err := Open(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AppendBatch(batch); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AppendBatch(records(3, "b")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err = Open(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if d := s.Depth(); d != 6 {
+		t.Errorf("depth after reopen = %d, want 6", d)
+	}
+	got, pos, err := s.Peek(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"a-0", "a-1", "a-2", "b-0", "b-1", "b-2"}
+	if len(got) != len(want) {
+		t.Fatalf("Peek returned %d records, want %d", len(got), len(want))
+	}
+	for i, r := range got {
+		if string(r.Value) != want[i] {
+			t.Errorf("record %d = %q, want %q", i, r.Value, want[i])
+		}
+	}
+	if err := s.Commit(pos, len(got)); err != nil {
+		t.Fatal(err)
+	}
+	if d := s.Depth(); d != 0 {
+		t.Errorf("depth after commit = %d, want 0", d)
+	}
+}
+
+func TestAppendEmptyBatch(t *testing.T) {
+	s, err := Open(t.TempDir(), Options{MaxBytes: 1 << 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if err := s.AppendBatch(nil); err != nil {
+		t.Errorf("AppendBatch(nil) = %v", err)
+	}
+	if d := s.Depth(); d != 0 {
+		t.Errorf("depth = %d, want 0", d)
+	}
+}
+
+// TestPeekAcrossSegments peeks across a segment rotation and
+// checks records appended after Peek wait for the next one
+func TestPeekAcrossSegments(t *testing.T) {
+	first := records(3, "a")
+	s, err := Open(t.TempDir(), Options{MaxBytes: 1 << 20, SegmentBytes: 2 * frameSize(first[0])})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	for _, r := range first {
+		if err := s.Append(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if st := s.Stats(); st.Segments != 2 {
+		t.Fatalf("segments = %d, want 2", st.Segments)
+	}
+
+	got, pos, err := s.Peek(10)
+	if err != nil || len(got) != 3 {
+		t.Fatalf("Peek = %d records, %v; want 3", len(got), err)
+	}
+	for i, r := range got {
+		if string(r.Value) != string(first[i].Value) {
+			t.Errorf("record %d = %q, want %q", i, r.Value, first[i].Value)
+		}
+	}
+	if err := s.Append(records(1, "b")[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Commit(pos, len(got)); err != nil {
+		t.Fatal(err)
+	}
+	got, _, err = s.Peek(10)
+	if err != nil || len(got) != 1 || string(got[0].Value) != "b-0" {
+		t.Fatalf("Peek after commit = %+v, %v; want b-0", got, err)
+	}
+}
+
+// TestDrainReportsStuckHead fails the head batch until it is reported
+// stuck, then lets it through
+func TestDrainReportsStuckHead(t *testing.T) {
+	s, err := Open(t.TempDir(), Options{MaxBytes: 1 << 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if err := s.AppendBatch(records(2, "a")); err != nil {
+		t.Fatal(err)
+	}
+
+	stuck := make(chan Stats)
+	release := make(chan struct{})
+	send := func(ctx context.Context, batch []Record) error {
+		if st := s.Stats(); st.HeadFailures < stuckAttempts {
+			return errors.New("gateway unavailable")
+		}
+		select {
+		case stuck <- s.Stats():
+		case <-ctx.Done():
+		}
+		<-release
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	go s.Drain(ctx, send, 10, time.Millisecond)
+
+	var st Stats
+	select {
+	case st = <-stuck:
+	case <-ctx.Done():
+		t.Fatal("head batch never reported stuck")
+	}
+	if !st.Stuck || st.HeadFailures != stuckAttempts || st.Failures != stuckAttempts || st.StalledSeconds <= 0 || st.Depth != 2 {
+		t.Errorf("stats while stuck = %+v", st)
+	}
+	close(release)
+
+	for s.Depth() > 0 && ctx.Err() == nil {
+		time.Sleep(time.Millisecond)
+	}
+	st = s.Stats()
+	if st.Stuck || st.HeadFailures != 0 || st.StalledSeconds != 0 || st.Failures != stuckAttempts || st.Drained != 2 {
+		t.Errorf("stats after delivery = %+v", st)
+	}
+}
@@ -0,0 +1,381 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"silentraven/internal/database"
+	"silentraven/internal/geo"
+	"silentraven/internal/models"
+)
+
+// MemoryStore keeps detections in process memory. It implements the same
+// filter semantics as the Postgres store using spherical geometry.
+type MemoryStore struct {
+	mu         sync.RWMutex
+	detections []models.DroneDetection
+	nextID     int64
+
+	nodes       map[string]*models.SensorNode
+	nodeEvents  []models.NodeEvent
+	nextEventID int64
+	nodeKeys    map[string][]models.NodeKey
+
+	apiKeys map[string]*models.APIKey
+
+	audit []models.AuditEvent
+
+	cases          []models.Case
+	nextCaseItemID int64
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		nextID: 1,
+		nodes:  make(map[string]*models.SensorNode),
+	}
+}
+
+// InsertDroneDetection stores a copy of the detection
+func (m *MemoryStore) InsertDroneDetection(d *models.DroneDetection) error {
+	if d.UASID == "" {
+		return fmt.Errorf("failed to insert drone detection: missing uas_id")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	d.ID = m.nextID
+	d.CreatedAt = time.Now()
+	m.nextID++
+	m.detections = append(m.detections, *d)
+	return nil
+}
+
+// GetRecentDetections returns the newest detections first
+func (m *MemoryStore) GetRecentDetections(limit int) ([]models.DroneDetection, error) {
+	page, err := m.QueryDetections(database.DetectionFilter{Limit: limit})
+	if err != nil {
+		return nil, err
+	}
+	return page.Detections, nil
+}
+
+// GetDetectionsForUAS returns one UAS's detections in [from, to], oldest first
+func (m *MemoryStore) GetDetectionsForUAS(uasID string, from, to time.Time) ([]models.DroneDetection, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var out []models.DroneDetection
+	for _, d := range m.detections {
+		if d.UASID == uasID && !d.DetectionTime.Before(from) && !d.DetectionTime.After(to) {
+			out = append(out, d)
+		}
+	}
+	sortByTime(out, true)
+	return out, nil
+}
+
+// QueryDetections returns a page of detections matching the filter
+func (m *MemoryStore) QueryDetections(f database.DetectionFilter) (*database.DetectionPage, error) {
+	if err := validateFilter(f); err != nil {
+		return nil, err
+	}
+
+	var cursorTime time.Time
+	var cursorID int64
+	if f.Cursor != "" {
+		var err error
+		if cursorTime, cursorID, err = database.DecodeCursor(f.Cursor); err != nil {
+			return nil, err
+		}
+	}
+
+	m.mu.RLock()
+	var matched []models.DroneDetection
+	for _, d := range m.detections {
+		if !matchesTemporal(d, f) || !matchesSpatial(d, f) {
+			continue
+		}
+		if f.Cursor != "" && !afterCursor(d, cursorTime, cursorID, f.Ascending) {
+			continue
+		}
+		matched = append(matched, d)
+	}
+	m.mu.RUnlock()
+
+	sortByTime(matched, f.Ascending)
+
+	limit := pageSize(f.Limit)
+	page := &database.DetectionPage{Detections: matched}
+	if len(matched) > limit {
+		page.Detections = matched[:limit]
+		last := page.Detections[limit-1]
+		page.NextCursor = database.EncodeCursor(last.DetectionTime, last.ID)
+	}
+	return page, nil
+}
+
+// LatestPerUAS returns the most recent detection of every matching UAS
+func (m *MemoryStore) LatestPerUAS(f database.DetectionFilter) ([]models.DroneDetection, error) {
+	if err := validateFilter(f); err != nil {
+		return nil, err
+	}
+
+	var out []models.DroneDetection
+	for _, d := range m.latest(f) {
+		if matchesSpatial(d, f) {
+			out = append(out, d)
+		}
+	}
+	sortByTime(out, false)
+
+	if f.Limit > 0 && len(out) > pageSize(f.Limit) {
+		out = out[:pageSize(f.Limit)]
+	}
+	return out, nil
+}
+
+// NearestUAS returns the k UAS whose latest position is closest to center
+func (m *MemoryStore) NearestUAS(center database.Point, k int, f database.DetectionFilter) ([]database.NearestDetection, error) {
+	if err := validateFilter(f); err != nil {
+		return nil, err
+	}
+	if k <= 0 {
+		k = 10
+	}
+
+	var out []database.NearestDetection
+	for _, d := range m.latest(f) {
+		if !matchesSpatial(d, f) {
+			continue
+		}
+		out = append(out, database.NearestDetection{
+			DroneDetection: d,
+			DistanceMeters: geo.Distance(center.Lat, center.Lon, d.Latitude, d.Longitude),
+		})
+	}
+
+	sort.Slice(out, func(i, j int) bool { return out[i].DistanceMeters < out[j].DistanceMeters })
+	if len(out) > pageSize(k) {
+		out = out[:pageSize(k)]
+	}
+	return out, nil
+}
+
+// Tracks groups matching detections into per-UAS tracks
+func (m *MemoryStore) Tracks(f database.DetectionFilter) ([]models.Track, error) {
+	if err := validateFilter(f); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	var matched []models.DroneDetection
+	for _, d := range m.detections {
+		if matchesTemporal(d, f) && matchesSpatial(d, f) {
+			matched = append(matched, d)
+		}
+	}
+	m.mu.RUnlock()
+
+	sort.SliceStable(matched, func(i, j int) bool {
+		if matched[i].UASID != matched[j].UASID {
+			return matched[i].UASID < matched[j].UASID
+		}
+		return matched[i].DetectionTime.Before(matched[j].DetectionTime)
+	})
+	if limit := pageSize(f.Limit); len(matched) > limit {
+		matched = matched[:limit]
+	}
+
+	var tracks []models.Track
+	for _, d := range matched {
+		if n := len(tracks); n == 0 || tracks[n-1].UASID != d.UASID {
+			tracks = append(tracks, models.Track{UASID: d.UASID, SN: d.SN})
+		}
+		tracks[len(tracks)-1].Points = append(tracks[len(tracks)-1].Points, models.TrackPoint{
+			Time:      d.DetectionTime,
+			Latitude:  d.Latitude,
+			Longitude: d.Longitude,
+			Height:    d.Height,
+		})
+	}
+	return tracks, nil
+}
+
+// Health always succeeds for the in-memory store
+func (m *MemoryStore) Health() error {
+	return nil
+}
+
+// Close releases nothing; the store stays usable
+func (m *MemoryStore) Close() error {
+	return nil
+}
+
+// latest returns each UAS's newest detection matching the temporal filter
+func (m *MemoryStore) latest(f database.DetectionFilter) []models.DroneDetection {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	byUAS := make(map[string]models.DroneDetection)
+	for _, d := range m.detections {
+		if !matchesTemporal(d, f) {
+			continue
+		}
+		if cur, ok := byUAS[d.UASID]; !ok || d.DetectionTime.After(cur.DetectionTime) {
+			byUAS[d.UASID] = d
+		}
+	}
+
+	out := make([]models.DroneDetection, 0, len(byUAS))
+	for _, d := range byUAS {
+		out = append(out, d)
+	}
+	return out
+}
+
+func validateFilter(f database.DetectionFilter) error {
+	if !f.From.IsZero() && !f.To.IsZero() && !f.From.Before(f.To) {
+		return fmt.Errorf("invalid time window: from %s is not before to %s", f.From, f.To)
+	}
+	if b := f.BBox; b != nil && b.MinLat > b.MaxLat {
+		return fmt.Errorf("invalid bounding box: min latitude above max latitude")
+	}
+	if r := f.Radius; r != nil && r.Meters <= 0 {
+		return fmt.Errorf("invalid radius: %.1f m", r.Meters)
+	}
+	for _, ring := range [][]database.Point{f.Polygon, f.OperatorPolygon} {
+		if len(ring) > 0 && len(ring) < 3 {
+			return fmt.Errorf("polygon needs at least 3 points, got %d", len(ring))
+		}
+	}
+	return nil
+}
+
+func matchesTemporal(d models.DroneDetection, f database.DetectionFilter) bool {
+	if f.UASID != "" && d.UASID != f.UASID {
+		return false
+	}
+	if f.SN != "" && d.SN != f.SN {
+		return false
+	}
+	if f.NodeID != "" && d.NodeID != f.NodeID {
+		return false
+	}
+	if !f.From.IsZero() && d.DetectionTime.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && d.DetectionTime.After(f.To) {
+		return false
+	}
+	return true
+}
+
+func matchesSpatial(d models.DroneDetection, f database.DetectionFilter) bool {
+	if b := f.BBox; b != nil {
+		if d.Latitude < b.MinLat || d.Latitude > b.MaxLat {
+			return false
+		}
+		if b.MinLon <= b.MaxLon {
+			if d.Longitude < b.MinLon || d.Longitude > b.MaxLon {
+				return false
+			}
+		} else if d.Longitude < b.MinLon && d.Longitude > b.MaxLon {
+			return false
+		}
+	}
+	if r := f.Radius; r != nil && geo.Distance(r.Lat, r.Lon, d.Latitude, d.Longitude) > r.Meters {
+		return false
+	}
+	if len(f.Polygon) > 0 && !pointInPolygon(d.Latitude, d.Longitude, f.Polygon) {
+		return false
+	}
+	if len(f.OperatorPolygon) > 0 {
+		if d.OperatorLatitude == 0 && d.OperatorLongitude == 0 {
+			return false
+		}
+		if !pointInPolygon(d.OperatorLatitude, d.OperatorLongitude, f.OperatorPolygon) {
+			return false
+		}
+	}
+	if s := f.Scope; s != nil && !inArea(d.Latitude, d.Longitude, s) {
+		return false
+	}
+	if f.MinHeight != nil && d.Height < *f.MinHeight {
+		return false
+	}
+	if f.MaxHeight != nil && d.Height > *f.MaxHeight {
+		return false
+	}
+	return true
+}
+
+// afterCursor reports whether d sorts after the cursor position
+func afterCursor(d models.DroneDetection, t time.Time, id int64, ascending bool) bool {
+	if d.DetectionTime.Equal(t) {
+		if ascending {
+			return d.ID > id
+		}
+		return d.ID < id
+	}
+	if ascending {
+		return d.DetectionTime.After(t)
+	}
+	return d.DetectionTime.Before(t)
+}
+
+// sortByTime orders by (detection_time, id)
+func sortByTime(ds []models.DroneDetection, ascending bool) {
+	sort.Slice(ds, func(i, j int) bool {
+		a, b := ds[i], ds[j]
+		if !a.DetectionTime.Equal(b.DetectionTime) {
+			if ascending {
+				return a.DetectionTime.Before(b.DetectionTime)
+			}
+			return a.DetectionTime.After(b.DetectionTime)
+		}
+		if ascending {
+			return a.ID < b.ID
+		}
+		return a.ID > b.ID
+	})
+}
+
+func pageSize(limit int) int {
+	if limit <= 0 {
+		return database.DefaultPageSize
+	}
+	if limit > database.MaxPageSize {
+		return database.MaxPageSize
+	}
+	return limit
+}
+
+// inArea reports whether a point is in a circle or polygon; an empty
+// area contains nothing
+func inArea(lat, lon float64, a *database.Area) bool {
+	switch {
+	case a.Radius != nil:
+		return geo.Distance(a.Radius.Lat, a.Radius.Lon, lat, lon) <= a.Radius.Meters
+	case len(a.Polygon) > 0:
+		return pointInPolygon(lat, lon, a.Polygon)
+	}
+	return false
+}
+
+// pointInPolygon is a planar ray-casting test on lon/lat
+func pointInPolygon(lat, lon float64, ring []database.Point) bool {
+	inside := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		pi, pj := ring[i], ring[j]
+		if (pi.Lat > lat) != (pj.Lat > lat) &&
+			lon < (pj.Lon-pi.Lon)*(lat-pi.Lat)/(pj.Lat-pi.Lat)+pi.Lon {
+			inside = !inside
+		}
+	}
+	return inside
+}
@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"silentraven/internal/database"
+	"silentraven/internal/models"
+)
+
+// CreateAPIKey stores a new key and fills in its CreatedAt
+func (m *MemoryStore) CreateAPIKey(k *models.APIKey) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.apiKeys == nil {
+		m.apiKeys = make(map[string]*models.APIKey)
+	}
+	if _, ok := m.apiKeys[k.ID]; ok {
+		return fmt.Errorf("failed to create API key: duplicate id %s", k.ID)
+	}
+	k.CreatedAt = time.Now()
+	stored := *k
+	m.apiKeys[k.ID] = &stored
+	return nil
+}
+
+// GetAPIKey returns one key or database.ErrNotFound
+func (m *MemoryStore) GetAPIKey(id string) (*models.APIKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	k, ok := m.apiKeys[id]
+	if !ok {
+		return nil, database.ErrNotFound
+	}
+	out := *k
+	return &out, nil
+}
+
+// ListAPIKeys returns every key, newest first
+func (m *MemoryStore) ListAPIKeys() ([]models.APIKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	keys := make([]models.APIKey, 0, len(m.apiKeys))
+	for _, k := range m.apiKeys {
+		keys = append(keys, *k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if !keys[i].CreatedAt.Equal(keys[j].CreatedAt) {
+			return keys[i].CreatedAt.After(keys[j].CreatedAt)
+		}
+		return keys[i].ID < keys[j].ID
+	})
+	return keys, nil
+}
+
+// RevokeAPIKey revokes a key from at; revoking twice keeps the first time
+func (m *MemoryStore) RevokeAPIKey(id string, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	k, ok := m.apiKeys[id]
+	if !ok {
+		return database.ErrNotFound
+	}
+	if k.RevokedAt.IsZero() {
+		k.RevokedAt = at
+	}
+	return nil
+}
+
+// ReplaceAPIKey records that a key was rotated to replacement and makes
+// it expire at expires, unless it already expires sooner
+func (m *MemoryStore) ReplaceAPIKey(id, replacement string, expires time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	k, ok := m.apiKeys[id]
+	if !ok {
+		return database.ErrNotFound
+	}
+	k.ReplacedBy = replacement
+	if k.ExpiresAt.IsZero() || expires.Before(k.ExpiresAt) {
+		k.ExpiresAt = expires
+	}
+	return nil
+}
+
+// TouchAPIKey records that a key was used at at
+func (m *MemoryStore) TouchAPIKey(id string, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if k, ok := m.apiKeys[id]; ok && at.After(k.LastUsedAt) {
+		k.LastUsedAt = at
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"time"
+
+	"silentraven/internal/database"
+	"silentraven/internal/models"
+)
+
+// AppendAudit chains e onto the audit log
+func (m *MemoryStore) AppendAudit(e *models.AuditEvent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var prev string
+	if n := len(m.audit); n > 0 {
+		prev = m.audit[n-1].Hash
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	e.Time = e.Time.UTC().Truncate(time.Microsecond)
+	e.Seq, e.PrevHash = int64(len(m.audit))+1, prev
+	e.Hash = e.ComputeHash()
+	m.audit = append(m.audit, *e)
+	return nil
+}
+
+// QueryAudit returns audit events matching the filter in sequence order
+func (m *MemoryStore) QueryAudit(f database.AuditFilter) ([]models.AuditEvent, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	limit := pageSize(f.Limit)
+	var events []models.AuditEvent
+	for _, e := range m.audit {
+		switch {
+		case f.Actor != "" && e.Actor != f.Actor,
+			f.Action != "" && e.Action != f.Action,
+			!f.From.IsZero() && e.Time.Before(f.From),
+			!f.To.IsZero() && e.Time.After(f.To),
+			e.Seq <= f.AfterSeq:
+			continue
+		}
+		events = append(events, e)
+		if len(events) == limit {
+			break
+		}
+	}
+	return events, nil
+}
+
+// StreamAudit calls fn for every audit event after seq afterSeq
+func (m *MemoryStore) StreamAudit(afterSeq int64, fn func(models.AuditEvent) error) error {
+	m.mu.RLock()
+	events := append([]models.AuditEvent(nil), m.audit...)
+	m.mu.RUnlock()
+
+	for _, e := range events {
+		if e.Seq <= afterSeq {
+			continue
+		}
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+
+	"silentraven/internal/database"
+	"silentraven/internal/models"
+)
+
+// CreateCase stores an open case and its attachments
+func (m *MemoryStore) CreateCase(c *models.Case) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	c.ID = int64(len(m.cases)) + 1
+	c.Status = models.CaseOpen
+	c.CreatedAt, c.UpdatedAt, c.ClosedAt = now, now, time.Time{}
+	c.Notes, c.Events = nil, nil
+	for i := range c.Attachments {
+		a := &c.Attachments[i]
+		m.nextCaseItemID++
+		a.ID, a.CaseID, a.AddedAt = m.nextCaseItemID, c.ID, now
+	}
+	m.cases = append(m.cases, cloneCase(*c))
+	return nil
+}
+
+// GetCase returns a case with its attachments, notes and history, or
+// database.ErrNotFound
+func (m *MemoryStore) GetCase(id int64) (*models.Case, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	c, ok := m.caseByID(id)
+	if !ok {
+		return nil, database.ErrNotFound
+	}
+	clone := cloneCase(*c)
+	return &clone, nil
+}
+
+// ListCases returns cases matching the filter, newest first
+func (m *MemoryStore) ListCases(f database.CaseFilter) ([]models.Case, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	limit := pageSize(f.Limit)
+	var cases []models.Case
+	for i := len(m.cases) - 1; i >= 0 && len(cases) < limit; i-- {
+		c := m.cases[i]
+		switch {
+		case f.Status != "" && c.Status != f.Status,
+			f.Assignee != "" && c.Assignee != f.Assignee,
+			f.UASID != "" && c.UASID != f.UASID,
+			f.BeforeID > 0 && c.ID >= f.BeforeID:
+			continue
+		}
+		c.Attachments, c.Notes, c.Events = nil, nil, nil
+		cases = append(cases, c)
+	}
+	return cases, nil
+}
+
+// ChangeCase sets a case's status or assignee and records the change
+func (m *MemoryStore) ChangeCase(e *models.CaseEvent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c, ok := m.caseByID(e.CaseID)
+	if !ok {
+		return database.ErrNotFound
+	}
+	if e.At.IsZero() {
+		e.At = time.Now()
+	}
+	switch e.Field {
+	case models.CaseFieldStatus:
+		if !models.CanMoveCase(c.Status, e.To) {
+			return fmt.Errorf("%w: %s to %s", database.ErrInvalidTransition, c.Status, e.To)
+		}
+		e.From, c.Status = c.Status, e.To
+		c.ClosedAt = time.Time{}
+		if e.To == models.CaseClosed {
+			c.ClosedAt = e.At
+		}
+	case models.CaseFieldAssignee:
+		e.From, c.Assignee = c.Assignee, e.To
+	default:
+		return fmt.Errorf("unknown case field %q", e.Field)
+	}
+	m.nextCaseItemID++
+	e.ID = m.nextCaseItemID
+	c.Events = append(c.Events, *e)
+	c.UpdatedAt = time.Now()
+	return nil
+}
+
+// AddCaseNote adds a note to a case
+func (m *MemoryStore) AddCaseNote(n *models.CaseNote) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c, ok := m.caseByID(n.CaseID)
+	if !ok {
+		return database.ErrNotFound
+	}
+	m.nextCaseItemID++
+	n.ID, n.CreatedAt = m.nextCaseItemID, time.Now()
+	c.Notes = append(c.Notes, *n)
+	c.UpdatedAt = n.CreatedAt
+	return nil
+}
+
+// AddCaseAttachment attaches a detection, track or evidence bundle to a
+// case
+func (m *MemoryStore) AddCaseAttachment(a *models.CaseAttachment) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c, ok := m.caseByID(a.CaseID)
+	if !ok {
+		return database.ErrNotFound
+	}
+	m.nextCaseItemID++
+	a.ID, a.AddedAt = m.nextCaseItemID, time.Now()
+	c.Attachments = append(c.Attachments, *a)
+	c.UpdatedAt = a.AddedAt
+	return nil
+}
+
+// caseByID returns the stored case; callers hold m.mu
+func (m *MemoryStore) caseByID(id int64) (*models.Case, bool) {
+	if id < 1 || id > int64(len(m.cases)) {
+		return nil, false
+	}
+	return &m.cases[id-1], true
+}
+
+// cloneCase copies c so callers cannot share its slices with the store
+func cloneCase(c models.Case) models.Case {
+	c.Attachments = append([]models.CaseAttachment(nil), c.Attachments...)
+	c.Notes = append([]models.CaseNote(nil), c.Notes...)
+	c.Events = append([]models.CaseEvent(nil), c.Events...)
+	return c
+}
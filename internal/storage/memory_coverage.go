@@ -0,0 +1,53 @@
+package storage
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"silentraven/internal/database"
+)
+
+// CoverageSamples returns the positions each node heard in [from, to),
+// snapped to database.CoverageGridDegrees
+func (m *MemoryStore) CoverageSamples(from, to time.Time, nodeID string) ([]database.CoverageSample, error) {
+	type key struct {
+		node     string
+		lat, lon float64
+	}
+	counts := make(map[key]int64)
+
+	m.mu.RLock()
+	for _, d := range m.detections {
+		if d.DetectionTime.Before(from) || !d.DetectionTime.Before(to) {
+			continue
+		}
+		if d.NodeID == "" || (nodeID != "" && d.NodeID != nodeID) {
+			continue
+		}
+		if d.Latitude == 0 && d.Longitude == 0 {
+			continue
+		}
+		counts[key{d.NodeID, snap(d.Latitude), snap(d.Longitude)}]++
+	}
+	m.mu.RUnlock()
+
+	out := make([]database.CoverageSample, 0, len(counts))
+	for k, n := range counts {
+		out = append(out, database.CoverageSample{NodeID: k.node, Latitude: k.lat, Longitude: k.lon, Detections: n})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].NodeID != out[j].NodeID {
+			return out[i].NodeID < out[j].NodeID
+		}
+		if out[i].Latitude != out[j].Latitude {
+			return out[i].Latitude < out[j].Latitude
+		}
+		return out[i].Longitude < out[j].Longitude
+	})
+	return out, nil
+}
+
+func snap(v float64) float64 {
+	return math.Round(v/database.CoverageGridDegrees) * database.CoverageGridDegrees
+}
@@ -0,0 +1,199 @@
+package storage
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"silentraven/internal/crypto"
+	"silentraven/internal/database"
+	"silentraven/internal/models"
+)
+
+// UpsertNode registers a node or updates its metadata, adding a new
+// public key to the node's key history
+func (m *MemoryStore) UpsertNode(n *models.SensorNode) error {
+	var fingerprint string
+	if n.PublicKey != "" {
+		var err error
+		if fingerprint, err = crypto.KeyFingerprint(n.PublicKey); err != nil {
+			return fmt.Errorf("failed to upsert node %s: %w", n.NodeID, err)
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	if m.nodes == nil {
+		m.nodes = make(map[string]*models.SensorNode)
+	}
+	if fingerprint != "" {
+		m.addNodeKey(models.NodeKey{NodeID: n.NodeID, Fingerprint: fingerprint, PublicKey: n.PublicKey, AddedAt: now})
+	}
+
+	existing, ok := m.nodes[n.NodeID]
+	if !ok {
+		stored := *n
+		stored.Status = models.NodeUnknown
+		stored.LastSeen = time.Time{}
+		stored.CreatedAt = now
+		stored.UpdatedAt = now
+		m.nodes[n.NodeID] = &stored
+		*n = stored
+		return nil
+	}
+
+	firmware := existing.Firmware
+	if n.Firmware != "" {
+		firmware = n.Firmware
+	}
+	status, lastSeen, created := existing.Status, existing.LastSeen, existing.CreatedAt
+	*existing = *n
+	existing.Firmware = firmware
+	existing.Status = status
+	existing.LastSeen = lastSeen
+	existing.CreatedAt = created
+	existing.UpdatedAt = now
+	*n = *existing
+	return nil
+}
+
+// addNodeKey records k unless the node already has it; callers hold m.mu
+func (m *MemoryStore) addNodeKey(k models.NodeKey) {
+	if m.nodeKeys == nil {
+		m.nodeKeys = make(map[string][]models.NodeKey)
+	}
+	for _, existing := range m.nodeKeys[k.NodeID] {
+		if existing.Fingerprint == k.Fingerprint {
+			return
+		}
+	}
+	m.nodeKeys[k.NodeID] = append(m.nodeKeys[k.NodeID], k)
+}
+
+// NodeKeys returns every key the node has been registered with, oldest first
+func (m *MemoryStore) NodeKeys(nodeID string) ([]models.NodeKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return slices.Clone(m.nodeKeys[nodeID]), nil
+}
+
+// GetNode returns one node or database.ErrNotFound
+func (m *MemoryStore) GetNode(nodeID string) (*models.SensorNode, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	n, ok := m.nodes[nodeID]
+	if !ok {
+		return nil, database.ErrNotFound
+	}
+	node := *n
+	return &node, nil
+}
+
+// ListNodes returns every registered node ordered by ID
+func (m *MemoryStore) ListNodes() ([]models.SensorNode, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	nodes := make([]models.SensorNode, 0, len(m.nodes))
+	for _, n := range m.nodes {
+		nodes = append(nodes, *n)
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].NodeID < nodes[j].NodeID })
+	return nodes, nil
+}
+
+// DeleteNode removes a node and its events
+func (m *MemoryStore) DeleteNode(nodeID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.nodes[nodeID]; !ok {
+		return database.ErrNotFound
+	}
+	delete(m.nodes, nodeID)
+
+	kept := m.nodeEvents[:0]
+	for _, e := range m.nodeEvents {
+		if e.NodeID != nodeID {
+			kept = append(kept, e)
+		}
+	}
+	m.nodeEvents = kept
+	return nil
+}
+
+// RecordHeartbeat stores a node's last-seen time, registering unknown nodes
+func (m *MemoryStore) RecordHeartbeat(hb models.NodeHeartbeat, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.nodes == nil {
+		m.nodes = make(map[string]*models.SensorNode)
+	}
+
+	n, ok := m.nodes[hb.NodeID]
+	if !ok {
+		n = &models.SensorNode{NodeID: hb.NodeID, Status: models.NodeUnknown, CreatedAt: time.Now()}
+		m.nodes[hb.NodeID] = n
+	}
+	if hb.Firmware != "" {
+		n.Firmware = hb.Firmware
+	}
+	if hb.Latitude != nil && hb.Longitude != nil {
+		n.Latitude, n.Longitude = *hb.Latitude, *hb.Longitude
+	}
+	if hb.ClockSkewMs != nil {
+		n.ClockSkewMs = *hb.ClockSkewMs
+	}
+	if at.After(n.LastSeen) {
+		n.LastSeen = at
+	}
+	n.UpdatedAt = time.Now()
+	return nil
+}
+
+// SetNodeStatus updates a node's status and records the transition
+func (m *MemoryStore) SetNodeStatus(event *models.NodeEvent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	n, ok := m.nodes[event.NodeID]
+	if !ok {
+		return database.ErrNotFound
+	}
+	n.Status = event.ToStatus
+	n.UpdatedAt = time.Now()
+
+	m.nextEventID++
+	event.ID = m.nextEventID
+	m.nodeEvents = append(m.nodeEvents, *event)
+	return nil
+}
+
+// NodeEvents returns a node's most recent status transitions
+func (m *MemoryStore) NodeEvents(nodeID string, limit int) ([]models.NodeEvent, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var events []models.NodeEvent
+	for _, e := range m.nodeEvents {
+		if e.NodeID == nodeID {
+			events = append(events, e)
+		}
+	}
+	sort.Slice(events, func(i, j int) bool {
+		if !events[i].At.Equal(events[j].At) {
+			return events[i].At.After(events[j].At)
+		}
+		return events[i].ID > events[j].ID
+	})
+	if len(events) > pageSize(limit) {
+		events = events[:pageSize(limit)]
+	}
+	return events, nil
+}
@@ -0,0 +1,175 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"silentraven/internal/database"
+)
+
+// RefreshStats is a no-op: the memory store aggregates on read
+func (m *MemoryStore) RefreshStats(from, to time.Time) error {
+	return nil
+}
+
+// StatsSeries returns unique UAS and detection counts per bucket in [from, to)
+func (m *MemoryStore) StatsSeries(bucket string, from, to time.Time) ([]database.StatsBucket, error) {
+	if _, ok := database.StatsBuckets[bucket]; !ok {
+		return nil, fmt.Errorf("unsupported bucket %q (use hour, day or week)", bucket)
+	}
+
+	type agg struct {
+		uas        map[string]bool
+		detections int64
+	}
+	buckets := make(map[time.Time]*agg)
+
+	m.mu.RLock()
+	for _, d := range m.detections {
+		if d.DetectionTime.Before(from) || !d.DetectionTime.Before(to) {
+			continue
+		}
+		b := truncateBucket(d.DetectionTime, bucket)
+		a := buckets[b]
+		if a == nil {
+			a = &agg{uas: make(map[string]bool)}
+			buckets[b] = a
+		}
+		a.uas[d.UASID] = true
+		a.detections++
+	}
+	m.mu.RUnlock()
+
+	out := make([]database.StatsBucket, 0, len(buckets))
+	for b, a := range buckets {
+		out = append(out, database.StatsBucket{Bucket: b, UniqueUAS: int64(len(a.uas)), Detections: a.detections})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Bucket.Before(out[j].Bucket) })
+	return out, nil
+}
+
+// NodeStats returns per-node activity in [from, to), busiest first
+func (m *MemoryStore) NodeStats(from, to time.Time) ([]database.NodeStat, error) {
+	uas, counts := m.groupBy(from, to, func(i int) string { return m.detections[i].NodeID })
+
+	out := make([]database.NodeStat, 0, len(counts))
+	for k, n := range counts {
+		out = append(out, database.NodeStat{NodeID: k, UniqueUAS: int64(len(uas[k])), Detections: n})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Detections > out[j].Detections })
+	return out, nil
+}
+
+// BusiestHours returns detections per hour of day (UTC), busiest first
+func (m *MemoryStore) BusiestHours(from, to time.Time) ([]database.HourStat, error) {
+	_, counts := m.groupBy(from, to, func(i int) string {
+		return fmt.Sprintf("%02d", m.detections[i].DetectionTime.UTC().Hour())
+	})
+
+	out := make([]database.HourStat, 0, len(counts))
+	for k, n := range counts {
+		var h int
+		fmt.Sscanf(k, "%d", &h)
+		out = append(out, database.HourStat{Hour: h, Detections: n})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Detections != out[j].Detections {
+			return out[i].Detections > out[j].Detections
+		}
+		return out[i].Hour < out[j].Hour
+	})
+	return out, nil
+}
+
+// TopDroneTypes returns the drone types seen by the most distinct UAS
+func (m *MemoryStore) TopDroneTypes(from, to time.Time, limit int) ([]database.DroneTypeStat, error) {
+	if limit <= 0 {
+		limit = 10
+	}
+	uas, counts := m.groupBy(from, to, func(i int) string { return m.detections[i].DroneType })
+
+	out := make([]database.DroneTypeStat, 0, len(counts))
+	for k, n := range counts {
+		out = append(out, database.DroneTypeStat{DroneType: k, UniqueUAS: int64(len(uas[k])), Detections: n})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].UniqueUAS != out[j].UniqueUAS {
+			return out[i].UniqueUAS > out[j].UniqueUAS
+		}
+		return out[i].Detections > out[j].Detections
+	})
+	if len(out) > limit {
+		out = out[:limit]
+	}
+	return out, nil
+}
+
+// LiveSummary returns today's unique UAS plus drones and nodes seen recently
+func (m *MemoryStore) LiveSummary(onlineWindow time.Duration) (*database.LiveSummary, error) {
+	now := time.Now().UTC()
+	today := now.Truncate(24 * time.Hour)
+	online := now.Add(-onlineWindow)
+
+	todayUAS := make(map[string]bool)
+	onlineUAS := make(map[string]bool)
+	nodes := make(map[string]bool)
+
+	m.mu.RLock()
+	for _, d := range m.detections {
+		if !d.DetectionTime.Before(today) {
+			todayUAS[d.UASID] = true
+		}
+		if d.DetectionTime.After(online) {
+			onlineUAS[d.UASID] = true
+			if d.NodeID != "" {
+				nodes[d.NodeID] = true
+			}
+		}
+	}
+	m.mu.RUnlock()
+
+	return &database.LiveSummary{
+		TodayUniqueUAS: int64(len(todayUAS)),
+		OnlineDrones:   int64(len(onlineUAS)),
+		NodesActive:    int64(len(nodes)),
+		AsOf:           now,
+	}, nil
+}
+
+// groupBy counts detections and distinct UAS in [from, to) per key
+func (m *MemoryStore) groupBy(from, to time.Time, key func(i int) string) (map[string]map[string]bool, map[string]int64) {
+	uas := make(map[string]map[string]bool)
+	counts := make(map[string]int64)
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for i, d := range m.detections {
+		if d.DetectionTime.Before(from) || !d.DetectionTime.Before(to) {
+			continue
+		}
+		k := key(i)
+		if uas[k] == nil {
+			uas[k] = make(map[string]bool)
+		}
+		uas[k][d.UASID] = true
+		counts[k]++
+	}
+	return uas, counts
+}
+
+// truncateBucket mirrors Postgres date_trunc for hour/day/week (UTC)
+func truncateBucket(t time.Time, bucket string) time.Time {
+	t = t.UTC()
+	switch bucket {
+	case "day":
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	case "week":
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		offset := (int(day.Weekday()) + 6) % 7 // ISO weeks start on Monday
+		return day.AddDate(0, 0, -offset)
+	default:
+		return t.Truncate(time.Hour)
+	}
+}
@@ -0,0 +1,14 @@
+package storage_test
+
+import (
+	"testing"
+
+	"silentraven/internal/storage"
+	"silentraven/internal/storage/storagetest"
+)
+
+func TestMemoryStore(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Store {
+		return storage.NewMemoryStore()
+	})
+}
@@ -0,0 +1,132 @@
+// Package storage defines the detection store used by services, with a
+// Postgres implementation (database.DB) and an in-memory implementation
+// for tests and demos.
+package storage
+
+import (
+	"fmt"
+	"time"
+
+	"silentraven/internal/database"
+	"silentraven/internal/models"
+	"silentraven/pkg/config"
+)
+
+// DetectionWriter persists detections
+type DetectionWriter interface {
+	// InsertDroneDetection stores d and fills in its ID and CreatedAt
+	InsertDroneDetection(d *models.DroneDetection) error
+}
+
+// DetectionReader queries detections and tracks
+type DetectionReader interface {
+	GetRecentDetections(limit int) ([]models.DroneDetection, error)
+	GetDetectionsForUAS(uasID string, from, to time.Time) ([]models.DroneDetection, error)
+	QueryDetections(f database.DetectionFilter) (*database.DetectionPage, error)
+	LatestPerUAS(f database.DetectionFilter) ([]models.DroneDetection, error)
+	NearestUAS(center database.Point, k int, f database.DetectionFilter) ([]database.NearestDetection, error)
+	Tracks(f database.DetectionFilter) ([]models.Track, error)
+}
+
+// StatsReader serves aggregated detection statistics
+type StatsReader interface {
+	// RefreshStats brings aggregates up to date for [from, to)
+	RefreshStats(from, to time.Time) error
+	StatsSeries(bucket string, from, to time.Time) ([]database.StatsBucket, error)
+	NodeStats(from, to time.Time) ([]database.NodeStat, error)
+	BusiestHours(from, to time.Time) ([]database.HourStat, error)
+	TopDroneTypes(from, to time.Time, limit int) ([]database.DroneTypeStat, error)
+	LiveSummary(onlineWindow time.Duration) (*database.LiveSummary, error)
+}
+
+// NodeStore persists the sensor node registry
+type NodeStore interface {
+	UpsertNode(n *models.SensorNode) error
+	// GetNode returns database.ErrNotFound for unknown nodes
+	GetNode(nodeID string) (*models.SensorNode, error)
+	ListNodes() ([]models.SensorNode, error)
+	DeleteNode(nodeID string) error
+	RecordHeartbeat(hb models.NodeHeartbeat, at time.Time) error
+	SetNodeStatus(event *models.NodeEvent) error
+	NodeEvents(nodeID string, limit int) ([]models.NodeEvent, error)
+	// NodeKeys returns every key the node has been registered with,
+	// oldest first; deleting a node keeps them
+	NodeKeys(nodeID string) ([]models.NodeKey, error)
+}
+
+// APIKeyStore persists hashed API keys
+type APIKeyStore interface {
+	CreateAPIKey(k *models.APIKey) error
+	// GetAPIKey returns database.ErrNotFound for unknown keys
+	GetAPIKey(id string) (*models.APIKey, error)
+	ListAPIKeys() ([]models.APIKey, error)
+	RevokeAPIKey(id string, at time.Time) error
+	// ReplaceAPIKey marks a rotated key and shortens its expiry to expires
+	ReplaceAPIKey(id, replacement string, expires time.Time) error
+	TouchAPIKey(id string, at time.Time) error
+}
+
+// AuditStore is the hash-chained, append-only audit log
+type AuditStore interface {
+	// AppendAudit fills in e's Seq, PrevHash and Hash and stores it
+	AppendAudit(e *models.AuditEvent) error
+	QueryAudit(f database.AuditFilter) ([]models.AuditEvent, error)
+	// StreamAudit calls fn for each event after afterSeq in order
+	StreamAudit(afterSeq int64, fn func(models.AuditEvent) error) error
+}
+
+// CaseStore persists enforcement cases
+type CaseStore interface {
+	// CreateCase stores an open case and its attachments, filling in IDs
+	// and times
+	CreateCase(c *models.Case) error
+	// GetCase returns a case with its attachments, notes and history, or
+	// database.ErrNotFound
+	GetCase(id int64) (*models.Case, error)
+	ListCases(f database.CaseFilter) ([]models.Case, error)
+	// ChangeCase sets a case's status or assignee and records the change;
+	// status changes off the workflow return database.ErrInvalidTransition
+	ChangeCase(e *models.CaseEvent) error
+	AddCaseNote(n *models.CaseNote) error
+	AddCaseAttachment(a *models.CaseAttachment) error
+}
+
+// CoverageReader supplies the samples used for coverage estimation
+type CoverageReader interface {
+	CoverageSamples(from, to time.Time, nodeID string) ([]database.CoverageSample, error)
+}
+
+// Store is the full detection storage interface
+type Store interface {
+	DetectionWriter
+	DetectionReader
+	StatsReader
+	NodeStore
+	CoverageReader
+	APIKeyStore
+	AuditStore
+	CaseStore
+	Health() error
+	Close() error
+}
+
+// Postgres must satisfy Store
+var _ Store = (*database.DB)(nil)
+
+// Backend names for config.StorageBackend
+const (
+	BackendPostgres = "postgres"
+	BackendMemory   = "memory"
+)
+
+// Open returns the store selected by cfg.StorageBackend
+func Open(cfg *config.Config) (Store, error) {
+	switch cfg.StorageBackend {
+	case BackendPostgres, "":
+		return database.New(cfg)
+	case BackendMemory:
+		return NewMemoryStore(), nil
+	default:
+		return nil, fmt.Errorf("unknown STORAGE_BACKEND %q (use postgres or memory)", cfg.StorageBackend)
+	}
+}
@@ -0,0 +1,891 @@
+// Package storagetest provides a conformance suite that every
+// storage.Store implementation must pass.
+//
+// Usage from an implementation's test:
+//
+//	func TestMemoryStore(t *testing.T) {
+//		storagetest.Run(t, func(t *testing.T) storage.Store {
+//			return storage.NewMemoryStore()
+//		})
+//	}
+package storagetest
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+
+	"silentraven/internal/crypto"
+	"silentraven/internal/database"
+	"silentraven/internal/models"
+	"silentraven/internal/storage"
+)
+
+// Run executes the conformance suite. newStore must return a store that
+// can be written to; the suite isolates its data by time window, so a
+// shared Postgres database is fine.
+func Run(t *testing.T, newStore func(t *testing.T) storage.Store) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, s storage.Store, fx *fixture)
+	}{
+		{"InsertAssignsIDs", testInsertAssignsIDs},
+		{"DetectionsForUAS", testDetectionsForUAS},
+		{"IdentityFilters", testIdentityFilters},
+		{"BoundingBox", testBoundingBox},
+		{"Radius", testRadius},
+		{"Polygon", testPolygon},
+		{"Scope", testScope},
+		{"HeightBand", testHeightBand},
+		{"Pagination", testPagination},
+		{"LatestPerUAS", testLatestPerUAS},
+		{"NearestUAS", testNearestUAS},
+		{"Tracks", testTracks},
+		{"InvalidFilters", testInvalidFilters},
+		{"Stats", testStats},
+		{"Nodes", testNodes},
+		{"NodeKeys", testNodeKeys},
+		{"CoverageSamples", testCoverageSamples},
+		{"APIKeys", testAPIKeys},
+		{"Audit", testAudit},
+		{"AuditConcurrentAppends", testAuditConcurrentAppends},
+		{"Cases", testCases},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newStore(t)
+			defer s.Close()
+			fx := seed(t, s)
+			tt.fn(t, s, fx)
+		})
+	}
+}
+
+// fixture describes the seeded data set
+type fixture struct {
+	base   time.Time
+	prefix string
+	alpha  string // 10 points flying north from (45.0, -75.0), node-a
+	bravo  string // 5 points hovering at (45.5, -75.5), node-b, with operator
+	total  int
+}
+
+func (fx *fixture) window() database.DetectionFilter {
+	return database.DetectionFilter{From: fx.base.Add(-time.Minute), To: fx.base.Add(time.Hour)}
+}
+
+// seed inserts two UAS in a unique time window
+func seed(t *testing.T, s storage.Store) *fixture {
+	t.Helper()
+
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	fx := &fixture{
+		base:   time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC).Add(time.Duration(r.Intn(1_000_000)) * time.Hour),
+		prefix: fmt.Sprintf("conf-%08x", r.Uint32()),
+	}
+	fx.alpha = fx.prefix + "-alpha"
+	fx.bravo = fx.prefix + "-bravo"
+
+	for i := 0; i < 10; i++ {
+		insert(t, s, &models.DroneDetection{
+			DetectionTime: fx.base.Add(time.Duration(i) * time.Second),
+			SN:            fx.alpha + "-sn",
+			UASID:         fx.alpha,
+			DroneType:     "quad",
+			Latitude:      45.0 + float64(i)*0.001,
+			Longitude:     -75.0,
+			Height:        float64(i * 10),
+			NodeID:        "node-a",
+		})
+	}
+	for i := 0; i < 5; i++ {
+		insert(t, s, &models.DroneDetection{
+			DetectionTime:     fx.base.Add(time.Duration(i)*time.Second + 500*time.Millisecond),
+			SN:                fx.bravo + "-sn",
+			UASID:             fx.bravo,
+			DroneType:         "fixed-wing",
+			Latitude:          45.5,
+			Longitude:         -75.5,
+			Height:            120,
+			OperatorLatitude:  45.49,
+			OperatorLongitude: -75.49,
+			NodeID:            "node-b",
+		})
+	}
+	fx.total = 15
+	return fx
+}
+
+func insert(t *testing.T, s storage.Store, d *models.DroneDetection) {
+	t.Helper()
+	if err := s.InsertDroneDetection(d); err != nil {
+		t.Fatalf("InsertDroneDetection: %v", err)
+	}
+}
+
+func query(t *testing.T, s storage.Store, f database.DetectionFilter) []models.DroneDetection {
+	t.Helper()
+	page, err := s.QueryDetections(f)
+	if err != nil {
+		t.Fatalf("QueryDetections: %v", err)
+	}
+	return page.Detections
+}
+
+func testInsertAssignsIDs(t *testing.T, s storage.Store, fx *fixture) {
+	d := &models.DroneDetection{
+		DetectionTime:  fx.base.Add(30 * time.Minute),
+		SN:             fx.prefix + "-sn",
+		UASID:          fx.prefix + "-new",
+		Latitude:       1,
+		Longitude:      2,
+		Signature:      "c2ln",
+		KeyFingerprint: "ab12",
+		RawData:        `{"UASID":"x"}`,
+	}
+	insert(t, s, d)
+	if d.ID == 0 {
+		t.Error("ID not assigned")
+	}
+	if d.CreatedAt.IsZero() {
+		t.Error("CreatedAt not assigned")
+	}
+
+	got := query(t, s, database.DetectionFilter{UASID: d.UASID})
+	if len(got) != 1 || got[0].ID != d.ID || got[0].SN != d.SN {
+		t.Fatalf("inserted detection not returned: %+v", got)
+	}
+	if got[0].Signature != d.Signature || got[0].KeyFingerprint != d.KeyFingerprint || got[0].RawData != d.RawData {
+		t.Errorf("signature fields not stored: %+v", got[0])
+	}
+	track, err := s.GetDetectionsForUAS(d.UASID, d.DetectionTime, d.DetectionTime.Add(time.Second))
+	if err != nil {
+		t.Fatalf("GetDetectionsForUAS: %v", err)
+	}
+	if len(track) != 1 || track[0].KeyFingerprint != d.KeyFingerprint {
+		t.Errorf("GetDetectionsForUAS lost the key fingerprint: %+v", track)
+	}
+}
+
+func testDetectionsForUAS(t *testing.T, s storage.Store, fx *fixture) {
+	got, err := s.GetDetectionsForUAS(fx.alpha, fx.base.Add(2*time.Second), fx.base.Add(5*time.Second))
+	if err != nil {
+		t.Fatalf("GetDetectionsForUAS: %v", err)
+	}
+	if len(got) != 4 {
+		t.Fatalf("got %d detections, want 4", len(got))
+	}
+	for i := 1; i < len(got); i++ {
+		if got[i].DetectionTime.Before(got[i-1].DetectionTime) {
+			t.Error("detections not ordered oldest first")
+		}
+	}
+}
+
+func testIdentityFilters(t *testing.T, s storage.Store, fx *fixture) {
+	f := fx.window()
+	if got := query(t, s, f); len(got) != fx.total {
+		t.Errorf("window: got %d, want %d", len(got), fx.total)
+	}
+
+	f = fx.window()
+	f.UASID = fx.bravo
+	if got := query(t, s, f); len(got) != 5 {
+		t.Errorf("uas_id: got %d, want 5", len(got))
+	}
+
+	f = fx.window()
+	f.SN = fx.alpha + "-sn"
+	if got := query(t, s, f); len(got) != 10 {
+		t.Errorf("sn: got %d, want 10", len(got))
+	}
+
+	f = fx.window()
+	f.NodeID = "node-b"
+	f.UASID = fx.bravo
+	if got := query(t, s, f); len(got) != 5 {
+		t.Errorf("node_id: got %d, want 5", len(got))
+	}
+}
+
+func testBoundingBox(t *testing.T, s storage.Store, fx *fixture) {
+	f := fx.window()
+	f.BBox = &database.BoundingBox{MinLat: 44.9, MinLon: -75.1, MaxLat: 45.0045, MaxLon: -74.9}
+	if got := query(t, s, f); len(got) != 5 {
+		t.Errorf("bbox: got %d, want 5", len(got))
+	}
+}
+
+func testRadius(t *testing.T, s storage.Store, fx *fixture) {
+	// alpha points are ~111 m apart going north
+	f := fx.window()
+	f.Radius = &database.Radius{Lat: 45.0, Lon: -75.0, Meters: 250}
+	if got := query(t, s, f); len(got) != 3 {
+		t.Errorf("radius: got %d, want 3", len(got))
+	}
+}
+
+func testPolygon(t *testing.T, s storage.Store, fx *fixture) {
+	square := []database.Point{
+		{Lat: 45.4, Lon: -75.6}, {Lat: 45.6, Lon: -75.6},
+		{Lat: 45.6, Lon: -75.4}, {Lat: 45.4, Lon: -75.4},
+	}
+
+	f := fx.window()
+	f.Polygon = square
+	if got := query(t, s, f); len(got) != 5 {
+		t.Errorf("polygon: got %d, want 5", len(got))
+	}
+
+	f = fx.window()
+	f.OperatorPolygon = square
+	if got := query(t, s, f); len(got) != 5 {
+		t.Errorf("operator polygon: got %d, want 5", len(got))
+	}
+}
+
+func testScope(t *testing.T, s storage.Store, fx *fixture) {
+	// A scope narrows the other spatial filters rather than replacing them
+	f := fx.window()
+	f.Scope = &database.Area{Radius: &database.Radius{Lat: 45.0, Lon: -75.0, Meters: 250}}
+	if got := query(t, s, f); len(got) != 3 {
+		t.Errorf("radius scope: got %d, want 3", len(got))
+	}
+	f.BBox = &database.BoundingBox{MinLat: 45.0005, MinLon: -75.1, MaxLat: 46, MaxLon: -74.9}
+	if got := query(t, s, f); len(got) != 2 {
+		t.Errorf("radius scope with bbox: got %d, want 2", len(got))
+	}
+
+	f = fx.window()
+	f.Scope = &database.Area{Polygon: []database.Point{
+		{Lat: 45.4, Lon: -75.6}, {Lat: 45.6, Lon: -75.6},
+		{Lat: 45.6, Lon: -75.4}, {Lat: 45.4, Lon: -75.4},
+	}}
+	if got := query(t, s, f); len(got) != 5 {
+		t.Errorf("polygon scope: got %d, want 5", len(got))
+	}
+	latest, err := s.LatestPerUAS(f)
+	if err != nil {
+		t.Fatalf("LatestPerUAS: %v", err)
+	}
+	if len(latest) != 1 || latest[0].UASID != fx.bravo {
+		t.Errorf("scoped latest = %d rows, want only %s", len(latest), fx.bravo)
+	}
+
+	f = fx.window()
+	f.Scope = &database.Area{}
+	if got := query(t, s, f); len(got) != 0 {
+		t.Errorf("empty scope: got %d, want 0", len(got))
+	}
+}
+
+func testHeightBand(t *testing.T, s storage.Store, fx *fixture) {
+	lo, hi := 30.0, 60.0
+	f := fx.window()
+	f.MinHeight = &lo
+	f.MaxHeight = &hi
+	if got := query(t, s, f); len(got) != 4 {
+		t.Errorf("height band: got %d, want 4", len(got))
+	}
+}
+
+func testPagination(t *testing.T, s storage.Store, fx *fixture) {
+	for _, ascending := range []bool{false, true} {
+		f := fx.window()
+		f.Limit = 4
+		f.Ascending = ascending
+
+		seen := make(map[int64]bool)
+		var last time.Time
+		pages := 0
+		for {
+			page, err := s.QueryDetections(f)
+			if err != nil {
+				t.Fatalf("QueryDetections: %v", err)
+			}
+			pages++
+			for _, d := range page.Detections {
+				if seen[d.ID] {
+					t.Fatalf("ascending=%v: duplicate ID %d across pages", ascending, d.ID)
+				}
+				seen[d.ID] = true
+				if !last.IsZero() && ascending == d.DetectionTime.Before(last) {
+					t.Fatalf("ascending=%v: out of order", ascending)
+				}
+				last = d.DetectionTime
+			}
+			if page.NextCursor == "" {
+				break
+			}
+			f.Cursor = page.NextCursor
+		}
+
+		if len(seen) != fx.total {
+			t.Errorf("ascending=%v: paged %d detections, want %d", ascending, len(seen), fx.total)
+		}
+		if pages != 4 {
+			t.Errorf("ascending=%v: got %d pages, want 4", ascending, pages)
+		}
+	}
+}
+
+func testLatestPerUAS(t *testing.T, s storage.Store, fx *fixture) {
+	got, err := s.LatestPerUAS(fx.window())
+	if err != nil {
+		t.Fatalf("LatestPerUAS: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("got %d, want 2", len(got))
+	}
+
+	want := map[string]time.Time{
+		fx.alpha: fx.base.Add(9 * time.Second),
+		fx.bravo: fx.base.Add(4*time.Second + 500*time.Millisecond),
+	}
+	for _, d := range got {
+		if !d.DetectionTime.Equal(want[d.UASID]) {
+			t.Errorf("%s: latest at %s, want %s", d.UASID, d.DetectionTime, want[d.UASID])
+		}
+	}
+
+	// Spatial filters apply to the latest position only
+	f := fx.window()
+	f.BBox = &database.BoundingBox{MinLat: 44.9, MinLon: -75.1, MaxLat: 45.0045, MaxLon: -74.9}
+	got, err = s.LatestPerUAS(f)
+	if err != nil {
+		t.Fatalf("LatestPerUAS: %v", err)
+	}
+	if len(got) != 0 {
+		t.Errorf("bbox around alpha's start: got %d, want 0", len(got))
+	}
+}
+
+func testNearestUAS(t *testing.T, s storage.Store, fx *fixture) {
+	got, err := s.NearestUAS(database.Point{Lat: 45.49, Lon: -75.49}, 10, fx.window())
+	if err != nil {
+		t.Fatalf("NearestUAS: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("got %d, want 2", len(got))
+	}
+	if got[0].UASID != fx.bravo {
+		t.Errorf("nearest is %s, want %s", got[0].UASID, fx.bravo)
+	}
+	if d := got[0].DistanceMeters; d < 1000 || d > 1600 {
+		t.Errorf("distance to bravo %.0f m, want ~1360 m", d)
+	}
+
+	got, err = s.NearestUAS(database.Point{Lat: 45.49, Lon: -75.49}, 1, fx.window())
+	if err != nil {
+		t.Fatalf("NearestUAS: %v", err)
+	}
+	if len(got) != 1 {
+		t.Errorf("k=1: got %d", len(got))
+	}
+}
+
+func testTracks(t *testing.T, s storage.Store, fx *fixture) {
+	tracks, err := s.Tracks(fx.window())
+	if err != nil {
+		t.Fatalf("Tracks: %v", err)
+	}
+	if len(tracks) != 2 {
+		t.Fatalf("got %d tracks, want 2", len(tracks))
+	}
+
+	counts := map[string]int{}
+	for _, tr := range tracks {
+		counts[tr.UASID] = len(tr.Points)
+		for i := 1; i < len(tr.Points); i++ {
+			if tr.Points[i].Time.Before(tr.Points[i-1].Time) {
+				t.Errorf("%s: points not ordered", tr.UASID)
+			}
+		}
+	}
+	if counts[fx.alpha] != 10 || counts[fx.bravo] != 5 {
+		t.Errorf("track sizes = %v", counts)
+	}
+}
+
+func testInvalidFilters(t *testing.T, s storage.Store, fx *fixture) {
+	bad := []database.DetectionFilter{
+		{From: fx.base, To: fx.base.Add(-time.Second)},
+		{BBox: &database.BoundingBox{MinLat: 10, MaxLat: 5}},
+		{Radius: &database.Radius{Lat: 1, Lon: 1, Meters: -5}},
+		{Polygon: []database.Point{{Lat: 1, Lon: 1}, {Lat: 2, Lon: 2}}},
+		{Cursor: "not a cursor!"},
+	}
+	for i, f := range bad {
+		if _, err := s.QueryDetections(f); err == nil {
+			t.Errorf("filter %d: expected error", i)
+		}
+	}
+}
+
+func testStats(t *testing.T, s storage.Store, fx *fixture) {
+	from, to := fx.base.Add(-time.Hour), fx.base.Add(time.Hour)
+	if err := s.RefreshStats(from, to); err != nil {
+		t.Fatalf("RefreshStats: %v", err)
+	}
+
+	series, err := s.StatsSeries("hour", from, to)
+	if err != nil {
+		t.Fatalf("StatsSeries: %v", err)
+	}
+	if len(series) != 1 || series[0].UniqueUAS != 2 || series[0].Detections != int64(fx.total) {
+		t.Errorf("series = %+v, want one bucket with 2 UAS / %d detections", series, fx.total)
+	}
+
+	if _, err := s.StatsSeries("fortnight", from, to); err == nil {
+		t.Error("expected error for unsupported bucket")
+	}
+
+	nodes, err := s.NodeStats(from, to)
+	if err != nil {
+		t.Fatalf("NodeStats: %v", err)
+	}
+	if len(nodes) != 2 || nodes[0].NodeID != "node-a" || nodes[0].Detections != 10 {
+		t.Errorf("nodes = %+v", nodes)
+	}
+
+	hours, err := s.BusiestHours(from, to)
+	if err != nil {
+		t.Fatalf("BusiestHours: %v", err)
+	}
+	if len(hours) != 1 || hours[0].Hour != fx.base.UTC().Hour() {
+		t.Errorf("hours = %+v", hours)
+	}
+
+	types, err := s.TopDroneTypes(from, to, 1)
+	if err != nil {
+		t.Fatalf("TopDroneTypes: %v", err)
+	}
+	if len(types) != 1 || types[0].UniqueUAS != 1 {
+		t.Errorf("types = %+v", types)
+	}
+}
+
+func testNodes(t *testing.T, s storage.Store, fx *fixture) {
+	id := fx.prefix + "-node"
+	defer s.DeleteNode(id)
+
+	if _, err := s.GetNode(id); !errors.Is(err, database.ErrNotFound) {
+		t.Fatalf("GetNode before register: err = %v, want ErrNotFound", err)
+	}
+
+	// A heartbeat from an unknown node registers it
+	lat, lon := 45.1, -75.1
+	skew := int64(-250)
+	hb := models.NodeHeartbeat{NodeID: id, Firmware: "1.2.0", Latitude: &lat, Longitude: &lon, ClockSkewMs: &skew}
+	if err := s.RecordHeartbeat(hb, fx.base); err != nil {
+		t.Fatalf("RecordHeartbeat: %v", err)
+	}
+	// An older, late-arriving heartbeat must not move last-seen backwards
+	if err := s.RecordHeartbeat(models.NodeHeartbeat{NodeID: id}, fx.base.Add(-time.Minute)); err != nil {
+		t.Fatalf("RecordHeartbeat: %v", err)
+	}
+
+	n, err := s.GetNode(id)
+	if err != nil {
+		t.Fatalf("GetNode: %v", err)
+	}
+	if !n.LastSeen.Equal(fx.base) || n.Firmware != "1.2.0" || n.Latitude != lat || n.ClockSkewMs != skew || n.Status != models.NodeUnknown {
+		t.Errorf("node after heartbeat = %+v", n)
+	}
+
+	// Metadata updates keep heartbeat-owned fields
+	update := &models.SensorNode{NodeID: id, Name: "Rooftop", AntennaType: "omni", Owner: "ops", Latitude: lat, Longitude: lon}
+	if err := s.UpsertNode(update); err != nil {
+		t.Fatalf("UpsertNode: %v", err)
+	}
+	if n, err = s.GetNode(id); err != nil {
+		t.Fatalf("GetNode: %v", err)
+	}
+	if n.Name != "Rooftop" || n.Firmware != "1.2.0" || !n.LastSeen.Equal(fx.base) {
+		t.Errorf("node after upsert = %+v", n)
+	}
+
+	for i, to := range []string{models.NodeOnline, models.NodeOffline} {
+		from := n.Status
+		event := &models.NodeEvent{NodeID: id, FromStatus: from, ToStatus: to, At: fx.base.Add(time.Duration(i+1) * time.Minute)}
+		if err := s.SetNodeStatus(event); err != nil {
+			t.Fatalf("SetNodeStatus: %v", err)
+		}
+		if event.ID == 0 {
+			t.Error("SetNodeStatus did not assign an event ID")
+		}
+		n.Status = to
+	}
+
+	events, err := s.NodeEvents(id, 10)
+	if err != nil {
+		t.Fatalf("NodeEvents: %v", err)
+	}
+	if len(events) != 2 || events[0].ToStatus != models.NodeOffline || events[1].ToStatus != models.NodeOnline {
+		t.Errorf("events = %+v, want offline then online (newest first)", events)
+	}
+
+	if err := s.SetNodeStatus(&models.NodeEvent{NodeID: id + "-missing", ToStatus: models.NodeOnline, At: fx.base}); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("SetNodeStatus on unknown node: err = %v, want ErrNotFound", err)
+	}
+
+	nodes, err := s.ListNodes()
+	if err != nil {
+		t.Fatalf("ListNodes: %v", err)
+	}
+	found := false
+	for _, n := range nodes {
+		found = found || n.NodeID == id
+	}
+	if !found {
+		t.Errorf("ListNodes missing %s", id)
+	}
+
+	if err := s.DeleteNode(id); err != nil {
+		t.Fatalf("DeleteNode: %v", err)
+	}
+	if err := s.DeleteNode(id); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("second DeleteNode: err = %v, want ErrNotFound", err)
+	}
+	if events, _ := s.NodeEvents(id, 10); len(events) != 0 {
+		t.Errorf("events survived node deletion: %+v", events)
+	}
+}
+
+func testNodeKeys(t *testing.T, s storage.Store, fx *fixture) {
+	id := fx.prefix + "-keyed"
+	defer s.DeleteNode(id)
+
+	var fingerprints []string
+	register := func(pub string) {
+		t.Helper()
+		if err := s.UpsertNode(&models.SensorNode{NodeID: id, PublicKey: pub}); err != nil {
+			t.Fatalf("UpsertNode: %v", err)
+		}
+	}
+	for range 2 {
+		key, err := crypto.GenerateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		pub, _ := crypto.PublicKeyPEM(key)
+		fp, _ := crypto.Fingerprint(&key.PublicKey)
+		fingerprints = append(fingerprints, fp)
+		register(pub)
+		// Re-registering the same key must not add it twice
+		register(pub)
+	}
+	// Clearing the key keeps the history
+	register("")
+
+	if err := s.UpsertNode(&models.SensorNode{NodeID: id, PublicKey: "not a key"}); err == nil {
+		t.Error("UpsertNode accepted an unparsable public key")
+	}
+
+	keys, err := s.NodeKeys(id)
+	if err != nil {
+		t.Fatalf("NodeKeys: %v", err)
+	}
+	if len(keys) != 2 || keys[0].Fingerprint != fingerprints[0] || keys[1].Fingerprint != fingerprints[1] {
+		t.Fatalf("keys = %+v, want %v oldest first", keys, fingerprints)
+	}
+	if keys[0].NodeID != id || keys[0].PublicKey == "" || keys[0].AddedAt.IsZero() {
+		t.Errorf("key = %+v", keys[0])
+	}
+
+	if err := s.DeleteNode(id); err != nil {
+		t.Fatalf("DeleteNode: %v", err)
+	}
+	if keys, _ := s.NodeKeys(id); len(keys) != 2 {
+		t.Errorf("keys after node deletion = %+v, want both kept", keys)
+	}
+}
+
+func testCoverageSamples(t *testing.T, s storage.Store, fx *fixture) {
+	from, to := fx.base.Add(-time.Minute), fx.base.Add(time.Minute)
+
+	samples, err := s.CoverageSamples(from, to, "")
+	if err != nil {
+		t.Fatalf("CoverageSamples: %v", err)
+	}
+
+	// alpha moves 0.001 deg per point (10 samples); bravo hovers (1 sample x 5)
+	perNode := map[string]int{}
+	var total int64
+	for _, c := range samples {
+		perNode[c.NodeID]++
+		total += c.Detections
+	}
+	if perNode["node-a"] != 10 || perNode["node-b"] != 1 || total != int64(fx.total) {
+		t.Errorf("samples per node = %v, total %d; want node-a 10, node-b 1, total %d", perNode, total, fx.total)
+	}
+
+	samples, err = s.CoverageSamples(from, to, "node-b")
+	if err != nil {
+		t.Fatalf("CoverageSamples(node-b): %v", err)
+	}
+	if len(samples) != 1 || samples[0].Detections != 5 || math.Abs(samples[0].Latitude-45.5) > 1e-9 {
+		t.Errorf("node-b samples = %+v", samples)
+	}
+}
+
+func testAPIKeys(t *testing.T, s storage.Store, fx *fixture) {
+	id := fx.prefix + "-key"
+	if _, err := s.GetAPIKey(id); !errors.Is(err, database.ErrNotFound) {
+		t.Fatalf("GetAPIKey before create: err = %v, want ErrNotFound", err)
+	}
+
+	key := &models.APIKey{ID: id, Name: "rooftop", Kind: models.KeyKindNode, Subject: "node-a", Hash: "abc"}
+	if err := s.CreateAPIKey(key); err != nil {
+		t.Fatalf("CreateAPIKey: %v", err)
+	}
+	client := &models.APIKey{ID: id + "-client", Kind: models.KeyKindClient, Subject: "agency",
+		Role: "partner", Jurisdiction: "county", Hash: "def"}
+	if err := s.CreateAPIKey(client); err != nil {
+		t.Fatalf("CreateAPIKey: %v", err)
+	}
+	if got, err := s.GetAPIKey(client.ID); err != nil || got.Role != "partner" || got.Jurisdiction != "county" {
+		t.Errorf("client key = %+v, %v", got, err)
+	}
+	if key.CreatedAt.IsZero() {
+		t.Error("CreateAPIKey did not set CreatedAt")
+	}
+
+	got, err := s.GetAPIKey(id)
+	if err != nil {
+		t.Fatalf("GetAPIKey: %v", err)
+	}
+	if got.Subject != "node-a" || got.Hash != "abc" || !got.ExpiresAt.IsZero() || !got.Active(time.Now()) {
+		t.Errorf("key = %+v", got)
+	}
+
+	// Rotation keeps the earlier of the current and grace expiries
+	expires := fx.base.Add(time.Hour)
+	if err := s.ReplaceAPIKey(id, id+"-next", expires); err != nil {
+		t.Fatalf("ReplaceAPIKey: %v", err)
+	}
+	if err := s.ReplaceAPIKey(id, id+"-next", expires.Add(time.Hour)); err != nil {
+		t.Fatalf("ReplaceAPIKey: %v", err)
+	}
+	if got, _ = s.GetAPIKey(id); got.ReplacedBy != id+"-next" || !got.ExpiresAt.Equal(expires) {
+		t.Errorf("key after rotation = %+v", got)
+	}
+
+	if err := s.TouchAPIKey(id, fx.base); err != nil {
+		t.Fatalf("TouchAPIKey: %v", err)
+	}
+	if err := s.RevokeAPIKey(id, fx.base); err != nil {
+		t.Fatalf("RevokeAPIKey: %v", err)
+	}
+	if err := s.RevokeAPIKey(id, fx.base.Add(time.Minute)); err != nil {
+		t.Fatalf("RevokeAPIKey: %v", err)
+	}
+	got, _ = s.GetAPIKey(id)
+	if !got.LastUsedAt.Equal(fx.base) || !got.RevokedAt.Equal(fx.base) || got.Active(fx.base) {
+		t.Errorf("key after revoke = %+v", got)
+	}
+
+	if err := s.RevokeAPIKey(id+"-missing", fx.base); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("RevokeAPIKey missing: err = %v, want ErrNotFound", err)
+	}
+}
+
+func testAudit(t *testing.T, s storage.Store, fx *fixture) {
+	// The log may hold earlier runs' events; work after its current head
+	var head models.AuditEvent
+	if err := s.StreamAudit(0, func(e models.AuditEvent) error { head = e; return nil }); err != nil {
+		t.Fatalf("StreamAudit: %v", err)
+	}
+
+	actor := fx.prefix + "-auditor"
+	for i, action := range []string{"access", "export", "access"} {
+		e := &models.AuditEvent{Actor: actor, ActorKind: "user", Action: action, Resource: fmt.Sprintf("r%d", i), Detail: `{"rows":1}`}
+		if err := s.AppendAudit(e); err != nil {
+			t.Fatalf("AppendAudit: %v", err)
+		}
+		if e.Seq != head.Seq+int64(i)+1 || e.Hash == "" {
+			t.Errorf("appended event = %+v", e)
+		}
+	}
+
+	// Stored events hash to what was stored and chain onto each other
+	prev := head
+	var n int
+	err := s.StreamAudit(head.Seq, func(e models.AuditEvent) error {
+		if e.Seq != prev.Seq+1 || e.PrevHash != prev.Hash || e.ComputeHash() != e.Hash {
+			t.Errorf("broken chain at seq %d: %+v", e.Seq, e)
+		}
+		prev = e
+		n++
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("StreamAudit: %v", err)
+	}
+	if n < 3 {
+		t.Errorf("streamed %d events, want at least 3", n)
+	}
+
+	events, err := s.QueryAudit(database.AuditFilter{Actor: actor, Action: "access"})
+	if err != nil {
+		t.Fatalf("QueryAudit: %v", err)
+	}
+	if len(events) != 2 || events[0].Resource != "r0" || events[1].Resource != "r2" {
+		t.Errorf("query by actor and action = %+v", events)
+	}
+	events, _ = s.QueryAudit(database.AuditFilter{Actor: actor, AfterSeq: events[0].Seq, Limit: 1})
+	if len(events) != 1 || events[0].Resource != "r1" {
+		t.Errorf("query after seq = %+v", events)
+	}
+}
+
+func testAuditConcurrentAppends(t *testing.T, s storage.Store, fx *fixture) {
+	var head models.AuditEvent
+	if err := s.StreamAudit(0, func(e models.AuditEvent) error { head = e; return nil }); err != nil {
+		t.Fatalf("StreamAudit: %v", err)
+	}
+
+	const writers, each = 8, 10
+	var wg sync.WaitGroup
+	errs := make(chan error, writers*each)
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < each; i++ {
+				e := &models.AuditEvent{Actor: fx.prefix + "-writer", ActorKind: "user", Action: "access", Resource: fmt.Sprintf("w%d/%d", w, i)}
+				if err := s.AppendAudit(e); err != nil {
+					errs <- err
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("AppendAudit: %v", err)
+	}
+
+	// Every append lands on the chain once, with no fork
+	prev, n := head, 0
+	err := s.StreamAudit(head.Seq, func(e models.AuditEvent) error {
+		if e.Seq != prev.Seq+1 || e.PrevHash != prev.Hash || e.ComputeHash() != e.Hash {
+			t.Errorf("broken chain at seq %d: %+v", e.Seq, e)
+		}
+		prev = e
+		n++
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("StreamAudit: %v", err)
+	}
+	if n != writers*each {
+		t.Errorf("chain grew by %d events, want %d", n, writers*each)
+	}
+}
+
+func testCases(t *testing.T, s storage.Store, fx *fixture) {
+	if _, err := s.GetCase(math.MaxInt32); !errors.Is(err, database.ErrNotFound) {
+		t.Fatalf("GetCase missing: err = %v, want ErrNotFound", err)
+	}
+
+	c := &models.Case{
+		Title:     "Airfield incursion",
+		Source:    models.CaseFromTrack,
+		UASID:     fx.alpha,
+		CreatedBy: "ana",
+		Attachments: []models.CaseAttachment{{
+			Kind: models.AttachTrack, Ref: fx.alpha, From: fx.base, To: fx.base.Add(time.Minute), AddedBy: "ana",
+		}},
+	}
+	if err := s.CreateCase(c); err != nil {
+		t.Fatalf("CreateCase: %v", err)
+	}
+	if c.ID == 0 || c.Status != models.CaseOpen || c.CreatedAt.IsZero() || c.Attachments[0].ID == 0 {
+		t.Errorf("created case = %+v", c)
+	}
+	other := &models.Case{Title: "Other", Source: models.CaseFromAlert, SourceRef: "geofence:stadium", UASID: fx.alpha, CreatedBy: "ana"}
+	if err := s.CreateCase(other); err != nil {
+		t.Fatalf("CreateCase: %v", err)
+	}
+
+	assign := &models.CaseEvent{CaseID: c.ID, Field: models.CaseFieldAssignee, To: "bob", Actor: "ana"}
+	if err := s.ChangeCase(assign); err != nil {
+		t.Fatalf("ChangeCase assignee: %v", err)
+	}
+	// The workflow is enforced
+	bad := &models.CaseEvent{CaseID: c.ID, Field: models.CaseFieldStatus, To: models.CaseReferred, Actor: "bob"}
+	if err := s.ChangeCase(bad); !errors.Is(err, database.ErrInvalidTransition) {
+		t.Errorf("open to referred: err = %v, want ErrInvalidTransition", err)
+	}
+	for _, status := range []string{models.CaseInvestigating, models.CaseReferred, models.CaseClosed} {
+		e := &models.CaseEvent{CaseID: c.ID, Field: models.CaseFieldStatus, To: status, Actor: "bob", At: fx.base}
+		if err := s.ChangeCase(e); err != nil {
+			t.Fatalf("ChangeCase to %s: %v", status, err)
+		}
+	}
+	missing := &models.CaseEvent{CaseID: math.MaxInt32, Field: models.CaseFieldStatus, To: models.CaseClosed}
+	if err := s.ChangeCase(missing); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("ChangeCase missing: err = %v, want ErrNotFound", err)
+	}
+
+	if err := s.AddCaseNote(&models.CaseNote{CaseID: c.ID, Author: "bob", Body: "Referred to county police"}); err != nil {
+		t.Fatalf("AddCaseNote: %v", err)
+	}
+	bundle := &models.CaseAttachment{CaseID: c.ID, Kind: models.AttachEvidence, Ref: "ev_1", SHA256: "abc", AddedBy: "bob"}
+	if err := s.AddCaseAttachment(bundle); err != nil {
+		t.Fatalf("AddCaseAttachment: %v", err)
+	}
+	if err := s.AddCaseNote(&models.CaseNote{CaseID: math.MaxInt32, Author: "bob", Body: "x"}); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("AddCaseNote missing: err = %v, want ErrNotFound", err)
+	}
+
+	got, err := s.GetCase(c.ID)
+	if err != nil {
+		t.Fatalf("GetCase: %v", err)
+	}
+	if got.Status != models.CaseClosed || got.Assignee != "bob" || !got.ClosedAt.Equal(fx.base) {
+		t.Errorf("case = %+v", got)
+	}
+	if len(got.Attachments) != 2 || !got.Attachments[0].To.Equal(fx.base.Add(time.Minute)) ||
+		got.Attachments[1].SHA256 != "abc" || !got.Attachments[1].From.IsZero() {
+		t.Errorf("attachments = %+v", got.Attachments)
+	}
+	if len(got.Notes) != 1 || got.Notes[0].Body != "Referred to county police" {
+		t.Errorf("notes = %+v", got.Notes)
+	}
+	if len(got.Events) != 4 || got.Events[0].From != "" || got.Events[0].To != "bob" ||
+		got.Events[3].From != models.CaseReferred || got.Events[3].To != models.CaseClosed {
+		t.Errorf("events = %+v", got.Events)
+	}
+
+	// Reopening clears the close time
+	reopen := &models.CaseEvent{CaseID: c.ID, Field: models.CaseFieldStatus, To: models.CaseOpen, Actor: "bob"}
+	if err := s.ChangeCase(reopen); err != nil {
+		t.Fatalf("ChangeCase reopen: %v", err)
+	}
+	if got, _ = s.GetCase(c.ID); !got.ClosedAt.IsZero() {
+		t.Errorf("reopened case closed_at = %v", got.ClosedAt)
+	}
+
+	cases, err := s.ListCases(database.CaseFilter{UASID: fx.alpha})
+	if err != nil {
+		t.Fatalf("ListCases: %v", err)
+	}
+	if len(cases) != 2 || cases[0].ID != other.ID || cases[1].ID != c.ID || cases[0].Attachments != nil {
+		t.Errorf("cases by UAS = %+v", cases)
+	}
+	cases, _ = s.ListCases(database.CaseFilter{UASID: fx.alpha, Assignee: "bob"})
+	if len(cases) != 1 || cases[0].ID != c.ID {
+		t.Errorf("cases by assignee = %+v", cases)
+	}
+	cases, _ = s.ListCases(database.CaseFilter{UASID: fx.alpha, BeforeID: other.ID, Limit: 1})
+	if len(cases) != 1 || cases[0].ID != c.ID {
+		t.Errorf("cases before ID = %+v", cases)
+	}
+}
@@ -0,0 +1,191 @@
+// Package tracing sets up OpenTelemetry tracing for the services. Trace
+// context crosses Redpanda in W3C traceparent message headers, so one
+// trace follows a detection from the gateway through ingestion and the
+// CoT publisher.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"silentraven/internal/logging"
+	"silentraven/pkg/config"
+)
+
+var logger = logging.For("tracing")
+
+// tracerName identifies spans created by this repository
+const tracerName = "silentraven"
+
+// shutdownTimeout bounds the final export of buffered spans
+const shutdownTimeout = 5 * time.Second
+
+// Init exports spans for service over OTLP/HTTP to the configured
+// collector. Without an endpoint, context is still propagated but no
+// spans are recorded. The returned function flushes pending spans at
+// shutdown.
+func Init(ctx context.Context, service string, cfg *config.Config) (func(), error) {
+	otel.SetTextMapPropagator(propagator())
+
+	if cfg.TracingEndpoint == "" {
+		return func() {}, nil
+	}
+
+	endpoint, err := endpointURL(cfg.TracingEndpoint)
+	if err != nil {
+		return nil, err
+	}
+	exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(endpoint))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+	}
+
+	tp := Setup(service, exporter, cfg.TracingSampleRatio)
+	logger.Info("Exporting traces", "endpoint", endpoint, "sample_ratio", cfg.TracingSampleRatio)
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := tp.Shutdown(ctx); err != nil {
+			logger.Warn("Failed to flush traces", logging.Err(err))
+		}
+	}, nil
+}
+
+// Setup installs a global tracer provider exporting spans for service
+// to exporter, sampling ratio of new traces. Pass an in-memory exporter
+// (sdk/trace/tracetest) to collect spans in-process.
+func Setup(service string, exporter sdktrace.SpanExporter, ratio float64) *sdktrace.TracerProvider {
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(service))),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+	)
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagator())
+	return tp
+}
+
+func propagator() propagation.TextMapPropagator {
+	return propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+}
+
+// endpointURL accepts a collector base URL (http://collector:4318) or a
+// full traces URL, and defaults the path to /v1/traces like the OTLP
+// environment variables do
+func endpointURL(endpoint string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return "", fmt.Errorf("invalid OTLP endpoint %q: want a URL such as http://collector:4318", endpoint)
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = "/v1/traces"
+	}
+	return u.String(), nil
+}
+
+// Tracer returns the tracer for the repository's spans
+func Tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+// Fail marks span as failed with err
+func Fail(span trace.Span, err error) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// FromRequest returns the request context joined to any trace the
+// caller propagated in its headers
+func FromRequest(r *http.Request) context.Context {
+	return otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+}
+
+// Inject writes the trace context in ctx into m's headers
+func Inject(ctx context.Context, m *kafka.Message) {
+	otel.GetTextMapPropagator().Inject(ctx, HeaderCarrier{&m.Headers})
+}
+
+// Extract returns ctx joined to the trace carried in m's headers
+func Extract(ctx context.Context, m kafka.Message) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, HeaderCarrier{&m.Headers})
+}
+
+// StartPublish starts a producer span for writing msgs to topic and
+// injects its context into every message
+func StartPublish(ctx context.Context, topic string, msgs []kafka.Message) (context.Context, trace.Span) {
+	ctx, span := Tracer().Start(ctx, "publish "+topic,
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			semconv.MessagingSystemKafka,
+			semconv.MessagingOperationTypePublish,
+			semconv.MessagingDestinationName(topic),
+			semconv.MessagingBatchMessageCount(len(msgs)),
+		))
+	for i := range msgs {
+		Inject(ctx, &msgs[i])
+	}
+	return ctx, span
+}
+
+// StartConsume starts a consumer span named name for processing m,
+// continuing the trace carried in its headers
+func StartConsume(ctx context.Context, name string, m kafka.Message) (context.Context, trace.Span) {
+	return Tracer().Start(Extract(ctx, m), name,
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			semconv.MessagingSystemKafka,
+			semconv.MessagingOperationTypeDeliver,
+			semconv.MessagingDestinationName(m.Topic),
+			semconv.MessagingDestinationPartitionID(strconv.Itoa(m.Partition)),
+			semconv.MessagingKafkaMessageOffset(int(m.Offset)),
+		))
+}
+
+// HeaderCarrier adapts Kafka message headers for propagation
+type HeaderCarrier struct {
+	headers *[]kafka.Header
+}
+
+// Get returns the value of the first header named key
+func (c HeaderCarrier) Get(key string) string {
+	for _, h := range *c.headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+// Set replaces any headers named key
+func (c HeaderCarrier) Set(key, value string) {
+	kept := make([]kafka.Header, 0, len(*c.headers)+1)
+	for _, h := range *c.headers {
+		if h.Key != key {
+			kept = append(kept, h)
+		}
+	}
+	*c.headers = append(kept, kafka.Header{Key: key, Value: []byte(value)})
+}
+
+// Keys lists the header names
+func (c HeaderCarrier) Keys() []string {
+	keys := make([]string, len(*c.headers))
+	for i, h := range *c.headers {
+		keys[i] = h.Key
+	}
+	return keys
+}
@@ -3,11 +3,16 @@ package config
 import (
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
-// Config holds all application configuration
+// Config holds all application configuration. Each setting has a
+// default, can be set in a YAML or TOML file (see settings for the keys),
+// and can be overridden by its environment variable.
 type Config struct {
 	// Database
 	DBHost     string
@@ -17,13 +22,31 @@ type Config struct {
 	DBPassword string
 	DBSSLMode  string
 
+	// Storage backend: "postgres" or "memory"
+	StorageBackend string
+
 	// Kafka/Redpanda
-	KafkaBrokers string
-	KafkaTopic   string
+	KafkaBrokers        string
+	KafkaTopic          string
+	KafkaHeartbeatTopic string
+
+	// Gateway store-and-forward spool (SpoolMaxMB 0 disables it)
+	SpoolDir   string
+	SpoolMaxMB int
 
 	// API
-	APIPort   string
-	APISecret string
+	APIPort              string
+	APISecret            string
+	QueryAPIPort         string
+	StatsRefreshInterval time.Duration
+
+	// Authentication: AuthMode "required" checks API keys and bearer
+	// tokens on the gateway and API ("off" for development); tokens are
+	// issued for AuthTokenTTL and signed with APISecret
+	AuthMode     string
+	AuthTokenTTL time.Duration
+	// CORSOrigins are the browser origins allowed to call the services
+	CORSOrigins []string
 
 	// Security
 	CertPath       string
@@ -31,51 +54,335 @@ type Config struct {
 	ServerCertFile string
 	ServerKeyFile  string
 
-	// Logging
-	LogLevel string
+	// Logging: LogLevel is a default level with optional per-component
+	// overrides ("info,gateway=debug"); LogFormat is text or json. Each
+	// per-detection message is logged LogSampleInitial times a second,
+	// then every LogSampleThereafter-th time (0 disables sampling).
+	LogLevel            string
+	LogFormat           string
+	LogSampleInitial    int
+	LogSampleThereafter int
+
+	// Prometheus /metrics listeners (empty disables) for services without
+	// an HTTP API, and for the gateway so its node-facing port doesn't
+	// expose them; the API serves /metrics on its port
+	GatewayMetricsAddr   string
+	IngestionMetricsAddr string
+	CoTMetricsAddr       string
+
+	// OpenTelemetry collector (OTLP/HTTP URL; empty disables export) and
+	// the fraction of new traces sampled
+	TracingEndpoint    string
+	TracingSampleRatio float64
+
+	// Retention and archival
+	RetentionRaw    time.Duration
+	RetentionTracks time.Duration
+	TrackBucket     time.Duration
+	CompressAfter   time.Duration
+	ArchiveDir      string
+	ArchivePeriod   time.Duration
+
+	// Sensor node monitoring
+	NodeDegradedAfter time.Duration
+	NodeOfflineAfter  time.Duration
+	NodeCheckInterval time.Duration
+	NodeAlertWebhook  string
+
+	// EvidenceKeyFile is the ECDSA key evidence bundles are signed with,
+	// created on first use
+	EvidenceKeyFile string
+
+	// Remote ID compliance: the fastest plausible ground speed (m/s) and
+	// the broadcast rate (Hz) below which a track is warned about
+	ComplianceMaxSpeed float64
+	ComplianceMinRate  float64
+
+	// CoT publisher destinations; TAK_MODE replaces them with one sink
+	CoTSinks []CoTSink
+	// GeoidGrid is a GTX geoid grid used instead of the embedded EGM96
+	// grid, e.g. PROJ's egm96_15.gtx
+	GeoidGrid string
+
+	// Protected areas
+	Geofences []Geofence
+
+	// Areas that scoped API roles are limited to, by name
+	Jurisdictions []Geofence
+
+	// sources records where each setting came from, by file key
+	sources map[string]string
+}
+
+// CoTSink is one TAK destination of the CoT publisher
+type CoTSink struct {
+	// Name labels the sink in logs and metrics; it defaults to Mode
+	Name string `json:"name" yaml:"name"`
+	// Mode is "tcp" (TAK Server), "direct" (UDP to one host) or
+	// "multicast" (UDP to the SA group, 239.2.3.1:6969)
+	Mode string `json:"mode" yaml:"mode"`
+	Host string `json:"host,omitempty" yaml:"host,omitempty"`
+	// Port defaults to 8088 for tcp and 6969 for direct
+	Port int `json:"port,omitempty" yaml:"port,omitempty"`
+}
+
+// Geofence is a named protected area: either a circle of RadiusM metres
+// around Latitude/Longitude, or a Polygon of [lat, lon] vertices
+type Geofence struct {
+	Name      string       `json:"name" yaml:"name"`
+	Latitude  float64      `json:"latitude,omitempty" yaml:"latitude,omitempty"`
+	Longitude float64      `json:"longitude,omitempty" yaml:"longitude,omitempty"`
+	RadiusM   float64      `json:"radius_m,omitempty" yaml:"radius_m,omitempty"`
+	Polygon   [][2]float64 `json:"polygon,omitempty" yaml:"polygon,omitempty,flow"`
+}
+
+// Jurisdiction returns the jurisdiction called name
+func (c *Config) Jurisdiction(name string) (Geofence, bool) {
+	for _, j := range c.Jurisdictions {
+		if j.Name == name {
+			return j, true
+		}
+	}
+	return Geofence{}, false
 }
 
-// Load reads configuration from environment variables
-func Load() (*Config, error) {
+// Load reads svc's configuration from the file named by CONFIG_FILE, if
+// any, and environment variables
+func Load(svc Service) (*Config, error) {
 	// Load .env file (optional in production)
 	_ = godotenv.Load()
 
-	config := &Config{
-		// Database
-		DBHost:     getEnv("DB_HOST", "localhost"),
-		DBPort:     getEnv("DB_PORT", "5432"),
-		DBName:     getEnv("DB_NAME", "silentraven"),
-		DBUser:     getEnv("DB_USER", "postgres"),
-		DBPassword: getEnv("DB_PASSWORD", ""),
-		DBSSLMode:  getEnv("DB_SSLMODE", "disable"),
+	return LoadFile(os.Getenv("CONFIG_FILE"), svc)
+}
 
-		// Kafka/Redpanda
-		KafkaBrokers: getEnv("KAFKA_BROKERS", "localhost:9092"),
-		KafkaTopic:   getEnv("KAFKA_TOPIC", "drone-detections"),
+// LoadFile reads configuration from a YAML or TOML file (none if path is
+// empty) with environment variables taking precedence, and validates it
+// for svc
+func LoadFile(path string, svc Service) (*Config, error) {
+	config := &Config{sources: make(map[string]string)}
 
-		// API
-		APIPort:   getEnv("API_PORT", "8080"),
-		APISecret: getEnv("API_SECRET", ""),
+	settings := config.settings()
+	for _, s := range settings {
+		if err := s.parse(s.def); err != nil {
+			return nil, fmt.Errorf("default %s: %w", s.key, err)
+		}
+		config.sources[s.key] = "default"
+	}
 
-		// Security
-		CertPath:       getEnv("CERT_PATH", "./certs"),
-		CACertFile:     getEnv("CA_CERT_FILE", "ca.crt"),
-		ServerCertFile: getEnv("SERVER_CERT_FILE", "server.crt"),
-		ServerKeyFile:  getEnv("SERVER_KEY_FILE", "server.key"),
+	if path != "" {
+		if err := config.loadFile(path, settings); err != nil {
+			return nil, err
+		}
+	}
+	if err := config.loadEnv(settings); err != nil {
+		return nil, err
+	}
 
-		// Logging
-		LogLevel: getEnv("LOG_LEVEL", "info"),
+	if err := config.Validate(svc.Needs...); err != nil {
+		return nil, err
 	}
+	return config, nil
+}
+
+// setting binds a Config field to its file key and environment variable
+type setting struct {
+	// key is the dotted path in the config file, e.g. "database.host"
+	key    string
+	env    string
+	def    string
+	target any // *string, *[]string, *int, *float64 or *time.Duration
+	// secret values are redacted when the config is printed, and can be
+	// read from a file named by <env>_FILE or <key>_file
+	secret bool
+	// reload settings take effect on SIGHUP; others need a restart
+	reload bool
+}
+
+// settings lists the scalar settings in the order they are printed
+func (c *Config) settings() []*setting {
+	return []*setting{
+		{key: "database.host", env: "DB_HOST", def: "localhost", target: &c.DBHost},
+		{key: "database.port", env: "DB_PORT", def: "5432", target: &c.DBPort},
+		{key: "database.name", env: "DB_NAME", def: "silentraven", target: &c.DBName},
+		{key: "database.user", env: "DB_USER", def: "postgres", target: &c.DBUser},
+		{key: "database.password", env: "DB_PASSWORD", target: &c.DBPassword, secret: true},
+		{key: "database.sslmode", env: "DB_SSLMODE", def: "disable", target: &c.DBSSLMode},
+
+		{key: "storage.backend", env: "STORAGE_BACKEND", def: "postgres", target: &c.StorageBackend},
+
+		{key: "kafka.brokers", env: "KAFKA_BROKERS", def: "localhost:9092", target: &c.KafkaBrokers},
+		{key: "kafka.topic", env: "KAFKA_TOPIC", def: "drone-detections", target: &c.KafkaTopic},
+		{key: "kafka.heartbeat_topic", env: "KAFKA_HEARTBEAT_TOPIC", def: "node-heartbeats", target: &c.KafkaHeartbeatTopic},
+
+		{key: "gateway.port", env: "API_PORT", def: "8080", target: &c.APIPort},
+		{key: "gateway.spool_dir", env: "SPOOL_DIR", def: "./spool", target: &c.SpoolDir},
+		{key: "gateway.spool_max_mb", env: "SPOOL_MAX_MB", def: "1024", target: &c.SpoolMaxMB},
+		{key: "gateway.metrics_addr", env: "GATEWAY_METRICS_ADDR", def: ":9103", target: &c.GatewayMetricsAddr},
+
+		{key: "api.port", env: "QUERY_API_PORT", def: "8081", target: &c.QueryAPIPort},
+		{key: "api.secret", env: "API_SECRET", target: &c.APISecret, secret: true},
+		{key: "api.stats_refresh_interval", env: "STATS_REFRESH_INTERVAL", def: "5m", target: &c.StatsRefreshInterval},
+
+		{key: "ingestion.metrics_addr", env: "INGESTION_METRICS_ADDR", def: ":9101", target: &c.IngestionMetricsAddr},
+
+		{key: "cot.metrics_addr", env: "COT_METRICS_ADDR", def: ":9102", target: &c.CoTMetricsAddr},
+		{key: "cot.geoid_grid", env: "GEOID_GRID", target: &c.GeoidGrid},
+
+		{key: "auth.mode", env: "AUTH_MODE", def: "required", target: &c.AuthMode},
+		{key: "auth.token_ttl", env: "AUTH_TOKEN_TTL", def: "1h", target: &c.AuthTokenTTL},
+		{key: "security.cors_origins", env: "CORS_ORIGINS", def: "*", target: &c.CORSOrigins},
 
-	// Validate required fields
-	if config.DBPassword == "" {
-		return nil, fmt.Errorf("DB_PASSWORD is required")
+		{key: "security.cert_path", env: "CERT_PATH", def: "./certs", target: &c.CertPath},
+		{key: "security.ca_cert_file", env: "CA_CERT_FILE", def: "ca.crt", target: &c.CACertFile},
+		{key: "security.server_cert_file", env: "SERVER_CERT_FILE", def: "server.crt", target: &c.ServerCertFile},
+		{key: "security.server_key_file", env: "SERVER_KEY_FILE", def: "server.key", target: &c.ServerKeyFile},
+
+		{key: "logging.level", env: "LOG_LEVEL", def: "info", target: &c.LogLevel, reload: true},
+		{key: "logging.format", env: "LOG_FORMAT", def: "text", target: &c.LogFormat, reload: true},
+		{key: "logging.sample_initial", env: "LOG_SAMPLE_INITIAL", def: "10", target: &c.LogSampleInitial, reload: true},
+		{key: "logging.sample_thereafter", env: "LOG_SAMPLE_THEREAFTER", def: "100", target: &c.LogSampleThereafter, reload: true},
+
+		{key: "tracing.endpoint", env: "OTEL_EXPORTER_OTLP_ENDPOINT", target: &c.TracingEndpoint},
+		{key: "tracing.sample_ratio", env: "TRACE_SAMPLE_RATIO", def: "1", target: &c.TracingSampleRatio},
+
+		{key: "retention.raw", env: "RETENTION_RAW", def: "90d", target: &c.RetentionRaw},
+		{key: "retention.tracks", env: "RETENTION_TRACKS", def: "730d", target: &c.RetentionTracks},
+		{key: "retention.track_bucket", env: "TRACK_BUCKET", def: "1m", target: &c.TrackBucket},
+		{key: "retention.compress_after", env: "COMPRESS_AFTER", def: "7d", target: &c.CompressAfter},
+		{key: "retention.archive_dir", env: "ARCHIVE_DIR", def: "./archive", target: &c.ArchiveDir},
+		{key: "retention.archive_period", env: "ARCHIVE_PERIOD", def: "24h", target: &c.ArchivePeriod},
+
+		{key: "nodes.degraded_after", env: "NODE_DEGRADED_AFTER", def: "1m", target: &c.NodeDegradedAfter},
+		{key: "nodes.offline_after", env: "NODE_OFFLINE_AFTER", def: "5m", target: &c.NodeOfflineAfter},
+		{key: "nodes.check_interval", env: "NODE_CHECK_INTERVAL", def: "15s", target: &c.NodeCheckInterval},
+		// Webhook URLs usually embed a token
+		{key: "nodes.alert_webhook", env: "NODE_ALERT_WEBHOOK", target: &c.NodeAlertWebhook, secret: true},
+
+		{key: "evidence.key_file", env: "EVIDENCE_KEY_FILE", def: "./keys/evidence.key", target: &c.EvidenceKeyFile},
+
+		{key: "compliance.max_speed", env: "COMPLIANCE_MAX_SPEED", def: "100", target: &c.ComplianceMaxSpeed},
+		{key: "compliance.min_rate", env: "COMPLIANCE_MIN_RATE", def: "1", target: &c.ComplianceMinRate},
+	}
+}
+
+// parse sets the setting from its string form
+func (s *setting) parse(value string) error {
+	switch t := s.target.(type) {
+	case *string:
+		*t = value
+	case *[]string:
+		*t = splitList(value)
+	case *int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", value)
+		}
+		*t = n
+	case *float64:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", value)
+		}
+		*t = f
+	case *time.Duration:
+		d, err := ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		*t = d
 	}
-	if config.APISecret == "" {
-		return nil, fmt.Errorf("API_SECRET is required")
+	return nil
+}
+
+// loadEnv applies the environment variables that are set
+func (c *Config) loadEnv(settings []*setting) error {
+	for _, s := range settings {
+		value, source, err := s.lookupEnv()
+		if err != nil {
+			return err
+		}
+		if source == "" {
+			continue
+		}
+		if err := s.parse(value); err != nil {
+			return fmt.Errorf("%s: %w", source, err)
+		}
+		c.sources[s.key] = "env " + source
 	}
 
-	return config, nil
+	sinks, err := envSinks()
+	if err != nil {
+		return err
+	}
+	if sinks != nil {
+		c.CoTSinks = sinks
+		c.sources["cot.sinks"] = "env TAK_MODE"
+	}
+	return nil
+}
+
+// lookupEnv returns the setting's environment value and the variable it
+// came from, reading secrets from the file named by <env>_FILE (as with
+// Docker secrets) when set
+func (s *setting) lookupEnv() (value, source string, err error) {
+	value = os.Getenv(s.env)
+	if !s.secret {
+		if value == "" {
+			return "", "", nil
+		}
+		return value, s.env, nil
+	}
+
+	fileEnv := s.env + "_FILE"
+	path := os.Getenv(fileEnv)
+	switch {
+	case path == "" && value == "":
+		return "", "", nil
+	case path == "":
+		return value, s.env, nil
+	case value != "":
+		return "", "", fmt.Errorf("set %s or %s, not both", s.env, fileEnv)
+	}
+
+	value, err = readSecret(path)
+	if err != nil {
+		return "", "", fmt.Errorf("%s: %w", fileEnv, err)
+	}
+	return value, fileEnv, nil
+}
+
+// readSecret reads a secret from a file, dropping the trailing newline
+func readSecret(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret: %w", err)
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// envSinks returns the single sink configured by TAK_MODE, if set
+func envSinks() ([]CoTSink, error) {
+	mode := os.Getenv("TAK_MODE")
+	if mode == "" {
+		return nil, nil
+	}
+
+	sink := CoTSink{Name: mode, Mode: mode}
+	var port string
+	switch mode {
+	case "tcp":
+		sink.Host, port = os.Getenv("TAK_SERVER_IP"), os.Getenv("TAK_SERVER_PORT")
+	case "direct":
+		sink.Host, port = os.Getenv("TAK_TARGET_IP"), os.Getenv("TAK_TARGET_PORT")
+	}
+	if port != "" {
+		n, err := strconv.Atoi(port)
+		if err != nil {
+			return nil, fmt.Errorf("TAK port: invalid integer %q", port)
+		}
+		sink.Port = n
+	}
+	return []CoTSink{sink}, nil
 }
 
 // GetDBConnectionString returns PostgreSQL connection string
@@ -91,10 +398,30 @@ func (c *Config) GetAPIAddress() string {
 	return ":" + c.APIPort
 }
 
-// getEnv reads environment variable with fallback default
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+// GetQueryAPIAddress returns the query API listen address
+func (c *Config) GetQueryAPIAddress() string {
+	return ":" + c.QueryAPIPort
+}
+
+// splitList splits a comma-separated list, dropping empty items
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// ParseDuration extends time.ParseDuration with a "d" (day) suffix
+func ParseDuration(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
 	}
-	return defaultValue
+	return time.ParseDuration(s)
 }
@@ -0,0 +1,182 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// clearEnv unsets every variable a setting could be read from
+func clearEnv(t *testing.T) {
+	t.Helper()
+	for _, s := range (&Config{}).settings() {
+		t.Setenv(s.env, "")
+		t.Setenv(s.env+"_FILE", "")
+	}
+	t.Setenv("TAK_MODE", "")
+}
+
+// writeFile writes content to name in a temporary directory
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestSecretFiles(t *testing.T) {
+	svc := Service{Name: "test", Needs: []Requirement{Database, APISecret}}
+	secret := writeFile(t, "db_password", "s3cret\n")
+
+	t.Run("env file", func(t *testing.T) {
+		clearEnv(t)
+		t.Setenv("DB_PASSWORD_FILE", secret)
+		t.Setenv("API_SECRET", "api")
+		cfg, err := LoadFile("", svc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.DBPassword != "s3cret" || cfg.sources["database.password"] != "env DB_PASSWORD_FILE" {
+			t.Errorf("password = %q from %s, want s3cret from DB_PASSWORD_FILE", cfg.DBPassword, cfg.sources["database.password"])
+		}
+	})
+
+	t.Run("config file key", func(t *testing.T) {
+		clearEnv(t)
+		path := writeFile(t, "config.yaml", "database:\n  password_file: "+secret+"\napi:\n  secret: api\n")
+		cfg, err := LoadFile(path, svc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.DBPassword != "s3cret" {
+			t.Errorf("password = %q, want s3cret", cfg.DBPassword)
+		}
+	})
+
+	tests := []struct {
+		name string
+		env  map[string]string
+		file string
+		want string
+	}{
+		{"env and file env", map[string]string{"DB_PASSWORD": "x", "DB_PASSWORD_FILE": secret}, "", "not both"},
+		{"missing env file", map[string]string{"DB_PASSWORD_FILE": secret + ".missing"}, "", "DB_PASSWORD_FILE"},
+		{"value and file key", nil, "database:\n  password: x\n  password_file: " + secret + "\n", "not both"},
+		{"file key on a plain setting", nil, "database:\n  host_file: " + secret + "\n", "unknown setting"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			t.Setenv("API_SECRET", "api")
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			path := ""
+			if tt.file != "" {
+				path = writeFile(t, "config.yaml", tt.file)
+			}
+			if _, err := LoadFile(path, svc); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("LoadFile = %v, want an error mentioning %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestNeeds(t *testing.T) {
+	tests := []struct {
+		svc     Service
+		env     map[string]string
+		missing []string // settings reported missing; none means it loads
+	}{
+		{Gateway, nil, []string{"database.password"}},
+		{Gateway, map[string]string{"AUTH_MODE": "off"}, nil},
+		{Ingestion, nil, []string{"database.password"}},
+		{Ingestion, map[string]string{"STORAGE_BACKEND": "memory"}, nil},
+		{API, nil, []string{"database.password", "api.secret"}},
+		{API, map[string]string{"DB_PASSWORD": "x"}, []string{"api.secret"}},
+		{CoTPublisher, nil, []string{"cot.sinks"}},
+		{CoTPublisher, map[string]string{"TAK_MODE": "multicast"}, nil},
+		{Archiver, map[string]string{"DB_PASSWORD": "x"}, nil},
+		{Migrate, nil, []string{"database.password"}},
+		{Service{Name: "tool"}, nil, nil},
+	}
+	for _, tt := range tests {
+		clearEnv(t)
+		for k, v := range tt.env {
+			t.Setenv(k, v)
+		}
+		_, err := LoadFile("", tt.svc)
+		if len(tt.missing) == 0 {
+			if err != nil {
+				t.Errorf("%s %v: %v", tt.svc.Name, tt.env, err)
+			}
+			continue
+		}
+		var verr *ValidationError
+		if !errors.As(err, &verr) || len(verr.Problems) != len(tt.missing) {
+			t.Errorf("%s %v: err = %v, want %v missing", tt.svc.Name, tt.env, err, tt.missing)
+			continue
+		}
+		for i, key := range tt.missing {
+			if !strings.HasPrefix(verr.Problems[i], key) {
+				t.Errorf("%s %v: problem %q, want %s", tt.svc.Name, tt.env, verr.Problems[i], key)
+			}
+		}
+	}
+}
+
+func TestChanges(t *testing.T) {
+	clearEnv(t)
+	base, err := LoadFile("", Service{Name: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	with := func(modify func(c *Config)) *Config {
+		next := *base
+		next.CoTSinks = append([]CoTSink(nil), base.CoTSinks...)
+		modify(&next)
+		return &next
+	}
+
+	tests := []struct {
+		name            string
+		next            *Config
+		reload, restart []string
+	}{
+		{"nothing", with(func(c *Config) {}), nil, nil},
+		{"log level", with(func(c *Config) { c.LogLevel = "debug" }), []string{"logging.level"}, nil},
+		{"sinks", with(func(c *Config) { c.CoTSinks = []CoTSink{{Name: "m", Mode: "multicast"}} }), []string{"cot.sinks"}, nil},
+		{"database", with(func(c *Config) { c.DBHost = "db" }), nil, []string{"database.host"}},
+		{"secret", with(func(c *Config) { c.APISecret = "rotated" }), nil, []string{"api.secret"}},
+		{"compliance", with(func(c *Config) { c.ComplianceMaxSpeed = 50 }), nil, []string{"compliance.max_speed"}},
+		{"geofences", with(func(c *Config) {
+			c.Geofences = []Geofence{{Name: "airport", Latitude: 51.47, Longitude: -0.45, RadiusM: 5000}}
+		}), nil, []string{"geofences"}},
+		{"jurisdictions", with(func(c *Config) {
+			c.Jurisdictions = []Geofence{{Name: "north", Latitude: 51.5, Longitude: -0.1, RadiusM: 5000}}
+		}), nil, []string{"jurisdictions"}},
+		{"both", with(func(c *Config) { c.LogFormat = "json"; c.RetentionRaw *= 2 }), []string{"logging.format"}, []string{"retention.raw"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reload, restart := base.Changes(tt.next)
+			if strings.Join(reload, ",") != strings.Join(tt.reload, ",") || strings.Join(restart, ",") != strings.Join(tt.restart, ",") {
+				t.Errorf("Changes = %v, %v; want %v, %v", reload, restart, tt.reload, tt.restart)
+			}
+			err := base.CheckReload(tt.next)
+			if len(tt.restart) == 0 {
+				if err != nil {
+					t.Errorf("CheckReload = %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrRestartRequired) || !strings.Contains(err.Error(), tt.restart[0]) {
+				t.Errorf("CheckReload = %v, want ErrRestartRequired naming %s", err, tt.restart[0])
+			}
+		})
+	}
+}
@@ -0,0 +1,346 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// redacted replaces secret values when the config is printed
+const redacted = "********"
+
+// loadFile applies the settings in a .yaml, .yml or .toml file
+func (c *Config) loadFile(path string, settings []*setting) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	raw := make(map[string]any)
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &raw)
+	case ".toml":
+		err = toml.Unmarshal(data, &raw)
+	default:
+		return fmt.Errorf("%s: unsupported config file type, want .yaml or .toml", path)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	byKey := make(map[string]*setting, len(settings))
+	for _, s := range settings {
+		byKey[s.key] = s
+	}
+
+	v := &ValidationError{}
+	c.applyFile(raw, "", byKey, v)
+	if len(v.Problems) > 0 {
+		v.Source = path
+		return v
+	}
+	return nil
+}
+
+// applyFile walks a decoded section, setting the fields its keys name
+func (c *Config) applyFile(section map[string]any, prefix string, byKey map[string]*setting, v *ValidationError) {
+	keys := make([]string, 0, len(section))
+	for k := range section {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		key, value := prefix+k, section[k]
+
+		var err error
+		switch {
+		case key == "cot.sinks":
+			err = decodeList(value, &c.CoTSinks)
+		case key == "geofences":
+			err = decodeList(value, &c.Geofences)
+		case key == "jurisdictions":
+			err = decodeList(value, &c.Jurisdictions)
+		case byKey[key] != nil:
+			err = byKey[key].set(value)
+		case secretFile(key, byKey) != nil:
+			name := strings.TrimSuffix(k, "_file")
+			if _, ok := section[name]; ok {
+				v.add(key, "set %s or %s, not both", name, k)
+				continue
+			}
+			if err := secretFile(key, byKey).setFromFile(value); err != nil {
+				v.add(key, "%v", err)
+				continue
+			}
+			c.sources[prefix+name] = "file " + k
+			continue
+		default:
+			if sub, ok := value.(map[string]any); ok && c.isSection(key, byKey) {
+				c.applyFile(sub, key+".", byKey, v)
+				continue
+			}
+			v.add(key, "unknown setting")
+			continue
+		}
+
+		if err != nil {
+			v.add(key, "%v", err)
+			continue
+		}
+		c.sources[key] = "file"
+	}
+}
+
+// isSection reports whether any setting lives under key
+func (c *Config) isSection(key string, byKey map[string]*setting) bool {
+	if key == "cot" {
+		return true
+	}
+	for k := range byKey {
+		if strings.HasPrefix(k, key+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// secretFile returns the secret setting whose file key is key, if any
+func secretFile(key string, byKey map[string]*setting) *setting {
+	name, ok := strings.CutSuffix(key, "_file")
+	if !ok {
+		return nil
+	}
+	if s := byKey[name]; s != nil && s.secret {
+		return s
+	}
+	return nil
+}
+
+// setFromFile sets a secret setting from the file named by value
+func (s *setting) setFromFile(value any) error {
+	path, ok := value.(string)
+	if !ok {
+		return fmt.Errorf("want a file path, got %v", value)
+	}
+	secret, err := readSecret(path)
+	if err != nil {
+		return err
+	}
+	return s.parse(secret)
+}
+
+// set sets the setting from a decoded YAML or TOML value
+func (s *setting) set(value any) error {
+	switch t := s.target.(type) {
+	case *string:
+		switch value.(type) {
+		case string, int, int64, float64:
+			*t = fmt.Sprint(value)
+			return nil
+		}
+	case *[]string:
+		switch list := value.(type) {
+		case string:
+			*t = splitList(list)
+			return nil
+		case []any:
+			items := make([]string, 0, len(list))
+			for _, item := range list {
+				str, ok := item.(string)
+				if !ok {
+					return fmt.Errorf("want a list of strings, got %v", value)
+				}
+				items = append(items, str)
+			}
+			*t = items
+			return nil
+		}
+		return fmt.Errorf("want a list of strings, got %v", value)
+	case *int:
+		if n, ok := integer(value); ok {
+			*t = n
+			return nil
+		}
+		return fmt.Errorf("want an integer, got %v", value)
+	case *float64:
+		switch n := value.(type) {
+		case float64:
+			*t = n
+			return nil
+		case int:
+			*t = float64(n)
+			return nil
+		case int64:
+			*t = float64(n)
+			return nil
+		}
+		return fmt.Errorf("want a number, got %v", value)
+	case *time.Duration:
+		if str, ok := value.(string); ok {
+			return s.parse(str)
+		}
+		return fmt.Errorf("want a duration such as \"90d\", \"36h\" or \"15m\", got %v", value)
+	}
+	return fmt.Errorf("want a string, got %v", value)
+}
+
+// integer converts the integer types YAML and TOML decode to
+func integer(value any) (int, bool) {
+	switch n := value.(type) {
+	case int:
+		return n, true
+	case int64:
+		return int(n), true
+	case float64:
+		if n == math.Trunc(n) {
+			return int(n), true
+		}
+	}
+	return 0, false
+}
+
+// decodeList decodes a list of tables into target, rejecting unknown
+// fields
+func decodeList(value any, target any) error {
+	if _, ok := value.([]any); !ok {
+		if _, ok := value.([]map[string]any); !ok {
+			return fmt.Errorf("want a list, got %v", value)
+		}
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(target); err != nil {
+		return fmt.Errorf("%s", strings.TrimPrefix(err.Error(), "json: "))
+	}
+	return nil
+}
+
+// WriteYAML writes the effective configuration as YAML, with secrets
+// redacted and each setting's source (default, file or env) as a comment
+func (c *Config) WriteYAML(w io.Writer) error {
+	root := &yaml.Node{Kind: yaml.MappingNode}
+	sections := make(map[string]*yaml.Node)
+
+	// section returns the mapping for key's section, creating it in order
+	section := func(key string) (*yaml.Node, string) {
+		name, field, ok := strings.Cut(key, ".")
+		if !ok {
+			return root, key
+		}
+		node := sections[name]
+		if node == nil {
+			node = &yaml.Node{Kind: yaml.MappingNode}
+			sections[name] = node
+			root.Content = append(root.Content, scalar(name), node)
+		}
+		return node, field
+	}
+
+	for _, s := range c.settings() {
+		value := s.String()
+		if s.secret && value != "" {
+			value = redacted
+		}
+		node, field := section(s.key)
+		v := scalar(value)
+		v.LineComment = c.sources[s.key]
+		node.Content = append(node.Content, scalar(field), v)
+
+		// The sinks follow the CoT publisher's scalar settings
+		if s.key == "cot.metrics_addr" {
+			if err := c.appendList(node, "sinks", "cot.sinks", c.CoTSinks); err != nil {
+				return err
+			}
+		}
+	}
+	if err := c.appendList(root, "geofences", "geofences", c.Geofences); err != nil {
+		return err
+	}
+	if err := c.appendList(root, "jurisdictions", "jurisdictions", c.Jurisdictions); err != nil {
+		return err
+	}
+
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(root); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// appendList adds a list setting to mapping node
+func (c *Config) appendList(node *yaml.Node, field, key string, list any) error {
+	value := &yaml.Node{}
+	if err := value.Encode(list); err != nil {
+		return fmt.Errorf("failed to encode %s: %w", key, err)
+	}
+	if value.Kind == yaml.ScalarNode {
+		// A nil list encodes as null
+		value = &yaml.Node{Kind: yaml.SequenceNode, Style: yaml.FlowStyle}
+	}
+	// Comments on block sequences attach to the following key
+	name := scalar(field)
+	name.LineComment = c.sources[key]
+	node.Content = append(node.Content, name, value)
+	return nil
+}
+
+func scalar(value string) *yaml.Node {
+	node := &yaml.Node{Kind: yaml.ScalarNode, Value: value}
+	if value == "" {
+		// Print "" rather than null
+		node.Style = yaml.DoubleQuotedStyle
+	}
+	return node
+}
+
+// String returns the setting's value in the form it is configured in
+func (s *setting) String() string {
+	switch t := s.target.(type) {
+	case *string:
+		return *t
+	case *[]string:
+		return strings.Join(*t, ",")
+	case *int:
+		return fmt.Sprint(*t)
+	case *float64:
+		return fmt.Sprint(*t)
+	case *time.Duration:
+		return FormatDuration(*t)
+	}
+	return ""
+}
+
+// FormatDuration is the inverse of ParseDuration: whole days print as
+// "90d", other durations without zero units, e.g. "5m" not "5m0s"
+func FormatDuration(d time.Duration) string {
+	const day = 24 * time.Hour
+	if d != 0 && d%day == 0 {
+		return fmt.Sprintf("%dd", d/day)
+	}
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
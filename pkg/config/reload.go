@@ -0,0 +1,50 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// ErrRestartRequired is returned by CheckReload when a new configuration
+// changes settings that only take effect on restart
+var ErrRestartRequired = errors.New("restart required")
+
+// Changes compares c with next, returning the keys of the changed
+// settings that take effect on reload and of those that need a restart.
+// Only the logging settings and the CoT sinks can be reloaded.
+func (c *Config) Changes(next *Config) (reload, restart []string) {
+	nextSettings := next.settings()
+	for i, s := range c.settings() {
+		if s.String() == nextSettings[i].String() {
+			continue
+		}
+		if s.reload {
+			reload = append(reload, s.key)
+		} else {
+			restart = append(restart, s.key)
+		}
+	}
+
+	if !reflect.DeepEqual(c.CoTSinks, next.CoTSinks) {
+		reload = append(reload, "cot.sinks")
+	}
+	if !reflect.DeepEqual(c.Geofences, next.Geofences) {
+		restart = append(restart, "geofences")
+	}
+	if !reflect.DeepEqual(c.Jurisdictions, next.Jurisdictions) {
+		restart = append(restart, "jurisdictions")
+	}
+	return reload, restart
+}
+
+// CheckReload reports whether next can replace the running c on reload.
+// A reload is all or nothing, so a change to any setting that needs a
+// restart rejects the whole of next with ErrRestartRequired.
+func (c *Config) CheckReload(next *Config) error {
+	if _, restart := c.Changes(next); len(restart) > 0 {
+		return fmt.Errorf("%w to change %s", ErrRestartRequired, strings.Join(restart, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package config
+
+// Requirement is a group of settings a service cannot run without
+type Requirement int
+
+const (
+	// Database needs the Postgres credentials unless the storage backend
+	// is memory
+	Database Requirement = iota
+	// Kafka needs the brokers and topics
+	Kafka
+	// APISecret needs API_SECRET
+	APISecret
+	// CoTSinks needs at least one TAK destination
+	CoTSinks
+	// APIKeys needs the key store, i.e. the database, unless auth is off
+	APIKeys
+)
+
+// Service is a program and the settings it needs
+type Service struct {
+	Name  string
+	Needs []Requirement
+}
+
+// The services and what they need. Tools with other needs declare their
+// own Service.
+var (
+	Gateway      = Service{Name: "gateway", Needs: []Requirement{Kafka, APIKeys}}
+	Ingestion    = Service{Name: "ingestion", Needs: []Requirement{Database, Kafka}}
+	API          = Service{Name: "api", Needs: []Requirement{Database, APISecret}}
+	CoTPublisher = Service{Name: "cot-publisher", Needs: []Requirement{Kafka, CoTSinks}}
+	Archiver     = Service{Name: "archiver", Needs: []Requirement{Database}}
+	Migrate      = Service{Name: "migrate", Needs: []Requirement{Database}}
+)
+
+// Services lists the services, for checking a shared configuration
+var Services = []Service{Gateway, Ingestion, API, CoTPublisher, Archiver, Migrate}
+
+// LookupService returns the service called name
+func LookupService(name string) (Service, bool) {
+	for _, svc := range Services {
+		if svc.Name == name {
+			return svc, true
+		}
+	}
+	return Service{}, false
+}
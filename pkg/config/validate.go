@@ -0,0 +1,266 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ValidationError lists every problem found in a configuration
+type ValidationError struct {
+	// Source is the config file the problems are in, if any
+	Source   string
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	msg := "invalid configuration"
+	if e.Source != "" {
+		msg += " in " + e.Source
+	}
+	return msg + ":\n  " + strings.Join(e.Problems, "\n  ")
+}
+
+// add records a problem with the setting at key
+func (e *ValidationError) add(key, format string, args ...any) {
+	e.Problems = append(e.Problems, key+": "+fmt.Sprintf(format, args...))
+}
+
+// validator checks settings, naming each by file key and environment
+// variable
+type validator struct {
+	ValidationError
+	env map[string]string
+}
+
+func (v *validator) add(key, format string, args ...any) {
+	if env := v.env[key]; env != "" {
+		key += " (" + env + ")"
+	}
+	v.ValidationError.add(key, format, args...)
+}
+
+// Validate checks the configuration and that the settings in needs are
+// present, reporting every invalid setting
+func (c *Config) Validate(needs ...Requirement) error {
+	v := &validator{env: make(map[string]string)}
+	for _, s := range c.settings() {
+		v.env[s.key] = s.env
+	}
+
+	for _, need := range needs {
+		c.require(v, need)
+	}
+
+	switch c.StorageBackend {
+	case "postgres", "memory":
+	default:
+		v.add("storage.backend", "must be postgres or memory, got %q", c.StorageBackend)
+	}
+	v.port("database.port", c.DBPort)
+	v.port("gateway.port", c.APIPort)
+	v.port("api.port", c.QueryAPIPort)
+
+	if c.SpoolMaxMB < 0 {
+		v.add("gateway.spool_max_mb", "must not be negative")
+	}
+
+	switch c.AuthMode {
+	case "required", "off":
+	default:
+		v.add("auth.mode", "must be required or off, got %q", c.AuthMode)
+	}
+	v.positive("auth.token_ttl", c.AuthTokenTTL)
+
+	switch strings.ToLower(c.LogFormat) {
+	case "", "text", "json":
+	default:
+		v.add("logging.format", "must be text or json, got %q", c.LogFormat)
+	}
+	if c.LogSampleInitial < 0 {
+		v.add("logging.sample_initial", "must not be negative")
+	}
+	if c.LogSampleThereafter < 0 {
+		v.add("logging.sample_thereafter", "must not be negative")
+	}
+
+	if c.TracingEndpoint != "" {
+		v.url("tracing.endpoint", c.TracingEndpoint)
+	}
+	if c.TracingSampleRatio < 0 || c.TracingSampleRatio > 1 {
+		v.add("tracing.sample_ratio", "must be between 0 and 1, got %v", c.TracingSampleRatio)
+	}
+
+	v.positive("api.stats_refresh_interval", c.StatsRefreshInterval)
+	v.positive("retention.raw", c.RetentionRaw)
+	v.positive("retention.tracks", c.RetentionTracks)
+	v.positive("retention.track_bucket", c.TrackBucket)
+	v.positive("retention.archive_period", c.ArchivePeriod)
+	if c.CompressAfter < 0 {
+		v.add("retention.compress_after", "must not be negative")
+	}
+
+	v.positive("nodes.degraded_after", c.NodeDegradedAfter)
+	v.positive("nodes.check_interval", c.NodeCheckInterval)
+	if c.NodeOfflineAfter <= c.NodeDegradedAfter {
+		v.add("nodes.offline_after", "must be longer than nodes.degraded_after (%s)", FormatDuration(c.NodeDegradedAfter))
+	}
+	if c.NodeAlertWebhook != "" {
+		v.url("nodes.alert_webhook", c.NodeAlertWebhook)
+	}
+
+	if c.ComplianceMaxSpeed <= 0 {
+		v.add("compliance.max_speed", "must be positive")
+	}
+	if c.ComplianceMinRate < 0 {
+		v.add("compliance.min_rate", "must not be negative")
+	}
+
+	c.validateSinks(v)
+	validateAreas(v, "geofences", c.Geofences)
+	validateAreas(v, "jurisdictions", c.Jurisdictions)
+
+	if len(v.Problems) > 0 {
+		return &v.ValidationError
+	}
+	return nil
+}
+
+// require checks the settings behind a requirement are set
+func (c *Config) require(v *validator, need Requirement) {
+	switch need {
+	case Database:
+		if c.StorageBackend != "postgres" {
+			return
+		}
+		v.required("database.host", c.DBHost)
+		v.required("database.name", c.DBName)
+		v.required("database.user", c.DBUser)
+		v.required("database.password", c.DBPassword)
+	case Kafka:
+		v.required("kafka.brokers", c.KafkaBrokers)
+		v.required("kafka.topic", c.KafkaTopic)
+		v.required("kafka.heartbeat_topic", c.KafkaHeartbeatTopic)
+	case APISecret:
+		v.required("api.secret", c.APISecret)
+	case APIKeys:
+		if c.AuthMode != "off" {
+			c.require(v, Database)
+		}
+	case CoTSinks:
+		if len(c.CoTSinks) == 0 {
+			v.add("cot.sinks", "at least one sink is required (or set TAK_MODE)")
+		}
+	}
+}
+
+// validateSinks checks the CoT sinks and fills in default names and ports
+func (c *Config) validateSinks(v *validator) {
+	names := make(map[string]bool)
+	for i := range c.CoTSinks {
+		sink := &c.CoTSinks[i]
+		key := fmt.Sprintf("cot.sinks[%d]", i)
+		if sink.Name == "" {
+			sink.Name = sink.Mode
+		}
+
+		switch sink.Mode {
+		case "tcp", "direct":
+			if sink.Host == "" {
+				v.add(key+".host", "is required for %s sinks", sink.Mode)
+			}
+			if sink.Port == 0 {
+				sink.Port = 8088
+				if sink.Mode == "direct" {
+					sink.Port = 6969
+				}
+			}
+			if sink.Port < 1 || sink.Port > 65535 {
+				v.add(key+".port", "must be between 1 and 65535, got %d", sink.Port)
+			}
+		case "multicast":
+			if sink.Host != "" || sink.Port != 0 {
+				v.add(key, "multicast sinks always send to 239.2.3.1:6969; remove host and port")
+			}
+		default:
+			v.add(key+".mode", "must be tcp, direct or multicast, got %q", sink.Mode)
+		}
+
+		if names[sink.Name] {
+			v.add(key+".name", "duplicate sink %q", sink.Name)
+		}
+		names[sink.Name] = true
+	}
+}
+
+// validateAreas checks each named area under key is a valid circle or
+// polygon
+func validateAreas(v *validator, key string, areas []Geofence) {
+	names := make(map[string]bool)
+	for i, g := range areas {
+		key := fmt.Sprintf("%s[%d]", key, i)
+		switch {
+		case g.Name == "":
+			v.add(key+".name", "is required")
+		case names[g.Name]:
+			v.add(key+".name", "duplicate name %q", g.Name)
+		}
+		names[g.Name] = true
+
+		circle := g.RadiusM != 0 || g.Latitude != 0 || g.Longitude != 0
+		switch {
+		case circle && len(g.Polygon) > 0:
+			v.add(key, "set either latitude, longitude and radius_m or polygon, not both")
+		case circle:
+			v.coordinate(key, g.Latitude, g.Longitude)
+			if g.RadiusM <= 0 {
+				v.add(key+".radius_m", "must be positive")
+			}
+		case len(g.Polygon) > 0:
+			if len(g.Polygon) < 3 {
+				v.add(key+".polygon", "needs at least 3 vertices, got %d", len(g.Polygon))
+			}
+			for j, p := range g.Polygon {
+				v.coordinate(fmt.Sprintf("%s.polygon[%d]", key, j), p[0], p[1])
+			}
+		default:
+			v.add(key, "needs latitude, longitude and radius_m, or polygon")
+		}
+	}
+}
+
+func (v *validator) required(key, value string) {
+	if value == "" {
+		v.add(key, "is required")
+	}
+}
+
+func (v *validator) port(key, value string) {
+	if n, err := strconv.Atoi(value); err != nil || n < 1 || n > 65535 {
+		v.add(key, "must be a port between 1 and 65535, got %q", value)
+	}
+}
+
+func (v *validator) positive(key string, d time.Duration) {
+	if d <= 0 {
+		v.add(key, "must be positive")
+	}
+}
+
+func (v *validator) url(key, value string) {
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		v.add(key, "must be an http or https URL")
+	}
+}
+
+func (v *validator) coordinate(key string, lat, lon float64) {
+	if lat < -90 || lat > 90 {
+		v.add(key, "latitude %v out of range", lat)
+	}
+	if lon < -180 || lon > 180 {
+		v.add(key, "longitude %v out of range", lon)
+	}
+}